@@ -0,0 +1,93 @@
+package cebench
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/qw4990/OptimizerTester/cetest"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// baselineErrors are |p-error|s of estimations of a group of records by an estimator.
+type baselineErrors struct {
+	pErrors     []float64
+	unsupported int
+}
+
+// EstimateByBaselines estimates expressions of records by baseline estimators with data read from the DSN,
+// and writes their p-errors next to the ones of TiDB for all records and each type of records.
+func EstimateByBaselines(infos EstInfos, dsn string, names []string, sampleRate float64, seed int64, writer io.Writer) error {
+	ins, err := tidb.ConnectToDSN(dsn, "baseline")
+	if err != nil {
+		return err
+	}
+	defer ins.Close()
+	var ests []cetest.Estimator
+	for _, name := range names {
+		est, err := cetest.NewBaselineEstimator(name, ins, cetest.BaselineConf{SampleRate: sampleRate}, seed)
+		if err != nil {
+			return err
+		}
+		ests = append(ests, est)
+	}
+	return writeBaselines(infos, ests, writer)
+}
+
+func writeBaselines(infos EstInfos, ests []cetest.Estimator, writer io.Writer) error {
+	var types []string
+	errs := make(map[string][]*baselineErrors) // type => errors of each estimator
+	tidbErrs := make(map[string][]float64)
+	for _, info := range infos {
+		for _, tp := range []string{"All", info.Type} {
+			if _, ok := errs[tp]; !ok {
+				types = append(types, tp)
+				errs[tp] = make([]*baselineErrors, len(ests))
+				for i := range ests {
+					errs[tp][i] = new(baselineErrors)
+				}
+			}
+			tidbErrs[tp] = append(tidbErrs[tp], math.Abs(pError(info.Est, info.Actual)))
+		}
+		query := fmt.Sprintf("SELECT * FROM %v WHERE %v", info.TableName, info.Expr)
+		for i, est := range ests {
+			card, err := est.Estimate(query)
+			for _, tp := range []string{"All", info.Type} {
+				if err != nil {
+					errs[tp][i].unsupported++
+					continue
+				}
+				errs[tp][i].pErrors = append(errs[tp][i].pErrors, math.Abs(pError(uint64(math.Round(card)), info.Actual)))
+			}
+		}
+	}
+	sort.Strings(types[1:])
+
+	str := bytes.Buffer{}
+	str.WriteString("\n## Baselines\n")
+	str.WriteString("\nBaseline estimators estimate the same expressions with data read from the DSN, which only support conjunctions of comparisons between columns and constants.\n")
+	for _, tp := range types {
+		str.WriteString(fmt.Sprintf("\n### %s\n", tp))
+		str.WriteString("\n| Estimator | Count | Unsupported | P50 | P90 | P95 | P99 | Max |\n")
+		str.WriteString("| ---- | ---- | ---- | ---- | ---- | ---- | ---- | ---- |\n")
+		writeBaselineRow(&str, "TiDB", tidbErrs[tp], 0)
+		for i, est := range ests {
+			writeBaselineRow(&str, est.Name(), errs[tp][i].pErrors, errs[tp][i].unsupported)
+		}
+	}
+	_, err := str.WriteTo(writer)
+	return err
+}
+
+func writeBaselineRow(str *bytes.Buffer, name string, pErrors []float64, unsupported int) {
+	if len(pErrors) == 0 {
+		str.WriteString(fmt.Sprintf("| %s | 0 | %d | - | - | - | - | - |\n", name, unsupported))
+		return
+	}
+	sort.Float64s(pErrors)
+	n := len(pErrors)
+	str.WriteString(fmt.Sprintf("| %s | %d | %d | %.3f | %.3f | %.3f | %.3f | %.3f |\n", name, n, unsupported,
+		pErrors[(n*50)/100], pErrors[(n*90)/100], pErrors[(n*95)/100], pErrors[(n*99)/100], pErrors[n-1]))
+}
@@ -0,0 +1,54 @@
+package cebench
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/cetest"
+)
+
+// mapEstimator estimates queries by a map, and queries not in it are unsupported.
+type mapEstimator map[string]float64
+
+func (e mapEstimator) Name() string {
+	return "baseline-map"
+}
+
+func (e mapEstimator) Estimate(query string) (float64, error) {
+	card, ok := e[query]
+	if !ok {
+		return 0, errors.Errorf("unsupported query %v", query)
+	}
+	return card, nil
+}
+
+func TestWriteBaselines(t *testing.T) {
+	infos := EstInfos{
+		{Expr: "a=1", Type: "Column Stats", Est: 10, Actual: 10, TableName: "db.t"},
+		{Expr: "a=2", Type: "Column Stats", Est: 5, Actual: 10, TableName: "db.t"},
+		{Expr: "a=1 OR b=1", Type: "Index Stats", Est: 10, Actual: 20, TableName: "db.t"},
+	}
+	est := mapEstimator{"SELECT * FROM db.t WHERE a=1": 20, "SELECT * FROM db.t WHERE a=2": 10}
+	var buf bytes.Buffer
+	if err := writeBaselines(infos, []cetest.Estimator{est}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"### All\n",
+		"| TiDB | 3 | 0 | 1.000 | 1.000 | 1.000 | 1.000 | 1.000 |\n",
+		"| baseline-map | 2 | 1 | 1.000 | 1.000 | 1.000 | 1.000 | 1.000 |\n",
+		"### Column Stats\n",
+		"### Index Stats\n",
+		"| baseline-map | 0 | 1 | - | - | - | - | - |\n", // the only index stats query is unsupported
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expect %q in:\n%v", expected, out)
+		}
+	}
+	if strings.Index(out, "### Column Stats") > strings.Index(out, "### Index Stats") {
+		t.Fatalf("types should be sorted after All:\n%v", out)
+	}
+}
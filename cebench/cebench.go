@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/qw4990/OptimizerTester/cetest"
 	"github.com/qw4990/OptimizerTester/tidb"
 	"io/fs"
 	"io/ioutil"
@@ -40,6 +41,9 @@ type OtherOption struct {
 	PErrorThreshold       uint
 	ConcurrencyForEachDSN uint
 	Labels                []string
+	Baselines             []string // baseline estimators estimating records with data read from the first DSN
+	BaselineSampleRate    float64
+	Seed                  int64
 }
 
 func RunCEBench(inOpt *InputOption, otherOpt *OtherOption) error {
@@ -51,6 +55,14 @@ func RunCEBench(inOpt *InputOption, otherOpt *OtherOption) error {
 	threshold := otherOpt.PErrorThreshold
 	concurrencyForEachDSN := otherOpt.ConcurrencyForEachDSN
 	needDedup = dedup
+	if len(otherOpt.Baselines) > 0 {
+		if len(dsns) == 0 {
+			return errors.New("baseline estimators need a DSN to read data")
+		}
+		if err := cetest.CheckBaselines(cetest.BaselineConf{Estimators: otherOpt.Baselines, SampleRate: otherOpt.BaselineSampleRate}); err != nil {
+			return err
+		}
+	}
 	// 1. Collect estimation information.
 	var allEstInfos EstInfos
 	if len(jsonLocations) > 0 {
@@ -86,6 +98,11 @@ func RunCEBench(inOpt *InputOption, otherOpt *OtherOption) error {
 		queryTaskChan := make(chan *tidb.QueryTask, 100)
 		tracePlanResChan := make(chan *tidb.QueryResult, 100)
 		actualCntResChan := make(chan *tidb.QueryResult, 100)
+		for i, dsn := range dsns {
+			if err := tidb.CheckFeatureByDSN(dsn, tidb.FeatureTraceEstimation); err != nil {
+				return fmt.Errorf("DSN#%d: %v", i, err)
+			}
+		}
 		for i, dsn := range dsns {
 			err = tidb.StartQueryRunner(dsn, queryTaskChan, concurrencyForEachDSN, 2, uint(i))
 			if err != nil {
@@ -168,6 +185,13 @@ func RunCEBench(inOpt *InputOption, otherOpt *OtherOption) error {
 	_, err = reportF.Write([]byte(fmt.Sprintf("\n## All cases with p-error above the threshold (%d):\n", threshold)))
 	WritePErrorAboveThresh(allEstInfos, reportF, float64(threshold))
 
+	if len(otherOpt.Baselines) > 0 {
+		err = EstimateByBaselines(allEstInfos, dsns[0], otherOpt.Baselines, otherOpt.BaselineSampleRate, otherOpt.Seed, reportF)
+		if err != nil {
+			return err
+		}
+	}
+
 	fmt.Printf("[%s] Analyze finished and results are written into files. Tester exited.\n", logTime())
 	return nil
 }
@@ -3,6 +3,7 @@ package cebench
 import (
 	"bufio"
 	"fmt"
+	"github.com/qw4990/OptimizerTester/datagen"
 	"github.com/qw4990/OptimizerTester/tidb"
 	"os"
 	"regexp"
@@ -52,7 +53,7 @@ func SQLProvider(paths []string, queryTaskChan chan<- *tidb.QueryTask, destChan
 		scanner.Split(onSemiColon)
 		for scanner.Scan() {
 			sql := scanner.Text()
-			sql = strings.TrimSpace(sql)
+			sql = strings.TrimSpace(datagen.StripLineComments(sql))
 			if len(sql) == 0 {
 				continue
 			}
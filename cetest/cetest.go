@@ -3,8 +3,6 @@ package cetest
 import (
 	"fmt"
 	"io/ioutil"
-	"math"
-	"sort"
 	"strings"
 	"sync"
 
@@ -21,12 +19,17 @@ type DatasetOpt struct {
 }
 
 type Option struct {
-	QueryTypes []QueryType   `toml:"query-types"`
-	Datasets   []DatasetOpt  `toml:"datasets"`
-	Instances  []tidb.Option `toml:"instances"`
-	AnaTables  []string      `toml:"analyze-tables"`
-	ReportDir  string        `toml:"report-dir"`
-	NSamples   int           `toml:"n-samples"`
+	QueryTypes  []QueryType     `toml:"query-types"`
+	Datasets    []DatasetOpt    `toml:"datasets"`
+	Instances   []tidb.Option   `toml:"instances"`
+	AnaTables   []string        `toml:"analyze-tables"`
+	ReportDir   string          `toml:"report-dir"`
+	NSamples    int             `toml:"n-samples"`
+	Sample      SampleConf      `toml:"sample"`
+	Baseline    BaselineConf    `toml:"baseline"`    // baseline estimators shown next to instances in reports
+	Metamorphic MetamorphicConf `toml:"metamorphic"` // relations checked in the metamorphic mode
+	Seed        int64           `toml:"seed"`        // a random seed is used if it's 0
+	Reports     []string        `toml:"reports"`     // perror-bar and qerror-box by default
 }
 
 // DecodeOption decodes option content.
@@ -40,6 +43,18 @@ func DecodeOption(content string) (Option, error) {
 			return Option{}, fmt.Errorf("unknown dateset=%v", ds.Name)
 		}
 	}
+	if err := checkReports(opt.Reports); err != nil {
+		return Option{}, err
+	}
+	if err := checkSampleStrategy(opt.Sample.Strategy); err != nil {
+		return Option{}, err
+	}
+	if err := CheckBaselines(opt.Baseline); err != nil {
+		return Option{}, err
+	}
+	if err := checkMetamorphic(opt.Metamorphic); err != nil {
+		return Option{}, err
+	}
 	return opt, nil
 }
 
@@ -54,6 +69,11 @@ const (
 
 	QTMulColsPointQueryOnIndex
 	QTMulColsRangeQueryOnIndex
+
+	QTSingleColNonExistPointOnCol // values between existing values
+	QTSingleColOutOfRangeOnCol    // values below the min or above the max
+	QTSingleColIsNullOnCol
+	QTSingleColIsNotNullOnCol
 )
 
 var (
@@ -65,6 +85,11 @@ var (
 
 		QTMulColsPointQueryOnIndex: "mul-cols-point-query-on-index",
 		QTMulColsRangeQueryOnIndex: "mul-cols-range-query-on-index",
+
+		QTSingleColNonExistPointOnCol: "single-col-non-exist-point-on-col",
+		QTSingleColOutOfRangeOnCol:    "single-col-out-of-range-on-col",
+		QTSingleColIsNullOnCol:        "single-col-is-null-on-col",
+		QTSingleColIsNotNullOnCol:     "single-col-is-not-null-on-col",
 	}
 )
 
@@ -72,6 +97,10 @@ func (qt QueryType) String() string {
 	return qtNameMap[qt]
 }
 
+func (qt QueryType) MarshalText() ([]byte, error) {
+	return []byte(qt.String()), nil
+}
+
 func (qt *QueryType) UnmarshalText(text []byte) error {
 	for k, v := range qtNameMap {
 		if v == string(text) {
@@ -83,20 +112,37 @@ func (qt *QueryType) UnmarshalText(text []byte) error {
 }
 
 var datasetMap = map[string]func(DatasetOpt) Dataset{ // read-only
-	"zipfx": newDatasetZipFX,
-	"imdb":  newDatasetIMDB,
-	"tpcc":  newDatasetTPCC,
+	"zipfx":  newDatasetZipFX,
+	"pzipfx": newDatasetPZipFX,
+	"imdb":   newDatasetIMDB,
+	"tpcc":   newDatasetTPCC,
 }
 
-func RunCETestWithConfig(confPath string) error {
+// ReadOption reads and decodes the option from the config file.
+func ReadOption(confPath string) (Option, error) {
 	confContent, err := ioutil.ReadFile(confPath)
 	if err != nil {
-		return errors.Trace(err)
+		return Option{}, errors.Trace(err)
 	}
-	opt, err := DecodeOption(string(confContent))
+	return DecodeOption(string(confContent))
+}
+
+func RunCETestWithConfig(confPath string) error {
+	opt, err := ReadOption(confPath)
 	if err != nil {
 		return err
 	}
+	return RunCETest(opt)
+}
+
+// RunCETest runs the test on all instances in the option.
+func RunCETest(opt Option) error {
+	opt.Seed = ResolveSeed(opt.Seed)
+	fmt.Printf("[CETest] seed=%v\n", opt.Seed)
+	if err := tidb.UseSeed(opt.Seed); err != nil {
+		return err
+	}
+	so := opt.Sample.sampleOption(opt.NSamples, opt.Seed)
 
 	instances, err := tidb.ConnectToInstances(opt.Instances)
 	if err != nil {
@@ -124,7 +170,7 @@ func RunCETestWithConfig(confPath string) error {
 
 			// analyze tables
 			for _, tbl := range opt.AnaTables {
-				sql := fmt.Sprintf("ANALYZE TABLE %v", tbl)
+				sql := analyzeTableSQL(ins, tbl)
 				if err := ins.Exec(sql); err != nil {
 					panic(fmt.Sprintf("sql=%v, err=%v", sql, err))
 				}
@@ -133,7 +179,7 @@ func RunCETestWithConfig(confPath string) error {
 			for dsIdx := range opt.Datasets {
 				ds := datasets[dsIdx]
 				for qtIdx, qt := range opt.QueryTypes {
-					ers, err := ds.GenEstResults(ins, opt.NSamples, qt)
+					ers, err := ds.GenEstResults(ins, so, qt)
 					if err != nil {
 						insErrs[insIdx] = fmt.Errorf("GenEstResult ins=%v, ds=%v, qt=%v, err=%v", opt.Instances[insIdx].Label,
 							opt.Datasets[dsIdx].Label, qt.String(), err)
@@ -152,7 +198,16 @@ func RunCETestWithConfig(confPath string) error {
 		}
 	}
 
-	if err := GenPErrorBarChartsReport(opt, collector); err != nil {
+	if len(opt.Baseline.Estimators) > 0 { // baselines read data from the first instance
+		if opt, collector, err = addBaselines(opt, instances[0], collector); err != nil {
+			return err
+		}
+	}
+
+	if err := SaveResults(opt, collector); err != nil {
+		return err
+	}
+	if err := RenderReports(opt, collector); err != nil {
 		return err
 	}
 
@@ -163,13 +218,9 @@ func printTop10BadCases(opt Option, collector EstResultCollector) error {
 	for insIdx := range opt.Instances {
 		for dsIdx := range opt.Datasets {
 			for qtIdx := range opt.QueryTypes {
-				ers := collector.EstResults(insIdx, dsIdx, qtIdx)
-				sort.Slice(ers, func(i, j int) bool {
-					return math.Abs(PError(ers[i])) > math.Abs(PError(ers[j]))
-				})
-				for i := 0; i < 10 && i < len(ers); i++ {
+				for _, r := range worstCases(collector.EstResults(insIdx, dsIdx, qtIdx), worstCaseLimit) {
 					fmt.Printf("[Worst-%v-%v-%v]: %v, perror=%v\n", opt.Instances[insIdx].Label, opt.Datasets[dsIdx].Label,
-						opt.QueryTypes[qtIdx].String(), ers[i].SQL, PError(ers[i]))
+						opt.QueryTypes[qtIdx].String(), r.SQL, PError(r))
 				}
 			}
 		}
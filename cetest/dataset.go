@@ -15,7 +15,7 @@ type Dataset interface {
 	Name() string
 
 	// GenEstResults ...
-	GenEstResults(ins tidb.Instance, nSamples int, qt QueryType) ([]EstResult, error)
+	GenEstResults(ins tidb.Instance, so SampleOption, qt QueryType) ([]EstResult, error)
 }
 
 type DATATYPE int
@@ -59,16 +59,76 @@ type datasetBase struct {
 	mciq *mulColIndexQuerier
 }
 
-func (ds *datasetBase) GenEstResults(ins tidb.Instance, nSamples int, qt QueryType) (ers []EstResult, err error) {
+// onTable makes queries of these query types run on the table, which should have the same columns and indexes as
+// the original table of these queries, like partitioned copies of it.
+// Other queries are removed from the dataset, so only data of this table is read when initializing.
+func (ds *datasetBase) onTable(tbl string, qts []QueryType) error {
+	origTbl := ""
+	checkTable := func(t string) error {
+		if origTbl != "" && origTbl != t {
+			return errors.Errorf("query types %v use different tables %v and %v of dataset %v", qts, origTbl, t, ds.opt.Name)
+		}
+		origTbl = t
+		return nil
+	}
+
+	scqMap := make(map[QueryType][2]int)
+	scqTbIdx := -1
+	mciqMap := make(map[QueryType]int)
+	var mciqIdxs []int
+	mciqNewIdxs := make(map[int]int)
+	for _, qt := range qts {
+		if pos, ok := ds.scq.qMap[qt]; ok {
+			if err := checkTable(ds.scq.tbs[pos[0]]); err != nil {
+				return err
+			}
+			scqTbIdx = pos[0]
+			scqMap[qt] = [2]int{0, pos[1]}
+		} else if idxIdx, ok := ds.mciq.qMap[qt]; ok {
+			if err := checkTable(ds.mciq.indexTables[idxIdx]); err != nil {
+				return err
+			}
+			if _, ok := mciqNewIdxs[idxIdx]; !ok {
+				mciqNewIdxs[idxIdx] = len(mciqIdxs)
+				mciqIdxs = append(mciqIdxs, idxIdx)
+			}
+			mciqMap[qt] = mciqNewIdxs[idxIdx]
+		} else {
+			return errors.Errorf("unsupported query-type=%v for dataset %v", qt, ds.opt.Name)
+		}
+	}
+
+	var tbs []string
+	var cols [][]string
+	var colTypes [][]DATATYPE
+	if scqTbIdx >= 0 {
+		tbs, cols, colTypes = []string{tbl}, [][]string{ds.scq.cols[scqTbIdx]}, [][]DATATYPE{ds.scq.colTypes[scqTbIdx]}
+	}
+	ds.scq = newSingleColQuerier(ds.opt.DB, tbs, cols, colTypes, scqMap)
+
+	indexes := make([]string, len(mciqIdxs))
+	indexTbs := make([]string, len(mciqIdxs))
+	indexCols := make([][]string, len(mciqIdxs))
+	indexColTypes := make([][]DATATYPE, len(mciqIdxs))
+	for i, idxIdx := range mciqIdxs {
+		indexes[i], indexTbs[i] = ds.mciq.indexes[idxIdx], tbl
+		indexCols[i], indexColTypes[i] = ds.mciq.indexCols[idxIdx], ds.mciq.colTypes[idxIdx]
+	}
+	ds.mciq = newMulColIndexQuerier(ds.opt.DB, indexes, indexTbs, indexCols, indexColTypes, mciqMap)
+	return nil
+}
+
+func (ds *datasetBase) GenEstResults(ins tidb.Instance, so SampleOption, qt QueryType) (ers []EstResult, err error) {
 	defer func(begin time.Time) {
 		fmt.Printf("[GenEstResults] dataset=%v, ins=%v, qt=%v, cost=%v\n", ds.opt.Label, ins.Opt().Label, qt, time.Since(begin))
 	}(time.Now())
 
 	switch qt {
-	case QTSingleColPointQueryOnCol, QTSingleColPointQueryOnIndex, QTSingleColMCVPointOnCol, QTSingleColMCVPointOnIndex:
-		ers, err = ds.scq.Collect(nSamples, qt, ers, ins, ds.args.ignoreError)
+	case QTSingleColPointQueryOnCol, QTSingleColPointQueryOnIndex, QTSingleColMCVPointOnCol, QTSingleColMCVPointOnIndex,
+		QTSingleColNonExistPointOnCol, QTSingleColOutOfRangeOnCol, QTSingleColIsNullOnCol, QTSingleColIsNotNullOnCol:
+		ers, err = ds.scq.Collect(so, qt, ers, ins, ds.args.ignoreError)
 	case QTMulColsRangeQueryOnIndex, QTMulColsPointQueryOnIndex:
-		ers, err = ds.mciq.Collect(nSamples, qt, ers, ins, ds.args.ignoreError)
+		ers, err = ds.mciq.Collect(so, qt, ers, ins, ds.args.ignoreError)
 	default:
 		return nil, errors.Errorf("unsupported query-type=%v", qt)
 	}
@@ -17,10 +17,14 @@ func newDatasetIMDB(opt DatasetOpt) Dataset {
 			[][]string{{"phonetic_code"}, {"person_id"}},
 			[][]DATATYPE{{DTString}, {DTInt}},
 			map[QueryType][2]int{
-				QTSingleColPointQueryOnCol:   {0, 0}, // SELECT * FROM title WHERE phonetic_code=?
-				QTSingleColPointQueryOnIndex: {1, 0}, // SELECT * FROM cast_info WHERE person_id=?
-				QTSingleColMCVPointOnCol:     {0, 0}, // SELECT * FROM title WHERE phonetic_code=?
-				QTSingleColMCVPointOnIndex:   {1, 0}, // SELECT * FROM cast_info WHERE person_id=?
+				QTSingleColPointQueryOnCol:    {0, 0}, // SELECT * FROM title WHERE phonetic_code=?
+				QTSingleColPointQueryOnIndex:  {1, 0}, // SELECT * FROM cast_info WHERE person_id=?
+				QTSingleColMCVPointOnCol:      {0, 0}, // SELECT * FROM title WHERE phonetic_code=?
+				QTSingleColMCVPointOnIndex:    {1, 0}, // SELECT * FROM cast_info WHERE person_id=?
+				QTSingleColNonExistPointOnCol: {0, 0}, // SELECT * FROM title WHERE phonetic_code=? with absent values
+				QTSingleColOutOfRangeOnCol:    {0, 0}, // SELECT * FROM title WHERE phonetic_code=?/>?/<? with values out of [min, max]
+				QTSingleColIsNullOnCol:        {0, 0}, // SELECT * FROM title WHERE phonetic_code IS NULL
+				QTSingleColIsNotNullOnCol:     {0, 0}, // SELECT * FROM title WHERE phonetic_code IS NOT NULL
 			}),
 		mciq: newMulColIndexQuerier(opt.DB,
 			[]string{"TITLE_production_year_episode_of_id_IDX"},
@@ -2,7 +2,6 @@ package cetest
 
 import (
 	"fmt"
-	"math/rand"
 	"strings"
 	"sync"
 	"time"
@@ -66,7 +65,7 @@ func (q *mulColIndexQuerier) init(ins tidb.Instance) (rerr error) {
 				}
 				whereCond += fmt.Sprintf("%v IS NOT NULL", col)
 			}
-			sql := fmt.Sprintf("SELECT %v, COUNT(*) FROM %v.`%v` WHERE %v GROUP BY %v ORDER BY %v", cols, q.db, q.indexTables[i], whereCond, cols, cols)
+			sql := fmt.Sprintf("SELECT %v, COUNT(*) FROM %v WHERE %v GROUP BY %v ORDER BY %v", cols, tableName(ins, q.db, q.indexTables[i]), whereCond, cols, cols)
 			rows, err := ins.Query(sql)
 			if err != nil {
 				rerr = err
@@ -95,20 +94,16 @@ func (q *mulColIndexQuerier) init(ins tidb.Instance) (rerr error) {
 	return
 }
 
-func (q *mulColIndexQuerier) Collect(nSamples int, qt QueryType, ers []EstResult, ins tidb.Instance, ignoreErr bool) ([]EstResult, error) {
+func (q *mulColIndexQuerier) Collect(so SampleOption, qt QueryType, ers []EstResult, ins tidb.Instance, ignoreErr bool) ([]EstResult, error) {
 	if err := q.init(ins); err != nil {
 		return nil, err
 	}
 	indexIdx := q.qMap[qt]
 	nRows := len(q.valRows[indexIdx])
-	if nSamples == 0 {
-		nSamples = nRows
-	}
-	sampleRate := float64(nSamples) / float64(nRows)
-	if sampleRate > 1 {
-		sampleRate = 1
-	}
+	samples := so.sampleRows(0, nRows)
+	results := make([]*EstResult, len(samples))
 
+	estimator := NewInstanceEstimator(ins)
 	begin := time.Now()
 	concurrency := 64
 	var resultLock sync.Mutex
@@ -119,11 +114,8 @@ func (q *mulColIndexQuerier) Collect(nSamples int, qt QueryType, ers []EstResult
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
-			for rowIdx := id; rowIdx < nRows; rowIdx += concurrency {
-				if rand.Float64() > sampleRate {
-					continue
-				}
-
+			for i := id; i < len(samples); i += concurrency {
+				rowIdx := samples[i]
 				var cond string
 				var act int
 				if qt == QTMulColsRangeQueryOnIndex {
@@ -132,8 +124,8 @@ func (q *mulColIndexQuerier) Collect(nSamples int, qt QueryType, ers []EstResult
 					cond, act = q.pointCond(indexIdx, rowIdx)
 				}
 
-				sql := fmt.Sprintf("SELECT * FROM %v.`%v` WHERE %v", q.db, q.indexTables[indexIdx], cond)
-				est, err := getEstRowFromExplain(ins, sql)
+				sql := fmt.Sprintf("SELECT * FROM %v WHERE %v", tableName(ins, q.db, q.indexTables[indexIdx]), cond)
+				est, err := estimator.Estimate(sql)
 				if err != nil {
 					if !ignoreErr {
 						panic(err)
@@ -142,12 +134,12 @@ func (q *mulColIndexQuerier) Collect(nSamples int, qt QueryType, ers []EstResult
 					continue
 				}
 
+				results[i] = &EstResult{SQL: sql, EstCard: est, TrueCard: float64(act)}
 				resultLock.Lock()
-				ers = append(ers, EstResult{sql, est, float64(act)})
 				processed++
 				if processed%5000 == 0 {
 					fmt.Printf("[MulColIndexQuerier-Process] ins=%v, index=%v, qt=%v, concurrency=%v, time-cost=%v, progress (%v/%v)\n",
-						ins.Opt().Label, q.indexTables[indexIdx], qt, concurrency, time.Since(begin), processed, nSamples)
+						ins.Opt().Label, q.indexTables[indexIdx], qt, concurrency, time.Since(begin), processed, len(samples))
 				}
 				resultLock.Unlock()
 			}
@@ -155,7 +147,7 @@ func (q *mulColIndexQuerier) Collect(nSamples int, qt QueryType, ers []EstResult
 	}
 
 	wg.Wait()
-	return ers, nil
+	return appendEstResults(ers, results), nil
 }
 
 func (q *mulColIndexQuerier) rangeCond(indexIdx, rowIdx int) (string, int) {
@@ -0,0 +1,47 @@
+package cetest
+
+/*
+datasetPZipFX's tables are generated by datagen with the same schema:
+
+	CREATE TABLE zint ( a INT, b INT, c INT, KEY(a), KEY(a, b), KEY(b), KEY(b, c) )
+
+and partitioned copies of zint like p_hash_zint_a, p_range_zint_c.
+Queries run on zint unless the table is specified by the partition mode.
+*/
+type datasetPZipFX struct {
+	datasetBase
+}
+
+func newDatasetPZipFX(opt DatasetOpt) Dataset {
+	return &datasetPZipFX{datasetBase{
+		opt:  opt,
+		args: parseArgs(opt.Args),
+		scq: newSingleColQuerier(opt.DB,
+			[]string{"zint"},
+			[][]string{{"c", "a"}},
+			[][]DATATYPE{{DTInt, DTInt}},
+			map[QueryType][2]int{
+				QTSingleColPointQueryOnCol:    {0, 0}, // SELECT * FROM zint WHERE c=?
+				QTSingleColPointQueryOnIndex:  {0, 1}, // SELECT * FROM zint WHERE a=?
+				QTSingleColMCVPointOnCol:      {0, 0}, // SELECT * FROM zint WHERE c=?
+				QTSingleColMCVPointOnIndex:    {0, 1}, // SELECT * FROM zint WHERE a=?
+				QTSingleColNonExistPointOnCol: {0, 0}, // SELECT * FROM zint WHERE c=? with absent values
+				QTSingleColOutOfRangeOnCol:    {0, 0}, // SELECT * FROM zint WHERE c=?/>?/<? with values out of [min, max]
+				QTSingleColIsNullOnCol:        {0, 0}, // SELECT * FROM zint WHERE c IS NULL
+				QTSingleColIsNotNullOnCol:     {0, 0}, // SELECT * FROM zint WHERE c IS NOT NULL
+			}),
+		mciq: newMulColIndexQuerier(opt.DB,
+			[]string{"a_2"},
+			[]string{"zint"},
+			[][]string{{"a", "b"}},
+			[][]DATATYPE{{DTInt, DTInt}},
+			map[QueryType]int{
+				QTMulColsPointQueryOnIndex: 0, // SELECT * FROM zint WHERE a=? AND b=?
+				QTMulColsRangeQueryOnIndex: 0, // SELECT * FROM zint WHERE a=? AND b>=? AND b<=?
+			}),
+	}}
+}
+
+func (ds *datasetPZipFX) Name() string {
+	return "PZipFX"
+}
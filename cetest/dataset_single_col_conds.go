@@ -0,0 +1,186 @@
+package cetest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// numOutOfRangeValues is the number of values generated on each side out of [min, max].
+const numOutOfRangeValues = 50
+
+// condition is a condition of a query with its true cardinality.
+type condition struct {
+	cond    string
+	actRows int
+}
+
+type conditions []condition
+
+func (cs conditions) at(i int) (string, int) {
+	return cs[i].cond, cs[i].actRows
+}
+
+// nullConds returns the IS NULL or IS NOT NULL condition on the column, whose true cardinality is counted by a query
+// since NULL values are ignored when initializing.
+func (tv *singleColQuerier) nullConds(ins tidb.Instance, tbIdx, colIdx int, qt QueryType) (conditions, error) {
+	col := tv.cols[tbIdx][colIdx]
+	q := fmt.Sprintf("SELECT COUNT(*), COUNT(%v) FROM %v", col, tableName(ins, tv.db, tv.tbs[tbIdx]))
+	rows, err := ins.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var total, notNull int
+	if !rows.Next() {
+		return nil, errors.Errorf("no result for %v", q)
+	}
+	if err := rows.Scan(&total, &notNull); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if qt == QTSingleColIsNullOnCol {
+		return conditions{{col + " IS NULL", total - notNull}}, nil
+	}
+	return conditions{{col + " IS NOT NULL", notNull}}, nil
+}
+
+// absentConds returns conditions on values not in the column, whose true cardinalities are all 0:
+// values between adjacent existing values for QTSingleColNonExistPointOnCol, and values below the min or above
+// the max for QTSingleColOutOfRangeOnCol, which are used in both point and range conditions.
+// Strings are compared in binary, so absent strings may exist under case-insensitive collations.
+func (tv *singleColQuerier) absentConds(tbIdx, colIdx int, qt QueryType) conditions {
+	col, tp := tv.cols[tbIdx][colIdx], tv.colTypes[tbIdx][colIdx]
+	vals := sortedDistVals(tv.orderedDistVals[tbIdx][colIdx], tp)
+	if len(vals) == 0 {
+		return nil
+	}
+	placeHolder := tv.colPlaceHolder(tbIdx, colIdx)
+	cond := func(op, val string) condition {
+		return condition{fmt.Sprintf("%v%v"+placeHolder, col, op, val), 0}
+	}
+
+	var cs conditions
+	if qt == QTSingleColNonExistPointOnCol {
+		for i := 1; i < len(vals); i++ {
+			if val, ok := valueBetween(vals[i-1], vals[i], tp); ok {
+				cs = append(cs, cond("=", val))
+			}
+		}
+		return cs
+	}
+	for i := 1; i <= numOutOfRangeValues; i++ {
+		if val, ok := valueOutOfRange(vals[0], vals[len(vals)-1], tp, -i); ok {
+			cs = append(cs, cond("=", val), cond("<", val))
+		}
+		if val, ok := valueOutOfRange(vals[0], vals[len(vals)-1], tp, i); ok {
+			cs = append(cs, cond("=", val), cond(">", val))
+		}
+	}
+	return cs
+}
+
+// sortedDistVals sorts distinct values by their values instead of their counts.
+func sortedDistVals(distVals []string, tp DATATYPE) []string {
+	vals := make([]string, len(distVals))
+	copy(vals, distVals)
+	if tp == DTString {
+		sort.Strings(vals)
+		return vals
+	}
+	nums := make([]float64, len(vals))
+	for i, v := range vals {
+		nums[i], _ = strconv.ParseFloat(v, 64)
+	}
+	sort.Sort(numStrings{vals, nums})
+	return vals
+}
+
+type numStrings struct {
+	strs []string
+	nums []float64
+}
+
+func (ns numStrings) Len() int           { return len(ns.strs) }
+func (ns numStrings) Less(i, j int) bool { return ns.nums[i] < ns.nums[j] }
+func (ns numStrings) Swap(i, j int) {
+	ns.strs[i], ns.strs[j] = ns.strs[j], ns.strs[i]
+	ns.nums[i], ns.nums[j] = ns.nums[j], ns.nums[i]
+}
+
+// valueBetween returns a value strictly between adjacent existing values l and r, if there is one.
+func valueBetween(l, r string, tp DATATYPE) (string, bool) {
+	switch tp {
+	case DTInt:
+		lv, err1 := strconv.ParseInt(l, 10, 64)
+		rv, err2 := strconv.ParseInt(r, 10, 64)
+		if err1 != nil || err2 != nil || rv-lv < 2 {
+			return "", false
+		}
+		return strconv.FormatInt(lv+(rv-lv)/2, 10), true
+	case DTDouble:
+		lv, err1 := strconv.ParseFloat(l, 64)
+		rv, err2 := strconv.ParseFloat(r, 64)
+		mid := lv + (rv-lv)/2
+		if err1 != nil || err2 != nil || mid <= lv || mid >= rv {
+			return "", false
+		}
+		return strconv.FormatFloat(mid, 'g', -1, 64), true
+	default:
+		val := l + "!"
+		if val >= r || strings.ContainsAny(val, `'\`) {
+			return "", false
+		}
+		return val, true
+	}
+}
+
+// valueOutOfRange returns the k-th value below the min if k < 0, or above the max if k > 0.
+// Values step by 1% of the range for numbers, and strings can only go above the max.
+func valueOutOfRange(min, max string, tp DATATYPE, k int) (string, bool) {
+	switch tp {
+	case DTInt:
+		minV, err1 := strconv.ParseInt(min, 10, 64)
+		maxV, err2 := strconv.ParseInt(max, 10, 64)
+		if err1 != nil || err2 != nil {
+			return "", false
+		}
+		step := (maxV - minV) / 100
+		if step < 1 {
+			step = 1
+		}
+		if k < 0 {
+			if minV < math.MinInt64-step*int64(k) {
+				return "", false
+			}
+			return strconv.FormatInt(minV+step*int64(k), 10), true
+		}
+		if maxV > math.MaxInt64-step*int64(k) {
+			return "", false
+		}
+		return strconv.FormatInt(maxV+step*int64(k), 10), true
+	case DTDouble:
+		minV, err1 := strconv.ParseFloat(min, 64)
+		maxV, err2 := strconv.ParseFloat(max, 64)
+		if err1 != nil || err2 != nil {
+			return "", false
+		}
+		step := (maxV - minV) / 100
+		if step == 0 {
+			step = 1
+		}
+		if k < 0 {
+			return strconv.FormatFloat(minV+step*float64(k), 'g', -1, 64), true
+		}
+		return strconv.FormatFloat(maxV+step*float64(k), 'g', -1, 64), true
+	default:
+		if k < 0 || strings.ContainsAny(max, `'\`) {
+			return "", false
+		}
+		return max + strings.Repeat("~", k), true
+	}
+}
@@ -2,16 +2,18 @@ package cetest
 
 import (
 	"fmt"
-	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/qw4990/OptimizerTester/tidb"
 )
 
-// singleColQuerier supports QTSingleColPointQueryOnCol, QTSingleColPointQueryOnIndex, QTSingleColMCVPointOnCol, QTSingleColMCVPointOnIndex
+// singleColQuerier supports QTSingleColPointQueryOnCol, QTSingleColPointQueryOnIndex, QTSingleColMCVPointOnCol, QTSingleColMCVPointOnIndex,
+// QTSingleColNonExistPointOnCol, QTSingleColOutOfRangeOnCol, QTSingleColIsNullOnCol, QTSingleColIsNotNullOnCol.
 // It generates queries like:
 //	SELECT * FROM t WHERE col = ?
+//	SELECT * FROM t WHERE col > ?
+//	SELECT * FROM t WHERE col IS NULL
 type singleColQuerier struct {
 	db       string
 	tbs      []string   // table names
@@ -54,7 +56,7 @@ func (tv *singleColQuerier) init(ins tidb.Instance) (rerr error) {
 		for i, tb := range tv.tbs {
 			for j, col := range tv.cols[i] {
 				begin := time.Now()
-				q := fmt.Sprintf("SELECT %v, COUNT(*) FROM %v.`%v` where %v is not null GROUP BY %v ORDER BY COUNT(*)", col, tv.db, tb, col, col)
+				q := fmt.Sprintf("SELECT %v, COUNT(*) FROM %v where %v is not null GROUP BY %v ORDER BY COUNT(*)", col, tableName(ins, tv.db, tb), col, col)
 				rows, err := ins.Query(q)
 				if err != nil {
 					rerr = err
@@ -80,44 +82,70 @@ func (tv *singleColQuerier) init(ins tidb.Instance) (rerr error) {
 	return
 }
 
-func (tv *singleColQuerier) Collect(nSamples int, qt QueryType, ers []EstResult, ins tidb.Instance, ignoreErr bool) ([]EstResult, error) {
+func (tv *singleColQuerier) Collect(so SampleOption, qt QueryType, ers []EstResult, ins tidb.Instance, ignoreErr bool) ([]EstResult, error) {
 	if err := tv.init(ins); err != nil {
 		return nil, err
 	}
 
 	tbIdx, colIdx := tv.qMap[qt][0], tv.qMap[qt][1]
-	rowBegin, rowEnd := 0, tv.ndv(tbIdx, colIdx)
-	if qt == QTSingleColMCVPointOnCol || qt == QTSingleColMCVPointOnIndex {
-		numNDVs := tv.ndv(tbIdx, colIdx)
-		numMCVs := numNDVs * 10 / 100 // 10%
-		rowBegin = rowEnd - numMCVs
+	var samples []int
+	condAt := func(rowIdx int) (string, int) {
+		return tv.pointCond(tbIdx, colIdx, rowIdx)
 	}
-
-	if nSamples == 0 {
-		nSamples = rowEnd - rowBegin
+	classAt := func(rowIdx int) string {
+		return "" // only values of point queries are classified
+	}
+	statsAt := func(rowIdx int) *StatsAnnotation {
+		return nil // only values of point queries are annotated
 	}
-	sampleRate := float64(nSamples) / float64(rowEnd-rowBegin)
-	if sampleRate > 1 {
-		sampleRate = 1
+	switch qt {
+	case QTSingleColIsNullOnCol, QTSingleColIsNotNullOnCol:
+		conds, err := tv.nullConds(ins, tbIdx, colIdx, qt)
+		if err != nil {
+			return nil, err
+		}
+		samples, condAt = SampleOption{}.sampleRows(0, len(conds)), conds.at
+	case QTSingleColNonExistPointOnCol, QTSingleColOutOfRangeOnCol:
+		conds := tv.absentConds(tbIdx, colIdx, qt)
+		samples, condAt = so.sampleRows(0, len(conds)), conds.at
+	default:
+		classes, bucketOf := freqClasses(tv.orderedDistVals[tbIdx][colIdx], tv.valActRows[tbIdx][colIdx],
+			tv.colTypes[tbIdx][colIdx], so.topN(), so.buckets())
+		classAt = func(rowIdx int) string {
+			return classes[rowIdx]
+		}
+		if cs := tv.columnStats(ins, tbIdx, colIdx); cs != nil {
+			statsAt = func(rowIdx int) *StatsAnnotation {
+				return cs.annotate(tv.orderedDistVals[tbIdx][colIdx][rowIdx])
+			}
+		}
+		if qt == QTSingleColMCVPointOnCol || qt == QTSingleColMCVPointOnIndex {
+			rowBegin, rowEnd := 0, tv.ndv(tbIdx, colIdx)
+			numNDVs := tv.ndv(tbIdx, colIdx)
+			numMCVs := numNDVs * 10 / 100 // 10%
+			rowBegin = rowEnd - numMCVs
+			samples = so.sampleRows(rowBegin, rowEnd)
+		} else {
+			samples = so.sampleValues(classes, bucketOf)
+		}
 	}
 
+	results := make([]*EstResult, len(samples))
 	concurrency := 64
 	var wg sync.WaitGroup
 	var resultLock sync.Mutex
 	processed := 0
 
+	estimator := NewInstanceEstimator(ins)
 	begin := time.Now()
 	for workerID := 0; workerID < concurrency; workerID++ {
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
-			for rowIdx := rowBegin + id; rowIdx < rowEnd; rowIdx += concurrency {
-				if rand.Float64() > sampleRate {
-					continue
-				}
-				cond, act := tv.pointCond(tbIdx, colIdx, rowIdx)
-				q := fmt.Sprintf("SELECT * FROM %v.`%v` WHERE %v", tv.db, tv.tbs[tbIdx], cond)
-				est, err := getEstRowFromExplain(ins, q)
+			for i := id; i < len(samples); i += concurrency {
+				cond, act := condAt(samples[i])
+				q := fmt.Sprintf("SELECT * FROM %v WHERE %v", tableName(ins, tv.db, tv.tbs[tbIdx]), cond)
+				est, err := estimator.Estimate(q)
 				if err != nil {
 					if !ignoreErr {
 						panic(err)
@@ -126,12 +154,12 @@ func (tv *singleColQuerier) Collect(nSamples int, qt QueryType, ers []EstResult,
 					continue
 
 				}
+				results[i] = &EstResult{SQL: q, EstCard: est, TrueCard: float64(act), FreqClass: classAt(samples[i]), Stats: statsAt(samples[i])}
 				resultLock.Lock()
-				ers = append(ers, EstResult{q, est, float64(act)})
 				processed++
 				if processed%5000 == 0 {
 					fmt.Printf("[SingleColQuerier-Process] ins=%v, table=%v, col=%v, qt=%v, concurrency=%v, time-cost=%v, progress (%v/%v)\n",
-						ins.Opt().Label, tv.tbs[tbIdx], tv.cols[tbIdx][colIdx], qt, concurrency, time.Since(begin), processed, nSamples)
+						ins.Opt().Label, tv.tbs[tbIdx], tv.cols[tbIdx][colIdx], qt, concurrency, time.Since(begin), processed, len(samples))
 				}
 				resultLock.Unlock()
 			}
@@ -139,7 +167,19 @@ func (tv *singleColQuerier) Collect(nSamples int, qt QueryType, ers []EstResult,
 	}
 
 	wg.Wait()
-	return ers, nil
+	return appendEstResults(ers, results), nil
+}
+
+// columnStats reads the stats of the column in the instance to annotate results, or returns nil if they can't be read,
+// like on other engines or old TiDB versions, since annotations are optional.
+func (tv *singleColQuerier) columnStats(ins tidb.Instance, tbIdx, colIdx int) *columnStats {
+	cs, err := readColumnStats(ins, tv.db, tv.tbs[tbIdx], tv.cols[tbIdx][colIdx], tv.colTypes[tbIdx][colIdx])
+	if err != nil {
+		fmt.Printf("[SingleColQuerier-Stats] ins=%v, table=%v, col=%v, skip stats attribution: %v\n",
+			ins.Opt().Label, tv.tbs[tbIdx], tv.cols[tbIdx][colIdx], err)
+		return nil
+	}
+	return cs
 }
 
 func (tv *singleColQuerier) ndv(tbIdx, colIdx int) int {
@@ -0,0 +1,108 @@
+package cetest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func TestSingleColQuerier(t *testing.T) {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	ins.OnExactQuery("SELECT phonetic_code, COUNT(*) FROM imdb.`title` where phonetic_code is not null GROUP BY phonetic_code ORDER BY COUNT(*)").
+		Return([]string{"phonetic_code", "COUNT(*)"}, []interface{}{"A5362", 2}, []interface{}{"B1234", 8})
+	ins.OnQuery("WHERE phonetic_code='A5362'$").ReturnTable(`
++-------------------------+---------+-----------+-----------------------+----------------------------------------+
+| id                      | estRows | task      | access object         | operator info                          |
++-------------------------+---------+-----------+-----------------------+----------------------------------------+
+| TableReader_7           | 3.00    | root      |                       | data:Selection_6                       |
+| └─Selection_6           | 3.00    | cop[tikv] |                       | eq(imdb.title.phonetic_code, "A5362")  |
+|   └─TableFullScan_5     | 10.00   | cop[tikv] | table:title           | keep order:false                       |
++-------------------------+---------+-----------+-----------------------+----------------------------------------+`)
+	ins.OnQuery("WHERE phonetic_code='B1234'$").ReturnTable(`
++-------------------------+---------+-----------+-----------------------+----------------------------------------+
+| id                      | estRows | task      | access object         | operator info                          |
++-------------------------+---------+-----------+-----------------------+----------------------------------------+
+| TableReader_7           | 7.50    | root      |                       | data:Selection_6                       |
+| └─Selection_6           | 7.50    | cop[tikv] |                       | eq(imdb.title.phonetic_code, "B1234")  |
+|   └─TableFullScan_5     | 10.00   | cop[tikv] | table:title           | keep order:false                       |
++-------------------------+---------+-----------+-----------------------+----------------------------------------+`)
+
+	qt := QTSingleColPointQueryOnCol
+	q := newSingleColQuerier("imdb", []string{"title"}, [][]string{{"phonetic_code"}}, [][]DATATYPE{{DTString}},
+		map[QueryType][2]int{qt: {0, 0}})
+	ers, err := q.Collect(SampleOption{}, qt, nil, ins, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ers) != 2 {
+		t.Fatalf("expect 2 results, got %v", ers)
+	}
+	expected := map[string]EstResult{
+		"SELECT * FROM imdb.`title` WHERE phonetic_code='A5362'": {EstCard: 3, TrueCard: 2},
+		"SELECT * FROM imdb.`title` WHERE phonetic_code='B1234'": {EstCard: 7.5, TrueCard: 8},
+	}
+	for _, er := range ers {
+		e, ok := expected[er.SQL]
+		if !ok || e.EstCard != er.EstCard || e.TrueCard != er.TrueCard {
+			t.Fatalf("unexpected result %+v", er)
+		}
+	}
+}
+
+func TestAbsentConds(t *testing.T) {
+	q := newSingleColQuerier("db", []string{"t"}, [][]string{{"a", "b", "c"}}, [][]DATATYPE{{DTInt, DTDouble, DTString}}, nil)
+	q.orderedDistVals[0] = [][]string{{"10", "1", "2", "5"}, {"1.5", "1"}, {"b", "a", "ab"}}
+
+	between := func(colIdx int) []string {
+		var conds []string
+		for _, c := range q.absentConds(0, colIdx, QTSingleColNonExistPointOnCol) {
+			if c.actRows != 0 {
+				t.Fatalf("unexpected true cardinality of %v", c)
+			}
+			conds = append(conds, c.cond)
+		}
+		return conds
+	}
+	if conds := between(0); !reflect.DeepEqual(conds, []string{"a=3", "a=7"}) {
+		t.Fatalf("unexpected conditions %v", conds)
+	}
+	if conds := between(1); !reflect.DeepEqual(conds, []string{"b=1.25"}) {
+		t.Fatalf("unexpected conditions %v", conds)
+	}
+	if conds := between(2); !reflect.DeepEqual(conds, []string{"c='a!'", "c='ab!'"}) {
+		t.Fatalf("unexpected conditions %v", conds)
+	}
+
+	outOfRange := q.absentConds(0, 0, QTSingleColOutOfRangeOnCol)
+	if len(outOfRange) != 4*numOutOfRangeValues || outOfRange[0].cond != "a=0" || outOfRange[1].cond != "a<0" ||
+		outOfRange[2].cond != "a=11" || outOfRange[3].cond != "a>11" {
+		t.Fatalf("unexpected conditions %v", outOfRange[:4])
+	}
+	if strs := q.absentConds(0, 2, QTSingleColOutOfRangeOnCol); len(strs) != 2*numOutOfRangeValues || strs[1].cond != "c>'b~'" {
+		t.Fatalf("unexpected conditions %v", strs[:2])
+	}
+}
+
+func TestNullConds(t *testing.T) {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	ins.OnExactQuery("SELECT a, COUNT(*) FROM db.`t` where a is not null GROUP BY a ORDER BY COUNT(*)").
+		Return([]string{"a", "COUNT(*)"}, []interface{}{1, 3})
+	ins.OnExactQuery("SELECT COUNT(*), COUNT(a) FROM db.`t`").Return([]string{"COUNT(*)", "COUNT(a)"}, []interface{}{10, 3})
+	ins.OnQuery("WHERE a IS NULL$").ReturnTable(`
++-------------------------+---------+-----------+---------------+--------------------+
+| id                      | estRows | task      | access object | operator info      |
++-------------------------+---------+-----------+---------------+--------------------+
+| TableReader_7           | 6.00    | root      |               | data:Selection_6   |
++-------------------------+---------+-----------+---------------+--------------------+`)
+
+	qt := QTSingleColIsNullOnCol
+	q := newSingleColQuerier("db", []string{"t"}, [][]string{{"a"}}, [][]DATATYPE{{DTInt}}, map[QueryType][2]int{qt: {0, 0}})
+	ers, err := q.Collect(SampleOption{NSamples: 100}, qt, nil, ins, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ers) != 1 || ers[0].SQL != "SELECT * FROM db.`t` WHERE a IS NULL" || ers[0].EstCard != 6 || ers[0].TrueCard != 7 {
+		t.Fatalf("unexpected results %+v", ers)
+	}
+}
@@ -17,10 +17,14 @@ func newDatasetTPCC(opt DatasetOpt) Dataset {
 			[][]string{{"ol_amount"}, {"c_ytd_payment"}},
 			[][]DATATYPE{{DTDouble}, {DTDouble}},
 			map[QueryType][2]int{
-				QTSingleColPointQueryOnCol:   {0, 0}, // select * from order_line where ol_amount = ?
-				QTSingleColPointQueryOnIndex: {1, 0}, // select * from customer where c_ytd_payment = ?
-				QTSingleColMCVPointOnCol:     {0, 0}, // select * from order_line where ol_amount = ?
-				QTSingleColMCVPointOnIndex:   {1, 0}, // select * from customer where c_ytd_payment = ?
+				QTSingleColPointQueryOnCol:    {0, 0}, // select * from order_line where ol_amount = ?
+				QTSingleColPointQueryOnIndex:  {1, 0}, // select * from customer where c_ytd_payment = ?
+				QTSingleColMCVPointOnCol:      {0, 0}, // select * from order_line where ol_amount = ?
+				QTSingleColMCVPointOnIndex:    {1, 0}, // select * from customer where c_ytd_payment = ?
+				QTSingleColNonExistPointOnCol: {0, 0}, // select * from order_line where ol_amount = ? with absent values
+				QTSingleColOutOfRangeOnCol:    {0, 0}, // select * from order_line where ol_amount =/>/< ? with values out of [min, max]
+				QTSingleColIsNullOnCol:        {0, 0}, // select * from order_line where ol_amount is null
+				QTSingleColIsNotNullOnCol:     {0, 0}, // select * from order_line where ol_amount is not null
 			}),
 		mciq: newMulColIndexQuerier(opt.DB,
 			[]string{"idx_c_discount_balance"},
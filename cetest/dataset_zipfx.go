@@ -17,10 +17,14 @@ func newDatasetZipFX(opt DatasetOpt) Dataset {
 	scqCols := [][]string{{"a", "b"}}
 	scqColTypes := [][]DATATYPE{{DTInt, DTInt}}
 	scqMap := map[QueryType][2]int{
-		QTSingleColPointQueryOnCol:   {0, 1}, // SELECT * FROM tint WHERE b=?
-		QTSingleColPointQueryOnIndex: {0, 0}, // SELECT * FROM tint WHERE a=?
-		QTSingleColMCVPointOnCol:     {0, 1}, // SELECT * FROM tint WHERE b=?
-		QTSingleColMCVPointOnIndex:   {0, 0}, // SELECT * FROM tint WHERE a=?
+		QTSingleColPointQueryOnCol:    {0, 1}, // SELECT * FROM tint WHERE b=?
+		QTSingleColPointQueryOnIndex:  {0, 0}, // SELECT * FROM tint WHERE a=?
+		QTSingleColMCVPointOnCol:      {0, 1}, // SELECT * FROM tint WHERE b=?
+		QTSingleColMCVPointOnIndex:    {0, 0}, // SELECT * FROM tint WHERE a=?
+		QTSingleColNonExistPointOnCol: {0, 1}, // SELECT * FROM tint WHERE b=? with absent values
+		QTSingleColOutOfRangeOnCol:    {0, 1}, // SELECT * FROM tint WHERE b=?/>?/<? with values out of [min, max]
+		QTSingleColIsNullOnCol:        {0, 1}, // SELECT * FROM tint WHERE b IS NULL
+		QTSingleColIsNotNullOnCol:     {0, 1}, // SELECT * FROM tint WHERE b IS NOT NULL
 	}
 
 	mciqIdxs := []string{"a_2"}
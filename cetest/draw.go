@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
-	"os"
+	"math"
 	"path"
 	"sort"
 
@@ -18,6 +18,7 @@ import (
 // GenPErrorBarChartsReport ...
 func GenPErrorBarChartsReport(opt Option, collector EstResultCollector) error {
 	md := bytes.Buffer{}
+	md.WriteString(fmt.Sprintf("> seed=%v\n\n", opt.Seed))
 	for qtIdx, qt := range opt.QueryTypes {
 		md.WriteString(fmt.Sprintf("# %v\n", qt))
 		for dsIdx, ds := range opt.Datasets {
@@ -48,7 +49,7 @@ func GenPErrorBarChartsReport(opt Option, collector EstResultCollector) error {
 			md.WriteString("\n")
 		}
 	}
-	return ioutil.WriteFile(path.Join(opt.ReportDir, "report.md"), md.Bytes(), 0666)
+	return ioutil.WriteFile(path.Join(opt.ReportDir, reportFileName(ReportPErrorBar)), md.Bytes(), 0666)
 }
 
 func analyzePError(results []EstResult, isOverEst bool) map[string]string {
@@ -91,6 +92,7 @@ func analyzePError(results []EstResult, isOverEst bool) map[string]string {
 // GenQErrorBoxPlotReport generates a report with MarkDown format.
 func GenQErrorBoxPlotReport(opt Option, collector EstResultCollector) error {
 	mdContent := bytes.Buffer{}
+	mdContent.WriteString(fmt.Sprintf("> seed=%v\n\n", opt.Seed))
 	for qtIdx, qt := range opt.QueryTypes {
 		mdContent.WriteString(fmt.Sprintf("## %v q-error report:\n", qt))
 		picPath, err := DrawQErrorBoxPlotGroupByQueryType(opt, collector, qtIdx)
@@ -112,11 +114,14 @@ func GenQErrorBoxPlotReport(opt Option, collector EstResultCollector) error {
 		}
 		mdContent.WriteString("\n")
 	}
-	return ioutil.WriteFile(path.Join(opt.ReportDir, "report.md"), mdContent.Bytes(), 0666)
+	return ioutil.WriteFile(path.Join(opt.ReportDir, reportFileName(ReportQErrorBox)), mdContent.Bytes(), 0666)
 }
 
 func analyzeQError(results []EstResult) map[string]float64 {
 	n := len(results)
+	if n == 0 {
+		return map[string]float64{"max": math.NaN(), "p50": math.NaN(), "p90": math.NaN(), "p95": math.NaN()}
+	}
 	qes := make([]float64, n)
 	for i := range results {
 		qes[i] = QError(results[i])
@@ -164,17 +169,11 @@ func DrawBarChartsGroupByQTAndDS(opt Option, collector EstResultCollector, qtIdx
 	}
 	p.NominalX(xNames...)
 
-	prefixDir := opt.ReportDir
-	if !path.IsAbs(prefixDir) {
-		absPrefix, err := os.Getwd()
-		if err != nil {
-			return "", errors.Trace(err)
-		}
-		prefixDir = path.Join(absPrefix, prefixDir)
+	pngName := barChartFileName(opt, qtIdx, dsIdx)
+	pngPath, err := picturePath(opt, pngName)
+	if err != nil {
+		return "", err
 	}
-
-	pngName := fmt.Sprintf("%v-%v-bar.png", opt.QueryTypes[qtIdx], opt.Datasets[dsIdx].Label)
-	pngPath := path.Join(prefixDir, pngName)
 	return pngName, p.Save(vg.Points(w+(w+5)*float64(len(boundaries)*len(opt.Instances))), 3*vg.Inch, pngPath)
 }
 
@@ -274,15 +273,158 @@ func DrawQErrorBoxPlotGroupByQueryType(opt Option, collector EstResultCollector,
 	p.Add(boxes...)
 	p.NominalX(picNames...)
 
-	prefixDir := opt.ReportDir
-	if !path.IsAbs(prefixDir) {
-		absPrefix, err := os.Getwd()
+	pngName := boxPlotFileName(opt, qtIdx)
+	pngPath, err := picturePath(opt, pngName)
+	if err != nil {
+		return "", err
+	}
+	return pngName, errors.Trace(p.Save(vg.Length(100+80*len(opt.Datasets)*len(opt.Instances)), 200, pngPath))
+}
+
+// GenBiasCDFReport generates a report with CDF curves of biases of all instances on each dataset and query type.
+func GenBiasCDFReport(opt Option, collector EstResultCollector) error {
+	md := bytes.Buffer{}
+	md.WriteString(fmt.Sprintf("> seed=%v\n\n", opt.Seed))
+	for qtIdx, qt := range opt.QueryTypes {
+		md.WriteString(fmt.Sprintf("# %v\n", qt))
+		for dsIdx, ds := range opt.Datasets {
+			md.WriteString(fmt.Sprintf("## %v\n", ds.Label))
+			picPath, err := DrawBiasCDF(opt, collector, qtIdx, dsIdx)
+			if err != nil {
+				return err
+			}
+			md.WriteString(fmt.Sprintf("![pic](%v)\n", picPath))
+
+			md.WriteString("\n| Instance | P10 | P50 | P90 |\n")
+			md.WriteString("| ---- | ---- | ---- | ---- |\n")
+			for insIdx, ins := range opt.Instances {
+				biases := sortedValues(collector.EstResults(insIdx, dsIdx, qtIdx), Bias)
+				md.WriteString(fmt.Sprintf("| %v | %.3f | %.3f | %.3f |\n",
+					ins.Label, percentile(biases, 0.1), percentile(biases, 0.5), percentile(biases, 0.9)))
+			}
+			md.WriteString("\n")
+		}
+	}
+	return ioutil.WriteFile(path.Join(opt.ReportDir, reportFileName(ReportBiasCDF)), md.Bytes(), 0666)
+}
+
+func sortedValues(rs []EstResult, calFunc func(EstResult) float64) []float64 {
+	vs := make([]float64, len(rs))
+	for i, r := range rs {
+		vs[i] = calFunc(r)
+	}
+	sort.Float64s(vs)
+	return vs
+}
+
+// percentile returns the p-th percentile of sorted values, or NaN if there is no value.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+// DrawBiasCDF draws CDF curves of biases of all instances and returns the picture's path.
+// The X axis is limited to [P1, P99] of all biases to keep extreme values from squeezing the curves.
+func DrawBiasCDF(opt Option, collector EstResultCollector, qtIdx, dsIdx int) (string, error) {
+	p := plot.New()
+	p.Title.Text = fmt.Sprintf("Bias CDF on %v", opt.Datasets[dsIdx].Label)
+	p.X.Label.Text = "bias"
+	p.Y.Label.Text = "cumulative probability"
+
+	var all []float64
+	for insIdx, ins := range opt.Instances {
+		biases := sortedValues(collector.EstResults(insIdx, dsIdx, qtIdx), Bias)
+		if len(biases) == 0 {
+			continue
+		}
+		all = append(all, biases...)
+		xys := make(plotter.XYs, len(biases))
+		for i, b := range biases {
+			xys[i].X, xys[i].Y = b, float64(i+1)/float64(len(biases))
+		}
+		line, err := plotter.NewLine(xys)
 		if err != nil {
 			return "", errors.Trace(err)
 		}
-		prefixDir = path.Join(absPrefix, prefixDir)
+		line.Color = plotutil.Color(insIdx)
+		p.Add(line)
+		p.Legend.Add(ins.Label, line)
+	}
+	if len(all) > 0 {
+		sort.Float64s(all)
+		p.X.Min, p.X.Max = percentile(all, 0.01), percentile(all, 0.99)
+		if p.X.Min == p.X.Max {
+			p.X.Min, p.X.Max = p.X.Min-1, p.X.Max+1
+		}
+	}
+	p.Y.Min, p.Y.Max = 0, 1
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	pngName := biasCDFFileName(opt, qtIdx, dsIdx)
+	pngPath, err := picturePath(opt, pngName)
+	if err != nil {
+		return "", err
+	}
+	return pngName, errors.Trace(p.Save(6*vg.Inch, 4*vg.Inch, pngPath))
+}
+
+// GenEstVsTrueScatterReport generates a report with scatter plots of estimated and true cardinalities of each instance
+// on each dataset and query type.
+func GenEstVsTrueScatterReport(opt Option, collector EstResultCollector) error {
+	md := bytes.Buffer{}
+	md.WriteString(fmt.Sprintf("> seed=%v\n\n", opt.Seed))
+	for qtIdx, qt := range opt.QueryTypes {
+		md.WriteString(fmt.Sprintf("# %v\n", qt))
+		for dsIdx, ds := range opt.Datasets {
+			md.WriteString(fmt.Sprintf("## %v\n", ds.Label))
+			for insIdx, ins := range opt.Instances {
+				md.WriteString(fmt.Sprintf("### %v\n", ins.Label))
+				picPath, err := DrawEstVsTrueScatter(opt, collector, qtIdx, dsIdx, insIdx)
+				if err != nil {
+					return err
+				}
+				md.WriteString(fmt.Sprintf("![pic](%v)\n\n", picPath))
+			}
+		}
 	}
+	return ioutil.WriteFile(path.Join(opt.ReportDir, reportFileName(ReportEstVsTrueScatter)), md.Bytes(), 0666)
+}
 
-	pngPath := path.Join(prefixDir, fmt.Sprintf("%v-box-plot.png", opt.QueryTypes[qtIdx]))
-	return pngPath, errors.Trace(p.Save(vg.Length(100+80*len(opt.Datasets)*len(opt.Instances)), 200, pngPath))
+// DrawEstVsTrueScatter draws log10(est+1) against log10(true+1) with the line of perfect estimations,
+// and returns the picture's path.
+func DrawEstVsTrueScatter(opt Option, collector EstResultCollector, qtIdx, dsIdx, insIdx int) (string, error) {
+	p := plot.New()
+	p.Title.Text = fmt.Sprintf("Est vs True on %v of %v", opt.Datasets[dsIdx].Label, opt.Instances[insIdx].Label)
+	p.X.Label.Text = "log10(true+1)"
+	p.Y.Label.Text = "log10(est+1)"
+
+	rs := collector.EstResults(insIdx, dsIdx, qtIdx)
+	xys := make(plotter.XYs, len(rs))
+	maxV := 1.0
+	for i, r := range rs {
+		xys[i].X, xys[i].Y = math.Log10(r.TrueCard+1), math.Log10(r.EstCard+1)
+		maxV = math.Max(maxV, math.Max(xys[i].X, xys[i].Y))
+	}
+	scatter, err := plotter.NewScatter(xys)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	scatter.Color = plotutil.Color(insIdx)
+	perfect, err := plotter.NewLine(plotter.XYs{{X: 0, Y: 0}, {X: maxV, Y: maxV}})
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	perfect.Dashes = []vg.Length{vg.Points(4), vg.Points(4)}
+	p.Add(scatter, perfect)
+	p.X.Min, p.X.Max, p.Y.Min, p.Y.Max = 0, maxV, 0, maxV
+
+	pngName := scatterFileName(opt, qtIdx, dsIdx, insIdx)
+	pngPath, err := picturePath(opt, pngName)
+	if err != nil {
+		return "", err
+	}
+	return pngName, errors.Trace(p.Save(4*vg.Inch, 4*vg.Inch, pngPath))
 }
@@ -5,9 +5,12 @@ import (
 )
 
 type EstResult struct {
-	SQL      string
-	EstCard  float64 // estimated cardinality
-	TrueCard float64 // true cardinality
+	SQL      string  `json:"sql"`
+	EstCard  float64 `json:"est-card"`  // estimated cardinality
+	TrueCard float64 `json:"true-card"` // true cardinality
+
+	FreqClass string           `json:"freq-class,omitempty"` // the frequency class of the value of single column point queries
+	Stats     *StatsAnnotation `json:"stats,omitempty"`      // how the value of single column point queries is covered by stats
 }
 
 // QError is max(est/true, true/est) or ((numerator+1)/(denominator+1)) if the denominator is 0.
@@ -0,0 +1,149 @@
+package cetest
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// Estimator estimates the cardinality of single table queries like "SELECT * FROM db.t WHERE cond".
+type Estimator interface {
+	// Name returns the name of the estimator, which is used as its label in reports.
+	Name() string
+
+	// Estimate returns the estimated cardinality of the query.
+	Estimate(query string) (float64, error)
+}
+
+// Estimators used as reference points of the estimations of instances.
+const (
+	EstimatorUniform = "uniform" // uniform values in [min, max] of each column and independent columns
+	EstimatorSample  = "sample"  // a Bernoulli sample of each table kept locally
+	EstimatorOracle  = "oracle"  // a perfect histogram of each column and independent columns
+)
+
+const (
+	baselineLabelPrefix       = "baseline-"
+	defaultBaselineSampleRate = 0.01
+)
+
+// BaselineConf is the baseline section in configs.
+type BaselineConf struct {
+	Estimators []string `toml:"estimators"`  // uniform, sample and oracle
+	SampleRate float64  `toml:"sample-rate"` // the rate of rows kept by the sample estimator, 0.01 by default
+}
+
+// CheckBaselines checks names of estimators and the sample rate in the conf.
+func CheckBaselines(conf BaselineConf) error {
+	for _, name := range conf.Estimators {
+		switch strings.ToLower(name) {
+		case EstimatorUniform, EstimatorSample, EstimatorOracle:
+		default:
+			return errors.Errorf("unknown baseline estimator=%v", name)
+		}
+	}
+	if conf.SampleRate < 0 || conf.SampleRate > 1 {
+		return errors.Errorf("invalid baseline sample-rate=%v", conf.SampleRate)
+	}
+	return nil
+}
+
+// instanceEstimator estimates queries by EXPLAIN on the instance.
+type instanceEstimator struct {
+	ins tidb.Instance
+}
+
+// NewInstanceEstimator returns the estimator of the optimizer of the instance.
+func NewInstanceEstimator(ins tidb.Instance) Estimator {
+	return instanceEstimator{ins}
+}
+
+func (e instanceEstimator) Name() string {
+	return e.ins.Opt().Label
+}
+
+func (e instanceEstimator) Estimate(query string) (float64, error) {
+	return getEstRowFromExplain(e.ins, query)
+}
+
+// NewBaselineEstimator returns the baseline estimator, which reads data from the instance lazily when a table
+// is estimated the first time.
+func NewBaselineEstimator(name string, ins tidb.Instance, conf BaselineConf, seed int64) (Estimator, error) {
+	name = strings.ToLower(name)
+	switch name {
+	case EstimatorUniform:
+		e := new(uniformEstimator)
+		e.init(name, ins)
+		return e, nil
+	case EstimatorOracle:
+		e := new(oracleEstimator)
+		e.init(name, ins)
+		return e, nil
+	case EstimatorSample:
+		rate := conf.SampleRate
+		if rate == 0 {
+			rate = defaultBaselineSampleRate
+		}
+		e := &sampleEstimator{rate: rate, rand: rand.New(rand.NewSource(seed))}
+		e.init(name, ins)
+		return e, nil
+	}
+	return nil, errors.Errorf("unknown baseline estimator=%v", name)
+}
+
+// addBaselines estimates queries of the first instance by baseline estimators with its data, and returns the option
+// and the collector with these estimators as new instances labeled like "baseline-uniform". Queries unsupported by
+// an estimator are excluded from its results, and counted by baselineUnsupported.
+func addBaselines(opt Option, ins tidb.Instance, collector EstResultCollector) (Option, EstResultCollector, error) {
+	var ests []Estimator
+	for _, name := range opt.Baseline.Estimators {
+		est, err := NewBaselineEstimator(name, ins, opt.Baseline, opt.Seed)
+		if err != nil {
+			return opt, nil, err
+		}
+		ests = append(ests, est)
+	}
+
+	nIns := len(opt.Instances)
+	newOpt := opt
+	newOpt.Instances = append([]tidb.Option{}, opt.Instances...)
+	for _, est := range ests {
+		newOpt.Instances = append(newOpt.Instances, tidb.Option{Label: est.Name()})
+	}
+	newCollector := NewEstResultCollector(len(newOpt.Instances), len(opt.Datasets), len(opt.QueryTypes))
+	for dsIdx := range opt.Datasets {
+		for qtIdx := range opt.QueryTypes {
+			for insIdx := 0; insIdx < nIns; insIdx++ {
+				newCollector.AppendEstResults(insIdx, dsIdx, qtIdx, collector.EstResults(insIdx, dsIdx, qtIdx))
+			}
+			for i, est := range ests {
+				for _, r := range collector.EstResults(0, dsIdx, qtIdx) {
+					card, err := est.Estimate(r.SQL)
+					if err != nil {
+						fmt.Printf("[CETest-Baseline] estimator=%v, sql=%v, skip unsupported query: %v\n", est.Name(), r.SQL, err)
+						continue
+					}
+					r.EstCard = card
+					newCollector.AddEstResult(nIns+i, dsIdx, qtIdx, r)
+				}
+				if n, _ := baselineUnsupported(newOpt, newCollector, nIns+i, dsIdx, qtIdx); n > 0 {
+					fmt.Printf("[CETest-Baseline] estimator=%v, ds=%v, qt=%v, %v of %v queries are unsupported\n", est.Name(),
+						opt.Datasets[dsIdx].Label, opt.QueryTypes[qtIdx], n, len(collector.EstResults(0, dsIdx, qtIdx)))
+				}
+			}
+		}
+	}
+	return newOpt, newCollector, nil
+}
+
+// baselineUnsupported returns the number of queries of the first instance which are unsupported by the baseline
+// estimator of the instance, or false if the instance is not a baseline.
+func baselineUnsupported(opt Option, collector EstResultCollector, insIdx, dsIdx, qtIdx int) (int, bool) {
+	if insIdx == 0 || !strings.HasPrefix(opt.Instances[insIdx].Label, baselineLabelPrefix) {
+		return 0, false
+	}
+	return len(collector.EstResults(0, dsIdx, qtIdx)) - len(collector.EstResults(insIdx, dsIdx, qtIdx)), true
+}
@@ -0,0 +1,484 @@
+package cetest
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/predicate"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// colCond is a condition on a column in conjunctive conditions estimated by baseline estimators.
+type colCond struct {
+	col   string
+	op    string // =, !=, <, <=, >, >=, is null or is not null
+	val   string
+	num   float64
+	isNum bool // numeric literals are compared as numbers, and others as strings
+}
+
+// match tells whether the value satisfies the condition.
+func (c colCond) match(v sql.NullString) bool {
+	switch c.op {
+	case "is null":
+		return !v.Valid
+	case "is not null":
+		return v.Valid
+	}
+	if !v.Valid {
+		return false
+	}
+	var cmp int
+	if c.isNum {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v.String), 64)
+		if err != nil {
+			return false
+		}
+		switch {
+		case f < c.num:
+			cmp = -1
+		case f > c.num:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(v.String, c.val)
+	}
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0 // >=
+}
+
+func matchAll(conds []colCond, v sql.NullString) bool {
+	for _, c := range conds {
+		if !c.match(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// groupByColumn groups conditions by their columns, and returns columns in the order they first appear.
+func groupByColumn(conds []colCond) ([]string, map[string][]colCond) {
+	var cols []string
+	groups := make(map[string][]colCond)
+	for _, c := range conds {
+		if _, ok := groups[c.col]; !ok {
+			cols = append(cols, c.col)
+		}
+		groups[c.col] = append(groups[c.col], c)
+	}
+	return cols, groups
+}
+
+// splitQuery splits a query like "SELECT * FROM db.`t` WHERE cond" into the table, whose quotes are kept to
+// access it, and conjunctive conditions.
+func splitQuery(query string) (string, []colCond, error) {
+	upper := strings.ToUpper(query)
+	from := strings.Index(upper, " FROM ")
+	if from < 0 {
+		return "", nil, errors.Errorf("no table in %v", query)
+	}
+	tbl, cond := strings.TrimSpace(query[from+len(" FROM "):]), ""
+	if where := strings.Index(upper, " WHERE "); where > from {
+		tbl, cond = strings.TrimSpace(query[from+len(" FROM "):where]), query[where+len(" WHERE "):]
+	}
+	conds, err := parseConds(cond)
+	return tbl, conds, err
+}
+
+// parseConds parses conjunctive conditions of columns and literals in SQL like "a=1 AND b>='x'", or in optimizer
+// traces like "and(eq(db.t.a, 1), ge(db.t.b, "x"))".
+func parseConds(cond string) ([]colCond, error) {
+	if strings.TrimSpace(cond) == "" {
+		return nil, nil
+	}
+	e, err := predicate.Parse(cond)
+	if err != nil {
+		return nil, err
+	}
+	var conds []colCond
+	for _, item := range predicate.Conjuncts(e) {
+		c, ok := item.(predicate.Cmp)
+		if !ok {
+			return nil, errors.Errorf("unsupported condition %v", cond)
+		}
+		switch c.Op {
+		case predicate.OpIsNull, predicate.OpNotNull:
+			conds = append(conds, colCond{col: c.Col, op: c.Op})
+		case predicate.OpIn:
+			return nil, errors.Errorf("unsupported condition %v", cond)
+		case predicate.OpBetween:
+			low, err := newColCond(c.Col, predicate.OpGE, c.Vals[0])
+			if err != nil {
+				return nil, err
+			}
+			high, err := newColCond(c.Col, predicate.OpLE, c.Vals[1])
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, low, high)
+		default:
+			cc, err := newColCond(c.Col, c.Op, c.Vals[0])
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cc)
+		}
+	}
+	return conds, nil
+}
+
+// newColCond returns the condition comparing the column with the literal.
+func newColCond(col, op string, l predicate.Literal) (colCond, error) {
+	c := colCond{col: col, op: op, val: l.Text}
+	switch {
+	case l.Null:
+		return colCond{}, errors.Errorf("unsupported comparison of %v with NULL", col)
+	case !l.Quoted:
+		num, err := strconv.ParseFloat(l.Text, 64)
+		if err != nil {
+			return colCond{}, errors.Errorf("invalid number %v", l.Text)
+		}
+		c.num, c.isNum = num, true
+	}
+	return c, nil
+}
+
+// baselineTable is the data of a table read by baseline estimators.
+type baselineTable struct {
+	rowCount float64
+	columns  map[string]*baselineColumn
+	sample   []map[string]sql.NullString
+}
+
+// baselineColumn is the data of a column read by baseline estimators.
+type baselineColumn struct {
+	notNull  float64
+	ndv      float64
+	min, max sql.NullString
+	vals     []sql.NullString // distinct values including NULL
+	counts   []float64
+}
+
+// baselineBase reads and caches data of tables by queries on the instance.
+type baselineBase struct {
+	name   string
+	ins    tidb.Instance
+	lock   sync.Mutex
+	tables map[string]*baselineTable
+}
+
+func (b *baselineBase) init(name string, ins tidb.Instance) {
+	b.name, b.ins, b.tables = name, ins, make(map[string]*baselineTable)
+}
+
+func (b *baselineBase) Name() string {
+	return baselineLabelPrefix + b.name
+}
+
+// table returns the cached data of the table, or creates it by the function.
+func (b *baselineBase) table(tbl string, load func() (*baselineTable, error)) (*baselineTable, error) {
+	key := strings.ToLower(tbl)
+	if t, ok := b.tables[key]; ok {
+		return t, nil
+	}
+	t, err := load()
+	if err != nil {
+		return nil, err
+	}
+	b.tables[key] = t
+	return t, nil
+}
+
+// column returns the cached data of the column, or creates it by the function.
+func (b *baselineBase) column(t *baselineTable, col string, load func() (*baselineColumn, error)) (*baselineColumn, error) {
+	if c, ok := t.columns[col]; ok {
+		return c, nil
+	}
+	c, err := load()
+	if err != nil {
+		return nil, err
+	}
+	t.columns[col] = c
+	return c, nil
+}
+
+func (b *baselineBase) rowCount(tbl string) (*baselineTable, error) {
+	return b.table(tbl, func() (*baselineTable, error) {
+		q := fmt.Sprintf("SELECT COUNT(*) FROM %v", tbl)
+		rows, err := b.ins.Query(q)
+		if err != nil {
+			return nil, errors.Annotatef(err, "run sql=%v", q)
+		}
+		defer rows.Close()
+		t := &baselineTable{columns: make(map[string]*baselineColumn)}
+		if !rows.Next() {
+			return nil, errors.Errorf("no result for %v", q)
+		}
+		return t, errors.Trace(rows.Scan(&t.rowCount))
+	})
+}
+
+// uniformEstimator assumes values of each column are uniformly distributed in [min, max], and columns are independent.
+// Ranges of strings are estimated as 1/3 of rows like pseudo stats.
+type uniformEstimator struct {
+	baselineBase
+}
+
+func (e *uniformEstimator) Estimate(query string) (float64, error) {
+	tbl, conds, err := splitQuery(query)
+	if err != nil {
+		return 0, err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	t, err := e.rowCount(tbl)
+	if err != nil || t.rowCount == 0 {
+		return 0, err
+	}
+	cols, groups := groupByColumn(conds)
+	sel := 1.0
+	for _, col := range cols {
+		c, err := e.column(t, col, func() (*baselineColumn, error) {
+			q := fmt.Sprintf("SELECT COUNT(%v), COUNT(DISTINCT %v), MIN(%v), MAX(%v) FROM %v", col, col, col, col, tbl)
+			rows, err := e.ins.Query(q)
+			if err != nil {
+				return nil, errors.Annotatef(err, "run sql=%v", q)
+			}
+			defer rows.Close()
+			if !rows.Next() {
+				return nil, errors.Errorf("no result for %v", q)
+			}
+			c := new(baselineColumn)
+			return c, errors.Trace(rows.Scan(&c.notNull, &c.ndv, &c.min, &c.max))
+		})
+		if err != nil {
+			return 0, err
+		}
+		sel *= c.uniformSelectivity(groups[col], t.rowCount)
+	}
+	return sel * t.rowCount, nil
+}
+
+// uniformSelectivity estimates conditions on the column by its NDV, min and max.
+func (c *baselineColumn) uniformSelectivity(conds []colCond, rowCount float64) float64 {
+	nullSel := (rowCount - c.notNull) / rowCount
+	var eq *colCond
+	var ranges []colCond
+	isNull, notEq := false, 0
+	for i := range conds {
+		switch conds[i].op {
+		case "is null":
+			isNull = true
+		case "is not null":
+		case "=":
+			eq = &conds[i]
+		case "!=":
+			notEq++
+		default:
+			ranges = append(ranges, conds[i])
+		}
+	}
+	if isNull {
+		if len(conds) > 1 && !matchAll(conds, sql.NullString{}) {
+			return 0
+		}
+		return nullSel
+	}
+	if c.notNull == 0 || c.ndv == 0 {
+		return 0
+	}
+
+	sel := 1 - nullSel
+	if eq != nil { // the value must satisfy all conditions and be in [min, max]
+		v := sql.NullString{String: eq.val, Valid: true}
+		if !matchAll(conds, v) || !c.inRange(*eq) {
+			return 0
+		}
+		return sel / c.ndv
+	}
+	sel *= math.Pow(1-1/c.ndv, float64(notEq))
+	if len(ranges) == 0 {
+		return sel
+	}
+	minV, err1 := strconv.ParseFloat(c.min.String, 64)
+	maxV, err2 := strconv.ParseFloat(c.max.String, 64)
+	lo, hi := minV, maxV
+	for _, r := range ranges {
+		if !r.isNum || err1 != nil || err2 != nil {
+			sel /= 3
+			continue
+		}
+		if r.op == "<" || r.op == "<=" {
+			hi = math.Min(hi, r.num)
+		} else {
+			lo = math.Max(lo, r.num)
+		}
+	}
+	if hi < lo {
+		return 0
+	}
+	if maxV == minV {
+		return sel
+	}
+	return sel * (hi - lo) / (maxV - minV)
+}
+
+// inRange tells whether the literal of the condition is in [min, max] of the column.
+func (c *baselineColumn) inRange(cond colCond) bool {
+	min := colCond{op: ">=", val: c.min.String, isNum: cond.isNum}
+	max := colCond{op: "<=", val: c.max.String, isNum: cond.isNum}
+	if cond.isNum {
+		var err1, err2 error
+		min.num, err1 = strconv.ParseFloat(c.min.String, 64)
+		max.num, err2 = strconv.ParseFloat(c.max.String, 64)
+		if err1 != nil || err2 != nil {
+			return true
+		}
+	}
+	v := sql.NullString{String: cond.val, Valid: true}
+	return min.match(v) && max.match(v)
+}
+
+// oracleEstimator knows the exact count of each value of each column, like a histogram with a bucket for each value,
+// and assumes columns are independent, so its errors only come from correlations between columns.
+type oracleEstimator struct {
+	baselineBase
+}
+
+func (e *oracleEstimator) Estimate(query string) (float64, error) {
+	tbl, conds, err := splitQuery(query)
+	if err != nil {
+		return 0, err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	t, err := e.rowCount(tbl)
+	if err != nil || t.rowCount == 0 {
+		return 0, err
+	}
+	cols, groups := groupByColumn(conds)
+	sel := 1.0
+	for _, col := range cols {
+		c, err := e.column(t, col, func() (*baselineColumn, error) {
+			q := fmt.Sprintf("SELECT %v, COUNT(*) FROM %v GROUP BY %v", col, tbl, col)
+			rows, err := e.ins.Query(q)
+			if err != nil {
+				return nil, errors.Annotatef(err, "run sql=%v", q)
+			}
+			defer rows.Close()
+			c := new(baselineColumn)
+			for rows.Next() {
+				var v sql.NullString
+				var cnt float64
+				if err := rows.Scan(&v, &cnt); err != nil {
+					return nil, errors.Trace(err)
+				}
+				c.vals, c.counts = append(c.vals, v), append(c.counts, cnt)
+			}
+			return c, errors.Trace(rows.Err())
+		})
+		if err != nil {
+			return 0, err
+		}
+		matched := 0.0
+		for i, v := range c.vals {
+			if matchAll(groups[col], v) {
+				matched += c.counts[i]
+			}
+		}
+		sel *= matched / t.rowCount
+	}
+	return sel * t.rowCount, nil
+}
+
+// sampleEstimator keeps a Bernoulli sample of each table, and estimates queries by the matched rows in the sample.
+type sampleEstimator struct {
+	baselineBase
+	rate float64
+	rand *rand.Rand
+}
+
+func (e *sampleEstimator) Estimate(query string) (float64, error) {
+	tbl, conds, err := splitQuery(query)
+	if err != nil {
+		return 0, err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	t, err := e.table(tbl, func() (*baselineTable, error) {
+		return e.sampleTable(tbl)
+	})
+	if err != nil || len(t.sample) == 0 {
+		return 0, err
+	}
+	matched := 0
+	for _, row := range t.sample {
+		ok := true
+		for _, c := range conds {
+			v, exist := row[c.col]
+			if !exist {
+				return 0, errors.Errorf("no column %v in %v", c.col, tbl)
+			}
+			if !c.match(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(t.sample)) * t.rowCount, nil
+}
+
+// sampleTable scans the table and keeps each row with the probability of the sample rate.
+func (e *sampleEstimator) sampleTable(tbl string) (*baselineTable, error) {
+	q := fmt.Sprintf("SELECT * FROM %v", tbl)
+	rows, err := e.ins.Query(q)
+	if err != nil {
+		return nil, errors.Annotatef(err, "run sql=%v", q)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	t := &baselineTable{columns: make(map[string]*baselineColumn)}
+	vals := make([]sql.NullString, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, errors.Trace(err)
+		}
+		t.rowCount++
+		if e.rand.Float64() >= e.rate {
+			continue
+		}
+		row := make(map[string]sql.NullString, len(cols))
+		for i, col := range cols {
+			row[strings.ToLower(col)] = vals[i]
+		}
+		t.sample = append(t.sample, row)
+	}
+	return t, errors.Trace(rows.Err())
+}
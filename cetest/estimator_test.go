@@ -0,0 +1,127 @@
+package cetest
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func TestParseConds(t *testing.T) {
+	cases := []struct {
+		cond  string
+		conds []colCond
+	}{
+		{"a=1 AND b='x''y'", []colCond{{col: "a", op: "=", val: "1", num: 1, isNum: true}, {col: "b", op: "=", val: "x'y"}}},
+		{"`a`>=-1.5 AND a<>2 AND (b IS NULL AND c IS NOT NULL)", []colCond{{col: "a", op: ">=", val: "-1.5", num: -1.5, isNum: true},
+			{col: "a", op: "!=", val: "2", num: 2, isNum: true}, {col: "b", op: "is null"}, {col: "c", op: "is not null"}}},
+		{`and(ge(db.t.a, 1), lt(10, db.t.a), not(isnull(db.t.b)), eq(db.t.c, "x"))`, []colCond{{col: "a", op: ">=", val: "1", num: 1, isNum: true},
+			{col: "a", op: ">", val: "10", num: 10, isNum: true}, {col: "b", op: "is not null"}, {col: "c", op: "=", val: "x"}}},
+		{"", nil},
+	}
+	for _, c := range cases {
+		conds, err := parseConds(c.cond)
+		if err != nil {
+			t.Fatalf("%v: %v", c.cond, err)
+		}
+		if !reflect.DeepEqual(conds, c.conds) {
+			t.Fatalf("%v: expected %+v, got %+v", c.cond, c.conds, conds)
+		}
+	}
+	for _, cond := range []string{"a=1 OR a=2", "a IN (1, 2)", "a=b", "or(eq(db.t.a, 1), eq(db.t.a, 2))", "a LIKE 'x%'"} {
+		if _, err := parseConds(cond); err == nil {
+			t.Fatalf("%v should be unsupported", cond)
+		}
+	}
+}
+
+func newBaselineTestInstance() *tidb.FakeInstance {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	ins.OnExactQuery("SELECT COUNT(*) FROM db.`t`").Return([]string{"COUNT(*)"}, []interface{}{10})
+	ins.OnExactQuery("SELECT COUNT(a), COUNT(DISTINCT a), MIN(a), MAX(a) FROM db.`t`").
+		Return([]string{"COUNT(a)", "COUNT(DISTINCT a)", "MIN(a)", "MAX(a)"}, []interface{}{8, 4, "1", "10"})
+	ins.OnExactQuery("SELECT a, COUNT(*) FROM db.`t` GROUP BY a").Return([]string{"a", "COUNT(*)"},
+		[]interface{}{nil, 2}, []interface{}{"1", 4}, []interface{}{"2", 2}, []interface{}{"5", 1}, []interface{}{"10", 1})
+	rows := [][]interface{}{{nil, "x"}, {nil, "y"}, {"1", "x"}, {"1", "x"}, {"1", "x"}, {"1", "y"}, {"2", "y"}, {"2", "y"},
+		{"5", "y"}, {"10", "z"}}
+	ins.OnExactQuery("SELECT * FROM db.`t`").Return([]string{"a", "b"}, rows...)
+	return ins
+}
+
+func TestBaselineEstimators(t *testing.T) {
+	ins := newBaselineTestInstance()
+	expected := map[string][]float64{ // estimations of queries below
+		EstimatorUniform: {2, 8 * 5 / 9.0, 2, 0, 8},
+		EstimatorOracle:  {4, 2, 2, 0, 8},
+		EstimatorSample:  {4, 2, 2, 0, 8},
+	}
+	queries := []string{
+		"SELECT * FROM db.`t` WHERE a=1",
+		"SELECT * FROM db.`t` WHERE a>=5",
+		"SELECT * FROM db.`t` WHERE a IS NULL",
+		"SELECT * FROM db.`t` WHERE a=20",
+		"SELECT * FROM db.`t` WHERE a IS NOT NULL",
+	}
+	for name, ests := range expected {
+		est, err := NewBaselineEstimator(name, ins, BaselineConf{SampleRate: 1}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if est.Name() != "baseline-"+name {
+			t.Fatalf("unexpected name %v", est.Name())
+		}
+		for i, q := range queries {
+			card, err := est.Estimate(q)
+			if err != nil {
+				t.Fatalf("%v %v: %v", name, q, err)
+			}
+			if math.Abs(card-ests[i]) > 1e-6 {
+				t.Fatalf("%v %v: expected %v, got %v", name, q, ests[i], card)
+			}
+		}
+	}
+
+	// the sample keeps correlations between columns, but the oracle assumes they're independent
+	sample, _ := NewBaselineEstimator(EstimatorSample, ins, BaselineConf{SampleRate: 1}, 1)
+	if card, err := sample.Estimate("SELECT * FROM db.`t` WHERE a=1 AND b='x'"); err != nil || card != 3 {
+		t.Fatalf("expected 3, got %v, %v", card, err)
+	}
+	if _, err := sample.Estimate("SELECT * FROM db.`t` WHERE c=1"); err == nil {
+		t.Fatalf("unknown columns should fail")
+	}
+}
+
+func TestAddBaselines(t *testing.T) {
+	ins := newBaselineTestInstance()
+	opt := Option{
+		QueryTypes: []QueryType{QTSingleColPointQueryOnCol},
+		Datasets:   []DatasetOpt{{Label: "t"}},
+		Instances:  []tidb.Option{{Label: "fake"}},
+		Baseline:   BaselineConf{Estimators: []string{EstimatorOracle}},
+	}
+	collector := NewEstResultCollector(1, 1, 1)
+	collector.AddEstResult(0, 0, 0, EstResult{SQL: "SELECT * FROM db.`t` WHERE a=2", EstCard: 1, TrueCard: 2, FreqClass: "topn"})
+	collector.AddEstResult(0, 0, 0, EstResult{SQL: "SELECT * FROM db.`t` WHERE a=1 OR a=2", EstCard: 1, TrueCard: 6})
+	newOpt, newCollector, err := addBaselines(opt, ins, collector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(newOpt.Instances) != 2 || newOpt.Instances[1].Label != "baseline-oracle" || len(opt.Instances) != 1 {
+		t.Fatalf("unexpected instances %v", newOpt.Instances)
+	}
+	if rs := newCollector.EstResults(0, 0, 0); len(rs) != 2 {
+		t.Fatalf("unexpected results of the instance %v", rs)
+	}
+	rs := newCollector.EstResults(1, 0, 0)
+	expected := []EstResult{{SQL: "SELECT * FROM db.`t` WHERE a=2", EstCard: 2, TrueCard: 2, FreqClass: "topn"}}
+	if !reflect.DeepEqual(rs, expected) { // unsupported queries are skipped
+		t.Fatalf("expected %v, got %v", expected, rs)
+	}
+	if n, ok := baselineUnsupported(newOpt, newCollector, 1, 0, 0); !ok || n != 1 {
+		t.Fatalf("expect 1 unsupported query of the baseline, got %v", n)
+	}
+	if _, ok := baselineUnsupported(newOpt, newCollector, 0, 0, 0); ok {
+		t.Fatal("the instance is not a baseline")
+	}
+}
@@ -0,0 +1,100 @@
+package cetest
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Frequency classes of values, which tell how TiDB estimates point queries on them.
+const (
+	FreqClassTopN   = "topn"   // values in TopN, estimated by their counts in TopN
+	FreqClassBucket = "bucket" // upper bounds of histogram buckets, estimated by their repeats in buckets
+	FreqClassNDV    = "ndv"    // other values, estimated by the uniform assumption on NDV
+)
+
+// freqClasses classifies distinct values ordered by their counts like the stats built by ANALYZE:
+// the most frequent topN values are in TopN, and others are put into equal-depth histogram buckets in value order.
+// It also returns the bucket of each value, which is -1 for values in TopN.
+func freqClasses(distVals []string, counts []int, tp DATATYPE, topN, buckets int) (classes []string, bucketOf []int) {
+	n := len(distVals)
+	classes, bucketOf = make([]string, n), make([]int, n)
+	if topN > n {
+		topN = n
+	}
+	for i := n - topN; i < n; i++ {
+		classes[i], bucketOf[i] = FreqClassTopN, -1
+	}
+
+	rest := n - topN
+	if rest == 0 {
+		return
+	}
+	rows := make([]int, rest) // rows out of TopN in value order
+	totRows := 0
+	for i := range rows {
+		rows[i] = i
+		totRows += counts[i]
+	}
+	if tp == DTString {
+		sort.SliceStable(rows, func(i, j int) bool { return distVals[rows[i]] < distVals[rows[j]] })
+	} else {
+		nums := make([]float64, rest)
+		for i := range nums {
+			nums[i], _ = strconv.ParseFloat(distVals[i], 64)
+		}
+		sort.SliceStable(rows, func(i, j int) bool { return nums[rows[i]] < nums[rows[j]] })
+	}
+
+	depth := float64(totRows) / float64(buckets)
+	bucket, acc := 0, 0
+	for i, rowIdx := range rows {
+		acc += counts[rowIdx]
+		classes[rowIdx], bucketOf[rowIdx] = FreqClassNDV, bucket
+		if float64(acc) >= depth*float64(bucket+1) || i == len(rows)-1 {
+			classes[rowIdx] = FreqClassBucket
+			bucket++
+		}
+	}
+	return
+}
+
+// sampleValues samples distinct values ordered by their counts by the strategy.
+func (so SampleOption) sampleValues(classes []string, bucketOf []int) []int {
+	n := len(classes)
+	nTopN := 0
+	for _, c := range classes {
+		if c == FreqClassTopN {
+			nTopN++
+		}
+	}
+
+	switch so.Strategy {
+	case SampleDecile:
+		strata := make([][]int, 10)
+		for i := 0; i < n; i++ {
+			strata[i*10/n] = append(strata[i*10/n], i)
+		}
+		return so.sampleStrata(strata)
+	case SampleTopN:
+		return so.sampleRows(n-nTopN, n)
+	case SampleTail:
+		tail := (n - nTopN) / 10
+		if tail == 0 && n > nTopN {
+			tail = 1
+		}
+		return so.sampleRows(0, tail)
+	case SampleBucket:
+		var strata [][]int
+		for i := 0; i < n; i++ {
+			if b := bucketOf[i]; b >= 0 {
+				for len(strata) <= b {
+					strata = append(strata, nil)
+				}
+				strata[b] = append(strata[b], i)
+			}
+		}
+		return so.sampleStrata(strata)
+	default:
+		return so.sampleRows(0, n)
+	}
+}
@@ -0,0 +1,71 @@
+package cetest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFreqClasses(t *testing.T) {
+	// values ordered by their counts
+	vals := []string{"5", "1", "4", "2", "3", "9"}
+	counts := []int{1, 1, 2, 2, 2, 10}
+	classes, bucketOf := freqClasses(vals, counts, DTInt, 1, 2)
+	// out of TopN in value order: 1(1), 2(2), 3(2), 4(2), 5(1), and each bucket has about 4 rows
+	expected := []string{FreqClassBucket, FreqClassNDV, FreqClassNDV, FreqClassNDV, FreqClassBucket, FreqClassTopN}
+	if !reflect.DeepEqual(classes, expected) {
+		t.Fatalf("expect classes %v, got %v", expected, classes)
+	}
+	if !reflect.DeepEqual(bucketOf, []int{1, 0, 1, 0, 0, -1}) {
+		t.Fatalf("unexpected buckets %v", bucketOf)
+	}
+
+	classes, _ = freqClasses(vals, counts, DTInt, 10, 2)
+	for _, c := range classes {
+		if c != FreqClassTopN {
+			t.Fatalf("expect all values in TopN, got %v", classes)
+		}
+	}
+}
+
+func TestSampleValues(t *testing.T) {
+	n := 1000
+	vals, counts := make([]string, n), make([]int, n)
+	for i := range vals {
+		vals[i], counts[i] = string(rune('a'+i%26))+string(rune('a'+i/26)), i+1
+	}
+	classes, bucketOf := freqClasses(vals, counts, DTString, 100, 10)
+
+	so := SampleOption{NSamples: 50, Seed: 7}
+	for _, strategy := range []string{SampleUniform, SampleDecile, SampleTopN, SampleTail, SampleBucket} {
+		so.Strategy = strategy
+		rows := so.sampleValues(classes, bucketOf)
+		if !reflect.DeepEqual(rows, so.sampleValues(classes, bucketOf)) {
+			t.Fatalf("samples of %v with the same seed are different", strategy)
+		}
+		if len(rows) == 0 {
+			t.Fatalf("no sample of %v", strategy)
+		}
+		for _, r := range rows {
+			if strategy == SampleTopN && classes[r] != FreqClassTopN {
+				t.Fatalf("sample %v of %v is not in TopN", r, strategy)
+			}
+			if strategy == SampleTail && r >= 90 {
+				t.Fatalf("sample %v of %v is not in the tail", r, strategy)
+			}
+			if strategy == SampleBucket && bucketOf[r] < 0 {
+				t.Fatalf("sample %v of %v is not in buckets", r, strategy)
+			}
+		}
+	}
+
+	so.Strategy = SampleDecile
+	perDecile := make([]int, 10)
+	for _, r := range so.sampleValues(classes, bucketOf) {
+		perDecile[r*10/n]++
+	}
+	for d, cnt := range perDecile {
+		if cnt == 0 {
+			t.Fatalf("no sample in decile %v: %v", d, perDecile)
+		}
+	}
+}
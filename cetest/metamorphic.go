@@ -0,0 +1,361 @@
+package cetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// Relations between estimations of metamorphic query pairs, which should hold for any consistent estimator.
+const (
+	RelConjunction = "conjunction" // est(p AND q) <= est(p)
+	RelWidenRange  = "widen-range" // est(range) <= est(widened range)
+	RelInOr        = "in-or"       // est(a IN (x, y)) = est(a=x OR a=y)
+	RelNegation    = "negation"    // est(p) + est(NOT p) = est(a IS NOT NULL)
+	RelTautology   = "tautology"   // est(p) = est(p AND tautology)
+)
+
+var allRelations = []string{RelConjunction, RelWidenRange, RelInOr, RelNegation, RelTautology}
+
+const (
+	// MetamorphicResultsFileName is the name of the file saving all checks of the metamorphic mode in the report dir.
+	MetamorphicResultsFileName = "metamorphic.json"
+	metamorphicReportFileName  = "metamorphic.md"
+
+	defaultMetamorphicTolerance = 0.01
+	defaultMetamorphicSamples   = 50 // values sampled from each column if n-samples is 0
+	maxReportedViolations       = 20 // violations listed in the report for each instance and relation
+)
+
+// MetamorphicConf is the metamorphic section in configs.
+type MetamorphicConf struct {
+	Relations []string `toml:"relations"` // all relations by default
+	Tolerance float64  `toml:"tolerance"` // the relative tolerance of comparing estimations, 0.01 by default
+}
+
+func checkMetamorphic(conf MetamorphicConf) error {
+	for _, rel := range conf.Relations {
+		if !inStrings(allRelations, rel) {
+			return errors.Errorf("unknown metamorphic relation=%v", rel)
+		}
+	}
+	if conf.Tolerance < 0 {
+		return errors.Errorf("invalid metamorphic tolerance=%v", conf.Tolerance)
+	}
+	return nil
+}
+
+func inStrings(strs []string, s string) bool {
+	for _, x := range strs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// MetamorphicCheck is a pair of related queries and their estimations.
+type MetamorphicCheck struct {
+	Instance string  `json:"instance"`
+	Dataset  string  `json:"dataset"`
+	Relation string  `json:"relation"`
+	SQL1     string  `json:"sql1"`
+	SQL2     string  `json:"sql2"`
+	Est1     float64 `json:"est1"`
+	Est2     float64 `json:"est2"`
+	RefSQL   string  `json:"ref-sql,omitempty"` // the query of IS NOT NULL in the negation relation
+	RefEst   float64 `json:"ref-est,omitempty"`
+	Violated bool    `json:"violated"`
+}
+
+// expectation describes the relation of the check, like "est1 <= est2".
+func (c MetamorphicCheck) expectation() string {
+	switch c.Relation {
+	case RelConjunction, RelWidenRange:
+		return "est1 <= est2"
+	case RelNegation:
+		return "est1 + est2 = ref-est"
+	}
+	return "est1 = est2"
+}
+
+// violated tells whether estimations violate the relation, where estimations are considered equal if their
+// difference is within the relative tolerance or 0.01, since estimations in EXPLAIN are rounded to 2 decimals.
+func (c MetamorphicCheck) violated(tolerance float64) bool {
+	slack := func(a, b float64) float64 {
+		return math.Max(0.01, tolerance*math.Max(math.Abs(a), math.Abs(b)))
+	}
+	switch c.Relation {
+	case RelConjunction, RelWidenRange:
+		return c.Est1 > c.Est2+slack(c.Est1, c.Est2)
+	case RelNegation:
+		return math.Abs(c.Est1+c.Est2-c.RefEst) > slack(c.Est1+c.Est2, c.RefEst)
+	}
+	return math.Abs(c.Est1-c.Est2) > slack(c.Est1, c.Est2)
+}
+
+// metamorphicQuerier is implemented by datasets with single column queries, whose columns and values are used to
+// generate metamorphic queries.
+type metamorphicQuerier interface {
+	singleColQuerier() *singleColQuerier
+}
+
+func (ds *datasetBase) singleColQuerier() *singleColQuerier {
+	return ds.scq
+}
+
+// metamorphicChecks generates pairs of queries of the relations on all columns of the querier.
+// Values of each column are sampled, and conjunctions use another column of the same table if there is one.
+func (tv *singleColQuerier) metamorphicChecks(ins tidb.Instance, so SampleOption, relations []string) ([]MetamorphicCheck, error) {
+	if err := tv.init(ins); err != nil {
+		return nil, err
+	}
+	if so.NSamples == 0 {
+		so.NSamples = defaultMetamorphicSamples
+	}
+	rng := rand.New(rand.NewSource(so.Seed))
+	var checks []MetamorphicCheck
+	for tbIdx, tb := range tv.tbs {
+		tbl := tableName(ins, tv.db, tb)
+		query := func(cond string) string {
+			return fmt.Sprintf("SELECT * FROM %v WHERE %v", tbl, cond)
+		}
+		for colIdx, col := range tv.cols[tbIdx] {
+			vals := quotableValues(sortedDistVals(tv.orderedDistVals[tbIdx][colIdx], tv.colTypes[tbIdx][colIdx]))
+			if len(vals) == 0 {
+				continue
+			}
+			lit := func(v string) string {
+				return fmt.Sprintf(tv.colPlaceHolder(tbIdx, colIdx), v)
+			}
+			other, otherVals := col, vals // the column used by conjunctions and tautologies
+			otherLit := lit
+			if nCols := len(tv.cols[tbIdx]); nCols > 1 {
+				otherIdx := (colIdx + 1) % nCols
+				if ovs := quotableValues(tv.orderedDistVals[tbIdx][otherIdx]); len(ovs) > 0 {
+					other, otherVals = tv.cols[tbIdx][otherIdx], ovs
+					otherLit = func(v string) string {
+						return fmt.Sprintf(tv.colPlaceHolder(tbIdx, otherIdx), v)
+					}
+				}
+			}
+
+			for _, k := range so.sampleRows(0, len(vals)) {
+				v := lit(vals[k])
+				for _, rel := range relations {
+					c := MetamorphicCheck{Relation: rel}
+					switch rel {
+					case RelConjunction:
+						c.SQL1 = query(fmt.Sprintf("%v>=%v AND %v=%v", col, v, other, otherLit(otherVals[rng.Intn(len(otherVals))])))
+						c.SQL2 = query(fmt.Sprintf("%v>=%v", col, v))
+					case RelWidenRange:
+						width := 1 + rng.Intn(len(vals)/10+1)
+						hi := minInt(k+width, len(vals)-1)
+						wideLo, wideHi := maxInt(k-1-rng.Intn(width), 0), minInt(hi+1+rng.Intn(width), len(vals)-1)
+						c.SQL1 = query(fmt.Sprintf("%v>=%v AND %v<=%v", col, v, col, lit(vals[hi])))
+						c.SQL2 = query(fmt.Sprintf("%v>=%v AND %v<=%v", col, lit(vals[wideLo]), col, lit(vals[wideHi])))
+					case RelInOr:
+						w := lit(vals[rng.Intn(len(vals))])
+						c.SQL1 = query(fmt.Sprintf("%v IN (%v, %v)", col, v, w))
+						c.SQL2 = query(fmt.Sprintf("(%v=%v OR %v=%v)", col, v, col, w))
+					case RelNegation:
+						p := fmt.Sprintf("%v=%v", col, v)
+						if k%2 == 1 { // ranges for half of values
+							p = fmt.Sprintf("%v<=%v", col, v)
+						}
+						c.SQL1, c.SQL2 = query(p), query(fmt.Sprintf("NOT (%v)", p))
+						c.RefSQL = query(fmt.Sprintf("%v IS NOT NULL", col))
+					case RelTautology:
+						p := fmt.Sprintf("%v=%v", col, v)
+						c.SQL1 = query(p)
+						c.SQL2 = query(fmt.Sprintf("%v AND (%v IS NULL OR %v IS NOT NULL)", p, other, other))
+					}
+					checks = append(checks, c)
+				}
+			}
+		}
+	}
+	return checks, nil
+}
+
+// quotableValues removes values that cannot be quoted in SQL directly.
+func quotableValues(vals []string) []string {
+	var qvs []string
+	for _, v := range vals {
+		if !strings.ContainsAny(v, `'\`) {
+			qvs = append(qvs, v)
+		}
+	}
+	return qvs
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// runMetamorphicChecks estimates queries of checks by the estimator concurrently, and decides whether they are violated.
+// Checks whose queries cannot be estimated are skipped.
+func runMetamorphicChecks(est Estimator, checks []MetamorphicCheck, tolerance float64) []MetamorphicCheck {
+	results := make([]*MetamorphicCheck, len(checks))
+	concurrency := 16
+	var wg sync.WaitGroup
+	for workerID := 0; workerID < concurrency; workerID++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := id; i < len(checks); i += concurrency {
+				c := checks[i]
+				var err error
+				if c.Est1, err = est.Estimate(c.SQL1); err == nil {
+					if c.Est2, err = est.Estimate(c.SQL2); err == nil && c.RefSQL != "" {
+						c.RefEst, err = est.Estimate(c.RefSQL)
+					}
+				}
+				if err != nil {
+					fmt.Printf("[CETest-Metamorphic] estimator=%v, relation=%v, skip the check: %v\n", est.Name(), c.Relation, err)
+					continue
+				}
+				c.Instance = est.Name()
+				c.Violated = c.violated(tolerance)
+				results[i] = &c
+			}
+		}(workerID)
+	}
+	wg.Wait()
+
+	var done []MetamorphicCheck
+	for _, r := range results {
+		if r != nil {
+			done = append(done, *r)
+		}
+	}
+	return done
+}
+
+// RunCETestMetamorphicMode checks whether estimations of related queries on columns of datasets are consistent on
+// all instances, and reports violations with their query pairs.
+func RunCETestMetamorphicMode(opt Option) error {
+	opt.Seed = ResolveSeed(opt.Seed)
+	fmt.Printf("[CETest] seed=%v\n", opt.Seed)
+	if err := tidb.UseSeed(opt.Seed); err != nil {
+		return err
+	}
+	so := opt.Sample.sampleOption(opt.NSamples, opt.Seed)
+	relations := opt.Metamorphic.Relations
+	if len(relations) == 0 {
+		relations = allRelations
+	}
+	tolerance := opt.Metamorphic.Tolerance
+	if tolerance == 0 {
+		tolerance = defaultMetamorphicTolerance
+	}
+
+	instances, err := tidb.ConnectToInstances(opt.Instances)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() {
+		for _, ins := range instances {
+			ins.Close()
+		}
+	}()
+
+	var checks []MetamorphicCheck
+	for _, ins := range instances {
+		for _, tbl := range opt.AnaTables {
+			if err := ins.Exec(analyzeTableSQL(ins, tbl)); err != nil {
+				return err
+			}
+		}
+		for _, dsOpt := range opt.Datasets {
+			ds, ok := datasetMap[dsOpt.Name](dsOpt).(metamorphicQuerier)
+			if !ok {
+				return errors.Errorf("dataset %v doesn't support metamorphic mode", dsOpt.Name)
+			}
+			dsChecks, err := ds.singleColQuerier().metamorphicChecks(ins, so, relations)
+			if err != nil {
+				return err
+			}
+			for _, c := range runMetamorphicChecks(NewInstanceEstimator(ins), dsChecks, tolerance) {
+				c.Dataset = dsOpt.Label
+				checks = append(checks, c)
+			}
+		}
+	}
+	return saveMetamorphicResults(opt, relations, checks)
+}
+
+// saveMetamorphicResults saves all checks into metamorphic.json, and a summary with violations into metamorphic.md.
+func saveMetamorphicResults(opt Option, relations []string, checks []MetamorphicCheck) error {
+	if err := os.MkdirAll(opt.ReportDir, 0777); err != nil {
+		return errors.Trace(err)
+	}
+	data, err := json.MarshalIndent(checks, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := ioutil.WriteFile(path.Join(opt.ReportDir, MetamorphicResultsFileName), data, 0666); err != nil {
+		return errors.Trace(err)
+	}
+
+	md := bytes.Buffer{}
+	md.WriteString("# Metamorphic Checks\n\n")
+	md.WriteString("| Instance | Dataset | Relation | Expectation | Checks | Violations |\n")
+	md.WriteString("| ---- | ---- | ---- | ---- | ---- | ---- |\n")
+	var violations []MetamorphicCheck
+	for _, ins := range opt.Instances {
+		for _, ds := range opt.Datasets {
+			for _, rel := range relations {
+				total, violated := 0, 0
+				for _, c := range checks {
+					if c.Instance != ins.Label || c.Dataset != ds.Label || c.Relation != rel {
+						continue
+					}
+					total++
+					if c.Violated {
+						violated++
+						if violated <= maxReportedViolations {
+							violations = append(violations, c)
+						}
+					}
+				}
+				md.WriteString(fmt.Sprintf("| %v | %v | %v | %v | %v | %v |\n", ins.Label, ds.Label, rel,
+					MetamorphicCheck{Relation: rel}.expectation(), total, violated))
+			}
+		}
+	}
+	if len(violations) > 0 {
+		md.WriteString(fmt.Sprintf("\n## Violations\n\nAt most %v violations of each instance, dataset and relation are listed, and all checks are in %v.\n",
+			maxReportedViolations, MetamorphicResultsFileName))
+		md.WriteString("\n| Instance | Relation | SQL1 | Est1 | SQL2 | Est2 | Ref-Est |\n")
+		md.WriteString("| ---- | ---- | ---- | ---- | ---- | ---- | ---- |\n")
+		for _, c := range violations {
+			ref := "-"
+			if c.RefSQL != "" {
+				ref = fmt.Sprintf("%.2f", c.RefEst)
+			}
+			md.WriteString(fmt.Sprintf("| %v | %v | %v | %.2f | %v | %.2f | %v |\n", c.Instance, c.Relation, c.SQL1, c.Est1, c.SQL2, c.Est2, ref))
+		}
+	}
+	return errors.Trace(ioutil.WriteFile(path.Join(opt.ReportDir, metamorphicReportFileName), md.Bytes(), 0666))
+}
@@ -0,0 +1,105 @@
+package cetest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func TestMetamorphicViolated(t *testing.T) {
+	cases := []struct {
+		check    MetamorphicCheck
+		violated bool
+	}{
+		{MetamorphicCheck{Relation: RelConjunction, Est1: 10, Est2: 20}, false},
+		{MetamorphicCheck{Relation: RelConjunction, Est1: 20.1, Est2: 20}, false}, // within the tolerance
+		{MetamorphicCheck{Relation: RelConjunction, Est1: 30, Est2: 20}, true},
+		{MetamorphicCheck{Relation: RelWidenRange, Est1: 0.01, Est2: 0}, false}, // rounded estimations
+		{MetamorphicCheck{Relation: RelInOr, Est1: 10, Est2: 10}, false},
+		{MetamorphicCheck{Relation: RelInOr, Est1: 10, Est2: 12}, true},
+		{MetamorphicCheck{Relation: RelTautology, Est1: 12, Est2: 10}, true},
+		{MetamorphicCheck{Relation: RelNegation, Est1: 30, Est2: 70, RefEst: 100}, false},
+		{MetamorphicCheck{Relation: RelNegation, Est1: 30, Est2: 80, RefEst: 100}, true},
+	}
+	for _, c := range cases {
+		if v := c.check.violated(0.01); v != c.violated {
+			t.Fatalf("%+v: expected violated=%v, got %v", c.check, c.violated, v)
+		}
+	}
+	if err := checkMetamorphic(MetamorphicConf{Relations: []string{"widen"}}); err == nil {
+		t.Fatalf("unknown relations should fail")
+	}
+}
+
+// funcEstimator estimates queries by a function.
+type funcEstimator func(query string) (float64, error)
+
+func (f funcEstimator) Name() string {
+	return "func"
+}
+
+func (f funcEstimator) Estimate(query string) (float64, error) {
+	return f(query)
+}
+
+func TestMetamorphicChecks(t *testing.T) {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	ins.OnExactQuery("SELECT a, COUNT(*) FROM db.`t` where a is not null GROUP BY a ORDER BY COUNT(*)").
+		Return([]string{"a", "COUNT(*)"}, []interface{}{"3", 1}, []interface{}{"1", 2}, []interface{}{"2", 5})
+	ins.OnExactQuery("SELECT b, COUNT(*) FROM db.`t` where b is not null GROUP BY b ORDER BY COUNT(*)").
+		Return([]string{"b", "COUNT(*)"}, []interface{}{"it's", 1}, []interface{}{"x", 4})
+
+	q := newSingleColQuerier("db", []string{"t"}, [][]string{{"a", "b"}}, [][]DATATYPE{{DTInt, DTString}}, nil)
+	checks, err := q.metamorphicChecks(ins, SampleOption{Seed: 1}, allRelations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 values of a and 1 quotable value of b
+	if len(checks) != 4*len(allRelations) {
+		t.Fatalf("expected %v checks, got %v", 4*len(allRelations), len(checks))
+	}
+	for _, c := range checks {
+		if strings.Contains(c.SQL1+c.SQL2, "it's") {
+			t.Fatalf("unquotable values should be skipped: %+v", c)
+		}
+		if !strings.HasPrefix(c.SQL1, "SELECT * FROM db.`t` WHERE ") {
+			t.Fatalf("unexpected query %v", c.SQL1)
+		}
+		if c.Relation == RelNegation && c.RefSQL == "" {
+			t.Fatalf("negation checks need the reference query: %+v", c)
+		}
+	}
+
+	// an estimator ignoring IN lists and conjunctions violates in-or and conjunction relations
+	est := funcEstimator(func(query string) (float64, error) {
+		switch {
+		case strings.Contains(query, "IS NULL OR"):
+			return 0, errors.New("unsupported")
+		case strings.Contains(query, " IN ("), strings.Contains(query, " AND b="):
+			return 100, nil
+		case strings.Contains(query, "NOT ("):
+			return 8 - 2, nil
+		case strings.Contains(query, "IS NOT NULL"):
+			return 8, nil
+		}
+		return 2, nil
+	})
+	violations := make(map[string]int)
+	results := runMetamorphicChecks(est, checks, 0.01)
+	for _, c := range results {
+		if c.Instance != "func" {
+			t.Fatalf("unexpected instance %v", c.Instance)
+		}
+		if c.Violated {
+			violations[c.Relation]++
+		}
+	}
+	if len(results) != 4*(len(allRelations)-1) { // tautology checks are skipped
+		t.Fatalf("expected %v results, got %v", 4*(len(allRelations)-1), len(results))
+	}
+	if violations[RelInOr] != 4 || violations[RelConjunction] != 3 || violations[RelNegation] != 0 || violations[RelWidenRange] != 0 {
+		t.Fatalf("unexpected violations %v", violations)
+	}
+}
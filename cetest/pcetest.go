@@ -3,8 +3,6 @@ package cetest
 import (
 	"fmt"
 	"io/ioutil"
-	"math"
-	"sort"
 	"strings"
 
 	"github.com/BurntSushi/toml"
@@ -12,15 +10,28 @@ import (
 	"github.com/qw4990/OptimizerTester/tidb"
 )
 
+const (
+	PruneModeStatic  = "static"  // queries are estimated with partition-level stats
+	PruneModeDynamic = "dynamic" // queries are estimated with global stats
+)
+
+// POption is the option of the partition mode, which tests query types of a dataset on its table and partitioned
+// copies of the table, under static and dynamic partition prune modes.
 type POption struct {
-	ReportDir string    `toml:"report-dir"`
-	AnaTables []string  `toml:"analyze-tables"`
-	NSamples  int       `toml:"n-samples"`
-	QueryType QueryType `toml:"query-type"`
-	Dataset   string    `toml:"dataset"`
-	DB        string    `toml:"db"`
-	Tables    []string  `toml:"tables"`
-	Labels    []string  `toml:"labels"`
+	ReportDir  string      `toml:"report-dir"`
+	AnaTables  []string    `toml:"analyze-tables"`
+	NSamples   int         `toml:"n-samples"`
+	Sample     SampleConf  `toml:"sample"`
+	Seed       int64       `toml:"seed"`       // a random seed is used if it's 0
+	QueryType  QueryType   `toml:"query-type"` // used if query-types is not specified
+	QueryTypes []QueryType `toml:"query-types"`
+	Dataset    string      `toml:"dataset"`
+	DB         string      `toml:"db"`
+	Args       []string    `toml:"args"` // args of the dataset, errors are ignored if it's not specified
+	Tables     []string    `toml:"tables"`
+	Labels     []string    `toml:"labels"`
+	PruneModes []string    `toml:"prune-modes"` // static and dynamic by default
+	Reports    []string    `toml:"reports"`     // perror-bar and qerror-box by default
 
 	Instance tidb.Option `toml:"instance"`
 }
@@ -30,32 +41,167 @@ func DecodePOption(content string) (POption, error) {
 	if _, err := toml.Decode(content, &opt); err != nil {
 		return POption{}, errors.Trace(err)
 	}
+	opt.Dataset = strings.ToLower(opt.Dataset)
+	if _, ok := datasetMap[opt.Dataset]; !ok {
+		return POption{}, errors.Errorf("unknown dataset=%v", opt.Dataset)
+	}
+	if len(opt.QueryTypes) == 0 {
+		opt.QueryTypes = []QueryType{opt.QueryType}
+	}
+	if opt.Args == nil {
+		opt.Args = []string{"error=ignore"}
+	}
+	if len(opt.Labels) == 0 {
+		opt.Labels = opt.Tables
+	}
+	if len(opt.Labels) != len(opt.Tables) {
+		return POption{}, errors.Errorf("%v labels for %v tables", len(opt.Labels), len(opt.Tables))
+	}
+	if len(opt.PruneModes) == 0 {
+		opt.PruneModes = []string{PruneModeStatic, PruneModeDynamic}
+	}
+	if err := checkReports(opt.Reports); err != nil {
+		return POption{}, err
+	}
+	if err := checkSampleStrategy(opt.Sample.Strategy); err != nil {
+		return POption{}, err
+	}
+	for i, mode := range opt.PruneModes {
+		opt.PruneModes[i] = strings.ToLower(mode)
+		if opt.PruneModes[i] != PruneModeStatic && opt.PruneModes[i] != PruneModeDynamic {
+			return POption{}, errors.Errorf("unknown prune-mode=%v", mode)
+		}
+	}
 	return opt, nil
 }
 
-func RunCETestPartitionModeWithConfig(confPath string) error {
+// ReadPOption reads and decodes the partition mode option from the config file.
+func ReadPOption(confPath string) (POption, error) {
 	confContent, err := ioutil.ReadFile(confPath)
 	if err != nil {
-		return errors.Trace(err)
+		return POption{}, errors.Trace(err)
 	}
-	opt, err := DecodePOption(string(confContent))
+	return DecodePOption(string(confContent))
+}
+
+func RunCETestPartitionModeWithConfig(confPath string) error {
+	opt, err := ReadPOption(confPath)
 	if err != nil {
 		return err
 	}
+	return RunCETestPartitionMode(opt)
+}
+
+// groupLabel is the label of results on the table under the prune mode.
+func (opt POption) groupLabel(tblIdx int, mode string) string {
+	return fmt.Sprintf("%v (%v)", opt.Labels[tblIdx], mode)
+}
+
+// reportOption describes results of the partition mode as an Option, where each group of a table and a prune mode
+// is an instance, to save and render them like normal results.
+func (opt POption) reportOption() Option {
+	ropt := Option{
+		QueryTypes: opt.QueryTypes,
+		Datasets:   []DatasetOpt{{Name: opt.Dataset, DB: opt.DB, Label: opt.Dataset, Args: opt.Args}},
+		ReportDir:  opt.ReportDir,
+		NSamples:   opt.NSamples,
+		Sample:     opt.Sample,
+		Seed:       opt.Seed,
+		Reports:    opt.Reports,
+	}
+	for _, mode := range opt.PruneModes {
+		for tblIdx := range opt.Tables {
+			ropt.Instances = append(ropt.Instances, tidb.Option{Label: opt.groupLabel(tblIdx, mode)})
+		}
+	}
+	return ropt
+}
+
+// connectWithPruneMode sets the global partition prune mode and connects to the instance again,
+// since global variables only take effect in new sessions.
+func connectWithPruneMode(insOpt tidb.Option, mode string) (tidb.Instance, error) {
+	ins, err := tidb.ConnectTo(insOpt)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := ins.ExecInNewSession(fmt.Sprintf("SET GLOBAL tidb_partition_prune_mode='%v'", mode)); err != nil {
+		ins.Close()
+		return nil, err
+	}
+	ins.Close()
+	return tidb.ConnectTo(insOpt)
+}
+
+// partitionGlobalVariables are global variables changed by the partition mode, which are restored after the test.
+var partitionGlobalVariables = []string{"tidb_partition_prune_mode", "tidb_analyze_version"}
+
+// globalVariables returns values of these global variables of the instance.
+func globalVariables(ins tidb.Instance, names []string) ([]string, error) {
+	values := make([]string, len(names))
+	for i, name := range names {
+		rows, err := ins.Query(fmt.Sprintf("SELECT @@GLOBAL.%v", name))
+		if err != nil {
+			return nil, err
+		}
+		if !rows.Next() {
+			rows.Close()
+			return nil, errors.Errorf("no value of the global variable %v", name)
+		}
+		err = rows.Scan(&values[i])
+		rows.Close()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return values, nil
+}
 
+// restoreGlobalVariables sets these global variables of the instance back to the values.
+func restoreGlobalVariables(insOpt tidb.Option, names, values []string) error {
+	ins, err := tidb.ConnectTo(insOpt)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer ins.Close()
+	for i, name := range names {
+		if err := ins.ExecInNewSession(fmt.Sprintf("SET GLOBAL %v='%v'", name, values[i])); err != nil {
+			return errors.Annotatef(err, "restore the global variable %v to %v", name, values[i])
+		}
+	}
+	return nil
+}
+
+// RunCETestPartitionMode runs the test in partition mode.
+// Global variables changed by the test are restored after it.
+func RunCETestPartitionMode(opt POption) (err error) {
+	opt.Seed = ResolveSeed(opt.Seed)
+	fmt.Printf("[CETest] seed=%v\n", opt.Seed)
+	if err := tidb.UseSeed(opt.Seed); err != nil {
+		return err
+	}
+	so := opt.Sample.sampleOption(opt.NSamples, opt.Seed)
+
+	// analyze tables in the dynamic mode to build both partition-level and global stats
 	ins, err := tidb.ConnectTo(opt.Instance)
 	if err != nil {
 		return errors.Trace(err)
 	}
-	defer func() {
+	oldValues, err := globalVariables(ins, partitionGlobalVariables)
+	if err != nil {
 		ins.Close()
+		return err
+	}
+	defer func() {
+		if restoreErr := restoreGlobalVariables(opt.Instance, partitionGlobalVariables, oldValues); err == nil {
+			err = restoreErr
+		}
 	}()
-
-	// enable dynamic pruning
-	if err := ins.ExecInNewSession("SET GLOBAL tidb_analyze_version=2"); err != nil {
+	err = ins.ExecInNewSession("SET GLOBAL tidb_analyze_version=2")
+	ins.Close()
+	if err != nil {
 		return err
 	}
-	if err := ins.ExecInNewSession("SET GLOBAL tidb_partition_prune_mode='dynamic'"); err != nil {
+	if ins, err = connectWithPruneMode(opt.Instance, PruneModeDynamic); err != nil {
 		return err
 	}
 	for _, tbl := range opt.AnaTables {
@@ -65,137 +211,53 @@ func RunCETestPartitionModeWithConfig(confPath string) error {
 			panic(fmt.Sprintf("sql=%v, err=%v", sql, err))
 		}
 	}
+	ins.Close()
 
-	collector := NewPEstResultCollector()
-	switch strings.ToLower(opt.Dataset) {
-	case "imdb":
-		switch opt.QueryType {
-		case QTSingleColPointQueryOnCol:
-			for _, tbl := range opt.Tables {
-				querier := newSingleColQuerier(opt.DB, []string{tbl}, [][]string{{"phonetic_code"}},
-					[][]DATATYPE{{DTString}}, map[QueryType][2]int{
-						QTSingleColPointQueryOnCol: {0, 0}, // SELECT * FROM title WHERE phonetic_code=?
-					})
-				ers, err := querier.Collect(opt.NSamples, QTSingleColPointQueryOnCol, nil, ins, true)
-				if err != nil {
-					return err
-				}
-				collector.AppendEstResults(ers)
-			}
-		case QTSingleColPointQueryOnIndex:
-			for _, tbl := range opt.Tables {
-				querier := newSingleColQuerier(opt.DB, []string{tbl}, [][]string{{"person_id"}},
-					[][]DATATYPE{{DTInt}}, map[QueryType][2]int{
-						QTSingleColPointQueryOnIndex: {0, 0}, // SELECT * FROM cast_info WHERE person_id=?
-					})
-				ers, err := querier.Collect(opt.NSamples, QTSingleColPointQueryOnIndex, nil, ins, true)
-				if err != nil {
-					return err
-				}
-				collector.AppendEstResults(ers)
-			}
-		case QTMulColsPointQueryOnIndex, QTMulColsRangeQueryOnIndex:
-			for _, tbl := range opt.Tables {
-				querier := newMulColIndexQuerier(opt.DB, []string{"TITLE_production_year_episode_of_id_IDX"},
-					[]string{tbl}, [][]string{{"production_year", "episode_of_id"}}, [][]DATATYPE{{DTInt, DTInt}},
-					map[QueryType]int{
-						opt.QueryType: 0,
-					})
-				ers, err := querier.Collect(opt.NSamples, opt.QueryType, nil, ins, true)
-				if err != nil {
-					return err
-				}
-				collector.AppendEstResults(ers)
-			}
-		default:
-			return fmt.Errorf("unsupported query type %v for imdb", opt.QueryType)
-		}
-	case "pzipfx":
-		switch opt.QueryType {
-		case QTSingleColPointQueryOnCol:
-			for _, tbl := range opt.Tables {
-				querier := newSingleColQuerier(opt.DB, []string{tbl}, [][]string{{"c"}},
-					[][]DATATYPE{{DTInt}}, map[QueryType][2]int{
-						// select * from p-{part-type}-zint-{part-cols} where c = ?
-						QTSingleColPointQueryOnCol: {0, 0},
-					})
-				ers, err := querier.Collect(opt.NSamples, QTSingleColPointQueryOnCol, nil, ins, true)
-				if err != nil {
-					return err
-				}
-				collector.AppendEstResults(ers)
-			}
-		case QTSingleColMCVPointOnCol:
-			for _, tbl := range opt.Tables {
-				querier := newSingleColQuerier(opt.DB, []string{tbl}, [][]string{{"c"}},
-					[][]DATATYPE{{DTInt}}, map[QueryType][2]int{
-						// select * from p-{part-type}-zint-{part-cols} where c = ?
-						QTSingleColMCVPointOnCol: {0, 0},
-					})
-				ers, err := querier.Collect(opt.NSamples, QTSingleColMCVPointOnCol, nil, ins, true)
-				if err != nil {
-					return err
-				}
-				collector.AppendEstResults(ers)
-			}
-		case QTSingleColPointQueryOnIndex:
-			for _, tbl := range opt.Tables {
-				querier := newSingleColQuerier(opt.DB, []string{tbl}, [][]string{{"a"}},
-					[][]DATATYPE{{DTInt}}, map[QueryType][2]int{
-						// select * from p-{part-type}-zint-{part-cols} where a = ?
-						QTSingleColPointQueryOnIndex: {0, 0},
-					})
-				ers, err := querier.Collect(opt.NSamples, QTSingleColPointQueryOnIndex, nil, ins, true)
-				if err != nil {
-					return err
-				}
-				collector.AppendEstResults(ers)
-			}
-		case QTSingleColMCVPointOnIndex:
-			for _, tbl := range opt.Tables {
-				querier := newSingleColQuerier(opt.DB, []string{tbl}, [][]string{{"a"}},
-					[][]DATATYPE{{DTInt}}, map[QueryType][2]int{
-						// select * from p-{part-type}-zint-{part-cols} where a = ?
-						QTSingleColMCVPointOnIndex: {0, 0},
-					})
-				ers, err := querier.Collect(opt.NSamples, QTSingleColMCVPointOnIndex, nil, ins, true)
-				if err != nil {
-					return err
-				}
-				collector.AppendEstResults(ers)
-			}
-		case QTMulColsPointQueryOnIndex, QTMulColsRangeQueryOnIndex:
-			for _, tbl := range opt.Tables {
-				querier := newMulColIndexQuerier(opt.DB, []string{"idx"}, []string{tbl}, [][]string{{"a", "b"}},
-					[][]DATATYPE{{DTInt, DTInt}},
-					map[QueryType]int{
-						opt.QueryType: 0,
-					})
-				ers, err := querier.Collect(opt.NSamples, opt.QueryType, nil, ins, true)
-				if err != nil {
-					return err
-				}
-				collector.AppendEstResults(ers)
-			}
-		default:
-			return fmt.Errorf("unsupported query type %v for pzipfx", opt.QueryType)
+	ropt := opt.reportOption()
+	collector := NewEstResultCollector(len(ropt.Instances), 1, len(opt.QueryTypes))
+	for modeIdx, mode := range opt.PruneModes {
+		if err := runPartitionModeWithPruneMode(opt, so, mode, func(tblIdx, qtIdx int, ers []EstResult) {
+			collector.AppendEstResults(modeIdx*len(opt.Tables)+tblIdx, 0, qtIdx, ers)
+		}); err != nil {
+			return err
 		}
-	default:
-		return fmt.Errorf("unknown dataset: %v", opt.Dataset)
 	}
 
-	if err := PGenPErrorBarChartsReport(opt, collector); err != nil {
+	if err := SaveResults(ropt, collector); err != nil {
 		return err
 	}
+	if err := RenderReports(ropt, collector); err != nil {
+		return err
+	}
+	return printTop10BadCases(ropt, collector)
+}
 
-	// print the worst 10 cases
-	for idx, label := range opt.Labels {
-		rs := collector.EstResults(idx)
-		sort.Slice(rs, func(i, j int) bool {
-			return math.Abs(PError(rs[i])) > math.Abs(PError(rs[j]))
+func runPartitionModeWithPruneMode(opt POption, so SampleOption, mode string, collect func(tblIdx, qtIdx int, ers []EstResult)) error {
+	insOpt := opt.Instance
+	insOpt.Label = fmt.Sprintf("%v-%v", insOpt.Label, mode)
+	ins, err := connectWithPruneMode(insOpt, mode)
+	if err != nil {
+		return err
+	}
+	defer ins.Close()
+
+	for tblIdx, tbl := range opt.Tables {
+		ds := datasetMap[opt.Dataset](DatasetOpt{Name: opt.Dataset, DB: opt.DB, Label: opt.groupLabel(tblIdx, mode), Args: opt.Args})
+		pds, ok := ds.(interface {
+			onTable(tbl string, qts []QueryType) error
 		})
-		for i := 0; i < 10 && i < len(rs); i++ {
-			fmt.Printf("[Worst-%v]: %v, perror=%v\n", label, rs[i].SQL, PError(rs[i]))
+		if !ok {
+			return errors.Errorf("dataset %v doesn't support partition mode", opt.Dataset)
+		}
+		if err := pds.onTable(tbl, opt.QueryTypes); err != nil {
+			return err
+		}
+		for qtIdx, qt := range opt.QueryTypes {
+			ers, err := ds.GenEstResults(ins, so, qt)
+			if err != nil {
+				return fmt.Errorf("GenEstResult table=%v, mode=%v, qt=%v, err=%v", tbl, mode, qt, err)
+			}
+			collect(tblIdx, qtIdx, ers)
 		}
 	}
 	return nil
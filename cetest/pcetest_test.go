@@ -0,0 +1,77 @@
+package cetest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func TestDecodePOption(t *testing.T) {
+	opt, err := DecodePOption(`
+dataset = "PZipFX"
+query-type = "single-col-point-query-on-index"
+tables = ["zint", "p_hash_zint_a"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(opt.QueryTypes, []QueryType{QTSingleColPointQueryOnIndex}) {
+		t.Fatalf("unexpected query types %v", opt.QueryTypes)
+	}
+	if !reflect.DeepEqual(opt.Labels, opt.Tables) || !reflect.DeepEqual(opt.PruneModes, []string{PruneModeStatic, PruneModeDynamic}) {
+		t.Fatalf("unexpected default labels %v or prune modes %v", opt.Labels, opt.PruneModes)
+	}
+	if labels := opt.reportOption().Instances; len(labels) != 4 || labels[1].Label != "p_hash_zint_a (static)" ||
+		labels[3].Label != "p_hash_zint_a (dynamic)" {
+		t.Fatalf("unexpected groups %v", labels)
+	}
+
+	if _, err := DecodePOption(`dataset = "pzipfx"
+prune-modes = ["static", "auto"]`); err == nil {
+		t.Fatal("expect an error for the unknown prune mode")
+	}
+	if _, err := DecodePOption(`dataset = "pzipfx"
+tables = ["zint"]
+labels = ["a", "b"]`); err == nil {
+		t.Fatal("expect an error for mismatched labels")
+	}
+}
+
+func TestDatasetOnTable(t *testing.T) {
+	ds := newDatasetIMDB(DatasetOpt{Name: "imdb", DB: "imdb"}).(*datasetIMDB)
+	if err := ds.onTable("p-title-hash-production_year", []QueryType{QTSingleColPointQueryOnCol, QTMulColsRangeQueryOnIndex, QTMulColsPointQueryOnIndex}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ds.scq.tbs, []string{"p-title-hash-production_year"}) || !reflect.DeepEqual(ds.scq.cols, [][]string{{"phonetic_code"}}) {
+		t.Fatalf("unexpected single col querier on %v, %v", ds.scq.tbs, ds.scq.cols)
+	}
+	if ds.scq.qMap[QTSingleColPointQueryOnCol] != [2]int{0, 0} {
+		t.Fatalf("unexpected query map %v", ds.scq.qMap)
+	}
+	if !reflect.DeepEqual(ds.mciq.indexTables, []string{"p-title-hash-production_year"}) || len(ds.mciq.indexCols) != 1 {
+		t.Fatalf("unexpected mul col index querier on %v, %v", ds.mciq.indexTables, ds.mciq.indexCols)
+	}
+
+	// title.phonetic_code and cast_info.person_id cannot be on the same table
+	ds = newDatasetIMDB(DatasetOpt{Name: "imdb", DB: "imdb"}).(*datasetIMDB)
+	if err := ds.onTable("title", []QueryType{QTSingleColPointQueryOnCol, QTSingleColPointQueryOnIndex}); err == nil {
+		t.Fatal("expect an error for query types on different tables")
+	}
+}
+
+func TestGlobalVariables(t *testing.T) {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	ins.OnExactQuery("SELECT @@GLOBAL.tidb_partition_prune_mode").Return([]string{"v"}, []interface{}{"static"})
+	ins.OnExactQuery("SELECT @@GLOBAL.tidb_analyze_version").Return([]string{"v"}, []interface{}{"1"})
+	values, err := globalVariables(ins, partitionGlobalVariables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, []string{"static", "1"}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+	if _, err := globalVariables(ins, []string{"tidb_unknown"}); err == nil {
+		t.Fatal("expect an error for the unknown variable")
+	}
+}
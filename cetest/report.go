@@ -0,0 +1,251 @@
+package cetest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// Reports can be selected by the reports option.
+const (
+	ReportPErrorBar        = "perror-bar"          // bar charts of p-error distributions of all instances
+	ReportQErrorBox        = "qerror-box"          // box plots of q-errors of all datasets and instances
+	ReportBiasCDF          = "bias-cdf"            // CDF curves of biases of all instances
+	ReportEstVsTrueScatter = "est-vs-true-scatter" // scatter plots of estimated and true cardinalities of each instance
+)
+
+var (
+	allReports     = []string{ReportPErrorBar, ReportQErrorBox, ReportBiasCDF, ReportEstVsTrueScatter}
+	defaultReports = []string{ReportPErrorBar, ReportQErrorBox}
+)
+
+func checkReports(reports []string) error {
+	for _, r := range reports {
+		known := false
+		for _, k := range allReports {
+			known = known || r == k
+		}
+		if !known {
+			return errors.Errorf("unknown report=%v, supported reports are %v", r, allReports)
+		}
+	}
+	return nil
+}
+
+func selectedReports(opt Option) []string {
+	if len(opt.Reports) == 0 {
+		return defaultReports
+	}
+	return opt.Reports
+}
+
+// reportFileName is the name of the Markdown file of the report.
+func reportFileName(report string) string {
+	return report + ".md"
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fileNameOf joins parts like labels into a file name without characters unsafe in paths.
+func fileNameOf(suffix string, parts ...string) string {
+	for i := range parts {
+		parts[i] = unsafeFileNameChars.ReplaceAllString(parts[i], "_")
+	}
+	return strings.Join(parts, "-") + suffix
+}
+
+func barChartFileName(opt Option, qtIdx, dsIdx int) string {
+	return fileNameOf("-bar.png", opt.QueryTypes[qtIdx].String(), opt.Datasets[dsIdx].Label)
+}
+
+func boxPlotFileName(opt Option, qtIdx int) string {
+	return fileNameOf("-box-plot.png", opt.QueryTypes[qtIdx].String())
+}
+
+func biasCDFFileName(opt Option, qtIdx, dsIdx int) string {
+	return fileNameOf("-bias-cdf.png", opt.QueryTypes[qtIdx].String(), opt.Datasets[dsIdx].Label)
+}
+
+func scatterFileName(opt Option, qtIdx, dsIdx, insIdx int) string {
+	return fileNameOf("-scatter.png", opt.QueryTypes[qtIdx].String(), opt.Datasets[dsIdx].Label, opt.Instances[insIdx].Label)
+}
+
+// picturePath returns the absolute path of the picture in the report dir.
+func picturePath(opt Option, picName string) (string, error) {
+	prefixDir := opt.ReportDir
+	if !path.IsAbs(prefixDir) {
+		absPrefix, err := os.Getwd()
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		prefixDir = path.Join(absPrefix, prefixDir)
+	}
+	return path.Join(prefixDir, picName), nil
+}
+
+// RenderReports renders the reports selected by the option into the report dir, one Markdown file for each report,
+// and worst cases in worst-cases.md, errors by frequency classes in freq-class.md if values are classified,
+// errors by stats categories in stats-attribution.md if values are annotated, and index.md linking all of them.
+func RenderReports(opt Option, collector EstResultCollector) error {
+	if err := os.MkdirAll(opt.ReportDir, 0777); err != nil {
+		return errors.Trace(err)
+	}
+	reports := selectedReports(opt)
+	if err := checkReports(reports); err != nil {
+		return err
+	}
+	for _, r := range reports {
+		var err error
+		switch r {
+		case ReportPErrorBar:
+			err = GenPErrorBarChartsReport(opt, collector)
+		case ReportQErrorBox:
+			err = GenQErrorBoxPlotReport(opt, collector)
+		case ReportBiasCDF:
+			err = GenBiasCDFReport(opt, collector)
+		case ReportEstVsTrueScatter:
+			err = GenEstVsTrueScatterReport(opt, collector)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := GenWorstCasesReport(opt, collector); err != nil {
+		return err
+	}
+	var breakdowns []string
+	if hasFreqClasses(opt, collector) {
+		if err := GenFreqClassReport(opt, collector); err != nil {
+			return err
+		}
+		breakdowns = append(breakdowns, "freq-class")
+	}
+	if hasStatsAnnotations(opt, collector) {
+		if err := GenStatsAttributionReport(opt, collector); err != nil {
+			return err
+		}
+		breakdowns = append(breakdowns, "stats-attribution")
+	}
+	return genReportIndex(opt, collector, reports, breakdowns)
+}
+
+func hasFreqClasses(opt Option, collector EstResultCollector) bool {
+	return anyResult(opt, collector, func(r EstResult) bool { return r.FreqClass != "" })
+}
+
+func hasStatsAnnotations(opt Option, collector EstResultCollector) bool {
+	return anyResult(opt, collector, func(r EstResult) bool { return r.Stats != nil })
+}
+
+func anyResult(opt Option, collector EstResultCollector, f func(r EstResult) bool) bool {
+	for insIdx := range opt.Instances {
+		for dsIdx := range opt.Datasets {
+			for qtIdx := range opt.QueryTypes {
+				for _, r := range collector.EstResults(insIdx, dsIdx, qtIdx) {
+					if f(r) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// GenFreqClassReport breaks q-errors down by frequency classes of values in freq-class.md,
+// to tell whether errors come from TopN, histogram buckets or the uniform assumption on NDV.
+func GenFreqClassReport(opt Option, collector EstResultCollector) error {
+	return genBreakdownReport(opt, collector, "freq-class.md", "Class",
+		[]string{FreqClassTopN, FreqClassBucket, FreqClassNDV}, func(r EstResult) string { return r.FreqClass })
+}
+
+// GenStatsAttributionReport breaks q-errors down by how values are covered by the stats read from instances
+// in stats-attribution.md, to tell which part of TopN and histograms errors come from.
+func GenStatsAttributionReport(opt Option, collector EstResultCollector) error {
+	return genBreakdownReport(opt, collector, "stats-attribution.md", "Category", statsCategories, func(r EstResult) string {
+		if r.Stats == nil {
+			return ""
+		}
+		return r.Stats.Category()
+	})
+}
+
+// genBreakdownReport writes q-error percentiles of results in each category into the file.
+func genBreakdownReport(opt Option, collector EstResultCollector, fileName, title string, categories []string, categoryOf func(r EstResult) string) error {
+	md := bytes.Buffer{}
+	md.WriteString(fmt.Sprintf("> seed=%v\n\n", opt.Seed))
+	for qtIdx, qt := range opt.QueryTypes {
+		md.WriteString(fmt.Sprintf("# %v\n", qt))
+		for dsIdx, ds := range opt.Datasets {
+			md.WriteString(fmt.Sprintf("## %v\n", ds.Label))
+			md.WriteString(fmt.Sprintf("\n| Instance | %v | Total | P50 | P90 | P99 | Max |\n", title))
+			md.WriteString("| ---- | ---- | ---- | ---- | ---- | ---- | ---- |\n")
+			for insIdx, ins := range opt.Instances {
+				byCategory := make(map[string][]EstResult)
+				for _, r := range collector.EstResults(insIdx, dsIdx, qtIdx) {
+					byCategory[categoryOf(r)] = append(byCategory[categoryOf(r)], r)
+				}
+				for _, c := range categories {
+					qes := sortedValues(byCategory[c], QError)
+					md.WriteString(fmt.Sprintf("| %v | %v | %v | %.3f | %.3f | %.3f | %.3f |\n", ins.Label, c, len(qes),
+						percentile(qes, 0.5), percentile(qes, 0.9), percentile(qes, 0.99), percentile(qes, 1)))
+				}
+			}
+			md.WriteString("\n")
+		}
+	}
+	return errors.Trace(ioutil.WriteFile(path.Join(opt.ReportDir, fileName), md.Bytes(), 0666))
+}
+
+// genReportIndex generates index.md linking pictures of all reports of each query type, dataset and instance,
+// with counts of queries unsupported by baseline estimators.
+func genReportIndex(opt Option, collector EstResultCollector, reports, breakdowns []string) error {
+	md := bytes.Buffer{}
+	md.WriteString(fmt.Sprintf("> seed=%v\n\n", opt.Seed))
+	md.WriteString("# Reports\n\n")
+	for _, r := range reports {
+		md.WriteString(fmt.Sprintf("- [%v](%v)\n", r, reportFileName(r)))
+	}
+	md.WriteString("- [worst-cases](worst-cases.md)\n")
+	for _, b := range breakdowns {
+		md.WriteString(fmt.Sprintf("- [%v](%v)\n", b, reportFileName(b)))
+	}
+	md.WriteString("\n")
+
+	for qtIdx, qt := range opt.QueryTypes {
+		md.WriteString(fmt.Sprintf("# %v\n", qt))
+		for dsIdx, ds := range opt.Datasets {
+			md.WriteString(fmt.Sprintf("## %v\n\n", ds.Label))
+			for _, r := range reports {
+				switch r {
+				case ReportPErrorBar:
+					md.WriteString(fmt.Sprintf("- %v: [all instances](%v)\n", r, barChartFileName(opt, qtIdx, dsIdx)))
+				case ReportQErrorBox:
+					md.WriteString(fmt.Sprintf("- %v: [all datasets and instances](%v)\n", r, boxPlotFileName(opt, qtIdx)))
+				case ReportBiasCDF:
+					md.WriteString(fmt.Sprintf("- %v: [all instances](%v)\n", r, biasCDFFileName(opt, qtIdx, dsIdx)))
+				case ReportEstVsTrueScatter:
+					links := make([]string, 0, len(opt.Instances))
+					for insIdx, ins := range opt.Instances {
+						links = append(links, fmt.Sprintf("[%v](%v)", ins.Label, scatterFileName(opt, qtIdx, dsIdx, insIdx)))
+					}
+					md.WriteString(fmt.Sprintf("- %v: %v\n", r, strings.Join(links, ", ")))
+				}
+			}
+			for insIdx, ins := range opt.Instances {
+				if n, ok := baselineUnsupported(opt, collector, insIdx, dsIdx, qtIdx); ok && n > 0 {
+					md.WriteString(fmt.Sprintf("- %v: %v of %v queries are unsupported and excluded\n", ins.Label, n,
+						len(collector.EstResults(0, dsIdx, qtIdx))))
+				}
+			}
+			md.WriteString("\n")
+		}
+	}
+	return errors.Trace(ioutil.WriteFile(path.Join(opt.ReportDir, "index.md"), md.Bytes(), 0666))
+}
@@ -0,0 +1,164 @@
+package cetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// ResultsFileName is the name of the file saving raw results in the report dir.
+const ResultsFileName = "results.json"
+
+// worstCaseLimit is the number of worst cases listed for each group of results.
+const worstCaseLimit = 10
+
+// ResultsFile is the raw results of a test, which can be used to render reports again without databases.
+type ResultsFile struct {
+	Seed       int64         `json:"seed"`
+	Instances  []string      `json:"instances"`
+	Datasets   []string      `json:"datasets"`
+	QueryTypes []QueryType   `json:"query-types"`
+	Reports    []string      `json:"reports,omitempty"`
+	Results    []ResultGroup `json:"results"`
+}
+
+// ResultGroup is results of a query type on a dataset of an instance.
+// Groups are located by indices of the instance, the dataset and the query type, since labels may be empty or duplicate.
+type ResultGroup struct {
+	Instance     string      `json:"instance"`
+	Dataset      string      `json:"dataset"`
+	QueryType    QueryType   `json:"query-type"`
+	InstanceIdx  int         `json:"instance-idx"`
+	DatasetIdx   int         `json:"dataset-idx"`
+	QueryTypeIdx int         `json:"query-type-idx"`
+	EstResults   []EstResult `json:"est-results"`
+}
+
+// SaveResults saves all results in the collector into the report dir.
+func SaveResults(opt Option, collector EstResultCollector) error {
+	rf := ResultsFile{Seed: opt.Seed, QueryTypes: opt.QueryTypes, Reports: opt.Reports}
+	for _, ins := range opt.Instances {
+		rf.Instances = append(rf.Instances, ins.Label)
+	}
+	for _, ds := range opt.Datasets {
+		rf.Datasets = append(rf.Datasets, ds.Label)
+	}
+	for insIdx, ins := range opt.Instances {
+		for dsIdx, ds := range opt.Datasets {
+			for qtIdx, qt := range opt.QueryTypes {
+				rf.Results = append(rf.Results, ResultGroup{
+					Instance:     ins.Label,
+					Dataset:      ds.Label,
+					QueryType:    qt,
+					InstanceIdx:  insIdx,
+					DatasetIdx:   dsIdx,
+					QueryTypeIdx: qtIdx,
+					EstResults:   collector.EstResults(insIdx, dsIdx, qtIdx),
+				})
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(rf, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := os.MkdirAll(opt.ReportDir, 0777); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(ioutil.WriteFile(path.Join(opt.ReportDir, ResultsFileName), data, 0666))
+}
+
+// LoadResults loads results saved by SaveResults, and returns an option describing them with the report dir
+// set to the directory of the file.
+func LoadResults(resultsPath string) (Option, EstResultCollector, error) {
+	data, err := ioutil.ReadFile(resultsPath)
+	if err != nil {
+		return Option{}, nil, errors.Trace(err)
+	}
+	var rf ResultsFile
+	if err := json.Unmarshal(data, &rf); err != nil {
+		return Option{}, nil, errors.Trace(err)
+	}
+
+	opt := Option{Seed: rf.Seed, QueryTypes: rf.QueryTypes, Reports: rf.Reports, ReportDir: filepath.Dir(resultsPath)}
+	for _, ins := range rf.Instances {
+		opt.Instances = append(opt.Instances, tidb.Option{Label: ins})
+	}
+	for _, ds := range rf.Datasets {
+		opt.Datasets = append(opt.Datasets, DatasetOpt{Label: ds})
+	}
+
+	collector := NewEstResultCollector(len(opt.Instances), len(opt.Datasets), len(opt.QueryTypes))
+	for _, g := range rf.Results {
+		if g.InstanceIdx < 0 || g.InstanceIdx >= len(rf.Instances) || rf.Instances[g.InstanceIdx] != g.Instance ||
+			g.DatasetIdx < 0 || g.DatasetIdx >= len(rf.Datasets) || rf.Datasets[g.DatasetIdx] != g.Dataset ||
+			g.QueryTypeIdx < 0 || g.QueryTypeIdx >= len(rf.QueryTypes) || rf.QueryTypes[g.QueryTypeIdx] != g.QueryType {
+			return Option{}, nil, errors.Errorf("unknown result group ins=%v#%v, ds=%v#%v, qt=%v#%v in %v",
+				g.Instance, g.InstanceIdx, g.Dataset, g.DatasetIdx, g.QueryType, g.QueryTypeIdx, resultsPath)
+		}
+		collector.AppendEstResults(g.InstanceIdx, g.DatasetIdx, g.QueryTypeIdx, g.EstResults)
+	}
+	return opt, collector, nil
+}
+
+// RenderReportsFromResults renders reports from the results file without accessing any database.
+// Reports selected when saving results are rendered if no report is specified.
+func RenderReportsFromResults(resultsPath, reportDir string, reports []string) error {
+	opt, collector, err := LoadResults(resultsPath)
+	if err != nil {
+		return err
+	}
+	if reportDir != "" {
+		opt.ReportDir = reportDir
+	}
+	if len(reports) > 0 {
+		opt.Reports = reports
+	}
+	return RenderReports(opt, collector)
+}
+
+// worstCases returns the results with the largest absolute p-error in descending order.
+func worstCases(ers []EstResult, limit int) []EstResult {
+	sorted := make([]EstResult, len(ers))
+	copy(sorted, ers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return math.Abs(PError(sorted[i])) > math.Abs(PError(sorted[j]))
+	})
+	if len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+// GenWorstCasesReport lists the worst cases of each query type on each dataset of each instance in worst-cases.md.
+func GenWorstCasesReport(opt Option, collector EstResultCollector) error {
+	md := bytes.Buffer{}
+	md.WriteString(fmt.Sprintf("> seed=%v\n\n", opt.Seed))
+	for qtIdx, qt := range opt.QueryTypes {
+		md.WriteString(fmt.Sprintf("# %v\n", qt))
+		for dsIdx, ds := range opt.Datasets {
+			md.WriteString(fmt.Sprintf("## %v\n", ds.Label))
+			for insIdx, ins := range opt.Instances {
+				md.WriteString(fmt.Sprintf("\n### %v\n", ins.Label))
+				md.WriteString("\n| SQL | Est | True | PError | QError |\n")
+				md.WriteString("| ---- | ---- | ---- | ---- | ---- |\n")
+				for _, r := range worstCases(collector.EstResults(insIdx, dsIdx, qtIdx), worstCaseLimit) {
+					md.WriteString(fmt.Sprintf("| `%v` | %.2f | %.2f | %.3f | %.3f |\n",
+						r.SQL, r.EstCard, r.TrueCard, PError(r), QError(r)))
+				}
+			}
+			md.WriteString("\n")
+		}
+	}
+	return errors.Trace(ioutil.WriteFile(path.Join(opt.ReportDir, "worst-cases.md"), md.Bytes(), 0666))
+}
@@ -0,0 +1,124 @@
+package cetest
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func TestSaveAndLoadResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cetest-results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opt := Option{
+		QueryTypes: []QueryType{QTSingleColPointQueryOnCol, QTMulColsRangeQueryOnIndex},
+		Datasets:   []DatasetOpt{{Label: "zipfx"}},
+		Instances:  []tidb.Option{{Label: "v5.4"}, {Label: "v6.0"}},
+		ReportDir:  dir,
+		Seed:       2022,
+		Reports:    []string{ReportPErrorBar, ReportBiasCDF, ReportEstVsTrueScatter},
+	}
+	collector := NewEstResultCollector(2, 1, 2)
+	for insIdx := 0; insIdx < 2; insIdx++ {
+		for qtIdx := 0; qtIdx < 2; qtIdx++ {
+			for i := 0; i < 20; i++ {
+				collector.AddEstResult(insIdx, 0, qtIdx, EstResult{
+					SQL:      "select * from t",
+					EstCard:  float64(i*(insIdx+1) + qtIdx),
+					TrueCard: float64(i),
+				})
+			}
+		}
+	}
+	if err := SaveResults(opt, collector); err != nil {
+		t.Fatal(err)
+	}
+
+	loadedOpt, loaded, err := LoadResults(path.Join(dir, ResultsFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loadedOpt.Seed != opt.Seed || !reflect.DeepEqual(loadedOpt.QueryTypes, opt.QueryTypes) || !reflect.DeepEqual(loadedOpt.Reports, opt.Reports) ||
+		len(loadedOpt.Instances) != 2 || loadedOpt.Instances[1].Label != "v6.0" || loadedOpt.Datasets[0].Label != "zipfx" {
+		t.Fatalf("unexpected option %+v", loadedOpt)
+	}
+	for insIdx := 0; insIdx < 2; insIdx++ {
+		for qtIdx := 0; qtIdx < 2; qtIdx++ {
+			if !reflect.DeepEqual(loaded.EstResults(insIdx, 0, qtIdx), collector.EstResults(insIdx, 0, qtIdx)) {
+				t.Fatalf("results of ins=%v, qt=%v are different after loading", insIdx, qtIdx)
+			}
+		}
+	}
+
+	if err := RenderReports(loadedOpt, loaded); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"index.md", "perror-bar.md", "bias-cdf.md", "est-vs-true-scatter.md", "worst-cases.md",
+		"mul-cols-range-query-on-index-zipfx-bias-cdf.png", "single-col-point-query-on-col-zipfx-v6.0-scatter.png"} {
+		if _, err := os.Stat(path.Join(dir, f)); err != nil {
+			t.Fatalf("%v is not rendered: %v", f, err)
+		}
+	}
+}
+
+func TestLoadResultsWithDuplicateLabels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cetest-results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opt := Option{
+		QueryTypes: []QueryType{QTSingleColPointQueryOnCol},
+		Datasets:   []DatasetOpt{{Label: "zipfx"}, {Label: "zipfx"}},
+		Instances:  []tidb.Option{{}, {}},
+		ReportDir:  dir,
+	}
+	collector := NewEstResultCollector(2, 2, 1)
+	for insIdx := 0; insIdx < 2; insIdx++ {
+		for dsIdx := 0; dsIdx < 2; dsIdx++ {
+			collector.AddEstResult(insIdx, dsIdx, 0, EstResult{EstCard: float64(insIdx*2 + dsIdx), TrueCard: 1})
+		}
+	}
+	if err := SaveResults(opt, collector); err != nil {
+		t.Fatal(err)
+	}
+	_, loaded, err := LoadResults(path.Join(dir, ResultsFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for insIdx := 0; insIdx < 2; insIdx++ {
+		for dsIdx := 0; dsIdx < 2; dsIdx++ {
+			if !reflect.DeepEqual(loaded.EstResults(insIdx, dsIdx, 0), collector.EstResults(insIdx, dsIdx, 0)) {
+				t.Fatalf("results of ins=%v, ds=%v are merged or lost: %v", insIdx, dsIdx, loaded.EstResults(insIdx, dsIdx, 0))
+			}
+		}
+	}
+}
+
+func TestWorstCases(t *testing.T) {
+	ers := []EstResult{{EstCard: 10, TrueCard: 10}, {EstCard: 1, TrueCard: 100}, {EstCard: 30, TrueCard: 10}}
+	worst := worstCases(ers, 2)
+	if len(worst) != 2 || worst[0].TrueCard != 100 || worst[1].EstCard != 30 {
+		t.Fatalf("unexpected worst cases %v", worst)
+	}
+	if ers[0].EstCard != 10 {
+		t.Fatal("input results are modified")
+	}
+}
+
+func TestFileNameOf(t *testing.T) {
+	if name := fileNameOf("-scatter.png", "single-col-point-query-on-col", "pzipfx", "range/N (static)"); name != "single-col-point-query-on-col-pzipfx-range_N_static_-scatter.png" {
+		t.Fatalf("unexpected file name %v", name)
+	}
+	if err := checkReports([]string{ReportQErrorBox, "pie"}); err == nil {
+		t.Fatal("expect an error for the unknown report")
+	}
+}
@@ -0,0 +1,131 @@
+package cetest
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// Strategies to sample values of single column point queries.
+const (
+	SampleUniform = "uniform" // all values with the same probability
+	SampleDecile  = "decile"  // the same number of values from each decile of values ordered by frequency
+	SampleTopN    = "topn"    // only the most frequent values, which are in TopN
+	SampleTail    = "tail"    // only the least frequent 10% values out of TopN
+	SampleBucket  = "bucket"  // the same number of values from each histogram bucket out of TopN
+)
+
+const (
+	defaultTopN    = 100
+	defaultBuckets = 256
+)
+
+// SampleOption decides which queries are sampled by queriers.
+type SampleOption struct {
+	NSamples int    // the expected number of samples, 0 means all
+	Seed     int64  // the same seed always samples the same queries, so bad cases can be regenerated
+	Strategy string // SampleUniform by default, only used by single column point queries
+	TopN     int    // the size of TopN assumed to classify values, defaultTopN if it's 0
+	Buckets  int    // the number of histogram buckets assumed to classify values, defaultBuckets if it's 0
+}
+
+// SampleConf is the sample section in configs.
+type SampleConf struct {
+	Strategy string `toml:"strategy"`
+	TopN     int    `toml:"topn"`
+	Buckets  int    `toml:"buckets"`
+}
+
+func (sc SampleConf) sampleOption(nSamples int, seed int64) SampleOption {
+	return SampleOption{NSamples: nSamples, Seed: seed, Strategy: sc.Strategy, TopN: sc.TopN, Buckets: sc.Buckets}
+}
+
+func checkSampleStrategy(strategy string) error {
+	switch strategy {
+	case "", SampleUniform, SampleDecile, SampleTopN, SampleTail, SampleBucket:
+		return nil
+	}
+	return errors.Errorf("unknown sample-strategy=%v", strategy)
+}
+
+func (so SampleOption) topN() int {
+	if so.TopN == 0 {
+		return defaultTopN
+	}
+	return so.TopN
+}
+
+func (so SampleOption) buckets() int {
+	if so.Buckets == 0 {
+		return defaultBuckets
+	}
+	return so.Buckets
+}
+
+// ResolveSeed returns the seed itself, or a new random seed if it's 0.
+// The seed of the session in the cassette in use is returned instead of a new random one if there is one,
+// so sessions recorded with random seeds can be replayed.
+func ResolveSeed(seed int64) int64 {
+	if seed == 0 {
+		if s, ok := tidb.CassetteSeed(); ok {
+			return s
+		}
+		return time.Now().UnixNano()
+	}
+	return seed
+}
+
+// sampleRows samples rows in [begin, end) in order, and each row is sampled with probability NSamples/(end-begin).
+// Samples are decided sequentially before sending queries concurrently to keep them reproducible.
+func (so SampleOption) sampleRows(begin, end int) []int {
+	nSamples := so.NSamples
+	if nSamples == 0 {
+		nSamples = end - begin
+	}
+	sampleRate := float64(nSamples) / float64(end-begin)
+	rng := rand.New(rand.NewSource(so.Seed))
+	rows := make([]int, 0, nSamples)
+	for rowIdx := begin; rowIdx < end; rowIdx++ {
+		if rng.Float64() > sampleRate {
+			continue
+		}
+		rows = append(rows, rowIdx)
+	}
+	return rows
+}
+
+// sampleStrata samples about the same number of rows from each stratum, which sum to NSamples, and returns them in order.
+func (so SampleOption) sampleStrata(strata [][]int) []int {
+	var rows []int
+	for i, stratum := range strata {
+		if len(stratum) == 0 {
+			continue
+		}
+		nSamples := len(stratum)
+		if so.NSamples > 0 {
+			nSamples = (so.NSamples + len(strata) - 1) / len(strata)
+		}
+		sampleRate := float64(nSamples) / float64(len(stratum))
+		rng := rand.New(rand.NewSource(so.Seed + int64(i)))
+		for _, rowIdx := range stratum {
+			if rng.Float64() <= sampleRate {
+				rows = append(rows, rowIdx)
+			}
+		}
+	}
+	sort.Ints(rows)
+	return rows
+}
+
+// appendEstResults appends results of samples in order, skipping samples failed to be estimated.
+func appendEstResults(ers []EstResult, results []*EstResult) []EstResult {
+	for _, r := range results {
+		if r != nil {
+			ers = append(ers, *r)
+		}
+	}
+	return ers
+}
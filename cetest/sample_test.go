@@ -0,0 +1,28 @@
+package cetest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSampleRows(t *testing.T) {
+	so := SampleOption{NSamples: 100, Seed: 2022}
+	rows := so.sampleRows(0, 1000)
+	if !reflect.DeepEqual(rows, so.sampleRows(0, 1000)) {
+		t.Fatal("samples with the same seed are different")
+	}
+	if n := len(rows); n < 50 || n > 150 {
+		t.Fatalf("expect about 100 samples, got %v", n)
+	}
+	for i := 1; i < len(rows); i++ {
+		if rows[i] <= rows[i-1] {
+			t.Fatalf("samples are not in order: %v", rows)
+		}
+	}
+	if reflect.DeepEqual(rows, SampleOption{NSamples: 100, Seed: 2023}.sampleRows(0, 1000)) {
+		t.Fatal("samples with different seeds are the same")
+	}
+	if n := len(SampleOption{}.sampleRows(10, 20)); n != 10 {
+		t.Fatalf("expect all rows to be sampled, got %v", n)
+	}
+}
@@ -0,0 +1,201 @@
+package cetest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// Categories of values by where their estimations come from in TiDB stats.
+const (
+	StatsCategoryTopN        = "topn"         // the value is in TopN
+	StatsCategoryBucketBound = "bucket-bound" // the value is the upper bound of a bucket and estimated by its repeats
+	StatsCategoryBucketInner = "bucket-inner" // the value is inside a bucket and estimated by the bucket's NDV
+	StatsCategoryNoBucket    = "no-bucket"    // the value is out of TopN and all buckets
+)
+
+var statsCategories = []string{StatsCategoryTopN, StatsCategoryBucketBound, StatsCategoryBucketInner, StatsCategoryNoBucket}
+
+// StatsAnnotation tells how the value of a point query is covered by the stats of its column.
+type StatsAnnotation struct {
+	InTopN     bool  `json:"in-topn"`
+	Bucket     int   `json:"bucket"`            // the bucket ID of the value, -1 if it's in no bucket
+	IsBound    bool  `json:"is-bound"`          // whether the value is the upper bound of the bucket
+	Repeats    int64 `json:"repeats,omitempty"` // repeats of the upper bound if IsBound
+	BucketNDV  int64 `json:"bucket-ndv,omitempty"`
+	ColumnNDV  int64 `json:"column-ndv"`
+	NullCount  int64 `json:"null-count"`
+	TopNCount  int64 `json:"topn-count,omitempty"`  // the count of the value in TopN if InTopN
+	BucketRows int64 `json:"bucket-rows,omitempty"` // the number of rows in the bucket
+}
+
+// Category returns the category of the value.
+func (a *StatsAnnotation) Category() string {
+	switch {
+	case a.InTopN:
+		return StatsCategoryTopN
+	case a.Bucket < 0:
+		return StatsCategoryNoBucket
+	case a.IsBound:
+		return StatsCategoryBucketBound
+	default:
+		return StatsCategoryBucketInner
+	}
+}
+
+type statsBucket struct {
+	id      int
+	rows    int64 // rows in this bucket
+	repeats int64
+	lower   string
+	upper   string
+	ndv     int64
+}
+
+// columnStats is the stats of a column read by SHOW STATS_HISTOGRAMS, SHOW STATS_TOPN and SHOW STATS_BUCKETS.
+type columnStats struct {
+	tp        DATATYPE
+	ndv       int64
+	nullCount int64
+	topN      map[string]int64 // normalized value => count
+	buckets   []statsBucket
+}
+
+// readColumnStats reads the stats of the column, which are the global stats for partitioned tables.
+func readColumnStats(ins tidb.Instance, db, tbl, col string, tp DATATYPE) (*columnStats, error) {
+	if ins.Opt().EngineName() != tidb.EngineTiDB {
+		return nil, errors.Errorf("stats attribution is unsupported for engine=%v", ins.Opt().EngineName())
+	}
+	where := fmt.Sprintf(" WHERE db_name='%v' AND table_name='%v' AND column_name='%v' AND is_index=0 AND partition_name IN ('', 'global')", db, tbl, col)
+	cs := &columnStats{tp: tp, topN: make(map[string]int64)}
+
+	columns, rows, err := readExplainResults(ins, "SHOW STATS_HISTOGRAMS"+where)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.Errorf("no stats of %v.%v.%v", db, tbl, col)
+	}
+	if cs.ndv, err = parseStatsInt(columns, rows[0], "distinct_count"); err != nil {
+		return nil, err
+	}
+	if cs.nullCount, err = parseStatsInt(columns, rows[0], "null_count"); err != nil {
+		return nil, err
+	}
+
+	columns, rows, err = readExplainResults(ins, "SHOW STATS_TOPN"+where)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		val, err := statsValue(columns, row, "value")
+		if err != nil {
+			return nil, err
+		}
+		cnt, err := parseStatsInt(columns, row, "count")
+		if err != nil {
+			return nil, err
+		}
+		cs.topN[cs.normalize(val)] = cnt
+	}
+
+	columns, rows, err = readExplainResults(ins, "SHOW STATS_BUCKETS"+where)
+	if err != nil {
+		return nil, err
+	}
+	var lastCount int64
+	for _, row := range rows {
+		var b statsBucket
+		var id, count int64
+		if id, err = parseStatsInt(columns, row, "bucket_id"); err != nil {
+			return nil, err
+		}
+		if count, err = parseStatsInt(columns, row, "count"); err != nil { // the count is cumulative
+			return nil, err
+		}
+		if b.repeats, err = parseStatsInt(columns, row, "repeats"); err != nil {
+			return nil, err
+		}
+		if b.ndv, err = parseStatsInt(columns, row, "ndv"); err != nil {
+			return nil, err
+		}
+		if b.lower, err = statsValue(columns, row, "lower_bound"); err != nil {
+			return nil, err
+		}
+		if b.upper, err = statsValue(columns, row, "upper_bound"); err != nil {
+			return nil, err
+		}
+		b.id, b.rows, lastCount = int(id), count-lastCount, count
+		cs.buckets = append(cs.buckets, b)
+	}
+	return cs, nil
+}
+
+func statsValue(columns, row []string, name string) (string, error) {
+	for i, c := range columns {
+		if strings.EqualFold(c, name) {
+			return row[i], nil
+		}
+	}
+	return "", errors.Errorf("no column %v in stats results, columns=%v", name, columns)
+}
+
+func parseStatsInt(columns, row []string, name string) (int64, error) {
+	v, err := statsValue(columns, row, name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	return n, errors.Trace(err)
+}
+
+// normalize makes values equal in the column type the same string, like 1 and 1.0 in DOUBLE.
+func (cs *columnStats) normalize(val string) string {
+	if cs.tp == DTString {
+		return val
+	}
+	if f, err := strconv.ParseFloat(val, 64); err == nil {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return val
+}
+
+// compare compares values in the column type.
+func (cs *columnStats) compare(a, b string) int {
+	if cs.tp != DTString {
+		fa, err1 := strconv.ParseFloat(a, 64)
+		fb, err2 := strconv.ParseFloat(b, 64)
+		if err1 == nil && err2 == nil {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// annotate tells how the value is covered by the stats.
+func (cs *columnStats) annotate(val string) *StatsAnnotation {
+	a := &StatsAnnotation{Bucket: -1, ColumnNDV: cs.ndv, NullCount: cs.nullCount}
+	if cnt, ok := cs.topN[cs.normalize(val)]; ok {
+		a.InTopN, a.TopNCount = true, cnt
+	}
+	for _, b := range cs.buckets {
+		if cs.compare(val, b.lower) < 0 || cs.compare(val, b.upper) > 0 {
+			continue
+		}
+		a.Bucket, a.BucketNDV, a.BucketRows = b.id, b.ndv, b.rows
+		if cs.compare(val, b.upper) == 0 {
+			a.IsBound, a.Repeats = true, b.repeats
+		}
+		break
+	}
+	return a
+}
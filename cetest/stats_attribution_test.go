@@ -0,0 +1,88 @@
+package cetest
+
+import (
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func fakeColumnStats(ins *tidb.FakeInstance) {
+	ins.OnQuery("^SHOW STATS_HISTOGRAMS WHERE .*column_name='a'").ReturnTable(`
++---------+------------+----------------+-------------+----------+---------------------+----------------+------------+--------------+-------------+
+| Db_name | Table_name | Partition_name | Column_name | Is_index | Update_time         | Distinct_count | Null_count | Avg_col_size | Correlation |
++---------+------------+----------------+-------------+----------+---------------------+----------------+------------+--------------+-------------+
+| db      | t          |                | a           |        0 | 2022-01-01 00:00:00 |              6 |          2 |            8 |           1 |
++---------+------------+----------------+-------------+----------+---------------------+----------------+------------+--------------+-------------+`)
+	ins.OnQuery("^SHOW STATS_TOPN WHERE .*column_name='a'").ReturnTable(`
++---------+------------+----------------+-------------+----------+-------+-------+
+| Db_name | Table_name | Partition_name | Column_name | Is_index | Value | Count |
++---------+------------+----------------+-------------+----------+-------+-------+
+| db      | t          |                | a           |        0 | 100   |    50 |
++---------+------------+----------------+-------------+----------+-------+-------+`)
+	ins.OnQuery("^SHOW STATS_BUCKETS WHERE .*column_name='a'").ReturnTable(`
++---------+------------+----------------+-------------+----------+-----------+-------+---------+-------------+-------------+-----+
+| Db_name | Table_name | Partition_name | Column_name | Is_index | Bucket_id | Count | Repeats | Lower_Bound | Upper_Bound | Ndv |
++---------+------------+----------------+-------------+----------+-----------+-------+---------+-------------+-------------+-----+
+| db      | t          |                | a           |        0 |         0 |    10 |       4 | 1           | 5           |   3 |
+| db      | t          |                | a           |        0 |         1 |    25 |       6 | 8           | 20          |   2 |
++---------+------------+----------------+-------------+----------+-----------+-------+---------+-------------+-------------+-----+`)
+}
+
+func TestAnnotateColumnStats(t *testing.T) {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	fakeColumnStats(ins)
+	cs, err := readColumnStats(ins, "db", "t", "a", DTInt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		val      string
+		expected StatsAnnotation
+		category string
+	}{
+		{"100", StatsAnnotation{InTopN: true, TopNCount: 50, Bucket: -1}, StatsCategoryTopN},
+		{"3", StatsAnnotation{Bucket: 0, BucketNDV: 3, BucketRows: 10}, StatsCategoryBucketInner},
+		{"5", StatsAnnotation{Bucket: 0, IsBound: true, Repeats: 4, BucketNDV: 3, BucketRows: 10}, StatsCategoryBucketBound},
+		{"20.0", StatsAnnotation{Bucket: 1, IsBound: true, Repeats: 6, BucketNDV: 2, BucketRows: 15}, StatsCategoryBucketBound},
+		{"6", StatsAnnotation{Bucket: -1}, StatsCategoryNoBucket},
+	}
+	for _, c := range cases {
+		c.expected.ColumnNDV, c.expected.NullCount = 6, 2
+		a := cs.annotate(c.val)
+		if *a != c.expected || a.Category() != c.category {
+			t.Fatalf("unexpected annotation %+v (%v) of %v", *a, a.Category(), c.val)
+		}
+	}
+}
+
+func TestSingleColQuerierStatsAttribution(t *testing.T) {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	fakeColumnStats(ins)
+	ins.OnExactQuery("SELECT a, COUNT(*) FROM db.`t` where a is not null GROUP BY a ORDER BY COUNT(*)").
+		Return([]string{"a", "COUNT(*)"}, []interface{}{3, 2}, []interface{}{100, 50})
+	ins.OnQuery("WHERE a=").ReturnTable(`
++-------------------------+---------+-----------+---------------+--------------------+
+| id                      | estRows | task      | access object | operator info      |
++-------------------------+---------+-----------+---------------+--------------------+
+| TableReader_7           | 5.00    | root      |               | data:Selection_6   |
++-------------------------+---------+-----------+---------------+--------------------+`)
+
+	qt := QTSingleColPointQueryOnCol
+	q := newSingleColQuerier("db", []string{"t"}, [][]string{{"a"}}, [][]DATATYPE{{DTInt}}, map[QueryType][2]int{qt: {0, 0}})
+	ers, err := q.Collect(SampleOption{}, qt, nil, ins, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	categories := make(map[string]string)
+	for _, er := range ers {
+		if er.Stats == nil {
+			t.Fatalf("no stats annotation in %+v", er)
+		}
+		categories[er.SQL] = er.Stats.Category()
+	}
+	if categories["SELECT * FROM db.`t` WHERE a=3"] != StatsCategoryBucketInner ||
+		categories["SELECT * FROM db.`t` WHERE a=100"] != StatsCategoryTopN {
+		t.Fatalf("unexpected categories %v", categories)
+	}
+}
@@ -1,6 +1,7 @@
 package cetest
 
 import (
+	"database/sql"
 	"fmt"
 	"strconv"
 	"strings"
@@ -11,67 +12,61 @@ import (
 )
 
 func getEstRowFromExplain(ins tidb.Instance, query string) (estRow float64, re error) {
+	switch ins.Opt().EngineName() {
+	case tidb.EngineMySQL:
+		return getEstRowFromMySQLExplain(ins, query)
+	case tidb.EnginePostgres:
+		return getEstRowFromPostgresExplain(ins, query)
+	}
 	sql := "EXPLAIN " + query
-	rows, err := ins.Query(sql)
+	columns, results, err := readExplainResults(ins, sql)
 	if err != nil {
 		return 0, fmt.Errorf("run sql=%v, err=%v", sql, err)
 	}
-	defer func() {
-		if err := rows.Close(); err != nil && re == nil {
-			re = err
-		}
-	}()
+	return ExtractEstRows(columns, results)
+}
 
-	types, err := rows.ColumnTypes()
+func getEstResultFromExplainAnalyze(ins tidb.Instance, query string) (r EstResult, re error) {
+	switch engine := ins.Opt().EngineName(); engine {
+	case tidb.EnginePostgres:
+		return getEstResultFromPostgresExplainAnalyze(ins, query)
+	case tidb.EngineTiDB:
+	default:
+		return EstResult{}, errors.Errorf("explain analyze is unsupported for engine=%v", engine)
+	}
+	begin := time.Now()
+	sql := "EXPLAIN ANALYZE " + query
+	columns, results, err := readExplainResults(ins, sql)
 	if err != nil {
-		return 0, err
+		return EstResult{}, err
 	}
-	nCols := len(types)
-	results := make([][]string, 0, 8)
-	for rows.Next() {
-		cols := make([]string, nCols)
-		ptrs := make([]interface{}, nCols)
-		for i := 0; i < nCols; i++ {
-			ptrs[i] = &cols[i]
-		}
-		if err := rows.Scan(ptrs...); err != nil {
-			return 0, err
-		}
-		results = append(results, cols)
+	if time.Since(begin) > time.Millisecond*50 {
+		fmt.Printf("[SLOW QUERY] %v cost %v\n", sql, time.Since(begin))
 	}
-
-	return ExtractEstRows(results, ins.Version())
+	return ExtractEstResult(columns, results)
 }
 
-func ExtractEstRows(explainResults [][]string, version string) (float64, error) {
-	if tidb.ToComparableVersion(version) < tidb.ToComparableVersion("v3.0.0") { // v2.x
-		panic("TODO")
-	} else if tidb.ToComparableVersion(version) < tidb.ToComparableVersion("v4.0.0") { // v3.x
-		panic("TODO")
-	} else if tidb.ToComparableVersion(version) < tidb.ToComparableVersion("v5.0.0") { // v4.x
-		return extractEstRowsForV4(explainResults)
+// tableName returns the qualified name of the table in the specified database, quoted in the way of the instance's engine.
+func tableName(ins tidb.Instance, db, tb string) string {
+	if ins.Opt().EngineName() == tidb.EnginePostgres {
+		return fmt.Sprintf(`%v."%v"`, db, tb)
 	}
-	return 0, nil
+	return fmt.Sprintf("%v.`%v`", db, tb)
 }
 
-func extractEstRowsForV4(explainResults [][]string) (float64, error) {
-	// | IndexReader_6          | 0.00    | root      |                             | index:IndexRangeScan_5 
-	est, err := strconv.ParseFloat(explainResults[0][1], 64)
-	if err != nil {
-		return 0, errors.Trace(err)
+// analyzeTableSQL returns the statement to collect statistics on the table.
+func analyzeTableSQL(ins tidb.Instance, tbl string) string {
+	if ins.Opt().EngineName() == tidb.EnginePostgres {
+		return "ANALYZE " + tbl
 	}
-	return est, nil
+	return "ANALYZE TABLE " + tbl
 }
 
-func getEstResultFromExplainAnalyze(ins tidb.Instance, query string) (r EstResult, re error) {
-	begin := time.Now()
-	sql := "EXPLAIN ANALYZE " + query
-	rows, err := ins.Query(sql)
+// readExplainResults runs the explain statement and returns its column names and all its rows as strings.
+func readExplainResults(ins tidb.Instance, explainSQL string) (columns []string, results [][]string, re error) {
+	rows, err := ins.Query(explainSQL)
 	if err != nil {
-		return EstResult{}, errors.Trace(err)
-	}
-	if time.Since(begin) > time.Millisecond*50 {
-		fmt.Printf("[SLOW QUERY] %v cost %v\n", sql, time.Since(begin))
+		return nil, nil, errors.Trace(err)
 	}
 	defer func() {
 		if err := rows.Close(); err != nil && re == nil {
@@ -81,69 +76,136 @@ func getEstResultFromExplainAnalyze(ins tidb.Instance, query string) (r EstResul
 
 	types, err := rows.ColumnTypes()
 	if err != nil {
-		return EstResult{}, err
+		return nil, nil, err
 	}
 	nCols := len(types)
-	results := make([][]string, 0, 8)
+	columns = make([]string, nCols)
+	for i, tp := range types {
+		columns[i] = tp.Name()
+	}
+	results = make([][]string, 0, 8)
 	for rows.Next() {
-		cols := make([]string, nCols)
+		vals := make([]sql.NullString, nCols)
 		ptrs := make([]interface{}, nCols)
 		for i := 0; i < nCols; i++ {
-			ptrs[i] = &cols[i]
+			ptrs[i] = &vals[i]
 		}
 		if err := rows.Scan(ptrs...); err != nil {
-			return EstResult{}, err
+			return nil, nil, err
+		}
+		cols := make([]string, nCols)
+		for i := range vals {
+			cols[i] = vals[i].String
 		}
 		results = append(results, cols)
 	}
+	return columns, results, rows.Err()
+}
+
+// The names of the columns in the results of EXPLAIN and EXPLAIN ANALYZE, which vary across TiDB versions:
+//
+//	v2.0:	id | parents | children | task | operator info | count
+//	v2.1-v3.x:	id | count | task | operator info [| execution info | memory | disk]
+//	v4.0+:	id | estRows [| actRows] | task | access object [| execution info] | operator info [| memory | disk]
+var (
+	estRowsColNames  = []string{"estrows", "count"}
+	actRowsColNames  = []string{"actrows"}
+	execInfoColNames = []string{"execution info", "execution_info"}
+	parentsColNames  = []string{"parents"}
+)
 
-	return ExtractEstResult(results, ins.Version())
+// findColumn returns the index of the first column whose name is in names, or -1 if there is no such column.
+func findColumn(columns []string, names []string) int {
+	for i, col := range columns {
+		col = strings.ToLower(strings.TrimSpace(col))
+		for _, name := range names {
+			if col == name {
+				return i
+			}
+		}
+	}
+	return -1
 }
 
-// ExtractEstResult extracts EstResults from results of explain analyze
-func ExtractEstResult(analyzeResults [][]string, version string) (EstResult, error) {
-	if tidb.ToComparableVersion(version) < tidb.ToComparableVersion("v3.0.0") { // v2.x
-		return EstResult{}, errors.Errorf("unsupported version=%v", version)
-	} else if tidb.ToComparableVersion(version) < tidb.ToComparableVersion("v4.0.0") { // v3.x
-		return extractEstResultForV3(analyzeResults)
-	} else if tidb.ToComparableVersion(version) < tidb.ToComparableVersion("v5.0.0") { // v4.x
-		return extractEstResultForV4(analyzeResults)
+// rootRow returns the row of the root operator.
+// The root operator is always the first row except for v2.0, whose root is the one without parents.
+func rootRow(columns []string, results [][]string) ([]string, error) {
+	if len(results) == 0 {
+		return nil, errors.New("empty explain results")
+	}
+	if parentsIdx := findColumn(columns, parentsColNames); parentsIdx >= 0 {
+		for _, row := range results {
+			if strings.TrimSpace(row[parentsIdx]) == "" {
+				return row, nil
+			}
+		}
 	}
-	return EstResult{}, errors.Errorf("unsupported version=%v", version)
+	return results[0], nil
 }
 
-func extractEstResultForV4(analyzeResults [][]string) (EstResult, error) {
-	// | TableReader_5         | 10000.00 | 0       | ...
-	est, err := strconv.ParseFloat(analyzeResults[0][1], 64)
+// ExtractEstRows extracts the estimated row count of the root operator from results of explain.
+// The column of estimated rows is located by its name, so it works for all TiDB versions and EXPLAIN FORMAT='brief'.
+func ExtractEstRows(columns []string, explainResults [][]string) (float64, error) {
+	// | IndexReader_6          | 0.00    | root      |                             | index:IndexRangeScan_5
+	estIdx := findColumn(columns, estRowsColNames)
+	if estIdx < 0 {
+		return 0, errors.Errorf("no estRows or count column in explain results, columns=%v", columns)
+	}
+	row, err := rootRow(columns, explainResults)
 	if err != nil {
-		return EstResult{}, errors.Trace(err)
+		return 0, err
 	}
-	act, err := strconv.ParseFloat(analyzeResults[0][2], 64)
+	est, err := strconv.ParseFloat(strings.TrimSpace(row[estIdx]), 64)
 	if err != nil {
-		return EstResult{}, errors.Trace(err)
+		return 0, errors.Trace(err)
 	}
-
-	return EstResult{
-		EstCard:  est,
-		TrueCard: act,
-	}, nil
+	return est, nil
 }
 
-func extractEstResultForV3(analyzeResults [][]string) (EstResult, error) {
-	//| TableReader_5     | 10000.00 | root | data:TableScan_4                                           | time:2.95024ms, loops:1, rows:0 | 115 Bytes |
-	est, err := strconv.ParseFloat(analyzeResults[0][1], 64)
+// ExtractEstResult extracts EstResults from results of explain analyze.
+// For versions without the actRows column (v2.1-v3.x), the actual row count is read from "rows:N" in the execution info.
+func ExtractEstResult(columns []string, analyzeResults [][]string) (EstResult, error) {
+	// v4.0+: | TableReader_5         | 10000.00 | 0       | ...
+	// v3.x:  | TableReader_5     | 10000.00 | root | data:TableScan_4 | time:2.95024ms, loops:1, rows:0 | 115 Bytes |
+	est, err := ExtractEstRows(columns, analyzeResults)
 	if err != nil {
-		return EstResult{}, errors.Trace(err)
+		return EstResult{}, err
 	}
-	info := analyzeResults[0][4]
-	tmp := strings.Split(info, ":")
-	actStr := tmp[len(tmp)-1]
-	act, err := strconv.ParseFloat(actStr, 64)
+	row, err := rootRow(columns, analyzeResults)
 	if err != nil {
-		return EstResult{}, errors.Trace(err)
+		return EstResult{}, err
 	}
+
+	var act float64
+	if actIdx := findColumn(columns, actRowsColNames); actIdx >= 0 {
+		if act, err = strconv.ParseFloat(strings.TrimSpace(row[actIdx]), 64); err != nil {
+			return EstResult{}, errors.Trace(err)
+		}
+	} else if infoIdx := findColumn(columns, execInfoColNames); infoIdx >= 0 {
+		if act, err = parseRowsFromExecInfo(row[infoIdx]); err != nil {
+			return EstResult{}, err
+		}
+	} else {
+		return EstResult{}, errors.Errorf("no actRows or execution info column in explain analyze results, columns=%v", columns)
+	}
+
 	return EstResult{
 		EstCard:  est,
 		TrueCard: act,
 	}, nil
 }
+
+// parseRowsFromExecInfo parses the actual row count from execution info like "time:2.95024ms, loops:1, rows:0".
+func parseRowsFromExecInfo(info string) (float64, error) {
+	for _, field := range strings.Split(info, ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), ":", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == "rows" {
+			act, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err != nil {
+				return 0, errors.Trace(err)
+			}
+			return act, nil
+		}
+	}
+	return 0, errors.Errorf("no rows in execution info=%v", info)
+}
@@ -0,0 +1,105 @@
+package cetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func getEstRowFromMySQLExplain(ins tidb.Instance, query string) (float64, error) {
+	sql := "EXPLAIN FORMAT=JSON " + query
+	rows, err := ins.Query(sql)
+	if err != nil {
+		return 0, fmt.Errorf("run sql=%v, err=%v", sql, err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return 0, errors.Errorf("empty explain results for sql=%v", sql)
+	}
+	var explainJSON string
+	if err := rows.Scan(&explainJSON); err != nil {
+		return 0, errors.Trace(err)
+	}
+	return ExtractMySQLEstRows(explainJSON)
+}
+
+// mysqlTableWrappers are operations in MySQL's JSON plans which wrap the accessed tables.
+var mysqlTableWrappers = []string{"ordering_operation", "grouping_operation", "duplicates_removal", "windowing"}
+
+// ExtractMySQLEstRows extracts the estimated row count from results of MySQL's EXPLAIN FORMAT=JSON.
+// For a single table, it's rows_examined_per_scan * filtered; for joins, it's rows_produced_per_join of the last table.
+//
+//	{"query_block": {"select_id": 1, "table": {"table_name": "t", "rows_examined_per_scan": 100, "filtered": "10.00", ...}}}
+func ExtractMySQLEstRows(explainJSON string) (float64, error) {
+	var plan struct {
+		QueryBlock map[string]interface{} `json:"query_block"`
+	}
+	if err := json.Unmarshal([]byte(explainJSON), &plan); err != nil {
+		return 0, errors.Trace(err)
+	}
+	if plan.QueryBlock == nil {
+		return 0, errors.Errorf("no query_block in explain results %v", explainJSON)
+	}
+	tables := collectMySQLTables(plan.QueryBlock)
+	if len(tables) == 0 {
+		if _, ok := plan.QueryBlock["message"]; ok { // e.g. "Impossible WHERE" or "no matching row in const table"
+			return 0, nil
+		}
+		return 0, errors.Errorf("no table in explain results %v", explainJSON)
+	}
+
+	last := tables[len(tables)-1]
+	if len(tables) > 1 {
+		if produced, ok := last["rows_produced_per_join"]; ok {
+			return mysqlNumber(produced)
+		}
+	}
+	examined, err := mysqlNumber(last["rows_examined_per_scan"])
+	if err != nil {
+		return 0, err
+	}
+	filtered := 100.0
+	if f, ok := last["filtered"]; ok {
+		if filtered, err = mysqlNumber(f); err != nil {
+			return 0, err
+		}
+	}
+	return examined * filtered / 100, nil
+}
+
+// collectMySQLTables returns all tables accessed by this query block in order.
+func collectMySQLTables(block map[string]interface{}) []map[string]interface{} {
+	if tbl, ok := block["table"].(map[string]interface{}); ok {
+		return []map[string]interface{}{tbl}
+	}
+	if loops, ok := block["nested_loop"].([]interface{}); ok {
+		var tables []map[string]interface{}
+		for _, loop := range loops {
+			if m, ok := loop.(map[string]interface{}); ok {
+				tables = append(tables, collectMySQLTables(m)...)
+			}
+		}
+		return tables
+	}
+	for _, wrapper := range mysqlTableWrappers {
+		if m, ok := block[wrapper].(map[string]interface{}); ok {
+			return collectMySQLTables(m)
+		}
+	}
+	return nil
+}
+
+// mysqlNumber converts numbers in MySQL's JSON plans, which may be numbers or strings like "10.00", to float64.
+func mysqlNumber(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		return f, errors.Trace(err)
+	}
+	return 0, errors.Errorf("invalid number %v in explain results", v)
+}
@@ -0,0 +1,73 @@
+package cetest_test
+
+import (
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/cetest"
+)
+
+func TestExtractMySQLEstRows(t *testing.T) {
+	cases := []struct {
+		name string
+		json string
+		est  float64
+	}{
+		{"ref", `{
+  "query_block": {
+    "select_id": 1,
+    "cost_info": {"query_cost": "12.25"},
+    "table": {
+      "table_name": "cast_info",
+      "access_type": "ref",
+      "possible_keys": ["person_id_cast_info"],
+      "key": "person_id_cast_info",
+      "rows_examined_per_scan": 35,
+      "rows_produced_per_join": 35,
+      "filtered": "100.00",
+      "cost_info": {"read_cost": "8.75", "eval_cost": "3.50", "prefix_cost": "12.25", "data_read_per_join": "2K"}
+    }
+  }
+}`, 35},
+		{"full-scan-with-filter", `{
+  "query_block": {
+    "select_id": 1,
+    "table": {
+      "table_name": "title",
+      "access_type": "ALL",
+      "rows_examined_per_scan": 2520000,
+      "rows_produced_per_join": 252000,
+      "filtered": "10.00",
+      "attached_condition": "(imdb.title.phonetic_code = 'A5362')"
+    }
+  }
+}`, 252000},
+		{"ordering", `{
+  "query_block": {
+    "select_id": 1,
+    "ordering_operation": {
+      "using_filesort": true,
+      "table": {"table_name": "t", "access_type": "range", "rows_examined_per_scan": 40, "filtered": "50.00"}
+    }
+  }
+}`, 20},
+		{"nested-loop", `{
+  "query_block": {
+    "select_id": 1,
+    "nested_loop": [
+      {"table": {"table_name": "t1", "access_type": "ALL", "rows_examined_per_scan": 100, "rows_produced_per_join": 10, "filtered": "10.00"}},
+      {"table": {"table_name": "t2", "access_type": "ref", "rows_examined_per_scan": 3, "rows_produced_per_join": 30, "filtered": "100.00"}}
+    ]
+  }
+}`, 30},
+		{"impossible-where", `{"query_block": {"select_id": 1, "message": "Impossible WHERE"}}`, 0},
+	}
+	for _, c := range cases {
+		est, err := cetest.ExtractMySQLEstRows(c.json)
+		if err != nil {
+			t.Fatalf("case %v: %v", c.name, err)
+		}
+		if est != c.est {
+			t.Fatalf("case %v: expected est=%v, got %v", c.name, c.est, est)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package cetest
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func getEstRowFromPostgresExplain(ins tidb.Instance, query string) (float64, error) {
+	sql := "EXPLAIN (FORMAT JSON) " + query
+	explainJSON, err := readPostgresExplain(ins, sql)
+	if err != nil {
+		return 0, err
+	}
+	return ExtractPostgresEstRows(explainJSON)
+}
+
+func getEstResultFromPostgresExplainAnalyze(ins tidb.Instance, query string) (EstResult, error) {
+	sql := "EXPLAIN (ANALYZE, FORMAT JSON) " + query
+	explainJSON, err := readPostgresExplain(ins, sql)
+	if err != nil {
+		return EstResult{}, err
+	}
+	r, err := ExtractPostgresEstResult(explainJSON)
+	r.SQL = query
+	return r, err
+}
+
+func readPostgresExplain(ins tidb.Instance, sql string) (string, error) {
+	rows, err := ins.Query(sql)
+	if err != nil {
+		return "", fmt.Errorf("run sql=%v, err=%v", sql, err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", errors.Errorf("empty explain results for sql=%v", sql)
+	}
+	var explainJSON string
+	if err := rows.Scan(&explainJSON); err != nil {
+		return "", errors.Trace(err)
+	}
+	return explainJSON, nil
+}
+
+type postgresPlan struct {
+	Plan struct {
+		NodeType    string   `json:"Node Type"`
+		PlanRows    float64  `json:"Plan Rows"`
+		ActualRows  *float64 `json:"Actual Rows"`
+		ActualLoops *float64 `json:"Actual Loops"`
+	} `json:"Plan"`
+}
+
+func parsePostgresPlan(explainJSON string) (postgresPlan, error) {
+	var plans []postgresPlan
+	if err := json.Unmarshal([]byte(explainJSON), &plans); err != nil {
+		return postgresPlan{}, errors.Trace(err)
+	}
+	if len(plans) == 0 || plans[0].Plan.NodeType == "" {
+		return postgresPlan{}, errors.Errorf("no plan in explain results %v", explainJSON)
+	}
+	return plans[0], nil
+}
+
+// ExtractPostgresEstRows extracts the estimated row count of the root node from results of Postgres's EXPLAIN (FORMAT JSON).
+//
+//	[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "t", "Plan Rows": 10, ...}}]
+func ExtractPostgresEstRows(explainJSON string) (float64, error) {
+	plan, err := parsePostgresPlan(explainJSON)
+	if err != nil {
+		return 0, err
+	}
+	return plan.Plan.PlanRows, nil
+}
+
+// ExtractPostgresEstResult extracts the estimated and actual row counts of the root node from results of
+// Postgres's EXPLAIN (ANALYZE, FORMAT JSON), where the actual row count is "Actual Rows" * "Actual Loops".
+func ExtractPostgresEstResult(explainJSON string) (EstResult, error) {
+	plan, err := parsePostgresPlan(explainJSON)
+	if err != nil {
+		return EstResult{}, err
+	}
+	if plan.Plan.ActualRows == nil {
+		return EstResult{}, errors.Errorf("no actual rows in explain results %v", explainJSON)
+	}
+	loops := 1.0
+	if plan.Plan.ActualLoops != nil {
+		loops = *plan.Plan.ActualLoops
+	}
+	return EstResult{EstCard: plan.Plan.PlanRows, TrueCard: *plan.Plan.ActualRows * loops}, nil
+}
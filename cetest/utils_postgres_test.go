@@ -0,0 +1,97 @@
+package cetest_test
+
+import (
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/cetest"
+)
+
+func TestExtractPostgresEstRows(t *testing.T) {
+	cases := []struct {
+		name string
+		json string
+		est  float64
+	}{
+		{"seq-scan", `[
+  {
+    "Plan": {
+      "Node Type": "Seq Scan",
+      "Parallel Aware": false,
+      "Relation Name": "title",
+      "Alias": "title",
+      "Startup Cost": 0.00,
+      "Total Cost": 66869.20,
+      "Plan Rows": 13,
+      "Plan Width": 94,
+      "Filter": "((phonetic_code)::text = 'A5362'::text)"
+    }
+  }
+]`, 13},
+		{"gather-index-scan", `[
+  {
+    "Plan": {
+      "Node Type": "Gather",
+      "Parallel Aware": false,
+      "Startup Cost": 1000.00,
+      "Total Cost": 37450.61,
+      "Plan Rows": 1523.5,
+      "Plan Width": 42,
+      "Workers Planned": 2,
+      "Plans": [
+        {
+          "Node Type": "Index Scan",
+          "Parent Relationship": "Outer",
+          "Parallel Aware": true,
+          "Relation Name": "cast_info",
+          "Index Name": "person_id_cast_info",
+          "Plan Rows": 635,
+          "Plan Width": 42
+        }
+      ]
+    }
+  }
+]`, 1523.5},
+	}
+	for _, c := range cases {
+		est, err := cetest.ExtractPostgresEstRows(c.json)
+		if err != nil {
+			t.Fatalf("case %v: %v", c.name, err)
+		}
+		if est != c.est {
+			t.Fatalf("case %v: expect %v, got %v", c.name, c.est, est)
+		}
+	}
+
+	if _, err := cetest.ExtractPostgresEstRows(`[]`); err == nil {
+		t.Fatal("expect an error for empty plans")
+	}
+}
+
+func TestExtractPostgresEstResult(t *testing.T) {
+	r, err := cetest.ExtractPostgresEstResult(`[
+  {
+    "Plan": {
+      "Node Type": "Seq Scan",
+      "Relation Name": "title",
+      "Plan Rows": 13,
+      "Actual Startup Time": 0.012,
+      "Actual Total Time": 250.6,
+      "Actual Rows": 9,
+      "Actual Loops": 1
+    },
+    "Planning Time": 0.105,
+    "Triggers": [],
+    "Execution Time": 250.7
+  }
+]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.EstCard != 13 || r.TrueCard != 9 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+
+	if _, err := cetest.ExtractPostgresEstResult(`[{"Plan": {"Node Type": "Seq Scan", "Plan Rows": 13}}]`); err == nil {
+		t.Fatal("expect an error for explain results without actual rows")
+	}
+}
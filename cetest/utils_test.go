@@ -0,0 +1,144 @@
+package cetest_test
+
+import (
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/cetest"
+)
+
+type explainCase struct {
+	name    string
+	columns []string
+	rows    [][]string
+	est     float64
+	act     float64 // only used for explain analyze cases
+}
+
+var explainCases = []explainCase{
+	{
+		name:    "v2.0",
+		columns: []string{"id", "parents", "children", "task", "operator info", "count"},
+		rows: [][]string{
+			{"TableScan_4", "Selection_5", "", "cop", "table:t, range:[-inf,+inf], keep order:false", "10000.00"},
+			{"Selection_5", "TableReader_6", "TableScan_4", "cop", "eq(test.t.a, 1)", "10.00"},
+			{"TableReader_6", "", "Selection_5", "root", "data:Selection_5", "10.00"},
+		},
+		est: 10,
+	},
+	{
+		name:    "v2.1",
+		columns: []string{"id", "count", "task", "operator info"},
+		rows: [][]string{
+			{"IndexReader_6", "10.00", "root", "index:IndexScan_5"},
+			{"└─IndexScan_5", "10.00", "cop", "table:t, index:a, range:[1,1], keep order:false, stats:pseudo"},
+		},
+		est: 10,
+	},
+	{
+		name:    "v3.0",
+		columns: []string{"id", "count", "task", "operator info"},
+		rows: [][]string{
+			{"IndexLookUp_10", "3.05", "root", ""},
+			{"├─IndexScan_8", "3.05", "cop", "table:t, index:a, range:[1,1], keep order:false"},
+			{"└─TableScan_9", "3.05", "cop", "table:t, keep order:false"},
+		},
+		est: 3.05,
+	},
+	{
+		name:    "v4.0",
+		columns: []string{"id", "estRows", "task", "access object", "operator info"},
+		rows: [][]string{
+			{"IndexReader_6", "0.00", "root", "", "index:IndexRangeScan_5"},
+			{"└─IndexRangeScan_5", "0.00", "cop[tikv]", "table:t, index:a(a)", "range:[1,1], keep order:false"},
+		},
+		est: 0,
+	},
+	{
+		name:    "v5.4",
+		columns: []string{"id", "estRows", "task", "access object", "operator info"},
+		rows: [][]string{
+			{"TableReader_7", "127.36", "root", "", "data:Selection_6"},
+			{"└─Selection_6", "127.36", "cop[tikv]", "", "eq(imdb.title.phonetic_code, \"A5362\")"},
+			{"  └─TableFullScan_5", "2528312.00", "cop[tikv]", "table:title", "keep order:false"},
+		},
+		est: 127.36,
+	},
+	{
+		name:    "v6.1-brief",
+		columns: []string{"id", "estRows", "task", "access object", "operator info"},
+		rows: [][]string{
+			{"IndexLookUp", "24.12", "root", "", ""},
+			{"├─IndexRangeScan(Build)", "24.12", "cop[tikv]", "table:cast_info, index:person_id_cast_info(person_id)", "range:[1024,1024], keep order:false"},
+			{"└─TableRowIDScan(Probe)", "24.12", "cop[tikv]", "table:cast_info", "keep order:false"},
+		},
+		est: 24.12,
+	},
+}
+
+var explainAnalyzeCases = []explainCase{
+	{
+		name:    "v2.1",
+		columns: []string{"id", "count", "task", "operator info", "execution info"},
+		rows: [][]string{
+			{"TableReader_5", "10000.00", "root", "data:TableScan_4", "time:2.95024ms, loops:1, rows:0"},
+			{"└─TableScan_4", "10000.00", "cop", "table:t, range:[-inf,+inf], keep order:false, stats:pseudo", ""},
+		},
+		est: 10000, act: 0,
+	},
+	{
+		name:    "v3.0",
+		columns: []string{"id", "count", "task", "operator info", "execution info", "memory"},
+		rows: [][]string{
+			{"TableReader_5", "10000.00", "root", "data:TableScan_4", "time:2.95024ms, loops:1, rows:17", "115 Bytes"},
+			{"└─TableScan_4", "10000.00", "cop", "table:t, range:[-inf,+inf], keep order:false, stats:pseudo", "time:0s, loops:0, rows:17", "N/A"},
+		},
+		est: 10000, act: 17,
+	},
+	{
+		name:    "v4.0",
+		columns: []string{"id", "estRows", "actRows", "task", "access object", "execution info", "operator info", "memory", "disk"},
+		rows: [][]string{
+			{"TableReader_5", "10000.00", "0", "root", "", "time:1.08ms, loops:1, cop_task: {num: 1, max: 1.01ms}", "data:TableFullScan_4", "109 Bytes", "N/A"},
+			{"└─TableFullScan_4", "10000.00", "0", "cop[tikv]", "table:t", "time:0s, loops:1", "keep order:false, stats:pseudo", "N/A", "N/A"},
+		},
+		est: 10000, act: 0,
+	},
+	{
+		name:    "v6.1-brief",
+		columns: []string{"id", "estRows", "actRows", "task", "access object", "execution info", "operator info", "memory", "disk"},
+		rows: [][]string{
+			{"IndexReader", "21.00", "20", "root", "", "time:612.1µs, loops:2, cop_task: {num: 1, max: 560.3µs}", "index:IndexRangeScan", "324 Bytes", "N/A"},
+			{"└─IndexRangeScan", "21.00", "20", "cop[tikv]", "table:t, index:a(a)", "tikv_task:{time:0s, loops:1}", "range:[1,1], keep order:false", "N/A", "N/A"},
+		},
+		est: 21, act: 20,
+	},
+}
+
+func TestExtractEstRows(t *testing.T) {
+	for _, c := range append(explainCases, explainAnalyzeCases...) {
+		est, err := cetest.ExtractEstRows(c.columns, c.rows)
+		if err != nil {
+			t.Fatalf("case %v: %v", c.name, err)
+		}
+		if est != c.est {
+			t.Fatalf("case %v: expected est=%v, got %v", c.name, c.est, est)
+		}
+	}
+}
+
+func TestExtractEstResult(t *testing.T) {
+	for _, c := range explainAnalyzeCases {
+		r, err := cetest.ExtractEstResult(c.columns, c.rows)
+		if err != nil {
+			t.Fatalf("case %v: %v", c.name, err)
+		}
+		if r.EstCard != c.est || r.TrueCard != c.act {
+			t.Fatalf("case %v: expected est=%v act=%v, got est=%v act=%v", c.name, c.est, c.act, r.EstCard, r.TrueCard)
+		}
+	}
+
+	// explain results without actual rows cannot be used
+	if _, err := cetest.ExtractEstResult(explainCases[1].columns, explainCases[1].rows); err == nil {
+		t.Fatal("expected an error for results of explain")
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/qw4990/OptimizerTester/cebench"
+	"github.com/qw4990/OptimizerTester/embedded"
 	"github.com/spf13/cobra"
 )
 
@@ -13,10 +14,25 @@ func newCEBenchCmd() *cobra.Command {
 	var needDedup bool
 	var badEstThreshold uint
 	var concurrencyForEachDSN uint
+	var baselines []string
+	var baselineSampleRate float64
+	var emb embeddedFlags
+	var embDataset, embDB string
 	cmd := &cobra.Command{
 		Use:   "cebench [-s xxx.sql -dsn \"root@tcp(127.0.0.1:4000)/imdb\" | -j xxx.json] [-o result]",
 		Short: "Cardinality Estimation Benchmark",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if emb.enabled {
+				svr, err := embedded.Start()
+				if err != nil {
+					return err
+				}
+				defer svr.Close()
+				if err := emb.prepare(svr, embDataset, embDB); err != nil {
+					return err
+				}
+				dsn = []string{svr.DSN(embDB)}
+			}
 			inputOpt := &cebench.InputOption{
 				QueryPath: queryLocation,
 				DSNs:      dsn,
@@ -27,6 +43,11 @@ func newCEBenchCmd() *cobra.Command {
 				Dedup:                 needDedup,
 				PErrorThreshold:       badEstThreshold,
 				ConcurrencyForEachDSN: concurrencyForEachDSN,
+				Baselines:             baselines,
+				BaselineSampleRate:    baselineSampleRate,
+			}
+			if len(baselines) > 0 {
+				otherOpt.Seed = resolvedSeed()
 			}
 			return cebench.RunCEBench(inputOpt, otherOpt)
 		},
@@ -38,6 +59,11 @@ func newCEBenchCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&needDedup, "dedup", true, "Whether deduplicate the estimation results")
 	cmd.Flags().UintVar(&badEstThreshold, "threshold", 10, "The estimation results with p-error higher than the threshold will be printed")
 	cmd.Flags().UintVar(&concurrencyForEachDSN, "concurrency", 4, "The connections opened for each DSN")
+	cmd.Flags().StringSliceVar(&baselines, "baseline", nil, "Baseline estimators shown next to TiDB in the report: uniform, sample or oracle, which read data by the first DSN")
+	cmd.Flags().Float64Var(&baselineSampleRate, "baseline-sample-rate", 0.01, "The rate of rows kept by the sample baseline estimator")
+	emb.register(cmd)
+	cmd.Flags().StringVar(&embDataset, "embedded-dataset", "zipfx", "The datagen dataset to load into the embedded server")
+	cmd.Flags().StringVar(&embDB, "embedded-db", "zipfx", "The database to load the dataset into, which is used by the DSN of the embedded server")
 	return cmd
 }
 
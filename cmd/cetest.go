@@ -3,12 +3,15 @@ package cmd
 import (
 	"github.com/pingcap/errors"
 	"github.com/qw4990/OptimizerTester/cetest"
+	"github.com/qw4990/OptimizerTester/embedded"
+	"github.com/qw4990/OptimizerTester/tidb"
 	"github.com/spf13/cobra"
 )
 
 func newCETestCmd() *cobra.Command {
 	var conf string
-	var partitionMode bool
+	var partitionMode, metamorphicMode bool
+	var emb embeddedFlags
 	cmd := &cobra.Command{
 		Use:   "cetest",
 		Short: "Cardinality Estimation Test",
@@ -17,12 +20,76 @@ func newCETestCmd() *cobra.Command {
 				return errors.New("no config")
 			}
 			if partitionMode {
-				return cetest.RunCETestPartitionModeWithConfig(conf)
+				if emb.enabled {
+					return errors.New("--embedded is not supported in the partition mode")
+				}
+				if metamorphicMode {
+					return errors.New("--metamorphic-mode cannot be used together with --partition-mode")
+				}
+				popt, err := cetest.ReadPOption(conf)
+				if err != nil {
+					return err
+				}
+				if cmd.Flags().Changed("seed") {
+					popt.Seed = seed
+				}
+				return cetest.RunCETestPartitionMode(popt)
 			}
-			return cetest.RunCETestWithConfig(conf)
+
+			opt, err := cetest.ReadOption(conf)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("seed") {
+				opt.Seed = resolvedSeed()
+			} else if emb.enabled && opt.Seed != 0 {
+				seed = opt.Seed // generate datasets of the embedded server with the seed in the config
+			}
+			run := cetest.RunCETest
+			if metamorphicMode {
+				run = cetest.RunCETestMetamorphicMode
+			}
+			if !emb.enabled {
+				return run(opt)
+			}
+
+			svr, err := embedded.Start()
+			if err != nil {
+				return err
+			}
+			defer svr.Close()
+			for _, ds := range opt.Datasets { // datasets in the config are generated by datagen
+				if err := emb.prepare(svr, ds.Name, ds.DB); err != nil {
+					return err
+				}
+			}
+			opt.Instances = []tidb.Option{svr.Option("embedded")}
+			return run(opt)
 		},
 	}
 	cmd.Flags().StringVar(&conf, "config", "", "CETester config path")
 	cmd.Flags().BoolVar(&partitionMode, "partition-mode", false, "Whether to use partition mode")
+	cmd.Flags().BoolVar(&metamorphicMode, "metamorphic-mode", false, "Whether to check consistency of estimations on related queries instead of their errors")
+	emb.register(cmd)
+	cmd.AddCommand(newCETestReportCmd())
+	return cmd
+}
+
+func newCETestReportCmd() *cobra.Command {
+	var from, reportDir string
+	var reports []string
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Render CETest reports from saved results without accessing databases",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from == "" {
+				return errors.New("no results file")
+			}
+			return cetest.RenderReportsFromResults(from, reportDir, reports)
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "The results file saved by cetest, e.g. <report-dir>/results.json")
+	cmd.Flags().StringVar(&reportDir, "report-dir", "", "Where to render reports, the directory of the results file by default")
+	cmd.Flags().StringSliceVar(&reports, "reports", nil, "Reports to render, reports selected by the config of the test by default")
 	return cmd
 }
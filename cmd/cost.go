@@ -1,30 +1,100 @@
 package cmd
 
 import (
+	"github.com/pingcap/errors"
 	"github.com/qw4990/OptimizerTester/cost"
+	"github.com/qw4990/OptimizerTester/embedded"
 	"github.com/spf13/cobra"
 )
 
+// tikvPlanLabels are labels of plans which can be evaluated on the embedded server without TiFlash.
+var tikvPlanLabels = []string{"TableScan", "IndexScan", "IndexLookup", "Sort", "StreamAgg", "HashAgg", "HashJoin", "MergeJoin", "IndexJoin"}
+
 func newCostEvalCmd() *cobra.Command {
+	var opt cost.EvalOption
+	var useEmbedded bool
 	cmd := &cobra.Command{
 		Use:   "cost-eval",
 		Short: "Cost Model Evaluation",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cost.CostEval()
+			if useEmbedded {
+				svr, err := embedded.Start()
+				if err != nil {
+					return err
+				}
+				defer svr.Close()
+				// the embedded TiDB is older than v6.2 without cost traces, so cost model v1 is evaluated
+				opt.CostModelVer = 1
+				if err := checkEmbeddedCostFeatures(svr, opt); err != nil {
+					return err
+				}
+				opt.Instance = svr.Option("embedded")
+				if len(opt.Labels) == 0 {
+					opt.Labels = tikvPlanLabels
+				}
+				if opt.GenSyntheticRows == 0 {
+					opt.GenSyntheticRows = 100000 // the embedded server starts with no data
+				}
+			}
+			if opt.GenSyntheticRows > 0 {
+				opt.Seed = resolvedSeed()
+			}
+			cost.CostEval(opt)
 			return nil
 		},
 	}
+	cmd.Flags().StringVar(&opt.Instance.Addr, "addr", "172.16.5.173", "The address of the TiDB instance")
+	cmd.Flags().IntVar(&opt.Instance.Port, "port", 4000, "The port of the TiDB instance")
+	cmd.Flags().StringVar(&opt.Instance.User, "user", "root", "The user of the TiDB instance")
+	cmd.Flags().StringVar(&opt.Instance.Password, "password", "", "The password of the TiDB instance")
+	cmd.Flags().StringSliceVar(&opt.Labels, "labels", nil, "Only evaluate queries with these labels")
+	cmd.Flags().IntVar(&opt.GenSyntheticRows, "gen-synthetic-rows", 0, "Generate synthetic data with so many rows before evaluation")
+	cmd.Flags().BoolVar(&opt.PlanChoice, "plan-choice", false, "Whether to evaluate the regret and the pairwise ranking accuracy of candidate plans of each query instead of correlations")
+	cmd.Flags().BoolVar(&useEmbedded, "embedded", false, "Whether to run on an embedded TiDB server with mock storage, where execution time is only indicative")
 	return cmd
 }
 
+// checkEmbeddedCostFeatures returns an error if the embedded server doesn't support features required by cost-eval,
+// which are probed on the server since its version is unknown.
+func checkEmbeddedCostFeatures(svr *embedded.Server, opt cost.EvalOption) error {
+	ins, err := svr.Connect("embedded")
+	if err != nil {
+		return err
+	}
+	defer ins.Close()
+	return errors.Annotate(opt.CheckFeatures(ins), "cost-eval can't run on the embedded server")
+}
+
 func newCostCaliCmd() *cobra.Command {
+	var opt cost.CaliOption
 	cmd := &cobra.Command{
 		Use:   "cost-cali",
 		Short: "Cost Model Calibration",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cost.CostCalibration()
+			if err := opt.Validate(); err != nil {
+				return err
+			}
+			opt.Seed = resolvedSeed()
+			cost.CostCalibration(opt)
 			return nil
 		},
 	}
+	cmd.Flags().StringVar(&opt.RecordsPath, "records", "./cost-calibration-data/synthetic-calibrated-records.json", "The file of records collected by cost-eval")
+	cmd.Flags().StringSliceVar(&opt.Labels, "labels", nil, "Only calibrate by records with these labels, all TiKV and TiFlash plans except index lookups, or all records in the search mode, if it's empty")
+	cmd.Flags().StringVar(&opt.Loss, "loss", cost.LossSquared, "The loss to minimize, squared, relative or log")
+	cmd.Flags().IntVar(&opt.Folds, "folds", 5, "The k of k-fold cross-validation, no cross-validation if it's less than 2")
+	cmd.Flags().IntVar(&opt.Bootstrap, "bootstrap", 200, "Resample records so many times to estimate confidence intervals of factors")
+	cmd.Flags().BoolVar(&opt.Search, "search", false, "Whether to search factors maximizing the metric by random search and coordinate descent instead of fitting execution time")
+	cmd.Flags().StringVar(&opt.Metric, "metric", cost.MetricKendall, "The metric maximized by search, kendall or plan-choice")
+	cmd.Flags().IntVar(&opt.SearchIters, "search-iters", 1000, "The number of random samples of search before coordinate descent")
+	cmd.Flags().IntVar(&opt.SearchTop, "search-top", 3, "The number of best factor vectors output by search")
+	cmd.Flags().IntVar(&opt.ModelVersion, "cost-model-version", 2, "The cost model version whose variables are set by scripts of calibrated factors, only 2 is supported since weights are collected from its cost traces")
+	cmd.Flags().BoolVar(&opt.Global, "global", false, "Whether scripts of calibrated factors set global variables instead of session variables")
+	cmd.Flags().BoolVar(&opt.Apply, "apply", false, "Whether to set calibrated factors on the instance and re-run evaluation queries to verify the improvement")
+	cmd.Flags().StringVar(&opt.Instance.Addr, "addr", "172.16.5.173", "The address of the TiDB instance to apply factors")
+	cmd.Flags().IntVar(&opt.Instance.Port, "port", 4000, "The port of the TiDB instance to apply factors")
+	cmd.Flags().StringVar(&opt.Instance.User, "user", "root", "The user of the TiDB instance to apply factors")
+	cmd.Flags().StringVar(&opt.Instance.Password, "password", "", "The password of the TiDB instance to apply factors")
+	cmd.Flags().StringVar(&opt.DB, "db", "synthetic", "The database of evaluation queries generated by cost-eval to verify factors")
 	return cmd
 }
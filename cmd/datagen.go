@@ -17,7 +17,7 @@ func newDatagenCmd() *cobra.Command {
 			if dataset == "" || dir == "" {
 				return errors.Errorf("invalid arguments")
 			}
-			return datagen.Generate(dataset, dsargs, dir)
+			return datagen.Generate(dataset, dsargs, dir, resolvedSeed())
 		},
 	}
 	cmd.Flags().StringVar(&dataset, "dataset", "", "Dataset name to generate")
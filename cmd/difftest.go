@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/difftest"
+	"github.com/spf13/cobra"
+)
+
+func newDiffTestCmd() *cobra.Command {
+	var opt difftest.Option
+	cmd := &cobra.Command{
+		Use:   "difftest -s query.sql --dsn \"root@tcp(127.0.0.1:4000)/db\" [--dsn ...] [--hints \"stream_agg(), agg_to_cop()\" ...]",
+		Short: "Differential Result Test across Plans forced by Hints and across Instances",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opt.QueryPath == "" {
+				return errors.New("no SQL file")
+			}
+			return difftest.RunDiffTest(opt)
+		},
+	}
+	cmd.Flags().StringVarP(&opt.QueryPath, "sql-file", "s", "", "SQL file or directory containing SQL files, like the output of querygen")
+	cmd.Flags().StringSliceVar(&opt.DSNs, "dsn", nil, "DSNs of instances like different versions of TiDB, where results of the first one without hints are the reference")
+	cmd.Flags().StringSliceVar(&opt.Labels, "labels", nil, "Labels of DSNs in the report")
+	cmd.Flags().StringArrayVar(&opt.HintSets, "hints", nil, "A set of hints forcing an alternative plan, where {table} is the first table of queries, default hint sets are used if not specified")
+	cmd.Flags().StringVarP(&opt.OutDir, "output-dir", "o", "difftest", "Directory to store the report")
+	cmd.Flags().IntVar(&opt.Concurrency, "concurrency", 4, "The number of queries tested concurrently")
+	cmd.Flags().BoolVar(&opt.Minimize, "minimize", true, "Whether to minimize conditions and hints of mismatched queries")
+	cmd.MarkFlagRequired("dsn")
+	return cmd
+}
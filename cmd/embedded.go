@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"github.com/qw4990/OptimizerTester/embedded"
+	"github.com/spf13/cobra"
+)
+
+// embeddedFlags are flags to run testers on an embedded TiDB server with datasets generated by datagen.
+type embeddedFlags struct {
+	enabled bool
+	args    string
+}
+
+func (f *embeddedFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.enabled, "embedded", false, "Whether to run on an embedded TiDB server with mock storage instead of a cluster")
+	cmd.Flags().StringVar(&f.args, "embedded-args", "x=1.5,n=10000,ndv=1000", "Arguments to generate datasets for the embedded server")
+}
+
+// prepare generates the dataset and loads it into the database of the embedded server.
+func (f *embeddedFlags) prepare(svr *embedded.Server, dataset, db string) error {
+	ins, err := svr.Connect("embedded")
+	if err != nil {
+		return err
+	}
+	defer ins.Close()
+	return embedded.PrepareDataset(ins, dataset, f.args, db, resolvedSeed())
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/qw4990/OptimizerTester/querygen"
+	"github.com/spf13/cobra"
+)
+
+func newOptBugsCmd() *cobra.Command {
+	var dsn, oracles []string
+	var outFile, dbName, tableName string
+	var n uint
+	cmd := &cobra.Command{
+		Use:   "optbugs",
+		Short: "Find logic bugs of the planner by TLP and NoREC on generated predicates",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return querygen.RunOptBugs(dsn, outFile, dbName, tableName, n, oracles, resolvedSeed())
+		},
+	}
+	cmd.Flags().StringSliceVar(&dsn, "dsn", nil, "DSN")
+	cmd.Flags().StringVarP(&outFile, "output-file", "o", "optbugs.sql", "File to store discrepancies with their SQLs")
+	cmd.Flags().StringVar(&dbName, "db", "", "Database Name")
+	cmd.Flags().StringVar(&tableName, "table", "", "Table Name")
+	cmd.Flags().UintVarP(&n, "query-num", "n", 300, "The number of predicates to check")
+	cmd.Flags().StringSliceVar(&oracles, "oracle", []string{querygen.OracleTLP, querygen.OracleNoREC}, "Oracles to check predicates: tlp or norec")
+	cmd.MarkFlagRequired("dsn")
+	cmd.MarkFlagRequired("db")
+	cmd.MarkFlagRequired("table")
+	return cmd
+}
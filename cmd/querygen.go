@@ -13,7 +13,7 @@ func newQueryGenCmd() *cobra.Command {
 		Use:   "querygen",
 		Short: "Cardinality Estimation Benchmark",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return querygen.RunQueryGen(dsn, outDir, dbName, tableName, n)
+			return querygen.RunQueryGen(dsn, outDir, dbName, tableName, n, resolvedSeed())
 		},
 	}
 	cmd.Flags().StringSliceVar(&dsn, "dsn", nil, "DSN")
@@ -1,22 +1,68 @@
 package cmd
 
 import (
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/cetest"
+	"github.com/qw4990/OptimizerTester/tidb"
 	"github.com/spf13/cobra"
 )
 
 var (
+	recordPath string
+	replayPath string
+	seed       int64
+
 	rootCmd = &cobra.Command{
 		Use:   "optimizer-tester",
 		Short: "TiDB Optimizer Tester",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if recordPath != "" && replayPath != "" {
+				return errors.New("--record and --replay cannot be used together")
+			}
+			if recordPath != "" {
+				tidb.RecordTo(recordPath)
+			}
+			if replayPath != "" {
+				if err := tidb.ReplayFrom(replayPath); err != nil {
+					return err
+				}
+			}
+			if cmd.Flags().Changed("seed") {
+				return tidb.UseSeed(seed)
+			}
+			return nil
+		},
 	}
 )
 
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	if saveErr := tidb.SaveCassette(); saveErr != nil && err == nil { // save what has been recorded even if the command fails
+		err = saveErr
+	}
+	return err
+}
+
+// resolvedSeed returns the seed specified by --seed, or a random seed decided once if it's not specified.
+// The seed of the cassette replayed by --replay is used instead of a random one if there is one.
+func resolvedSeed() int64 {
+	if seed == 0 {
+		seed = cetest.ResolveSeed(0)
+		fmt.Printf("[optimizer-tester] use random seed=%v\n", seed)
+		if err := tidb.UseSeed(seed); err != nil { // it's the seed of the cassette if any, so this never fails
+			panic(err)
+		}
+	}
+	return seed
 }
 
 func init() {
 	cobra.OnInitialize()
+	rootCmd.PersistentFlags().StringVar(&recordPath, "record", "", "Record all SQL statements and their results into this cassette file")
+	rootCmd.PersistentFlags().Int64Var(&seed, "seed", 0, "The seed of all random generators, a random one is used and printed if it's 0")
+	rootCmd.PersistentFlags().StringVar(&replayPath, "replay", "", "Replay SQL results from this cassette file instead of accessing databases")
 	rootCmd.AddCommand(newCETestCmd())
 	rootCmd.AddCommand(newDatagenCmd())
 	rootCmd.AddCommand(newCEBenchCmd())
@@ -24,4 +70,7 @@ func init() {
 	rootCmd.AddCommand(newCostEvalCmd())
 	rootCmd.AddCommand(newCostCaliCmd())
 	rootCmd.AddCommand(newQueryGenCmd())
+	rootCmd.AddCommand(newOptBugsCmd())
+	rootCmd.AddCommand(newStatsSimCmd())
+	rootCmd.AddCommand(newDiffTestCmd())
 }
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/statssim"
+	"github.com/spf13/cobra"
+)
+
+func newStatsSimCmd() *cobra.Command {
+	var opt statssim.Option
+	cmd := &cobra.Command{
+		Use:   "statssim",
+		Short: "Estimate queries offline by TiDB stats dumps",
+	}
+	cmd.PersistentFlags().StringArrayVar(&opt.Dumps, "dump", nil, "The stats dump file returned by /stats/dump/{db}/{table}")
+	cmd.PersistentFlags().StringVar(&opt.Partition, "partition", statssim.GlobalPartition, "The partition whose stats are used for partitioned tables")
+	cmd.PersistentFlags().StringArrayVar(&opt.Indexes, "index", nil, "Columns of an index which are not in stats dumps, e.g. db.t.idx_ab(a,b) or db.t.uk_a(a):unique")
+	cmd.PersistentFlags().StringArrayVar(&opt.Kinds, "kind", nil, "The kind of a column overriding the inferred one, e.g. db.t.a=string, kinds are int, uint, float and string")
+	cmd.AddCommand(newStatsSimFetchCmd())
+	cmd.AddCommand(newStatsSimEstimateCmd(&opt))
+	cmd.AddCommand(newStatsSimCETestCmd(&opt))
+	cmd.AddCommand(newStatsSimCEBenchCmd(&opt))
+	return cmd
+}
+
+func newStatsSimFetchCmd() *cobra.Command {
+	var statusAddr, db, outDir string
+	var tables []string
+	cmd := &cobra.Command{
+		Use:   "fetch --status-addr 127.0.0.1:10080 --db imdb -t title -t cast_info [-o stats]",
+		Short: "Fetch stats dumps of tables from a TiDB server",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := os.MkdirAll(outDir, 0777); err != nil {
+				return errors.Trace(err)
+			}
+			for _, t := range tables {
+				data, err := statssim.FetchDump(statusAddr, db, t)
+				if err != nil {
+					return err
+				}
+				file := path.Join(outDir, fmt.Sprintf("%v.%v.json", db, t))
+				if err := ioutil.WriteFile(file, data, 0666); err != nil {
+					return errors.Trace(err)
+				}
+				fmt.Printf("[statssim] save stats of %v.%v into %v\n", db, t, file)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&statusAddr, "status-addr", "127.0.0.1:10080", "The status address of the TiDB server")
+	cmd.Flags().StringVar(&db, "db", "", "The database of tables")
+	cmd.Flags().StringArrayVarP(&tables, "table", "t", nil, "The table to fetch stats of")
+	cmd.Flags().StringVarP(&outDir, "output-dir", "o", "stats", "Directory to store stats dumps")
+	return cmd
+}
+
+func newStatsSimEstimateCmd(opt *statssim.Option) *cobra.Command {
+	var sqls []string
+	cmd := &cobra.Command{
+		Use:   "estimate --dump db.t.json --sql \"SELECT * FROM db.t WHERE a > 1\"",
+		Short: "Estimate the number of rows of queries",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sim, err := statssim.NewSimulatorFromOption(*opt)
+			if err != nil {
+				return err
+			}
+			for _, sql := range sqls {
+				est, err := sim.EstimateSQL(sql)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%.2f\t%v\n", est, sql)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringArrayVar(&sqls, "sql", nil, "The query to estimate, like SELECT * FROM db.t WHERE cond")
+	return cmd
+}
+
+func newStatsSimCETestCmd(opt *statssim.Option) *cobra.Command {
+	var from, instance, outDir string
+	cmd := &cobra.Command{
+		Use:   "cetest --dump db.t.json --from <report-dir>/results.json [--instance label] [-o result]",
+		Short: "Estimate queries in CETest results offline and compare them with live estimations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from == "" {
+				return errors.New("no results file")
+			}
+			sim, err := statssim.NewSimulatorFromOption(*opt)
+			if err != nil {
+				return err
+			}
+			return statssim.CompareCETest(sim, from, instance, outDir)
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "The results file saved by cetest, e.g. <report-dir>/results.json")
+	cmd.Flags().StringVar(&instance, "instance", "", "The label of the instance whose queries are estimated, the first one by default")
+	cmd.Flags().StringVarP(&outDir, "output-dir", "o", "result", "Directory to store the results")
+	return cmd
+}
+
+func newStatsSimCEBenchCmd(opt *statssim.Option) *cobra.Command {
+	var jsonLocations []string
+	var outDir string
+	cmd := &cobra.Command{
+		Use:   "cebench --dump db.t.json -j full_est_info.json [-o result]",
+		Short: "Estimate records of optimizer traces in CEBench results offline and compare them with live estimations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sim, err := statssim.NewSimulatorFromOption(*opt)
+			if err != nil {
+				return err
+			}
+			return statssim.CompareCEBench(sim, jsonLocations, outDir)
+		},
+	}
+	cmd.Flags().StringArrayVarP(&jsonLocations, "json", "j", nil, "The JSON file containing bench intermediate result")
+	cmd.Flags().StringVarP(&outDir, "output-dir", "o", "result", "Directory to store the results")
+	return cmd
+}
@@ -0,0 +1,310 @@
+package cost
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// costFactorIndex returns the index in CostWeights of the factor in cost formulas of cost model v2,
+// like tidb_cpu_factor or tikv_scan_factor, or -1 if the factor is not in CostWeights.
+func costFactorIndex(factor string) int {
+	switch factor {
+	case "tidb_cpu_factor":
+		return 0 // CPU
+	case "tikv_cpu_factor", "tiflash_cpu_factor":
+		return 1 // CopCPU
+	case "tikv_scan_factor":
+		return 3 // Scan
+	case "tikv_desc_scan_factor":
+		return 4 // DescScan
+	case "tidb_request_factor":
+		return 6 // Seek
+	case "tiflash_scan_factor":
+		return 7 // TiFlashScan
+	}
+	switch {
+	case strings.HasSuffix(factor, "_net_factor"): // tidb_kv_net_factor, tidb_flash_net_factor, tiflash_mpp_net_factor
+		return 2 // Net
+	case strings.HasSuffix(factor, "_mem_factor"): // tidb_mem_factor, tikv_mem_factor, tiflash_mem_factor
+		return 5 // Mem
+	}
+	return -1
+}
+
+// linearCost is a cost which is linear to cost factors: constant + sum(weights[i] * factors[i]).
+type linearCost struct {
+	constant float64
+	weights  CostWeights
+}
+
+func (c linearCost) isConstant() bool {
+	return c.weights.IsZero()
+}
+
+// formulaParser parses cost formulas of cost model v2 like
+// "((scan(10000*logrowsize(32)*tikv_scan_factor(40.7))) + (net(10000*rowsize(16)*tidb_kv_net_factor(3.96))))/15.00"
+// into linear costs, and records values of factors in formulas.
+type formulaParser struct {
+	formula string
+	pos     int
+	factors CostFactors
+}
+
+func (p *formulaParser) skipSpaces() {
+	for p.pos < len(p.formula) && p.formula[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *formulaParser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.formula) {
+		return 0
+	}
+	return p.formula[p.pos]
+}
+
+func (p *formulaParser) expect(c byte) error {
+	if p.peek() != c {
+		return errors.Errorf("expect '%c' at %v of formula %v", c, p.pos, p.formula)
+	}
+	p.pos++
+	return nil
+}
+
+// parseExpr parses "term {(+|-) term}".
+func (p *formulaParser) parseExpr() (linearCost, error) {
+	lc, err := p.parseTerm()
+	if err != nil {
+		return lc, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		rc, err := p.parseTerm()
+		if err != nil {
+			return lc, err
+		}
+		sign := 1.0
+		if op == '-' {
+			sign = -1
+		}
+		lc.constant += sign * rc.constant
+		for i := range lc.weights {
+			lc.weights[i] += sign * rc.weights[i]
+		}
+	}
+	return lc, nil
+}
+
+// parseTerm parses "factor {(*|/) factor}", where at most one side of * and the divisor must be constant.
+func (p *formulaParser) parseTerm() (linearCost, error) {
+	lc, err := p.parseFactor()
+	if err != nil {
+		return lc, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		rc, err := p.parseFactor()
+		if err != nil {
+			return lc, err
+		}
+		if !lc.isConstant() && !rc.isConstant() || op == '/' && !rc.isConstant() {
+			return lc, errors.Errorf("the formula %v is not linear to cost factors", p.formula)
+		}
+		if lc.isConstant() && op == '*' {
+			lc, rc = rc, lc
+		}
+		k := rc.constant
+		if op == '/' {
+			k = 1 / k
+		}
+		lc.constant *= k
+		for i := range lc.weights {
+			lc.weights[i] *= k
+		}
+	}
+	return lc, nil
+}
+
+// parseFactor parses a number, "(expr)", "-factor" or "name(expr)", where factors like tikv_scan_factor(40.7)
+// are weights of 1, functions like logrowsize(32) are log2 of their arguments, and other functions like cpu(...)
+// and rowsize(16) are their arguments.
+func (p *formulaParser) parseFactor() (linearCost, error) {
+	switch c := p.peek(); {
+	case c == '(':
+		p.pos++
+		lc, err := p.parseExpr()
+		if err != nil {
+			return lc, err
+		}
+		return lc, p.expect(')')
+	case c == '-':
+		p.pos++
+		lc, err := p.parseFactor()
+		lc.constant = -lc.constant
+		for i := range lc.weights {
+			lc.weights[i] = -lc.weights[i]
+		}
+		return lc, err
+	case c >= '0' && c <= '9' || c == '.':
+		begin := p.pos
+		for p.pos < len(p.formula) && strings.IndexByte("0123456789.eE", p.formula[p.pos]) >= 0 {
+			if (p.formula[p.pos] == 'e' || p.formula[p.pos] == 'E') && p.pos+1 < len(p.formula) &&
+				(p.formula[p.pos+1] == '+' || p.formula[p.pos+1] == '-') {
+				p.pos++
+			}
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.formula[begin:p.pos], 64)
+		return linearCost{constant: v}, errors.Annotatef(err, "formula %v", p.formula)
+	case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		begin := p.pos
+		for p.pos < len(p.formula) && (p.formula[p.pos] == '_' || p.formula[p.pos] >= 'a' && p.formula[p.pos] <= 'z' ||
+			p.formula[p.pos] >= 'A' && p.formula[p.pos] <= 'Z' || p.formula[p.pos] >= '0' && p.formula[p.pos] <= '9') {
+			p.pos++
+		}
+		name := p.formula[begin:p.pos]
+		if err := p.expect('('); err != nil {
+			return linearCost{}, err
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return arg, err
+		}
+		if err := p.expect(')'); err != nil {
+			return arg, err
+		}
+		switch {
+		case strings.HasSuffix(name, "_factor"):
+			if !arg.isConstant() {
+				return arg, errors.Errorf("the value of %v in formula %v is not a constant", name, p.formula)
+			}
+			idx := costFactorIndex(name)
+			if idx < 0 { // factors like tidb_disk_factor are not calibrated, so treat them as constants
+				fmt.Printf("[cost-trace] unknown factor %v in formula %v is treated as a constant\n", name, p.formula)
+				return linearCost{constant: arg.constant}, nil
+			}
+			p.factors[idx] = arg.constant
+			var lc linearCost
+			lc.weights[idx] = 1
+			return lc, nil
+		case strings.HasPrefix(name, "log"):
+			if !arg.isConstant() {
+				return arg, errors.Errorf("the argument of %v in formula %v is not a constant", name, p.formula)
+			}
+			return linearCost{constant: math.Log2(arg.constant)}, nil
+		}
+		return arg, nil
+	}
+	return linearCost{}, errors.Errorf("unexpected character at %v of formula %v", p.pos, p.formula)
+}
+
+// parseCostFormula parses the cost formula into weights of cost factors and values of factors in the formula, and
+// returns the cost calculated by them.
+func parseCostFormula(formula string) (CostWeights, CostFactors, float64, error) {
+	p := &formulaParser{formula: formula}
+	lc, err := p.parseExpr()
+	if err != nil {
+		return CostWeights{}, CostFactors{}, 0, err
+	}
+	if p.peek() != 0 {
+		return CostWeights{}, CostFactors{}, 0, errors.Errorf("unexpected character at %v of formula %v", p.pos, formula)
+	}
+	return lc.weights, p.factors, lc.constant + lc.weights.CalCost(p.factors), nil
+}
+
+// costTraceOperator is an operator in the result of EXPLAIN FORMAT='cost_trace'.
+type costTraceOperator struct {
+	id      string
+	estCost float64
+	formula string
+}
+
+// costCloseTo returns whether the cost is within 1% of the expected one.
+func costCloseTo(cost, expected float64) bool {
+	return math.Abs(cost-expected) <= math.Max(0.01, 0.01*math.Abs(expected))
+}
+
+// costWeightsFromTrace returns weights and values of cost factors of the plan. If the formula of the root operator
+// covers the cost of the whole plan, its weights are returned, otherwise formulas of all operators are summed up.
+func costWeightsFromTrace(ops []costTraceOperator) (CostWeights, CostFactors, error) {
+	if len(ops) == 0 {
+		return CostWeights{}, CostFactors{}, errors.New("no operator in the cost trace")
+	}
+	var sumWeights CostWeights
+	var factors CostFactors
+	var sumCost float64
+	for i, op := range ops {
+		w, fs, cost, err := parseCostFormula(op.formula)
+		if err != nil {
+			return CostWeights{}, CostFactors{}, errors.Annotatef(err, "operator %v", op.id)
+		}
+		for k := range fs {
+			if fs[k] != 0 {
+				factors[k] = fs[k]
+			}
+		}
+		if i == 0 && costCloseTo(cost, op.estCost) {
+			return w, factors, nil
+		}
+		for k := range w {
+			sumWeights[k] += w[k]
+		}
+		sumCost += cost
+	}
+	if !costCloseTo(sumCost, ops[0].estCost) {
+		return CostWeights{}, CostFactors{}, errors.Errorf("costs calculated by formulas (root=%v, sum=%v) don't match the plan cost %v",
+			ops[0].id, sumCost, ops[0].estCost)
+	}
+	return sumWeights, factors, nil
+}
+
+// extractCostWeights runs EXPLAIN FORMAT='cost_trace' on the query, and returns weights and values of cost factors
+// of its plan. It panics if the server doesn't expose cost formulas, since records without weights cannot be used to
+// calibrate.
+func extractCostWeights(ins tidb.Instance, query string) (CostWeights, CostFactors) {
+	explain := `explain format='cost_trace' ` + query
+	rs, err := ins.Query(explain)
+	if err != nil {
+		panic(fmt.Sprintf("the server doesn't expose cost formulas by EXPLAIN FORMAT='cost_trace' of cost model v2: %v", err))
+	}
+	cols, err := rs.Columns()
+	checkErr(err)
+	idIdx, costIdx, formulaIdx := -1, -1, -1
+	for i, c := range cols {
+		switch strings.ToLower(c) {
+		case "id":
+			idIdx = i
+		case "estcost":
+			costIdx = i
+		case "costformula":
+			formulaIdx = i
+		}
+	}
+	if idIdx < 0 || costIdx < 0 || formulaIdx < 0 {
+		rs.Close()
+		panic(fmt.Sprintf("the server doesn't expose cost formulas, columns of %v are %v", explain, cols))
+	}
+
+	var ops []costTraceOperator
+	vals := make([]string, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	for rs.Next() {
+		checkErr(rs.Scan(dest...))
+		ops = append(ops, costTraceOperator{id: vals[idIdx], estCost: mustStr2Float(vals[costIdx]), formula: vals[formulaIdx]})
+	}
+	checkErr(rs.Close())
+	cw, fs, err := costWeightsFromTrace(ops)
+	if err != nil {
+		panic(fmt.Sprintf("cannot extract cost weights of %v: %v", query, err))
+	}
+	return cw, fs
+}
@@ -0,0 +1,60 @@
+package cost
+
+import (
+	"testing"
+)
+
+func TestParseCostFormula(t *testing.T) {
+	cases := []struct {
+		formula string
+		weights CostWeights
+		cost    float64
+	}{
+		{"(scan(1000*logrowsize(32)*tikv_scan_factor(4))) + (net(1000*rowsize(16)*tidb_kv_net_factor(5)))",
+			CostWeights{0, 0, 16000, 5000}, 100000},
+		{"((cpu(100*filters(2)*tikv_cpu_factor(30)) + seek(2*tidb_request_factor(6e+05))))/2.00",
+			CostWeights{0, 100, 0, 0, 0, 0, 1}, 603000},
+		{"cpu(10*tidb_cpu_factor(50)) - 1 + 10*tidb_disk_factor(1.5)", // unknown factors are constants
+			CostWeights{10}, 514},
+	}
+	for _, c := range cases {
+		w, _, cost, err := parseCostFormula(c.formula)
+		if err != nil {
+			t.Fatalf("parse %v: %v", c.formula, err)
+		}
+		if !w.EqualTo(c.weights) || cost != c.cost {
+			t.Fatalf("unexpected weights %v and cost %v of %v", w, cost, c.formula)
+		}
+	}
+
+	for _, formula := range []string{
+		"tidb_cpu_factor(1)*tikv_cpu_factor(2)",
+		"10/tidb_cpu_factor(1)",
+		"cpu(10*tidb_cpu_factor(1)",
+		"cpu(10*tidb_cpu_factor(1)))",
+	} {
+		if _, _, _, err := parseCostFormula(formula); err == nil {
+			t.Fatalf("expect an error when parsing %v", formula)
+		}
+	}
+}
+
+func TestCostWeightsFromTrace(t *testing.T) {
+	ops := []costTraceOperator{ // the root formula covers the whole plan
+		{"HashAgg_5", 1100, "cpu(10*tidb_cpu_factor(10)) + (scan(100*tikv_scan_factor(10)))"},
+		{"TableScan_4", 1000, "scan(100*tikv_scan_factor(10))"},
+	}
+	if cw, fs, err := costWeightsFromTrace(ops); err != nil || !cw.EqualTo(CostWeights{10, 0, 0, 100}) || fs != (CostFactors{10, 0, 0, 10}) {
+		t.Fatalf("unexpected weights %v, factors %v, err %v", cw, fs, err)
+	}
+
+	ops[0].formula = "cpu(10*tidb_cpu_factor(10))" // each formula only covers its own operator
+	if cw, fs, err := costWeightsFromTrace(ops); err != nil || !cw.EqualTo(CostWeights{10, 0, 0, 100}) || fs != (CostFactors{10, 0, 0, 10}) {
+		t.Fatalf("unexpected weights %v, factors %v, err %v", cw, fs, err)
+	}
+
+	ops[1].formula = "scan(50*tikv_scan_factor(10))"
+	if _, _, err := costWeightsFromTrace(ops); err == nil {
+		t.Fatalf("expect an error when costs of formulas don't match the plan cost")
+	}
+}
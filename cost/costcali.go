@@ -2,7 +2,11 @@ package cost
 
 import (
 	"fmt"
+	"math/rand"
 	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
 )
 
 const NumFactors = 8
@@ -47,63 +51,197 @@ func (cw CostWeights) CalCost(factors CostFactors) float64 {
 	return cost
 }
 
-// CostCalibration ...
-func CostCalibration() {
-	recordsPath := "./cost-calibration-data/synthetic-calibrated-records.json"
+// CaliOption is the option of cost model calibration.
+type CaliOption struct {
+	RecordsPath string   // records with weights of cost factors collected by cost evaluation
+	Labels      []string // only calibrate by records with these labels, defaultCaliLabels if it's empty
+	Loss        string   // LossSquared, LossRelative or LossLog
+	Folds       int      // the k of k-fold cross-validation, no cross-validation if it's less than 2
+	Bootstrap   int      // resample records so many times to estimate confidence intervals of factors
+	Seed        int64
+
+	Search      bool   // search factors maximizing the metric instead of fitting execution time
+	Metric      string // MetricKendall or MetricPlanChoice
+	SearchIters int    // the number of random samples before coordinate descent
+	SearchTop   int    // the number of best vectors to output
+
+	ModelVersion int         // the cost model version whose variables are set by scripts of factors, only 2 is valid
+	Global       bool        // whether scripts set global variables instead of session variables
+	Apply        bool        // whether to apply factors on the instance and re-run evaluation queries to verify them
+	Instance     tidb.Option // the instance to apply factors
+	DB           string      // the database of evaluation queries generated by cost-eval
+}
+
+// Validate checks whether the option is valid. Factors can only be exported to variables of cost model v2, since
+// weights of records are parsed from cost traces of cost model v2, whose formulas differ from ones of cost model v1.
+func (opt CaliOption) Validate() error {
+	switch opt.Loss {
+	case LossSquared, LossRelative, LossLog:
+	default:
+		return errors.Errorf("unknown loss %v", opt.Loss)
+	}
+	if opt.Search && opt.Metric != MetricKendall && opt.Metric != MetricPlanChoice {
+		return errors.Errorf("unknown metric %v", opt.Metric)
+	}
+	if opt.ModelVersion != 2 {
+		return errors.Errorf("factors calibrated from cost traces of cost model v2 can't be exported to cost model v%v", opt.ModelVersion)
+	}
+	return nil
+}
+
+var defaultCaliLabels = []string{
+	// TiKV Plans
+	"TableScan",
+	"IndexScan",
+	"WideTableScan",
+	"WideIndexScan",
+	"DescTableScan",
+	"DescIndexScan",
+	"StreamAgg",
+	"HashAgg",
+	"Sort",
+	"HashJoin",
+	"MergeJoin",
+	//"IndexLookup",
+	//"WideIndexLookup",
+
+	// TiFlash Plans
+	"TiFlashScan",
+	"TiFlashAgg",
+	"MPPScan",
+	"MPPTiDBAgg",
+	"MPPHJ",
+	//"MPP2PhaseAgg",
+	//"MPPBCJ",
+}
+
+// CostCalibration calibrates factors of each engine group by records, and draws costs recalculated by these factors.
+// Factors merged from all groups are written into cost-cali-factors.sql, and applied on the instance if required.
+// In the search mode, a single factor vector of all engines is searched to maximize a ranking metric instead.
+// Records with noisy execution time or without weights of factors are excluded.
+func CostCalibration(opt CaliOption) {
 	var rs Records
-	if err := readFrom(recordsPath, &rs); err != nil {
+	if err := readFrom(opt.RecordsPath, &rs); err != nil {
 		panic(err)
 	}
+	rs = recordsWithWeights(excludeNoisyRecords(rs))
+	if opt.Search { // all records are used to search factors unless labels are specified
+		if len(opt.Labels) > 0 {
+			rs = filterCaliRecordsByLabel(rs, opt.Labels, nil)
+		}
+		searchOnRecords(rs, opt, rand.New(rand.NewSource(opt.Seed)))
+		return
+	}
+	labels := opt.Labels
+	if len(labels) == 0 {
+		labels = defaultCaliLabels
+	}
+	rs = filterCaliRecordsByLabel(rs, labels, nil)
+
+	groups := make(map[string]Records)
+	for _, r := range rs {
+		g := caliGroupOf(r.Label)
+		groups[g] = append(groups[g], r)
+	}
 
-	whiteList := []string{
-		// TiKV Plans
-		"TableScan",
-		"IndexScan",
-		"WideTableScan",
-		"WideIndexScan",
-		"DescTableScan",
-		"DescIndexScan",
-		"StreamAgg",
-		"HashAgg",
-		"Sort",
-		"HashJoin",
-		"MergeJoin",
-		//"IndexLookup",
-		//"WideIndexLookup",
-
-		// TiFlash Plans
-		"TiFlashScan",
-		"TiFlashAgg",
-		"MPPScan",
-		"MPPTiDBAgg",
-		"MPPHJ",
-		//"MPP2PhaseAgg",
-		//"MPPBCJ",
-	}
-	rs = filterCaliRecordsByLabel(rs, whiteList, nil)
-
-	// ====== Manual Calibration ======
-	// (CPU, CopCPU, Net, Scan, DescScan, Mem, Seek, TiFlashScan)
-	// (30,	30,		4,		100,	150,		0,		1.2*1e7, 	10)
-	recalculateAndDraw(rs,
-		&CostFactors{30, 30, 4, 100, 150, 0, 1.2 * 1e7, 10}, // for TiDB Plans
-		&CostFactors{30, 2, 4, 100, 150, 0, 1.2 * 1e7, 4},   // for TiFlash Plans, 1) CopCPU is used as TiFlash CPU here
-		&CostFactors{30, 2, 1, 100, 150, 0, 1.2 * 1e7, 4}) // for MPP Plans, 1) it using stream net mode
-	//recalculateAndDraw(rs, nil, nil, nil)
-
-	// ====== Automatic Regression ======
 	// (CPU, CopCPU, Net, Scan, DescScan, Mem, Seek, TiFlashScan)
-	//rs = maskRecords(rs, [NumFactors]bool{false, false, true, true, false, false, false})
-	//ret := regressionCostFactors(rs)
-	//fmt.Println(ret.String())
+	rng := rand.New(rand.NewSource(opt.Seed))
+	var results []CaliResult
+	factors := make(map[string]*CostFactors)
+	for _, g := range caliGroups {
+		if len(groups[g]) == 0 {
+			continue
+		}
+		ret, err := calibrate(g, groups[g], opt, rng)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("[cost-cali] %v factors: %v\n", g, ret.Factors)
+		results = append(results, ret)
+		factors[g] = &ret.Factors
+	}
+	fmt.Println(caliReport(results, opt.Loss))
+	recalculateAndDraw(rs, factors[GroupTiDB], factors[GroupTiFlash], factors[GroupMPP])
+	if len(results) == 0 {
+		return
+	}
+	fs, from := mergeCaliResults(results)
+	writeFactorScript("cost-cali-factors.sql", fs, opt, fmt.Sprintf("factors calibrated with the %v loss, %v", opt.Loss, from))
+	if opt.Apply {
+		applyAndReport(fs, opt)
+	}
+}
+
+// mergeCaliResults merges factors of all engine groups into a single vector, since all groups share the same
+// variables of factors. Each factor is taken from the first group in caliGroups which uses it, and from describes
+// the groups where factors come from.
+func mergeCaliResults(results []CaliResult) (fs CostFactors, from string) {
+	var parts []string
+	var merged [NumFactors]bool
+	for _, r := range results {
+		var names []string
+		for k := range fs {
+			if r.Used[k] && !merged[k] {
+				fs[k], merged[k] = r.Factors[k], true
+				names = append(names, factorNames[k])
+			}
+		}
+		if len(names) > 0 {
+			parts = append(parts, fmt.Sprintf("%v of %v plans", strings.Join(names, ", "), r.Group))
+		}
+	}
+	return fs, strings.Join(parts, "; ")
+}
+
+// recordsWithWeights returns records with weights of factors, since costs of other records, like ones recorded
+// without cost traces of cost model v2, cannot be recalculated by any factor.
+func recordsWithWeights(rs Records) Records {
+	ret := make(Records, 0, len(rs))
+	for _, r := range rs {
+		if r.CostWeights.IsZero() {
+			fmt.Printf("[cost-cali] skip the record without weights %v %v\n", r.Label, r.SQL)
+			continue
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+// applyAndReport applies factors on the instance, and prints whether they improve Kendall τ of evaluation queries.
+func applyAndReport(fs CostFactors, opt CaliOption) {
+	ins, err := tidb.ConnectTo(opt.Instance)
+	if err != nil {
+		panic(err)
+	}
+	defer ins.Close()
+	before, after, err := applyAndVerify(ins, fs, opt)
+	if err != nil {
+		panic(err)
+	}
+	verdict := "improved"
+	if after <= before {
+		verdict = "NOT improved"
+	}
+	fmt.Printf("[cost-cali] Kendall τ of %v queries is %v by factors %v: %.4f -> %.4f\n", opt.DB, verdict, fs, before, after)
+}
+
+// recalculated returns copies of records whose costs are recalculated by the factors.
+func recalculated(rs Records, fs CostFactors) Records {
+	ret := make(Records, len(rs))
+	for i, r := range rs {
+		r.Cost = r.CostWeights.CalCost(fs)
+		ret[i] = r
+	}
+	return ret
 }
 
 func recalculateAndDraw(rs Records, fs4TiDB, fs4TiFlash, fs4MPP *CostFactors) {
 	for i := range rs {
 		fs := fs4TiDB
-		if strings.Contains(rs[i].Label, "TiFlash") {
+		switch caliGroupOf(rs[i].Label) {
+		case GroupTiFlash:
 			fs = fs4TiFlash
-		} else if strings.Contains(rs[i].Label, "MPP") {
+		case GroupMPP:
 			fs = fs4MPP
 		}
 		if fs != nil {
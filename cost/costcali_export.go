@@ -0,0 +1,126 @@
+package cost
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+var factorNames = [NumFactors]string{"CPU", "CopCPU", "Net", "Scan", "DescScan", "Mem", "Seek", "TiFlashScan"}
+
+// factorVariables are variables of factors of each cost model version, where factors without variables are empty.
+// Variables of cost model v2 are only available on TiDB builds exposing them, so they are checked before applied.
+var factorVariables = map[int][NumFactors]string{
+	1: {"tidb_opt_cpu_factor", "tidb_opt_copcpu_factor", "tidb_opt_network_factor", "tidb_opt_scan_factor",
+		"tidb_opt_desc_factor", "tidb_opt_memory_factor", "tidb_opt_seek_factor", ""},
+	2: {"tidb_opt_cpu_factor_v2", "tidb_opt_copcpu_factor_v2", "tidb_opt_network_factor_v2", "tidb_opt_scan_factor_v2",
+		"tidb_opt_desc_factor_v2", "tidb_opt_memory_factor_v2", "tidb_opt_seek_factor_v2", "tidb_opt_tiflash_scan_factor_v2"},
+}
+
+// factorStatements returns SET statements of factors for the cost model version with their variables, and comments
+// of factors which are not set since they have no variable or are not calibrated.
+func factorStatements(fs CostFactors, ver int, global bool) (stmts, vars, comments []string, err error) {
+	allVars, ok := factorVariables[ver]
+	if !ok {
+		return nil, nil, nil, errors.Errorf("unknown cost model version %v", ver)
+	}
+	scope := "session"
+	if global {
+		scope = "global"
+	}
+	for k, v := range allVars {
+		switch {
+		case fs[k] == 0:
+			comments = append(comments, fmt.Sprintf("%v is not calibrated", factorNames[k]))
+		case v == "":
+			comments = append(comments, fmt.Sprintf("%v=%.6g has no variable in cost model v%v", factorNames[k], fs[k], ver))
+		default:
+			stmts = append(stmts, fmt.Sprintf("set @@%v.%v=%.6g", scope, v, fs[k]))
+			vars = append(vars, v)
+		}
+	}
+	return stmts, vars, comments, nil
+}
+
+// FactorScript returns a SQL script setting variables of factors for the cost model version.
+func FactorScript(fs CostFactors, ver int, global bool, title string) (string, error) {
+	stmts, _, comments, err := factorStatements(fs, ver, global)
+	if err != nil {
+		return "", err
+	}
+	scope := "session"
+	if global {
+		scope = "global"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "-- %v\n", title)
+	fmt.Fprintf(&sb, "-- cost model v%v, %v\n", ver, fs)
+	fmt.Fprintf(&sb, "set @@%v.tidb_cost_model_version=%v;\n", scope, ver)
+	for _, c := range comments {
+		fmt.Fprintf(&sb, "-- %v\n", c)
+	}
+	for _, s := range stmts {
+		sb.WriteString(s + ";\n")
+	}
+	return sb.String(), nil
+}
+
+// writeFactorScript writes the SQL script of factors into the file.
+func writeFactorScript(f string, fs CostFactors, opt CaliOption, title string) {
+	script, err := FactorScript(fs, opt.ModelVersion, opt.Global, title)
+	if err != nil {
+		panic(err)
+	}
+	noerr(ioutil.WriteFile(f, []byte(script), 0666))
+	fmt.Printf("[cost-cali] the script setting factors is written to %v\n", f)
+}
+
+// applyAndVerify sets factors on the instance, and re-runs evaluation queries of the database to check whether
+// Kendall τ between costs and execution time is improved compared with default factors. Factors are only set on
+// sessions of the instance.
+func applyAndVerify(ins tidb.Instance, fs CostFactors, opt CaliOption) (before, after float64, err error) {
+	stmts, vars, _, err := factorStatements(fs, opt.ModelVersion, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	var missing []string
+	for _, v := range vars {
+		rs, err := ins.Query(fmt.Sprintf("show variables like '%v'", v))
+		if err != nil {
+			return 0, 0, err
+		}
+		if !rs.Next() {
+			missing = append(missing, v)
+		}
+		if err := rs.Close(); err != nil {
+			return 0, 0, errors.Trace(err)
+		}
+	}
+	if len(missing) > 0 {
+		return 0, 0, errors.Errorf("the instance doesn't support variables %v of cost model v%v", missing, opt.ModelVersion)
+	}
+
+	eo := &evalOpt{db: opt.DB, costModelVer: opt.ModelVersion, processRepeat: 2, processTimeLimitMS: 2000}
+	if err := eo.checkFeatures(ins); err != nil {
+		return 0, 0, err
+	}
+	var qs Queries
+	queryFile := filepath.Join("./cost-calibration-data", fmt.Sprintf("%v-queries.json", opt.DB))
+	if err := readFrom(queryFile, &qs); err != nil {
+		return 0, 0, errors.Annotatef(err, "read evaluation queries of %v generated by cost-eval", opt.DB)
+	}
+	if len(opt.Labels) > 0 {
+		qs = filterQueriesByLabel(qs, opt.Labels)
+	}
+
+	initSQLs := eo.InitSQLs(ins)
+	rs := runCostEvalQueries(ins, opt.DB, qs, initSQLs, explainTrueCardCost, eo.processRepeat, eo.processTimeLimitMS, false)
+	before = KendallCorrelationByRecords(excludeNoisyRecords(rs))
+	rs = runCostEvalQueries(ins, opt.DB, qs, append(initSQLs, stmts...), explainTrueCardCost, eo.processRepeat, eo.processTimeLimitMS, false)
+	after = KendallCorrelationByRecords(excludeNoisyRecords(rs))
+	return before, after, nil
+}
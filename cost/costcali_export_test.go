@@ -0,0 +1,101 @@
+package cost
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func TestFactorScript(t *testing.T) {
+	fs := CostFactors{30, 0, 4, 100, 150, 0, 1.2e7, 10} // (CPU, CopCPU, Net, Scan, DescScan, Mem, Seek, TiFlashScan)
+	script, err := FactorScript(fs, 1, false, "test factors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `-- test factors
+-- cost model v1, [CPU: 30.00, copCPU: 0.00, Net: 4.00, Scan: 100.00, DescScan: 150.00, Mem: 0.00, Seek: 12000000.00, TiFlashScan: 10.00]
+set @@session.tidb_cost_model_version=1;
+-- CopCPU is not calibrated
+-- Mem is not calibrated
+-- TiFlashScan=10 has no variable in cost model v1
+set @@session.tidb_opt_cpu_factor=30;
+set @@session.tidb_opt_network_factor=4;
+set @@session.tidb_opt_scan_factor=100;
+set @@session.tidb_opt_desc_factor=150;
+set @@session.tidb_opt_seek_factor=1.2e+07;
+`
+	if script != expected {
+		t.Fatalf("unexpected script:\n%v", script)
+	}
+
+	script, err = FactorScript(fs, 2, true, "test factors")
+	if err != nil || !strings.Contains(script, "set @@global.tidb_opt_tiflash_scan_factor_v2=10;\n") {
+		t.Fatalf("unexpected script:\n%v\nerr: %v", script, err)
+	}
+	if _, err := FactorScript(fs, 3, false, "test factors"); err == nil {
+		t.Fatalf("expect an error of the unknown cost model version")
+	}
+}
+
+func TestApplyUnsupportedFactors(t *testing.T) {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	ins.OnExactQuery("show variables like 'tidb_opt_cpu_factor'").Return([]string{"Variable_name", "Value"}, []interface{}{"tidb_opt_cpu_factor", "3"})
+	ins.OnQuery("^show variables like").Return([]string{"Variable_name", "Value"})
+	_, _, err := applyAndVerify(ins, CostFactors{30, 0, 4}, CaliOption{ModelVersion: 1, DB: "synthetic"})
+	if err == nil || !strings.Contains(err.Error(), "[tidb_opt_network_factor]") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if qs := ins.Queries(); len(qs) != 2 { // no factor is set
+		t.Fatalf("unexpected statements %v", qs)
+	}
+}
+
+func TestMergeCaliResults(t *testing.T) {
+	tidbRet := CaliResult{Group: GroupTiDB, Factors: CostFactors{30, 0, 4, 100, 0, 0, 1.2e7, 0}}
+	tidbRet.Used[0], tidbRet.Used[2], tidbRet.Used[3], tidbRet.Used[6] = true, true, true, true
+	tiflashRet := CaliResult{Group: GroupTiFlash, Factors: CostFactors{50, 0, 8, 0, 0, 0, 0, 10}}
+	tiflashRet.Used[0], tiflashRet.Used[2], tiflashRet.Used[7] = true, true, true
+
+	fs, from := mergeCaliResults([]CaliResult{tidbRet, tiflashRet})
+	if fs != (CostFactors{30, 0, 4, 100, 0, 0, 1.2e7, 10}) {
+		t.Fatalf("factors used by TiDB plans should be taken from them, got %v", fs)
+	}
+	if from != "CPU, Net, Scan, Seek of TiDB plans; TiFlashScan of TiFlash plans" {
+		t.Fatalf("unexpected sources %v", from)
+	}
+	script, err := FactorScript(fs, 2, false, "factors calibrated with the log loss, "+from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `-- factors calibrated with the log loss, CPU, Net, Scan, Seek of TiDB plans; TiFlashScan of TiFlash plans
+-- cost model v2, [CPU: 30.00, copCPU: 0.00, Net: 4.00, Scan: 100.00, DescScan: 0.00, Mem: 0.00, Seek: 12000000.00, TiFlashScan: 10.00]
+set @@session.tidb_cost_model_version=2;
+-- CopCPU is not calibrated
+-- DescScan is not calibrated
+-- Mem is not calibrated
+set @@session.tidb_opt_cpu_factor_v2=30;
+set @@session.tidb_opt_network_factor_v2=4;
+set @@session.tidb_opt_scan_factor_v2=100;
+set @@session.tidb_opt_seek_factor_v2=1.2e+07;
+set @@session.tidb_opt_tiflash_scan_factor_v2=10;
+`
+	if script != expected {
+		t.Fatalf("unexpected script:\n%v", script)
+	}
+}
+
+func TestCaliOptionValidate(t *testing.T) {
+	opt := CaliOption{Loss: LossLog, ModelVersion: 2}
+	if err := opt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	opt.ModelVersion = 1
+	if err := opt.Validate(); err == nil || !strings.Contains(err.Error(), "cost model v1") {
+		t.Fatalf("factors of cost model v2 shouldn't be exported to cost model v1, got %v", err)
+	}
+	opt.ModelVersion, opt.Search, opt.Metric = 2, true, "abs"
+	if err := opt.Validate(); err == nil {
+		t.Fatal("expect an error of the unknown metric")
+	}
+}
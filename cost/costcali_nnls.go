@@ -0,0 +1,411 @@
+package cost
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// Losses minimized by the calibration, where cost is the sum of weights multiplied by factors.
+const (
+	LossSquared  = "squared"  // sum((cost - time)^2)
+	LossRelative = "relative" // sum(((cost - time) / time)^2)
+	LossLog      = "log"      // sum((log(cost) - log(time))^2)
+)
+
+// Engine groups of plans, factors of each group are calibrated separately.
+const (
+	GroupTiDB    = "TiDB"
+	GroupTiFlash = "TiFlash"
+	GroupMPP     = "MPP"
+)
+
+var caliGroups = []string{GroupTiDB, GroupTiFlash, GroupMPP}
+
+// caliGroupOf returns the engine group of the plan label.
+func caliGroupOf(label string) string {
+	if strings.Contains(label, "TiFlash") {
+		return GroupTiFlash
+	} else if strings.Contains(label, "MPP") {
+		return GroupMPP
+	}
+	return GroupTiDB
+}
+
+// CaliResult is the calibration result of an engine group.
+type CaliResult struct {
+	Group      string
+	Records    int
+	Factors    CostFactors
+	Used       [NumFactors]bool // whether factors have non-zero weights in any record, other factors are always 0
+	Lower      CostFactors      // lower bounds of 95% confidence intervals of factors
+	Upper      CostFactors      // upper bounds of 95% confidence intervals of factors
+	TrainR2    float64
+	TrainTau   float64
+	HeldOutR2  float64 // R² of held-out records in cross-validation
+	HeldOutTau float64
+	Folds      int // the number of folds of cross-validation
+	Bootstrap  int // the number of bootstrap resamples
+
+	// folds and resamples are skipped if weights of their factors are linearly dependent
+	SkippedFolds     int
+	SkippedResamples int
+}
+
+// calibrate fits factors of records by non-negative least squares under the loss, evaluates the fit by k-fold
+// cross-validation, and estimates confidence intervals of factors by bootstrap.
+func calibrate(group string, rs Records, opt CaliOption, rng *rand.Rand) (CaliResult, error) {
+	ret := CaliResult{Group: group, Records: len(rs)}
+	if len(rs) == 0 {
+		return ret, errors.Errorf("no record to calibrate %v plans", group)
+	}
+	for _, r := range rs {
+		for k, w := range r.CostWeights {
+			ret.Used[k] = ret.Used[k] || w != 0
+		}
+	}
+
+	fs, err := fitFactors(rs, opt.Loss)
+	if err != nil {
+		return ret, err
+	}
+	ret.Factors = fs
+	ret.TrainR2, ret.TrainTau = fitQuality(rs, fs)
+
+	ret.HeldOutR2, ret.HeldOutTau = math.NaN(), math.NaN()
+	if opt.Folds > 1 && len(rs) >= opt.Folds {
+		ret.Folds = opt.Folds
+		perm := rng.Perm(len(rs))
+		heldOut := make(Records, 0, len(rs))
+		for fold := 0; fold < opt.Folds; fold++ {
+			var train, test Records
+			for i, idx := range perm {
+				if i%opt.Folds == fold {
+					test = append(test, rs[idx])
+				} else {
+					train = append(train, rs[idx])
+				}
+			}
+			fs, err := fitFactors(train, opt.Loss)
+			if errors.Cause(err) == errLinearlyDependent { // folds may lack records distinguishing factors
+				ret.SkippedFolds++
+				continue
+			} else if err != nil {
+				return ret, err
+			}
+			heldOut = append(heldOut, recalculated(test, fs)...)
+		}
+		if ret.SkippedFolds > 0 {
+			fmt.Printf("[cost-cali] %v of %v folds of %v plans are skipped since weights of their factors are linearly dependent\n",
+				ret.SkippedFolds, ret.Folds, group)
+		}
+		if len(heldOut) > 0 {
+			ret.HeldOutR2, ret.HeldOutTau = rSquared(heldOut), KendallCorrelationByRecords(heldOut)
+		}
+	}
+
+	ret.Lower, ret.Upper = fs, fs
+	if opt.Bootstrap > 0 {
+		ret.Bootstrap = opt.Bootstrap
+		samples := make([][]float64, NumFactors)
+		resampled := make(Records, len(rs))
+		for b := 0; b < opt.Bootstrap; b++ {
+			for i := range resampled {
+				resampled[i] = rs[rng.Intn(len(rs))]
+			}
+			fs, err := fitFactors(resampled, opt.Loss)
+			if errors.Cause(err) == errLinearlyDependent { // resamples may lack records distinguishing factors
+				ret.SkippedResamples++
+				continue
+			} else if err != nil {
+				return ret, err
+			}
+			for k := range fs {
+				samples[k] = append(samples[k], fs[k])
+			}
+		}
+		if ret.SkippedResamples > 0 {
+			fmt.Printf("[cost-cali] %v of %v bootstrap resamples of %v plans are skipped since weights of their factors are linearly dependent\n",
+				ret.SkippedResamples, ret.Bootstrap, group)
+		}
+		for k := range samples {
+			if len(samples[k]) == 0 { // all resamples are skipped
+				break
+			}
+			sort.Float64s(samples[k])
+			ret.Lower[k] = quantile(samples[k], 0.025)
+			ret.Upper[k] = quantile(samples[k], 0.975)
+		}
+	}
+	return ret, nil
+}
+
+// fitQuality returns R² and Kendall τ between costs calculated by factors and execution time of records.
+func fitQuality(rs Records, fs CostFactors) (r2, tau float64) {
+	rs = recalculated(rs, fs)
+	return rSquared(rs), KendallCorrelationByRecords(rs)
+}
+
+// rSquared returns the coefficient of determination of costs of records as predictions of their execution time.
+func rSquared(rs Records) float64 {
+	var avg float64
+	for _, r := range rs {
+		avg += r.TimeMS
+	}
+	avg /= float64(len(rs))
+	var ssRes, ssTot float64
+	for _, r := range rs {
+		ssRes += (r.TimeMS - r.Cost) * (r.TimeMS - r.Cost)
+		ssTot += (r.TimeMS - avg) * (r.TimeMS - avg)
+	}
+	if ssTot == 0 {
+		return math.NaN()
+	}
+	return 1 - ssRes/ssTot
+}
+
+// quantile returns the q-quantile of sorted values by linear interpolation.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	l := int(math.Floor(pos))
+	if l+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[l] + (pos-float64(l))*(sorted[l+1]-sorted[l])
+}
+
+// fitFactors returns non-negative factors minimizing the loss between costs and execution time of records.
+// The log loss is minimized by Gauss-Newton iterations starting from the solution of the relative loss, whose
+// linearized problems are non-negative least squares as well.
+func fitFactors(rs Records, loss string) (CostFactors, error) {
+	a := make([][]float64, len(rs))
+	b := make([]float64, len(rs))
+	for i, r := range rs {
+		if r.TimeMS <= 0 && loss != LossSquared {
+			return CostFactors{}, errors.Errorf("the %v loss requires positive execution time, got %v of %v", loss, r.TimeMS, r.SQL)
+		}
+		a[i] = append([]float64(nil), r.CostWeights[:]...)
+		b[i] = r.TimeMS
+	}
+
+	switch loss {
+	case LossSquared:
+		return scaledNNLS(a, b)
+	case LossRelative, LossLog:
+		ra := make([][]float64, len(rs))
+		rb := make([]float64, len(rs))
+		for i := range a {
+			ra[i] = scaleRow(a[i], 1/b[i])
+			rb[i] = 1
+		}
+		fs, err := scaledNNLS(ra, rb)
+		if err != nil || loss == LossRelative {
+			return fs, err
+		}
+		for iter := 0; iter < 50; iter++ {
+			for i := range a {
+				cost := rs[i].CostWeights.CalCost(fs)
+				if cost <= 0 { // linearize around the actual time if the cost is not positive
+					cost = b[i]
+				}
+				ra[i] = scaleRow(a[i], 1/cost)
+				rb[i] = 1 + math.Log(b[i]) - math.Log(cost)
+			}
+			next, err := scaledNNLS(ra, rb)
+			if err != nil {
+				return fs, err
+			}
+			var diff, norm float64
+			for k := range next {
+				diff += (next[k] - fs[k]) * (next[k] - fs[k])
+				norm += next[k] * next[k]
+			}
+			fs = next
+			if diff <= 1e-12*norm {
+				break
+			}
+		}
+		return fs, nil
+	}
+	return CostFactors{}, errors.Errorf("unknown loss %v", loss)
+}
+
+func scaleRow(row []float64, k float64) []float64 {
+	ret := make([]float64, len(row))
+	for i := range row {
+		ret[i] = row[i] * k
+	}
+	return ret
+}
+
+// scaledNNLS solves the non-negative least squares problem after scaling columns of A by their max absolute values,
+// since weights of different factors usually differ by orders of magnitude.
+func scaledNNLS(a [][]float64, b []float64) (CostFactors, error) {
+	var scale [NumFactors]float64
+	for _, row := range a {
+		for k, v := range row {
+			scale[k] = math.Max(scale[k], math.Abs(v))
+		}
+	}
+	sa := make([][]float64, len(a))
+	for i, row := range a {
+		sa[i] = make([]float64, NumFactors)
+		for k, v := range row {
+			if scale[k] > 0 {
+				sa[i][k] = v / scale[k]
+			}
+		}
+	}
+	x, err := nnls(sa, b)
+	var fs CostFactors
+	for k := range fs {
+		if scale[k] > 0 {
+			fs[k] = x[k] / scale[k]
+		}
+	}
+	return fs, err
+}
+
+// nnls solves min ||Ax - b|| subject to x >= 0 by the active set method of Lawson and Hanson.
+func nnls(a [][]float64, b []float64) ([]float64, error) {
+	n := 0
+	if len(a) > 0 {
+		n = len(a[0])
+	}
+	x := make([]float64, n)
+	passive := make([]bool, n)
+	gradient := func() []float64 { // A^T(b - Ax)
+		w := make([]float64, n)
+		for i, row := range a {
+			res := b[i]
+			for k, v := range row {
+				res -= v * x[k]
+			}
+			for k, v := range row {
+				w[k] += v * res
+			}
+		}
+		return w
+	}
+	const tol = 1e-10
+	for iter := 0; iter < 10*(n+1); iter++ {
+		w := gradient()
+		j := -1
+		for k := range w {
+			if !passive[k] && w[k] > tol && (j < 0 || w[k] > w[j]) {
+				j = k
+			}
+		}
+		if j < 0 {
+			return x, nil
+		}
+		passive[j] = true
+		for {
+			z, err := leastSquares(a, b, passive)
+			if err != nil {
+				return x, err
+			}
+			alpha := 1.0
+			for k := range z {
+				if passive[k] && z[k] <= tol && x[k]-z[k] > 0 {
+					alpha = math.Min(alpha, x[k]/(x[k]-z[k]))
+				}
+			}
+			for k := range x {
+				x[k] += alpha * (z[k] - x[k])
+			}
+			if alpha == 1 {
+				break
+			}
+			for k := range x {
+				if passive[k] && x[k] <= tol {
+					passive[k], x[k] = false, 0
+				}
+			}
+		}
+	}
+	return x, errors.New("non-negative least squares doesn't converge")
+}
+
+var errLinearlyDependent = errors.New("weights of factors are linearly dependent, try to calibrate fewer factors")
+
+// leastSquares solves min ||Ax - b|| on columns in the passive set by normal equations, and other elements of x are 0.
+func leastSquares(a [][]float64, b []float64, passive []bool) ([]float64, error) {
+	var cols []int
+	for k, p := range passive {
+		if p {
+			cols = append(cols, k)
+		}
+	}
+	m := len(cols)
+	ata := make([][]float64, m) // augmented matrix [A^T A | A^T b]
+	for i := range ata {
+		ata[i] = make([]float64, m+1)
+	}
+	for r, row := range a {
+		for i, ci := range cols {
+			for j, cj := range cols {
+				ata[i][j] += row[ci] * row[cj]
+			}
+			ata[i][m] += row[ci] * b[r]
+		}
+	}
+	for i := 0; i < m; i++ { // Gaussian elimination with partial pivoting
+		p := i
+		for r := i + 1; r < m; r++ {
+			if math.Abs(ata[r][i]) > math.Abs(ata[p][i]) {
+				p = r
+			}
+		}
+		if math.Abs(ata[p][i]) < 1e-12 {
+			return nil, errLinearlyDependent
+		}
+		ata[i], ata[p] = ata[p], ata[i]
+		for r := i + 1; r < m; r++ {
+			k := ata[r][i] / ata[i][i]
+			for c := i; c <= m; c++ {
+				ata[r][c] -= k * ata[i][c]
+			}
+		}
+	}
+	z := make([]float64, len(passive))
+	for i := m - 1; i >= 0; i-- {
+		v := ata[i][m]
+		for j := i + 1; j < m; j++ {
+			v -= ata[i][j] * z[cols[j]]
+		}
+		z[cols[i]] = v / ata[i][i]
+	}
+	return z, nil
+}
+
+// caliReport returns a markdown report of calibration results.
+func caliReport(results []CaliResult, loss string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Cost Calibration (loss=%v)\n", loss)
+	fmt.Fprintf(&sb, "\n| Group | Records | R² | Kendall τ | Held-out R² | Held-out Kendall τ |\n|---|---|---|---|---|---|\n")
+	for _, r := range results {
+		fmt.Fprintf(&sb, "| %v | %v | %.4f | %.4f | %.4f | %.4f |\n", r.Group, r.Records, r.TrainR2, r.TrainTau, r.HeldOutR2, r.HeldOutTau)
+	}
+	for _, r := range results {
+		fmt.Fprintf(&sb, "\n## %v\n\n| Factor | Value | 95%% CI |\n|---|---|---|\n", r.Group)
+		for k, name := range factorNames {
+			if !r.Used[k] {
+				fmt.Fprintf(&sb, "| %v | - | - |\n", name)
+				continue
+			}
+			fmt.Fprintf(&sb, "| %v | %.4g | [%.4g, %.4g] |\n", name, r.Factors[k], r.Lower[k], r.Upper[k])
+		}
+		if r.SkippedFolds > 0 {
+			fmt.Fprintf(&sb, "\n%v of %v folds are skipped since weights of their factors are linearly dependent.\n", r.SkippedFolds, r.Folds)
+		}
+		if r.SkippedResamples > 0 {
+			fmt.Fprintf(&sb, "\n%v of %v bootstrap resamples are skipped since weights of their factors are linearly dependent.\n", r.SkippedResamples, r.Bootstrap)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,97 @@
+package cost
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestNNLS(t *testing.T) {
+	// x = (1, 2) fits exactly
+	x, err := nnls([][]float64{{1, 0}, {0, 1}, {1, 1}}, []float64{1, 2, 3})
+	if err != nil || math.Abs(x[0]-1) > 1e-9 || math.Abs(x[1]-2) > 1e-9 {
+		t.Fatalf("unexpected solution %v, err %v", x, err)
+	}
+
+	// the unconstrained solution is (3, -1), and the non-negative one is (1.5, 0)
+	x, err = nnls([][]float64{{1, 1}, {1, 2}}, []float64{2, 1})
+	if err != nil || math.Abs(x[0]-1.5) > 1e-9 || x[1] != 0 {
+		t.Fatalf("unexpected solution %v, err %v", x, err)
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	truth := CostFactors{0, 0, 4, 100, 0, 0, 1e6} // (CPU, CopCPU, Net, Scan, DescScan, Mem, Seek, TiFlashScan)
+	rng := rand.New(rand.NewSource(1))
+	var rs Records
+	for i := 0; i < 60; i++ {
+		cw := CostWeights{0, 0, float64(rng.Intn(100000)), float64(rng.Intn(10000)), 0, 0, float64(1 + rng.Intn(10))}
+		rs = append(rs, Record{Label: "TableScan", CostWeights: cw, TimeMS: cw.CalCost(truth) * (1 + 0.01*rng.Float64())})
+	}
+
+	for _, loss := range []string{LossSquared, LossRelative, LossLog} {
+		ret, err := calibrate(GroupTiDB, rs, CaliOption{Loss: loss, Folds: 5, Bootstrap: 50}, rng)
+		if err != nil {
+			t.Fatalf("calibrate with %v loss: %v", loss, err)
+		}
+		for k := range truth {
+			if math.Abs(ret.Factors[k]-truth[k]) > 0.05*truth[k] {
+				t.Fatalf("unexpected factors %v with %v loss", ret.Factors, loss)
+			}
+			if ret.Used[k] && (ret.Lower[k] > ret.Factors[k] || ret.Upper[k] < ret.Factors[k]) {
+				t.Fatalf("unexpected confidence interval [%v, %v] of %v with %v loss", ret.Lower[k], ret.Upper[k], ret.Factors[k], loss)
+			}
+		}
+		if ret.TrainR2 < 0.99 || ret.HeldOutR2 < 0.99 || ret.HeldOutTau < 0.9 {
+			t.Fatalf("unexpected fit quality %+v with %v loss", ret, loss)
+		}
+		if report := caliReport([]CaliResult{ret}, loss); !strings.Contains(report, "| CPU | - | - |") {
+			t.Fatalf("unexpected report:\n%v", report)
+		}
+	}
+
+	if _, err := calibrate(GroupTiDB, rs, CaliOption{Loss: "abs"}, rng); err == nil {
+		t.Fatalf("expect an error of the unknown loss")
+	}
+}
+
+func TestCalibrateWithLinearlyDependentResamples(t *testing.T) {
+	rs := Records{ // some resamples contain only records whose weights of Net and Scan are proportional
+		{Label: "TableScan", CostWeights: CostWeights{0, 0, 1, 2, 0, 0, 1}, TimeMS: 1.2e6},
+		{Label: "TableScan", CostWeights: CostWeights{0, 0, 2, 1, 0, 0, 1}, TimeMS: 1.5e6},
+		{Label: "TableScan", CostWeights: CostWeights{0, 0, 3, 3, 0, 0, 1}, TimeMS: 1.9e6},
+	}
+	ret, err := calibrate(GroupTiDB, rs, CaliOption{Loss: LossSquared, Bootstrap: 20}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.SkippedResamples == 0 || ret.SkippedResamples == ret.Bootstrap {
+		t.Fatalf("some resamples should be skipped, got %v of %v", ret.SkippedResamples, ret.Bootstrap)
+	}
+	report := caliReport([]CaliResult{ret}, LossSquared)
+	if !strings.Contains(report, fmt.Sprintf("%v of 20 bootstrap resamples are skipped", ret.SkippedResamples)) {
+		t.Fatalf("unexpected report:\n%v", report)
+	}
+}
+
+func TestCalibrateWithLinearlyDependentFolds(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var rs Records
+	for i := 0; i < 4; i++ { // weights of Scan are always twice of Net except the last record
+		n := float64(1 + rng.Intn(5))
+		rs = append(rs, Record{Label: "TableScan", CostWeights: CostWeights{0, 0, n, 2 * n, 0, 0, 1}, TimeMS: 1e6 * (1 + rng.Float64())})
+	}
+	rs = append(rs, Record{Label: "TableScan", CostWeights: CostWeights{0, 0, 2, 1, 0, 0, 1}, TimeMS: 1.5e6})
+	ret, err := calibrate(GroupTiDB, rs, CaliOption{Loss: LossSquared, Folds: 5}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret.SkippedFolds != 1 || ret.Folds != 5 || math.IsNaN(ret.HeldOutR2) {
+		t.Fatalf("the fold without the last record should be skipped, got %+v", ret)
+	}
+	if report := caliReport([]CaliResult{ret}, LossSquared); !strings.Contains(report, "1 of 5 folds are skipped") {
+		t.Fatalf("unexpected report:\n%v", report)
+	}
+}
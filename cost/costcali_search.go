@@ -0,0 +1,152 @@
+package cost
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// Metrics maximized by the search of cost factors.
+const (
+	MetricKendall    = "kendall"     // Kendall τ between costs and execution time of all records
+	MetricPlanChoice = "plan-choice" // the ratio of queries whose cheapest candidate plan is the fastest one
+)
+
+// defaultSearchBounds are bounds of factors searched by CostCalibration, which cover factors of cost model v2.
+// (CPU, CopCPU, Net, Scan, DescScan, Mem, Seek, TiFlashScan)
+var defaultSearchBounds = [NumFactors][2]float64{{1, 200}, {1, 200}, {0.1, 50}, {1, 1000}, {1, 2000}, {0.01, 10}, {1e4, 1e8}, {0.1, 100}}
+
+// SearchResult is a factor vector found by search with its score.
+type SearchResult struct {
+	Factors CostFactors
+	Score   float64
+}
+
+// searchScore returns the score of factors on records by the metric.
+func searchScore(rs Records, fs CostFactors, metric string) float64 {
+	rs = recalculated(rs, fs)
+	if metric == MetricKendall {
+		return KendallCorrelationByRecords(rs)
+	}
+	pcs := EvalPlanChoices(rs)
+	if len(pcs) == 0 {
+		return math.NaN()
+	}
+	var optimal int
+	for _, pc := range pcs {
+		if pc.Chosen.SQL == pc.Best.SQL {
+			optimal++
+		}
+	}
+	return float64(optimal) / float64(len(pcs))
+}
+
+// searchFactors searches factors within bounds maximizing the metric on records, by random search followed by
+// coordinate descent from the top n samples, and returns the best n vectors in descending order of scores.
+// Factors are sampled and stepped in the log space since they differ by orders of magnitude, and factors
+// without weights in any record are always 0.
+func searchFactors(rs Records, metric string, bounds [NumFactors][2]float64, iters, n int, rng *rand.Rand) ([]SearchResult, error) {
+	if metric != MetricKendall && metric != MetricPlanChoice {
+		return nil, errors.Errorf("unknown metric %v", metric)
+	}
+	if metric == MetricPlanChoice && len(EvalPlanChoices(rs)) == 0 {
+		return nil, errors.New("no query with at least 2 candidate plans in records")
+	}
+	var used []int
+	for k := 0; k < NumFactors; k++ {
+		for _, r := range rs {
+			if r.CostWeights[k] != 0 {
+				used = append(used, k)
+				break
+			}
+		}
+		if bounds[k][0] <= 0 || bounds[k][0] > bounds[k][1] {
+			return nil, errors.Errorf("invalid bounds %v of the factor %v", bounds[k], k)
+		}
+	}
+	if len(used) == 0 {
+		return nil, errors.New("no record with weights of factors")
+	}
+
+	var results []SearchResult
+	for i := 0; i < iters; i++ {
+		var fs CostFactors
+		for _, k := range used {
+			lo, hi := math.Log(bounds[k][0]), math.Log(bounds[k][1])
+			fs[k] = math.Exp(lo + rng.Float64()*(hi-lo))
+		}
+		results = append(results, SearchResult{fs, searchScore(rs, fs, metric)})
+	}
+	sortSearchResults(results)
+	if len(results) > n {
+		results = results[:n]
+	}
+
+	for i := range results {
+		best := results[i]
+		for step := 2.0; step > 1.01; step = math.Sqrt(step) {
+			for improved := true; improved; {
+				improved = false
+				for _, k := range used {
+					for _, mul := range []float64{step, 1 / step} {
+						fs := best.Factors
+						fs[k] = math.Max(bounds[k][0], math.Min(bounds[k][1], fs[k]*mul))
+						if score := searchScore(rs, fs, metric); score > best.Score {
+							best, improved = SearchResult{fs, score}, true
+						}
+					}
+				}
+			}
+		}
+		results[i] = best
+	}
+	sortSearchResults(results)
+	return results, nil
+}
+
+// sortSearchResults sorts results in descending order of scores, where NaN scores are the lowest.
+func sortSearchResults(results []SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score || !math.IsNaN(results[i].Score) && math.IsNaN(results[j].Score)
+	})
+}
+
+// searchOnRecords searches factors maximizing the metric, and prints the best vectors, draws their scatter plots and
+// writes their scripts.
+func searchOnRecords(rs Records, opt CaliOption, rng *rand.Rand) {
+	results, err := searchFactors(rs, opt.Metric, defaultSearchBounds, opt.SearchIters, opt.SearchTop, rng)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(searchReport(results, opt.Metric))
+	for i, ret := range results {
+		f := fmt.Sprintf("cost-cali-search-%v-%v.png", opt.Metric, i+1)
+		drawCostRecordsTo(recalculated(rs, ret.Factors), f)
+		fmt.Printf("[cost-cali] the scatter plot of the No.%v vector is drawn to %v\n", i+1, f)
+		writeFactorScript(fmt.Sprintf("cost-cali-search-%v-%v.sql", opt.Metric, i+1), ret.Factors, opt,
+			fmt.Sprintf("the No.%v factors searched by %v with the score %.4f", i+1, opt.Metric, ret.Score))
+	}
+	if opt.Apply && len(results) > 0 {
+		applyAndReport(results[0].Factors, opt)
+	}
+}
+
+// searchReport returns a markdown report of search results.
+func searchReport(results []SearchResult, metric string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Cost Factor Search (metric=%v)\n\n", metric)
+	sb.WriteString("| No. | Score | CPU | CopCPU | Net | Scan | DescScan | Mem | Seek | TiFlashScan |\n")
+	sb.WriteString("|---|---|---|---|---|---|---|---|---|---|\n")
+	for i, r := range results {
+		fmt.Fprintf(&sb, "| %v | %.4f |", i+1, r.Score)
+		for _, f := range r.Factors {
+			fmt.Fprintf(&sb, " %.4g |", f)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
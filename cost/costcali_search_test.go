@@ -0,0 +1,61 @@
+package cost
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSearchFactors(t *testing.T) {
+	truth := CostFactors{0, 0, 4, 100, 0, 0, 1e6} // (CPU, CopCPU, Net, Scan, DescScan, Mem, Seek, TiFlashScan)
+	rng := rand.New(rand.NewSource(1))
+	var rs Records
+	for i := 0; i < 40; i++ {
+		for j, label := range []string{"TableScan", "IndexLookup"} {
+			cw := CostWeights{0, 0, float64(rng.Intn(100000)), float64(rng.Intn(10000)), 0, 0, float64(1 + rng.Intn(10))}
+			rs = append(rs, Record{Label: label, Group: fmt.Sprintf("Scan#%v", i), SQL: fmt.Sprintf("q%v-%v", i, j),
+				CostWeights: cw, TimeMS: cw.CalCost(truth)})
+		}
+	}
+
+	for _, metric := range []string{MetricKendall, MetricPlanChoice} {
+		results, err := searchFactors(rs, metric, defaultSearchBounds, 200, 2, rng)
+		if err != nil {
+			t.Fatalf("search by %v: %v", metric, err)
+		}
+		if len(results) != 2 || results[0].Score < results[1].Score {
+			t.Fatalf("unexpected results %+v by %v", results, metric)
+		}
+		if results[0].Score < 0.9 || results[0].Score != searchScore(rs, results[0].Factors, metric) {
+			t.Fatalf("unexpected best result %+v by %v", results[0], metric)
+		}
+		fs := results[0].Factors
+		if fs[0] != 0 || fs[2] < defaultSearchBounds[2][0] || fs[2] > defaultSearchBounds[2][1] { // CPU is not used
+			t.Fatalf("unexpected factors %v by %v", fs, metric)
+		}
+		if report := searchReport(results, metric); !strings.Contains(report, fmt.Sprintf("| 1 | %.4f |", results[0].Score)) {
+			t.Fatalf("unexpected report:\n%v", report)
+		}
+	}
+
+	if _, err := searchFactors(rs, "pearson", defaultSearchBounds, 10, 1, rng); err == nil {
+		t.Fatalf("expect an error of the unknown metric")
+	}
+	for i := range rs {
+		rs[i].Group = ""
+	}
+	if _, err := searchFactors(rs, MetricPlanChoice, defaultSearchBounds, 10, 1, rng); err == nil {
+		t.Fatalf("expect an error when there is no candidate plan")
+	}
+}
+
+func TestRecordsWithWeights(t *testing.T) {
+	rs := Records{
+		{Label: "TableScan", SQL: "q1", CostWeights: CostWeights{0, 0, 1, 1, 0, 0, 1}},
+		{Label: "TableScan", SQL: "q2"}, // recorded by cost model v1
+	}
+	if ret := recordsWithWeights(rs); len(ret) != 1 || ret[0].SQL != "q1" {
+		t.Fatalf("records without weights should be excluded, got %+v", ret)
+	}
+}
@@ -2,6 +2,7 @@ package cost
 
 import (
 	"fmt"
+	"math/rand"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -10,19 +11,32 @@ import (
 	"github.com/qw4990/OptimizerTester/tidb"
 )
 
+// EvalOption is the option of cost model evaluation.
+type EvalOption struct {
+	Instance         tidb.Option
+	Labels           []string // only evaluate queries with these labels if it's not empty
+	GenSyntheticRows int      // generate synthetic data with so many rows before evaluation if it's positive
+	Seed             int64    // the seed to generate synthetic data
+	PlanChoice       bool     // evaluate whether the cheapest candidate plan of each query is the fastest one
+	CostModelVer     int      // the cost model version to evaluate, 2 by default, weights of factors are only recorded by 2
+}
+
+func (opt EvalOption) costModelVer() int {
+	if opt.CostModelVer == 0 {
+		return 2
+	}
+	return opt.CostModelVer
+}
+
+// CheckFeatures checks whether the instance supports all features required by the evaluation.
+func (opt EvalOption) CheckFeatures(ins tidb.Instance) error {
+	eo := &evalOpt{costModelVer: opt.costModelVer()}
+	return eo.checkFeatures(ins)
+}
+
 // CostEval ...
-func CostEval() {
-	opt := tidb.Option{
-		Addr:     "172.16.5.173",
-		Port:     4000,
-		User:     "root",
-		Password: "",
-		Label:    "",
-	}
-	//opt.Addr = "127.0.0.1"
-	//opt.Port = 4001
-
-	ins, err := tidb.ConnectTo(opt)
+func CostEval(evalOption EvalOption) {
+	ins, err := tidb.ConnectTo(evalOption.Instance)
 	if err != nil {
 		panic(err)
 	}
@@ -33,15 +47,22 @@ func CostEval() {
 		//{"tpch1g", "tpch", "original", 2, 1, 2000},
 		//{"tpch1g", "tpch", "calibrated", 30, 2, 2000},
 		//{"synthetic", "synthetic", "original", 20, 2, 300},
-		{"synthetic", "synthetic", 2, 10, 1, 2000},
+		{"synthetic", "synthetic", evalOption.costModelVer(), 10, 1, 2000, evalOption.Labels},
 		//{"synthetic", "synthetic", "calibrating", 30, 3, 200},
 	}
 
+	if evalOption.GenSyntheticRows > 0 {
+		fmt.Printf("[cost-eval] gen synthetic data with seed=%v\n", evalOption.Seed)
+		genSyntheticData(ins, rand.New(rand.NewSource(evalOption.Seed)), evalOption.GenSyntheticRows, "synthetic")
+	}
 	for _, opt := range opts {
+		if evalOption.PlanChoice {
+			evalPlanChoiceOnDataset(ins, opt)
+			continue
+		}
 		evalOnDataset(ins, opt)
 	}
 	//drawSummary(opts)
-	//genSyntheticData(ins, 1000000, "synthetic")
 }
 
 type evalOpt struct {
@@ -51,14 +72,18 @@ type evalOpt struct {
 	queryScale         int
 	processRepeat      int
 	processTimeLimitMS int
+	labels             []string
 }
 
-func (opt *evalOpt) InitSQLs() []string {
+func (opt *evalOpt) InitSQLs(ins tidb.Instance) []string {
 	initSQLs := []string{
 		`set @@tidb_distsql_scan_concurrency=1`,
 		`set @@tidb_executor_concurrency=1`,
 		`set @@tidb_opt_tiflash_concurrency_factor=1`,
 	}
+	if tidb.CheckFeature(ins, tidb.FeatureCostModelV2) != nil {
+		return initSQLs // cost model v1 is the only choice
+	}
 	switch opt.costModelVer {
 	case 2:
 		initSQLs = append(initSQLs, `set @@tidb_enable_new_cost_interface=1`, `set @@tidb_cost_model_version=2`)
@@ -68,6 +93,41 @@ func (opt *evalOpt) InitSQLs() []string {
 	return initSQLs
 }
 
+// Prefixes of EXPLAIN ANALYZE statements showing plan costs, which are calculated with true cardinalities by
+// true_card_cost, or estimated cardinalities by verbose on servers older than v6.2.
+const (
+	explainTrueCardCost = `explain analyze format='true_card_cost' `
+	explainVerbose      = `explain analyze format='verbose' `
+)
+
+// checkFeatures checks whether the instance supports all features required by this evaluation.
+// Cost model v1 can be evaluated with estimated cardinalities if true_card_cost is unsupported.
+func (opt *evalOpt) checkFeatures(ins tidb.Instance) error {
+	err := tidb.CheckFeature(ins, tidb.FeatureTrueCardCost)
+	if opt.costModelVer == 2 {
+		if err != nil {
+			return err
+		}
+		if err := tidb.CheckFeature(ins, tidb.FeatureCostModelV2); err != nil {
+			return err
+		}
+		return tidb.CheckFeature(ins, tidb.FeatureCostTrace)
+	}
+	if err != nil {
+		return tidb.CheckFeature(ins, tidb.FeatureVerboseExplain)
+	}
+	return nil
+}
+
+// explainAnalyze returns the prefix of EXPLAIN ANALYZE statements of queries, which shows costs with true
+// cardinalities if the instance supports it.
+func (opt *evalOpt) explainAnalyze(ins tidb.Instance) string {
+	if opt.costModelVer != 2 && tidb.CheckFeature(ins, tidb.FeatureTrueCardCost) != nil {
+		return explainVerbose
+	}
+	return explainTrueCardCost
+}
+
 func (opt *evalOpt) GenQueries(ins tidb.Instance) Queries {
 	switch strings.ToLower(opt.dataset) {
 	case "imdb":
@@ -83,6 +143,9 @@ func (opt *evalOpt) GenQueries(ins tidb.Instance) Queries {
 
 func evalOnDataset(ins tidb.Instance, opt *evalOpt) {
 	fmt.Println("[cost-eval] start cost model evaluation ", opt.db, opt.dataset, opt.costModelVer)
+	if err := opt.checkFeatures(ins); err != nil {
+		panic(err)
+	}
 	var qs Queries
 	dataDir := "./cost-calibration-data"
 	queryFile := filepath.Join(dataDir, fmt.Sprintf("%v-queries.json", opt.db))
@@ -95,7 +158,11 @@ func evalOnDataset(ins tidb.Instance, opt *evalOpt) {
 		fmt.Println("[cost-eval] read queries from file successfully ")
 	}
 
-	for _, sql := range opt.InitSQLs() {
+	if len(opt.labels) > 0 {
+		qs = filterQueriesByLabel(qs, opt.labels)
+	}
+
+	for _, sql := range opt.InitSQLs(ins) {
 		fmt.Println(sql + ";")
 	}
 
@@ -103,7 +170,7 @@ func evalOnDataset(ins tidb.Instance, opt *evalOpt) {
 	recordFile := filepath.Join(dataDir, fmt.Sprintf("%v-%v-records.json", opt.db, opt.costModelVer))
 	if err := readFrom(recordFile, &rs); err != nil {
 		fmt.Println("[cost-eval] read records file error: ", err)
-		rs = runCostEvalQueries(ins, opt.db, qs, opt.InitSQLs(), opt.processRepeat, opt.processTimeLimitMS)
+		rs = runCostEvalQueries(ins, opt.db, qs, opt.InitSQLs(ins), opt.explainAnalyze(ins), opt.processRepeat, opt.processTimeLimitMS, opt.costModelVer == 2)
 		saveTo(recordFile, rs)
 	} else {
 		fmt.Println("[cost-eval] read records from file successfully")
@@ -113,6 +180,7 @@ func evalOnDataset(ins tidb.Instance, opt *evalOpt) {
 		return rs[i].TimeMS < rs[j].TimeMS
 	})
 
+	rs = excludeNoisyRecords(rs)
 	tmp := make(Records, 0, len(rs))
 	for _, r := range rs {
 		if r.Label == "MPP2PhaseAgg" {
@@ -169,6 +237,7 @@ type Query struct {
 	SQL     string
 	Label   string
 	TypeID  int
+	Group   string // queries in the same group are candidate plans of the same query
 }
 
 type PlanChecker func(rawPlan []string) (reason string, ok bool)
@@ -181,11 +250,15 @@ type Record struct {
 	Label       string
 	SQL         string
 	CostWeights CostWeights
+	Group       string
+	TimeStats   TimeStats // TimeMS is the median of samples if they are recorded
 }
 
 type Records []Record
 
-func runCostEvalQueries(ins tidb.Instance, db string, qs Queries, initSQLs []string, processRepeat, processTimeLimitMS int) Records {
+// runCostEvalQueries runs queries by the EXPLAIN ANALYZE prefix and records their costs and time, and weights of cost
+// factors if withWeights is true, which requires true_card_cost and cost traces of cost model v2.
+func runCostEvalQueries(ins tidb.Instance, db string, qs Queries, initSQLs []string, explainAnalyze string, processRepeat, processTimeLimitMS int, withWeights bool) Records {
 	beginAt := time.Now()
 	ins.MustExec(fmt.Sprintf(`use %v`, db))
 	for _, q := range initSQLs {
@@ -203,11 +276,12 @@ func runCostEvalQueries(ins tidb.Instance, db string, qs Queries, initSQLs []str
 			ins.MustExec(sql)
 		}
 
-		query := `explain analyze format='true_card_cost' ` + q.SQL
+		query := explainAnalyze + q.SQL
 		var label string
-		var planCost, timeMS float64
+		var planCost float64
 		var cw CostWeights
-		label, planCost, timeMS, tle, cw := extractCostTimeFromQuery(ins, query, processRepeat, processTimeLimitMS, true, getPlanChecker(q.Label))
+		tp := newTimingPolicy(processRepeat, processTimeLimitMS)
+		label, planCost, ts, tle, cw := extractCostTimeFromQuery(ins, query, tp, processTimeLimitMS, true, withWeights, getPlanChecker(q.Label))
 		if tle { // skip all queries with the same TypeID
 			fmt.Println("[cost-eval] skip TLE queries")
 			tid := q.TypeID
@@ -222,10 +296,11 @@ func runCostEvalQueries(ins tidb.Instance, db string, qs Queries, initSQLs []str
 		}
 		records = append(records, Record{
 			Cost:        planCost,
-			TimeMS:      timeMS,
+			TimeMS:      ts.Median,
 			Label:       label,
 			SQL:         query,
 			CostWeights: cw,
+			TimeStats:   ts,
 		})
 		i++
 	}
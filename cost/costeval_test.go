@@ -2,34 +2,211 @@ package cost
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/pingcap/errors"
 	"github.com/qw4990/OptimizerTester/tidb"
 )
 
+const fakeExplainAnalyzeResult = `
++-------------------------------+---------+-----------+---------+-----------+---------------------+----------------------------------------------+-------------------------------+-----------+------+
+| id                            | estRows | estCost   | actRows | task      | access object       | execution info                               | operator info                 | memory    | disk |
++-------------------------------+---------+-----------+---------+-----------+---------------------+----------------------------------------------+-------------------------------+-----------+------+
+| IndexLookUp_7                 | 6666.00 | 120000.00 | 6666    | root      |                     | time:%vms, loops:8, index_task: {total_time: 1ms} |                               | 200.1 KB  | N/A  |
+| ├─IndexRangeScan_5(Build)     | 6666.00 | 30000.00  | 6666    | cop[tikv] | table:t, index:b(b) | tikv_task:{time:1ms, loops:10}               | range:[1,6666], keep order:false | N/A    | N/A  |
+| └─TableRowIDScan_6(Probe)     | 6666.00 | 40000.00  | 6666    | cop[tikv] | table:t             | tikv_task:{time:2ms, loops:12}               | keep order:false              | N/A       | N/A  |
++-------------------------------+---------+-----------+---------+-----------+---------------------+----------------------------------------------+-------------------------------+-----------+------+`
+
+const fakeCostTraceResult = `
++---------------------------+---------+-----------+-----------------------------------------------------------------------------------------------------------------------+
+| id                        | estRows | estCost   | costFormula                                                                                                           |
++---------------------------+---------+-----------+-----------------------------------------------------------------------------------------------------------------------+
+| IndexLookUp_7             | 6666.00 | 120000.00 | (scan(1000*logrowsize(32)*tikv_scan_factor(4))) + (net(1000*rowsize(16)*tidb_kv_net_factor(5))) + seek(10*tidb_request_factor(2000)) |
+| ├─IndexRangeScan_5(Build) | 6666.00 | 30000.00  | scan(1500*logrowsize(32)*tikv_scan_factor(4))                                                                         |
+| └─TableRowIDScan_6(Probe) | 6666.00 | 40000.00  | scan(2000*logrowsize(32)*tikv_scan_factor(4))                                                                         |
++---------------------------+---------+-----------+-----------------------------------------------------------------------------------------------------------------------+`
+
 func TestRunCostEvalQuery(t *testing.T) {
-	opt := tidb.Option{
-		Addr:     "172.16.5.173",
-		Port:     4000,
-		User:     "root",
-		Password: "",
-		Label:    "",
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	q := "select /*+ use_index(t, b) */ b, c from t where b>=1 and b<=6666"
+	rule := `^explain analyze format='true_card_cost' select /\*\+ use_index\(t, b\) \*/`
+	ins.OnQuery(rule).ReturnTable(fmt.Sprintf(fakeExplainAnalyzeResult, 100)).Times(1) // the first run is ignored
+	ins.OnQuery(rule).ReturnTable(fmt.Sprintf(fakeExplainAnalyzeResult, 14)).Times(1)
+	ins.OnQuery(rule).ReturnTable(fmt.Sprintf(fakeExplainAnalyzeResult, 16)).Times(1)
+	ins.OnQuery(rule).ReturnTable(fmt.Sprintf(fakeExplainAnalyzeResult, 15)).Times(3)        // repeat until the CI is within ±5%
+	ins.OnQuery(`^explain analyze`).ReturnTable(fmt.Sprintf(fakeExplainAnalyzeResult, 1000)) // TLE
+	ins.OnQuery(`^explain format='cost_trace' select /\*\+ use_index\(t, b\) \*/`).ReturnTable(fakeCostTraceResult)
+
+	qs := Queries{
+		{SQL: q, TypeID: 1},
+		{SQL: "select /*+ use_index(t, c) */ * from t", Label: "IndexLookup", TypeID: 2},
+		{SQL: "select /*+ use_index(t, c) */ * from t where c > 1", Label: "IndexLookup", TypeID: 2},
+	}
+	rs := runCostEvalQueries(ins, "synthetic", qs, []string{"set @@tidb_cost_model_version=2"}, explainTrueCardCost, 2, 500, true)
+	if len(rs) != 1 {
+		t.Fatalf("expect 1 record, got %v", len(rs))
+	}
+	r := rs[0]
+	if r.Label != "IndexLookUp" || r.Cost != 120000 || r.TimeMS != 15 || !r.CostWeights.EqualTo(CostWeights{0, 0, 16000, 5000, 0, 0, 10}) {
+		t.Fatalf("unexpected record %+v", r)
+	}
+	if ts := r.TimeStats; len(ts.Samples) != 5 || ts.Median != 15 || ts.Noisy {
+		t.Fatalf("unexpected time stats %+v", ts)
 	}
-	//opt.Addr = "127.0.0.1"
 
-	ins, err := tidb.ConnectTo(opt)
-	if err != nil {
-		panic(err)
+	executed := ins.Queries()
+	if executed[0] != "use synthetic" || executed[1] != "set @@tidb_cost_model_version=2" {
+		t.Fatalf("unexpected init statements %v", executed[:2])
+	}
+	if executed[8] != "explain format='cost_trace' "+q {
+		t.Fatalf("unexpected cost trace statement %v", executed[8])
+	}
+	if len(executed) != 2+6+1+2 { // the second query is TLE, and the third one with the same TypeID is skipped
+		t.Fatalf("unexpected statements %v", executed)
 	}
+}
 
-	qs := Queries{Query{
-		SQL:    "select /*+ use_index(t, b) */ b, c from t where b>=1 and b<=6666",
-		Label:  "",
-		TypeID: 0,
-	}}
+func TestRunCostEvalQueryV1(t *testing.T) {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	q := "select /*+ use_index(t, b) */ b, c from t where b>=1 and b<=6666"
+	ins.OnQuery(`^explain analyze format='true_card_cost' `).ReturnTable(fmt.Sprintf(fakeExplainAnalyzeResult, 10))
 
-	// select /*+ use_index(t, b) */ b, c from t where b>=1 and b<=6666
-	rs := runCostEvalQueries(ins, "synthetic", qs, []string{}, nil, 2, 500)
-	r := rs[0]
-	fmt.Println(">>>>>>> r.Label ", r.Label)
+	rs := runCostEvalQueries(ins, "synthetic", Queries{{SQL: q, TypeID: 1}}, []string{"set @@tidb_cost_model_version=1"}, explainTrueCardCost, 2, 500, false)
+	if len(rs) != 1 || !rs[0].CostWeights.IsZero() {
+		t.Fatalf("unexpected records %+v", rs)
+	}
+	for _, sql := range ins.Queries() {
+		if strings.HasPrefix(sql, "explain format='cost_trace'") {
+			t.Fatalf("unexpected cost trace statement %v without cost model v2", sql)
+		}
+	}
+}
+
+func TestRunCostEvalQueryWithEstimatedTrace(t *testing.T) {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	q := "select /*+ use_index(t, b) */ b, c from t where b>=1 and b<=6666"
+	ins.OnQuery(`^explain analyze format='true_card_cost' `).ReturnTable(fmt.Sprintf(fakeExplainAnalyzeResult, 10))
+	trace := strings.Replace(fakeCostTraceResult, "120000.00 | (scan(1000*", "110000.00 | (scan(500*", 1) // fewer estimated rows
+	ins.OnQuery(`^explain format='cost_trace' `).ReturnTable(trace)
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "doesn't match the plan cost 120000") {
+			t.Fatalf("weights not matching the plan cost with true cardinalities should be rejected, got %v", r)
+		}
+	}()
+	runCostEvalQueries(ins, "synthetic", Queries{{SQL: q, TypeID: 1}}, nil, explainTrueCardCost, 2, 500, true)
+}
+
+func TestEvalPlanChoices(t *testing.T) {
+	rs := Records{
+		{Group: "Agg#0", Label: "StreamAgg", Cost: 10, TimeMS: 30, SQL: "q1"},
+		{Group: "Agg#0", Label: "HashAgg", Cost: 20, TimeMS: 10, SQL: "q2"},
+		{Group: "Agg#0", Label: "MPP2PhaseAgg", Cost: 30, TimeMS: 20, SQL: "q3"},
+		{Group: "Agg#1", Label: "StreamAgg", Cost: 10, TimeMS: 5, SQL: "q4"},
+		{Group: "Agg#1", Label: "HashAgg", Cost: 20, TimeMS: 5, SQL: "q5"},
+		{Group: "Join#0", Label: "HashJoin", Cost: 10, TimeMS: 5, SQL: "q6"}, // a single candidate is skipped
+		{Label: "TableScan", Cost: 1, TimeMS: 1, SQL: "q7"},
+	}
+	pcs := EvalPlanChoices(rs)
+	if len(pcs) != 2 {
+		t.Fatalf("expect 2 plan choices, got %+v", pcs)
+	}
+	pc := pcs[0]
+	if pc.Candidates != 3 || pc.Chosen.Label != "StreamAgg" || pc.Best.Label != "HashAgg" || pc.Regret != 3 {
+		t.Fatalf("unexpected plan choice %+v", pc)
+	}
+	if pc.PairAccuracy != 1.0/3 { // only (HashAgg, MPP2PhaseAgg) is ordered correctly
+		t.Fatalf("unexpected pair accuracy %v", pc.PairAccuracy)
+	}
+	if pc := pcs[1]; pc.Regret != 1 || pc.PairAccuracy != 1 { // pairs with the same time are not counted
+		t.Fatalf("unexpected plan choice %+v", pc)
+	}
+	if report := planChoiceReport(pcs); !strings.Contains(report, "| All | 2 | 50.00% | 2.000 |") {
+		t.Fatalf("unexpected report:\n%v", report)
+	}
+}
+
+func TestRunPlanChoiceQueries(t *testing.T) {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	ins.OnExactQuery("show warnings").Return([]string{"Level", "Code", "Message"}).Times(1)
+	ins.OnExactQuery("show warnings").Return([]string{"Level", "Code", "Message"},
+		[]interface{}{"Warning", 1815, "Optimizer Hint /*+ INL_JOIN(t2) */ or /*+ TIDB_INLJ(t2) */ is inapplicable"})
+	ins.OnQuery(`^explain analyze format='true_card_cost' `).ReturnTable(fmt.Sprintf(fakeExplainAnalyzeResult, 10))
+
+	qs := Queries{
+		{SQL: "select /*+ tidb_hj(t1, t2) */ * from t t1, t t2 where t1.b=t2.b", Label: "HashJoin", Group: "Join#0"},
+		{SQL: "select /*+ tidb_inlj(t2) */ * from t t1, t t2 where t1.b=t2.b", Label: "IndexJoin", Group: "Join#0"},
+	}
+	rs := runPlanChoiceQueries(ins, "synthetic", qs, nil, explainTrueCardCost, 2, 500, false)
+	if len(rs) != 1 || rs[0].Label != "HashJoin" {
+		t.Fatalf("the candidate with ignored hints should be skipped, got %+v", rs)
+	}
+	for _, sql := range ins.Queries() {
+		if strings.HasPrefix(sql, "explain analyze") && strings.Contains(sql, "tidb_inlj") {
+			t.Fatalf("unexpected statement %v of the skipped candidate", sql)
+		}
+	}
+}
+
+func TestRunPlanChoiceQueriesAfterMPP(t *testing.T) {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	ins.OnExactQuery("show warnings").Return([]string{"Level", "Code", "Message"},
+		[]interface{}{"Warning", 1105, "Scalar subquery is evaluated in advance"}) // not about hints
+	ins.OnQuery(`^explain analyze format='true_card_cost' `).ReturnTable(fmt.Sprintf(fakeExplainAnalyzeResult, 10))
+
+	agg := planChoiceClasses[2]
+	mpp, tikv := agg.candidates[5], agg.candidates[0]
+	if mpp.label != "MPP2PhaseAgg" || tikv.label != "StreamAgg" {
+		t.Fatalf("unexpected candidates %v and %v", mpp.label, tikv.label)
+	}
+	qs := Queries{ // the last candidate of a query is followed by the first one of the next query
+		{PreSQLs: mpp.preSQLs, SQL: fmt.Sprintf(agg.sql, mpp.hints, 1, 10), Label: mpp.label, Group: "Agg#0"},
+		{PreSQLs: tikv.preSQLs, SQL: fmt.Sprintf(agg.sql, tikv.hints, 1, 20), Label: tikv.label, Group: "Agg#1"},
+	}
+	rs := runPlanChoiceQueries(ins, "synthetic", qs, nil, explainTrueCardCost, 2, 500, false)
+	if len(rs) != 2 {
+		t.Fatalf("candidates with warnings not about hints shouldn't be skipped, got %+v", rs)
+	}
+
+	var enforceMPP string
+	for _, sql := range ins.Queries() {
+		if strings.HasPrefix(sql, "set @@session.tidb_enforce_mpp=") {
+			enforceMPP = sql
+		}
+		if strings.HasPrefix(sql, "explain") && strings.Contains(sql, "stream_agg()") && enforceMPP != "set @@session.tidb_enforce_mpp=0" {
+			t.Fatalf("the TiKV candidate runs after %v", enforceMPP)
+		}
+	}
+	for _, class := range planChoiceClasses {
+		for _, c := range class.candidates {
+			if len(c.preSQLs) == 0 {
+				t.Fatalf("the candidate %v doesn't reset variables of MPP", c.label)
+			}
+		}
+	}
+}
+
+func TestEvalOptionCheckFeatures(t *testing.T) {
+	// the embedded server is older than v6.2 with an unknown version, so features are probed
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "embedded"})
+	ins.OnQuery(`^explain analyze format='true_card_cost' `).ReturnError(errors.New("unknown explain format"))
+	ins.OnQuery(`^explain analyze format='verbose' `).ReturnTable(fmt.Sprintf(fakeExplainAnalyzeResult, 10))
+	if err := (EvalOption{}).CheckFeatures(ins); err == nil {
+		t.Fatal("cost model v2 requires true_card_cost and cost traces")
+	}
+	opt := EvalOption{CostModelVer: 1}
+	if err := opt.CheckFeatures(ins); err != nil {
+		t.Fatalf("cost model v1 should be evaluated with verbose explain, got %v", err)
+	}
+
+	eo := &evalOpt{costModelVer: opt.costModelVer()}
+	if prefix := eo.explainAnalyze(ins); prefix != explainVerbose {
+		t.Fatalf("unexpected explain analyze %v", prefix)
+	}
+	q := "select /*+ use_index(t, b) */ b, c from t where b>=1 and b<=6666"
+	rs := runCostEvalQueries(ins, "synthetic", Queries{{SQL: q, TypeID: 1}}, eo.InitSQLs(ins), eo.explainAnalyze(ins), 2, 500, false)
+	if len(rs) != 1 || rs[0].Cost != 120000 || rs[0].SQL != explainVerbose+q {
+		t.Fatalf("unexpected records %+v", rs)
+	}
 }
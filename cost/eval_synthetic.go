@@ -462,7 +462,7 @@ func genSyntheticEvalMPPBCJ(ins tidb.Instance, scale float64, n int) (qs Queries
 	return
 }
 
-func genSyntheticData(ins tidb.Instance, n int, db string) {
+func genSyntheticData(ins tidb.Instance, rng *rand.Rand, n int, db string) {
 	ins.MustExec(fmt.Sprintf(`create database if not exists %v`, db))
 	ins.MustExec(fmt.Sprintf(`use %v`, db))
 	ins.MustExec(`create table if not exists t (
@@ -486,7 +486,7 @@ func genSyntheticData(ins tidb.Instance, n int, db string) {
 		fmt.Printf("[cost-eval] gen synthetic data %v-%v, duration from the beginning %v\n", l, r, time.Since(beginAt))
 		rows = rows[:0]
 		for k := l; k < r; k++ {
-			rows = append(rows, fmt.Sprintf("(%v, %v, %v, %v)", k, rand.Intn(n), "space(128)", rand.Intn(n)))
+			rows = append(rows, fmt.Sprintf("(%v, %v, %v, %v)", k, rng.Intn(n), "space(128)", rng.Intn(n)))
 		}
 		ins.MustExec(fmt.Sprintf(`insert into t values %v`, strings.Join(rows, ", ")))
 	}
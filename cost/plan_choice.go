@@ -0,0 +1,306 @@
+package cost
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// planCandidate is an alternative plan of a query forced by hints.
+type planCandidate struct {
+	label   string
+	hints   string
+	preSQLs []string
+}
+
+// Candidates of a query run one after another in the same session, so each candidate resets variables of MPP.
+var (
+	mppPreSQLs     = []string{`set @@session.tidb_allow_batch_cop=1`, `set @@session.tidb_allow_mpp=1`, `set @@session.tidb_enforce_mpp=1`}
+	tiflashPreSQLs = []string{`set @@session.tidb_enforce_mpp=0`, `set @@session.tidb_allow_mpp=0`}
+	tikvPreSQLs    = []string{`set @@session.tidb_allow_batch_cop=0`, `set @@session.tidb_allow_mpp=0`, `set @@session.tidb_enforce_mpp=0`}
+)
+
+// planChoiceClasses are queries on the synthetic table and their candidate plans, where %v are ranges of b.
+var planChoiceClasses = []struct {
+	name       string
+	sql        string
+	candidates []planCandidate
+}{
+	{"Selection", "select /*+ %v */ b, d from t where b>=%v and b<=%v", []planCandidate{
+		{"TableScan", "use_index(t, primary), read_from_storage(tikv[t])", tikvPreSQLs},
+		{"IndexLookup", "use_index(t, b), read_from_storage(tikv[t])", tikvPreSQLs},
+		{"TiFlashScan", "read_from_storage(tiflash[t])", tiflashPreSQLs},
+	}},
+	{"Order", "select /*+ %v */ b, d from t where b>=%v and b<=%v order by b", []planCandidate{
+		{"IndexLookup", "use_index(t, b), read_from_storage(tikv[t])", tikvPreSQLs},
+		{"Sort", "use_index(t, primary), read_from_storage(tikv[t])", tikvPreSQLs},
+	}},
+	{"Agg", "select /*+ %v */ b, count(1) from t where b>=%v and b<=%v group by b", []planCandidate{
+		{"StreamAgg", "use_index(t, b), stream_agg(), agg_to_cop(), read_from_storage(tikv[t])", tikvPreSQLs},
+		{"HashAgg", "use_index(t, b), hash_agg(), agg_to_cop(), read_from_storage(tikv[t])", tikvPreSQLs},
+		{"RootHashAgg", "use_index(t, b), hash_agg(), agg_not_to_cop(), read_from_storage(tikv[t])", tikvPreSQLs},
+		{"TableScanHashAgg", "use_index(t, primary), hash_agg(), read_from_storage(tikv[t])", tikvPreSQLs},
+		{"MPP1PhaseAgg", "read_from_storage(tiflash[t]), mpp_1phase_agg()", mppPreSQLs},
+		{"MPP2PhaseAgg", "read_from_storage(tiflash[t]), mpp_2phase_agg()", mppPreSQLs},
+	}},
+	{"Join", "select /*+ %v */ t1.b, t2.b from t t1, t t2 where t1.b=t2.b and t1.b>=%v and t1.b<=%v", []planCandidate{
+		{"HashJoin", "use_index(t1, b), use_index(t2, b), tidb_hj(t1, t2), read_from_storage(tikv[t1, t2])", tikvPreSQLs},
+		{"MergeJoin", "use_index(t1, b), use_index(t2, b), tidb_smj(t1, t2), read_from_storage(tikv[t1, t2])", tikvPreSQLs},
+		{"IndexJoin", "use_index(t1, b), tidb_inlj(t2), read_from_storage(tikv[t1, t2])", tikvPreSQLs},
+		{"MPPHJ", "shuffle_join(t1, t2), read_from_storage(tiflash[t1, t2])", mppPreSQLs},
+		{"MPPBCJ", "broadcast_join(t1, t2), read_from_storage(tiflash[t1, t2])", mppPreSQLs},
+	}},
+}
+
+// genSyntheticPlanChoiceQueries generates n queries of each class on the synthetic table, whose candidate plans are
+// in the same group.
+func genSyntheticPlanChoiceQueries(ins tidb.Instance, db string, n int) (qs Queries) {
+	ins.MustExec(fmt.Sprintf(`use %v`, db))
+	var minB, maxB int
+	mustReadOneLine(ins, `select min(b), max(b) from t`, &minB, &maxB)
+	maxB = int(float64(maxB) * getSyntheticScale("HashJoin")) // keep the slowest candidates acceptable
+	for _, class := range planChoiceClasses {
+		tid := genTypeID()
+		for i := 0; i < n; i++ {
+			l, r := randRange(minB, maxB, i, n)
+			for _, c := range class.candidates {
+				qs = append(qs, Query{
+					PreSQLs: c.preSQLs,
+					SQL:     fmt.Sprintf(class.sql, c.hints, l, r),
+					Label:   c.label,
+					TypeID:  tid,
+					Group:   fmt.Sprintf("%v#%v", class.name, i),
+				})
+			}
+		}
+	}
+	return
+}
+
+// runPlanChoiceQueries runs candidate plans like runCostEvalQueries, but skips candidates which cannot be planned,
+// like TiFlash plans without TiFlash replicas, whose hints are ignored, or exceed the time limit, instead of all
+// queries of their types.
+func runPlanChoiceQueries(ins tidb.Instance, db string, qs Queries, initSQLs []string, explainAnalyze string, processRepeat, processTimeLimitMS int, withWeights bool) Records {
+	beginAt := time.Now()
+	ins.MustExec(fmt.Sprintf(`use %v`, db))
+	for _, q := range initSQLs {
+		fmt.Printf("[cost-eval] init SQL %v;\n", q)
+		ins.MustExec(q)
+	}
+
+	records := make([]Record, 0, len(qs))
+	for i, q := range qs {
+		fmt.Printf("[cost-eval] run candidate %v %v/%v %v\n", q, i, len(qs), time.Since(beginAt))
+		for _, sql := range q.PreSQLs {
+			ins.MustExec(sql)
+		}
+		if reason, ok := checkCandidate(ins, q.SQL); !ok {
+			fmt.Printf("[cost-eval] skip the candidate %v\n", reason)
+			continue
+		}
+		query := explainAnalyze + q.SQL
+		tp := newTimingPolicy(processRepeat, processTimeLimitMS)
+		_, planCost, ts, tle, cw := extractCostTimeFromQuery(ins, query, tp, processTimeLimitMS, true, withWeights, nil)
+		if tle {
+			fmt.Println("[cost-eval] skip the TLE candidate")
+			continue
+		}
+		records = append(records, Record{
+			Cost:        planCost,
+			TimeMS:      ts.Median,
+			Label:       q.Label,
+			SQL:         query,
+			CostWeights: cw,
+			Group:       q.Group,
+			TimeStats:   ts,
+		})
+	}
+	return records
+}
+
+// checkCandidate explains the candidate, and returns why it should be skipped if it cannot be planned, or the planner
+// ignores its hints, which is reported by warnings of the explain statement. Other warnings are ignored.
+func checkCandidate(ins tidb.Instance, sql string) (reason string, ok bool) {
+	if err := ins.Exec(`explain ` + sql); err != nil {
+		return fmt.Sprintf("which cannot be planned: %v", err), false
+	}
+	rows, err := ins.Query(`show warnings`)
+	if err != nil {
+		return fmt.Sprintf("whose warnings cannot be read: %v", err), false
+	}
+	defer rows.Close()
+	var warnings []string
+	for rows.Next() {
+		var level, msg string
+		var code int
+		if err := rows.Scan(&level, &code, &msg); err != nil {
+			return fmt.Sprintf("whose warnings cannot be read: %v", err), false
+		}
+		if isHintWarning(code, msg) {
+			warnings = append(warnings, msg)
+		}
+	}
+	if len(warnings) > 0 {
+		return fmt.Sprintf("whose hints are ignored: %v", strings.Join(warnings, "; ")), false
+	}
+	return "", true
+}
+
+// isHintWarning returns whether the warning is about a hint which is ignored, like "Optimizer Hint STREAM_AGG is
+// inapplicable" or "Key 'b' doesn't exist in table 't'" of use_index.
+func isHintWarning(code int, msg string) bool {
+	return code == 1815 || code == 1176 || strings.Contains(strings.ToLower(msg), "hint")
+}
+
+// PlanChoice is how well the cost model chooses among candidate plans of a query.
+type PlanChoice struct {
+	Group        string
+	Candidates   int
+	Chosen       Record  // the candidate with the lowest cost
+	Best         Record  // the fastest candidate
+	Regret       float64 // the time of the chosen plan / the time of the best plan
+	PairAccuracy float64 // the ratio of pairs of candidates whose costs are in the same order as their times
+}
+
+// EvalPlanChoices groups records by their groups, and evaluates the choice of the cost model in each group with
+// at least 2 candidates. Pairs of candidates with the same time are not counted by the pairwise accuracy.
+func EvalPlanChoices(rs Records) []PlanChoice {
+	var groups []string
+	grouped := make(map[string]Records)
+	for _, r := range rs {
+		if r.Group == "" {
+			continue
+		}
+		if _, ok := grouped[r.Group]; !ok {
+			groups = append(groups, r.Group)
+		}
+		grouped[r.Group] = append(grouped[r.Group], r)
+	}
+
+	var pcs []PlanChoice
+	for _, g := range groups {
+		cs := grouped[g]
+		if len(cs) < 2 {
+			continue
+		}
+		pc := PlanChoice{Group: g, Candidates: len(cs), Chosen: cs[0], Best: cs[0]}
+		var pairs, correct int
+		for i, c := range cs {
+			if c.Cost < pc.Chosen.Cost {
+				pc.Chosen = c
+			}
+			if c.TimeMS < pc.Best.TimeMS {
+				pc.Best = c
+			}
+			for _, d := range cs[i+1:] {
+				if c.TimeMS == d.TimeMS {
+					continue
+				}
+				pairs++
+				if (c.Cost < d.Cost) == (c.TimeMS < d.TimeMS) && c.Cost != d.Cost {
+					correct++
+				}
+			}
+		}
+		pc.Regret = pc.Chosen.TimeMS / math.Max(pc.Best.TimeMS, 1e-3)
+		pc.PairAccuracy = 1
+		if pairs > 0 {
+			pc.PairAccuracy = float64(correct) / float64(pairs)
+		}
+		pcs = append(pcs, pc)
+	}
+	return pcs
+}
+
+func evalPlanChoiceOnDataset(ins tidb.Instance, opt *evalOpt) {
+	fmt.Println("[cost-eval] start plan choice evaluation ", opt.db, opt.dataset, opt.costModelVer)
+	if err := opt.checkFeatures(ins); err != nil {
+		panic(err)
+	}
+	if strings.ToLower(opt.dataset) != "synthetic" {
+		panic(fmt.Sprintf("plan choice evaluation is unsupported on %v", opt.dataset))
+	}
+	var qs Queries
+	dataDir := "./cost-calibration-data"
+	queryFile := filepath.Join(dataDir, fmt.Sprintf("%v-plan-choice-queries.json", opt.db))
+	if err := readFrom(queryFile, &qs); err != nil {
+		fmt.Println("[cost-eval] read queries file error: ", err)
+		qs = genSyntheticPlanChoiceQueries(ins, opt.db, opt.queryScale)
+		fmt.Printf("[cost-eval] gen %v candidates for %v\n", len(qs), opt.db)
+		saveTo(queryFile, qs)
+	} else {
+		fmt.Println("[cost-eval] read queries from file successfully ")
+	}
+	if len(opt.labels) > 0 {
+		qs = filterQueriesByLabel(qs, opt.labels)
+	}
+
+	var rs Records
+	recordFile := filepath.Join(dataDir, fmt.Sprintf("%v-%v-plan-choice-records.json", opt.db, opt.costModelVer))
+	if err := readFrom(recordFile, &rs); err != nil {
+		fmt.Println("[cost-eval] read records file error: ", err)
+		rs = runPlanChoiceQueries(ins, opt.db, qs, opt.InitSQLs(ins), opt.explainAnalyze(ins), opt.processRepeat, opt.processTimeLimitMS, opt.costModelVer == 2)
+		saveTo(recordFile, rs)
+	} else {
+		fmt.Println("[cost-eval] read records from file successfully")
+	}
+
+	pcs := EvalPlanChoices(rs)
+	reportFile := fmt.Sprintf("%v-%v-plan-choice.md", opt.db, opt.costModelVer)
+	noerr(ioutil.WriteFile(reportFile, []byte(planChoiceReport(pcs)), 0666))
+	fmt.Printf("[cost-eval] plan choice report of %v-%v is written to %v\n", opt.db, opt.costModelVer, reportFile)
+}
+
+// planChoiceReport summarizes plan choices of each class of queries, and lists the choice of each query.
+func planChoiceReport(pcs []PlanChoice) string {
+	classes := []string{"All"}
+	byClass := map[string][]PlanChoice{"All": pcs}
+	for _, pc := range pcs {
+		class := strings.Split(pc.Group, "#")[0]
+		if _, ok := byClass[class]; !ok {
+			classes = append(classes, class)
+		}
+		byClass[class] = append(byClass[class], pc)
+	}
+
+	str := bytes.Buffer{}
+	str.WriteString("# Plan Choice\n\n")
+	str.WriteString("The chosen plan is the candidate with the lowest cost, and the regret is its time divided by the time of the fastest candidate.\n\n")
+	str.WriteString("| Class | Queries | Optimal | Avg Regret | P50 Regret | P90 Regret | Max Regret | Avg Pair Accuracy |\n")
+	str.WriteString("| ---- | ---- | ---- | ---- | ---- | ---- | ---- | ---- |\n")
+	for _, class := range classes {
+		cs := byClass[class]
+		if len(cs) == 0 {
+			continue
+		}
+		regrets := make([]float64, 0, len(cs))
+		var optimal int
+		var accuracy float64
+		for _, pc := range cs {
+			regrets = append(regrets, pc.Regret)
+			if pc.Chosen.SQL == pc.Best.SQL {
+				optimal++
+			}
+			accuracy += pc.PairAccuracy
+		}
+		sort.Float64s(regrets)
+		n := len(regrets)
+		str.WriteString(fmt.Sprintf("| %v | %v | %.2f%% | %.3f | %.3f | %.3f | %.3f | %.3f |\n", class, n,
+			float64(optimal)*100/float64(n), Average(regrets), regrets[n*50/100], regrets[n*90/100], regrets[n-1], accuracy/float64(n)))
+	}
+
+	str.WriteString("\n| Query | Candidates | Chosen | Best | Regret | Pair Accuracy |\n")
+	str.WriteString("| ---- | ---- | ---- | ---- | ---- | ---- |\n")
+	for _, pc := range pcs {
+		str.WriteString(fmt.Sprintf("| %v | %v | %v (%.2f, %.2fms) | %v (%.2f, %.2fms) | %.3f | %.3f |\n", pc.Group, pc.Candidates,
+			pc.Chosen.Label, pc.Chosen.Cost, pc.Chosen.TimeMS, pc.Best.Label, pc.Best.Cost, pc.Best.TimeMS, pc.Regret, pc.PairAccuracy))
+	}
+	return str.String()
+}
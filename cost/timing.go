@@ -0,0 +1,87 @@
+package cost
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// TimeStats are statistics of execution time of all runs of a query except the warm-up one.
+type TimeStats struct {
+	Samples []float64
+	Median  float64
+	P90     float64
+	StdDev  float64
+	CV      float64 // the coefficient of variation, StdDev / mean
+	Noisy   bool    // whether the confidence interval is still wide when the timing budget is used up
+}
+
+func newTimeStats(samples []float64) TimeStats {
+	ts := TimeStats{Samples: samples}
+	if len(samples) == 0 {
+		return ts
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	ts.Median, ts.P90 = quantile(sorted, 0.5), quantile(sorted, 0.9)
+	if len(samples) > 1 {
+		ts.StdDev = StandardDeviation(samples)
+	}
+	if avg := Average(samples); avg > 0 {
+		ts.CV = ts.StdDev / avg
+	}
+	return ts
+}
+
+// timingPolicy decides how many times a query runs to measure its execution time.
+type timingPolicy struct {
+	minRuns  int     // run at least so many times except the warm-up run
+	maxRuns  int     // stop after so many runs even if the confidence interval is still wide
+	maxRelCI float64 // stop if the half width of the 95% confidence interval of the mean / the mean <= this
+	budgetMS float64 // stop after the total time of runs exceeds this
+}
+
+// newTimingPolicy returns the policy repeating at least repeat times and at most 5 times as many, until the 95%
+// confidence interval is within ±5% of the mean or queries have run for 10 times the time limit in total.
+func newTimingPolicy(repeat, timeLimitMS int) timingPolicy {
+	return timingPolicy{minRuns: repeat, maxRuns: 5 * repeat, maxRelCI: 0.05, budgetMS: 10 * float64(timeLimitMS)}
+}
+
+// done returns whether to stop repeating after these samples, and whether they are noisy if it stops.
+func (tp timingPolicy) done(samples []float64) (done, noisy bool) {
+	n := len(samples)
+	if n < tp.minRuns {
+		return false, false
+	}
+	tight := true
+	if avg := Average(samples); n < 2 {
+		tight = false
+	} else if avg > 0 {
+		tight = 1.96*StandardDeviation(samples)/math.Sqrt(float64(n)) <= tp.maxRelCI*avg
+	}
+	if tight {
+		return true, false
+	}
+	var total float64
+	for _, s := range samples {
+		total += s
+	}
+	if n >= tp.maxRuns || tp.budgetMS > 0 && total >= tp.budgetMS {
+		return true, true
+	}
+	return false, false
+}
+
+// excludeNoisyRecords returns records whose execution time is not noisy.
+func excludeNoisyRecords(rs Records) Records {
+	ret := make(Records, 0, len(rs))
+	for _, r := range rs {
+		if r.TimeStats.Noisy {
+			fmt.Printf("[cost-eval/cali] exclude the noisy record %v (median=%.2fms, p90=%.2fms, cv=%.2f) %v\n",
+				r.Label, r.TimeStats.Median, r.TimeStats.P90, r.TimeStats.CV, r.SQL)
+			continue
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
@@ -0,0 +1,44 @@
+package cost
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTimeStats(t *testing.T) {
+	ts := newTimeStats([]float64{10, 12, 11, 50, 9, 10, 11, 12, 10, 13})
+	if ts.Median != 11 || math.Abs(ts.P90-16.7) > 1e-9 || len(ts.Samples) != 10 {
+		t.Fatalf("unexpected stats %+v", ts)
+	}
+	if math.Abs(ts.StdDev-12.43) > 0.01 || math.Abs(ts.CV-0.84) > 0.01 {
+		t.Fatalf("unexpected stats %+v", ts)
+	}
+	if ts := newTimeStats(nil); ts.Median != 0 || ts.CV != 0 {
+		t.Fatalf("unexpected stats of no sample %+v", ts)
+	}
+}
+
+func TestTimingPolicy(t *testing.T) {
+	tp := timingPolicy{minRuns: 3, maxRuns: 6, maxRelCI: 0.05, budgetMS: 1000}
+	cases := []struct {
+		samples     []float64
+		done, noisy bool
+	}{
+		{[]float64{10, 10}, false, false},                // less than minRuns
+		{[]float64{10, 10, 10}, true, false},             // the CI is tight
+		{[]float64{10, 20, 10}, false, false},            // the CI is wide
+		{[]float64{10, 20, 10, 20, 10, 20}, true, true},  // maxRuns is reached
+		{[]float64{400, 500, 300}, true, true},           // the budget is used up
+		{[]float64{100, 101, 99, 100, 100}, true, false}, // the CI becomes tight
+	}
+	for _, c := range cases {
+		if done, noisy := tp.done(c.samples); done != c.done || noisy != c.noisy {
+			t.Fatalf("unexpected done=%v noisy=%v of %v", done, noisy, c.samples)
+		}
+	}
+
+	rs := Records{{SQL: "q1"}, {SQL: "q2", TimeStats: TimeStats{Noisy: true}}, {SQL: "q3"}}
+	if rs = excludeNoisyRecords(rs); len(rs) != 2 || rs[1].SQL != "q3" {
+		t.Fatalf("unexpected records %+v", rs)
+	}
+}
@@ -78,11 +78,14 @@ func readFrom(f string, r interface{}) error {
 	return nil
 }
 
+// extractCostTimeFromQuery runs the query repeatedly as the timing policy decides, and returns the average plan cost
+// and statistics of execution time of all runs except the first one.
 func extractCostTimeFromQuery(ins tidb.Instance, explainAnalyzeQuery string,
-	repeat, timeLimitMS int, checkRowCount bool,
-	planChecker PlanChecker) (rootOperator string, avgPlanCost, avgTimeMS float64, tle bool, cw CostWeights) {
-	var totalPlanCost, totalTimeMS float64
-	for i := 0; i < repeat+1; i++ {
+	tp timingPolicy, timeLimitMS int, checkRowCount, withWeights bool,
+	planChecker PlanChecker) (rootOperator string, avgPlanCost float64, ts TimeStats, tle bool, cw CostWeights) {
+	var totalPlanCost float64
+	var samples []float64
+	for i := 0; ; i++ {
 		rs := ins.MustQuery(explainAnalyzeQuery)
 		explainResult := ParseExplainAnalyzeResultsWithRows(rs)
 		fmt.Printf("[cost-eval/cali] iter: %v, cost: %v, timeMS: %v, query: %v\n", i, explainResult.PlanCost, explainResult.TimeMS, explainAnalyzeQuery)
@@ -90,7 +93,7 @@ func extractCostTimeFromQuery(ins tidb.Instance, explainAnalyzeQuery string,
 			continue // ignore the first processing
 		}
 		if timeLimitMS > 0 && int(explainResult.TimeMS) > timeLimitMS {
-			return "", 0, 0, true, cw
+			return "", 0, ts, true, cw
 		}
 		if planChecker != nil {
 			reason, ok := planChecker(explainResult.RawPlan)
@@ -99,10 +102,27 @@ func extractCostTimeFromQuery(ins tidb.Instance, explainAnalyzeQuery string,
 			}
 		}
 		totalPlanCost += explainResult.PlanCost
-		totalTimeMS += explainResult.TimeMS
+		samples = append(samples, explainResult.TimeMS)
 		rootOperator = explainResult.RootOperator
+		if done, noisy := tp.done(samples); done {
+			ts = newTimeStats(samples)
+			ts.Noisy = noisy
+			break
+		}
+	}
+	avgPlanCost = totalPlanCost / float64(len(samples))
+	if withWeights {
+		// the cost trace is planned with estimated cardinalities, so its weights are only valid if they produce the
+		// plan cost with true cardinalities, otherwise factors fitted by weights don't explain the recorded cost
+		query := strings.TrimPrefix(explainAnalyzeQuery, explainTrueCardCost)
+		var fs CostFactors
+		cw, fs = extractCostWeights(ins, query)
+		if cost := cw.CalCost(fs); !costCloseTo(cost, avgPlanCost) {
+			panic(fmt.Sprintf("the cost %v calculated by weights of the cost trace doesn't match the plan cost %v with true cardinalities of %v",
+				cost, avgPlanCost, query))
+		}
 	}
-	return rootOperator, totalPlanCost / float64(repeat), totalTimeMS / float64(repeat), false, cw
+	return rootOperator, avgPlanCost, ts, false, cw
 }
 
 type ExplainAnalyzeResult struct {
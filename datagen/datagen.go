@@ -29,12 +29,13 @@ var (
 )
 
 // Generate ...
-func Generate(dataset, args, dir string) error {
+// The same seed always generates the same data.
+func Generate(dataset, args, dir string, seed int64) error {
 	switch strings.ToLower(dataset) {
 	case "zipfx":
-		return GenZipfXData(args, dir)
+		return GenZipfXData(args, dir, seed)
 	case "pzipfx":
-		return GenPZipfXData(args, dir)
+		return GenPZipfXData(args, dir, seed)
 	}
 	return errors.Errorf("unsupported dataset=%v", dataset)
 }
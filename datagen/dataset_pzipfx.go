@@ -179,8 +179,8 @@ func genPartitionSchema(t tableMeta, opt pZipfXOpt, ints []int, doubles []float6
 	return content, nil
 }
 
-func GenPZipfXSchema(dir string, opt pZipfXOpt, ints []int, doubles []float64) error {
-	var content string
+func GenPZipfXSchema(dir string, seed int64, opt pZipfXOpt, ints []int, doubles []float64) error {
+	content := seedComment(seed)
 
 	for _, table := range tables {
 		s, err := genPartitionSchema(table, opt, ints, doubles)
@@ -194,7 +194,7 @@ func GenPZipfXSchema(dir string, opt pZipfXOpt, ints []int, doubles []float64) e
 	return ioutil.WriteFile(schemaFile, []byte(content), 0666)
 }
 
-func GenPZipfXData(args, dir string) error {
+func GenPZipfXData(args, dir string, seed int64) error {
 	opt, err := parsePZipfXOpt(args)
 	if err != nil {
 		return err
@@ -204,17 +204,18 @@ func GenPZipfXData(args, dir string) error {
 	if opt.partitionNum > opt.ndv {
 		num = opt.partitionNum
 	}
-	ints := prepareIntNDV(int(num + 1))
+	rng := rand.New(rand.NewSource(seed))
+	ints := prepareIntNDV(rng, int(num+1))
 	sortedInts := make([]int, len(ints))
 	copy(sortedInts, ints)
 	sort.Ints(sortedInts)
 
-	doubles := prepareDoubleNDV(int(num + 1))
+	doubles := prepareDoubleNDV(rng, int(num+1))
 	sortedDoubles := make([]float64, len(doubles))
 	copy(sortedDoubles, doubles)
 	sort.Float64s(sortedDoubles)
 
-	if err := GenPZipfXSchema(dir, opt, sortedInts, sortedDoubles); err != nil {
+	if err := GenPZipfXSchema(dir, seed, opt, sortedInts, sortedDoubles); err != nil {
 		return err
 	}
 
@@ -232,14 +233,13 @@ func GenPZipfXData(args, dir string) error {
 		}
 		defer f.Close()
 		w := csv.NewWriter(f)
-		r := rand.New(rand.NewSource(time.Now().Unix()))
 		zips := make([]*rand.Zipf, 0, colNum)
 		for i := 0; i < colNum; i++ {
-			zips = append(zips, rand.NewZipf(r, opt.x, 2, uint64(opt.ndv)))
+			zips = append(zips, rand.NewZipf(rng, opt.x, 2, uint64(opt.ndv)))
 		}
 		cols := make([]string, 0, colNum)
 
-		strFactor := uint64(r.Intn(10000)) + 1
+		strFactor := uint64(rng.Intn(10000)) + 1
 
 		for i := 0; i < int(opt.n); i++ {
 			cols := cols[:0]
@@ -265,11 +265,12 @@ func GenPZipfXData(args, dir string) error {
 		}
 		w.Flush()
 	}
-	return GenPZipfXLoadSQL(dir)
+	return GenPZipfXLoadSQL(dir, seed)
 }
 
-func GenPZipfXLoadSQL(dir string) error {
+func GenPZipfXLoadSQL(dir string, seed int64) error {
 	var buf bytes.Buffer
+	buf.WriteString(seedComment(seed))
 	buf.WriteString("SET @@tidb_dml_batch_size=50000;\n")
 
 	if !path.IsAbs(dir) {
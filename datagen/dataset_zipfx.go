@@ -50,20 +50,21 @@ func parseZipfXOpt(args string) (opt zipfXOpt, err error) {
 	return
 }
 
-func GenZipfXData(args, dir string) error {
+func GenZipfXData(args, dir string, seed int64) error {
 	opt, err := parseZipfXOpt(args)
 	if err != nil {
 		return err
 	}
-	if err := GenZipfXSchema(dir); err != nil {
+	if err := GenZipfXSchema(dir, seed); err != nil {
 		return err
 	}
 
 	tbNames := []string{"tint", "tdouble", "tstring", "tdatetime"}
-	ints1 := prepareIntNDV(int(opt.ndv+1))
-	ints2 := prepareIntNDV(int(opt.ndv+1))
-	doubles1 := prepareDoubleNDV(int(opt.ndv+1))
-	doubles2 := prepareDoubleNDV(int(opt.ndv+1))
+	rng := rand.New(rand.NewSource(seed))
+	ints1 := prepareIntNDV(rng, int(opt.ndv+1))
+	ints2 := prepareIntNDV(rng, int(opt.ndv+1))
+	doubles1 := prepareDoubleNDV(rng, int(opt.ndv+1))
+	doubles2 := prepareDoubleNDV(rng, int(opt.ndv+1))
 	for tbIdx, tp := range []DATAType{TypeInt, TypeDouble, TypeString, TypeDateTime} {
 		tb := tbNames[tbIdx]
 		csvFile := path.Join(dir, fmt.Sprintf("zipfx_%v.csv", tb))
@@ -74,11 +75,10 @@ func GenZipfXData(args, dir string) error {
 		defer f.Close()
 		w := csv.NewWriter(f)
 
-		r := rand.New(rand.NewSource(time.Now().Unix()))
-		zipfx := rand.NewZipf(r, opt.x, 2, uint64(opt.ndv))
+		zipfx := rand.NewZipf(rng, opt.x, 2, uint64(opt.ndv))
 		cols := make([]string, 0, 2)
 
-		strFactor := uint64(r.Intn(10000)) + 1
+		strFactor := uint64(rng.Intn(10000)) + 1
 		for i := 0; i < int(opt.n); i++ {
 			cols = cols[:0]
 			c1, c2 := zipfx.Uint64(), zipfx.Uint64()
@@ -107,11 +107,11 @@ func GenZipfXData(args, dir string) error {
 		}
 		w.Flush()
 	}
-	return GenZipfXLoadSQL(dir)
+	return GenZipfXLoadSQL(dir, seed)
 }
 
-func GenZipfXSchema(dir string) error {
-	content := `CREATE TABLE tint ( a INT, b INT, KEY(a), KEY(a, b) );
+func GenZipfXSchema(dir string, seed int64) error {
+	content := seedComment(seed) + `CREATE TABLE tint ( a INT, b INT, KEY(a), KEY(a, b) );
 CREATE TABLE tdouble ( a DOUBLE, b DOUBLE, KEY(a), KEY(a, b) );
 CREATE TABLE tstring ( a VARCHAR(32), b VARCHAR(32), KEY(a), KEY(a, b) );
 CREATE TABLE tdatetime (a DATETIME, b DATETIME, KEY(a), KEY(a, b));
@@ -121,8 +121,9 @@ CREATE TABLE tdatetime (a DATETIME, b DATETIME, KEY(a), KEY(a, b));
 }
 
 // load data local infile '/Users/zhangyuanjia/Workspace/go/src/github.com/qw4990/OptimizerTester/datagen/test/zipfx_tint.csv' into table tint;
-func GenZipfXLoadSQL(dir string) error {
+func GenZipfXLoadSQL(dir string, seed int64) error {
 	var buf bytes.Buffer
+	buf.WriteString(seedComment(seed))
 	buf.WriteString("SET @@tidb_dml_batch_size=500000;\n")
 	tbNames := []string{"tint", "tdouble", "tstring", "tdatetime"}
 
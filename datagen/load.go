@@ -0,0 +1,110 @@
+package datagen
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+var (
+	createTablePattern = regexp.MustCompile(`(?i)^CREATE TABLE\s+`)
+	loadDataPattern    = regexp.MustCompile(`(?i)LOAD DATA LOCAL INFILE '(.+)' INTO TABLE (\S+)`)
+)
+
+// insertBatchSize is the number of rows inserted by one statement when loading data.
+const insertBatchSize = 1000
+
+// LoadData loads the dataset generated into dir by Generate into the database.
+// Tables in <dataset>_schema.sql are created in db, and CSV files in <dataset>_load.sql are inserted in batches,
+// which is slower than LOAD DATA but works for any instance without enabling local files.
+func LoadData(ins tidb.Instance, dataset, dir, db string) error {
+	dataset = strings.ToLower(dataset)
+	if err := ins.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %v", db)); err != nil {
+		return err
+	}
+
+	schema, err := ioutil.ReadFile(path.Join(dir, dataset+"_schema.sql"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, stmt := range strings.Split(string(schema), ";") {
+		stmt = strings.TrimSpace(StripLineComments(stmt))
+		if stmt == "" {
+			continue
+		}
+		stmt = createTablePattern.ReplaceAllString(stmt, "CREATE TABLE "+db+".")
+		if err := ins.Exec(stmt); err != nil {
+			return errors.Errorf("create table %v: %v", stmt, err)
+		}
+	}
+
+	loadSQLs, err := ioutil.ReadFile(path.Join(dir, dataset+"_load.sql"))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, m := range loadDataPattern.FindAllStringSubmatch(string(loadSQLs), -1) {
+		if err := loadCSV(ins, m[1], fmt.Sprintf("%v.%v", db, m[2])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadCSV(ins tidb.Instance, csvFile, table string) error {
+	f, err := os.Open(csvFile)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	rows := make([]string, 0, insertBatchSize)
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		sql := fmt.Sprintf("INSERT INTO %v VALUES %v", table, strings.Join(rows, ", "))
+		rows = rows[:0]
+		return ins.Exec(sql)
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+		vals := make([]string, len(record))
+		for i, v := range record {
+			vals[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		}
+		rows = append(rows, "("+strings.Join(vals, ", ")+")")
+		if len(rows) == insertBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// StripLineComments removes lines starting with "--", like seed comments written by datagen and querygen.
+func StripLineComments(sql string) string {
+	lines := strings.Split(sql, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,61 @@
+package datagen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func TestLoadData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zipfx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := Generate("zipfx", "x=1.5,n=2500,ndv=100", dir, 2022); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := ioutil.ReadFile(filepath.Join(dir, "zipfx_schema.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(schema), "-- seed: 2022\n") {
+		t.Fatalf("no seed in the schema file: %v", string(schema))
+	}
+	csv1, err := ioutil.ReadFile(filepath.Join(dir, "zipfx_tint.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Generate("zipfx", "x=1.5,n=2500,ndv=100", dir, 2022); err != nil {
+		t.Fatal(err)
+	}
+	csv2, err := ioutil.ReadFile(filepath.Join(dir, "zipfx_tint.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(csv1) != string(csv2) {
+		t.Fatal("data generated with the same seed are different")
+	}
+
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+	if err := LoadData(ins, "zipfx", dir, "zipfx"); err != nil {
+		t.Fatal(err)
+	}
+	var creates, inserts int
+	for _, q := range ins.Queries() {
+		switch {
+		case strings.HasPrefix(q, "CREATE TABLE zipfx."):
+			creates++
+		case strings.HasPrefix(q, "INSERT INTO zipfx.tint VALUES"):
+			inserts++
+		}
+	}
+	if creates != 4 || inserts != 3 { // 2500 rows are inserted in 3 batches
+		t.Fatalf("unexpected statements, creates=%v, inserts=%v", creates, inserts)
+	}
+}
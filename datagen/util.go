@@ -2,19 +2,20 @@ package datagen
 
 import (
 	"bytes"
+	"fmt"
 	"math/rand"
 )
 
 const timeLayout = "2006-01-02 15:04:05"
 
-func prepareIntNDV(ndv int) []int {
+func prepareIntNDV(rng *rand.Rand, ndv int) []int {
 	ints := make([]int, ndv)
 	intMap := make(map[int]bool)
 	for i := range ints {
 		var ok bool
 		var data int
 		for {
-			data = rand.Intn(int(2e9))
+			data = rng.Intn(int(2e9))
 			_, ok = intMap[data]
 			if !ok {
 				break
@@ -26,14 +27,14 @@ func prepareIntNDV(ndv int) []int {
 	return ints
 }
 
-func prepareDoubleNDV(ndv int) []float64 {
+func prepareDoubleNDV(rng *rand.Rand, ndv int) []float64 {
 	doubles := make([]float64, ndv)
 	doubleMap := make(map[float64]bool)
 	for i := range doubles {
 		var ok bool
 		var data float64
 		for {
-			data = rand.Float64() * 2e9
+			data = rng.Float64() * 2e9
 			_, ok = doubleMap[data]
 			if !ok {
 				break
@@ -53,3 +54,8 @@ func uint2Str(v uint64) string {
 	}
 	return buf.String()
 }
+
+// seedComment returns the comment recording the seed in generated SQL files, so the dataset can be regenerated exactly.
+func seedComment(seed int64) string {
+	return fmt.Sprintf("-- seed: %v\n", seed)
+}
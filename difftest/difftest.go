@@ -0,0 +1,392 @@
+// Package difftest runs queries under alternative plans forced by hints and on different instances, like different
+// versions of TiDB, and compares their results regardless of the order of rows. Different results of the same query
+// are potential optimizer or executor bugs, which are reported with minimized reproductions.
+package difftest
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+const (
+	// ReportFileName is the name of the report of mismatches in the output directory.
+	ReportFileName = "difftest.md"
+	// MismatchesFileName is the name of the file saving all mismatches in the output directory.
+	MismatchesFileName = "mismatches.json"
+
+	maxDiffRows = 5 // rows only in one side listed for each mismatch
+)
+
+// DefaultHintSets are used if no hint set is specified, where "{table}" is the first table of queries.
+var DefaultHintSets = []string{
+	"use_index({table})", // table scans
+	"use_index_merge({table})",
+	"stream_agg(), agg_to_cop()",
+	"hash_agg(), agg_not_to_cop()",
+	"must_reorder()",
+	"read_from_storage(tiflash[{table}]), mpp_1phase_agg()",
+	"read_from_storage(tiflash[{table}]), mpp_2phase_agg()",
+}
+
+// Option is the option of differential tests.
+type Option struct {
+	QueryPath   string   // a SQL file or a directory of SQL files
+	DSNs        []string // results of the first DSN without hints are the reference
+	Labels      []string // labels of DSNs, "DSN#i" by default
+	HintSets    []string // DefaultHintSets if empty
+	OutDir      string
+	Concurrency int  // queries tested concurrently
+	Minimize    bool // whether to minimize queries and hints of mismatches
+}
+
+// Variant is an instance running queries with a set of hints.
+type Variant struct {
+	Instance tidb.Instance
+	Hints    string
+}
+
+// Label returns the label of the instance with hints of the variant.
+func (v Variant) Label() string {
+	if v.Hints == "" {
+		return v.Instance.Opt().Label
+	}
+	return fmt.Sprintf("%v /*+ %v */", v.Instance.Opt().Label, v.Hints)
+}
+
+// Mismatch is a query whose results under a variant differ from the reference.
+type Mismatch struct {
+	Query         string   `json:"query"`
+	Variant       string   `json:"variant"`
+	Instance      string   `json:"instance"`
+	Version       string   `json:"version"`
+	RefRows       int      `json:"ref-rows"`
+	Rows          int      `json:"rows"`
+	OnlyInRef     []string `json:"only-in-ref"`
+	OnlyInVariant []string `json:"only-in-variant"`
+	RefSQL        string   `json:"ref-sql"`     // the minimized query run on the reference
+	VariantSQL    string   `json:"variant-sql"` // the minimized query with minimized hints run on the variant
+}
+
+// variantStats counts queries run by a variant.
+type variantStats struct {
+	queries    int
+	mismatches int
+	errors     int
+	firstErr   string
+}
+
+// Tester compares results of queries on variants with the reference.
+type Tester struct {
+	ref      Variant
+	variants []Variant
+	minimize bool
+
+	lock  sync.Mutex
+	stats []variantStats
+}
+
+// NewTester returns a tester taking the first instance without hints as the reference, and every instance with
+// every hint set and the other instances without hints as variants.
+func NewTester(instances []tidb.Instance, hintSets []string, minimize bool) *Tester {
+	t := &Tester{ref: Variant{Instance: instances[0]}, minimize: minimize}
+	for i, ins := range instances {
+		if i > 0 {
+			t.variants = append(t.variants, Variant{Instance: ins})
+		}
+		for _, hints := range hintSets {
+			t.variants = append(t.variants, Variant{Instance: ins, Hints: hints})
+		}
+	}
+	t.stats = make([]variantStats, len(t.variants))
+	return t
+}
+
+// Check runs the query on the reference and all variants, and returns mismatches.
+// Queries failing on the reference and queries with LIMIT, whose results depend on plans, are skipped.
+func (t *Tester) Check(query string) ([]Mismatch, error) {
+	if hasLimit(query) {
+		return nil, nil
+	}
+	ref, err := queryRows(t.ref.Instance, query)
+	if err != nil {
+		return nil, err
+	}
+	var mismatches []Mismatch
+	for i, v := range t.variants {
+		rows, err := queryRows(v.Instance, injectHints(query, v.Hints))
+		t.lock.Lock()
+		t.stats[i].queries++
+		if err != nil {
+			t.stats[i].errors++
+			if t.stats[i].firstErr == "" {
+				t.stats[i].firstErr = err.Error()
+			}
+		}
+		t.lock.Unlock()
+		if err != nil {
+			continue
+		}
+		onlyInRef, onlyInVariant := diffRows(ref, rows)
+		if len(onlyInRef)+len(onlyInVariant) == 0 {
+			continue
+		}
+		t.lock.Lock()
+		t.stats[i].mismatches++
+		t.lock.Unlock()
+		m := Mismatch{Query: query, Variant: v.Label(), Instance: v.Instance.Opt().Label,
+			Version: v.Instance.Version().String(), RefRows: len(ref), Rows: len(rows), OnlyInRef: headRows(onlyInRef), OnlyInVariant: headRows(onlyInVariant)}
+		minQuery, minHints := query, v.Hints
+		if t.minimize {
+			minQuery, minHints = t.minimizeMismatch(v, query)
+		}
+		m.RefSQL, m.VariantSQL = minQuery, injectHints(minQuery, minHints)
+		mismatches = append(mismatches, m)
+	}
+	return mismatches, nil
+}
+
+// mismatched tells whether the query with hints on the instance returns results different from the reference.
+func (t *Tester) mismatched(ins tidb.Instance, query, hints string) bool {
+	ref, err := queryRows(t.ref.Instance, query)
+	if err != nil {
+		return false
+	}
+	rows, err := queryRows(ins, injectHints(query, hints))
+	if err != nil {
+		return false
+	}
+	onlyInRef, onlyInVariant := diffRows(ref, rows)
+	return len(onlyInRef)+len(onlyInVariant) > 0
+}
+
+// minimizeMismatch greedily removes conditions in WHERE, clauses after WHERE and single hints, as long as results
+// still mismatch, and returns the minimized query and hints.
+func (t *Tester) minimizeMismatch(v Variant, query string) (string, string) {
+	sq := parseSelectQuery(query)
+	for i := 0; i < len(sq.conds); {
+		tmp := sq
+		tmp.conds = append(append([]string{}, sq.conds[:i]...), sq.conds[i+1:]...)
+		if t.mismatched(v.Instance, tmp.String(), v.Hints) {
+			sq = tmp
+		} else {
+			i++
+		}
+	}
+	if order := topLevelKeyword(sq.suffix, 0, "ORDER"); order >= 0 { // results are compared regardless of orders
+		tmp := sq
+		tmp.suffix = strings.TrimSpace(sq.suffix[:order])
+		if t.mismatched(v.Instance, tmp.String(), v.Hints) {
+			sq = tmp
+		}
+	}
+	minQuery := sq.String()
+
+	hints := splitHints(v.Hints)
+	for i := 0; i < len(hints) && len(hints) > 1; {
+		tmp := append(append([]string{}, hints[:i]...), hints[i+1:]...)
+		if t.mismatched(v.Instance, minQuery, strings.Join(tmp, ", ")) {
+			hints = tmp
+		} else {
+			i++
+		}
+	}
+	return minQuery, strings.Join(hints, ", ")
+}
+
+// queryRows returns rows of the query as strings, where values are separated by ", ".
+// Floats are formatted with 12 significant digits since their last digits may vary with the order of computation.
+func queryRows(ins tidb.Instance, query string) ([]string, error) {
+	rows, err := ins.Query(query)
+	if err != nil {
+		return nil, errors.Annotatef(err, "query %v", query)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	vals := make([]sql.RawBytes, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	var results []string
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, errors.Trace(err)
+		}
+		strs := make([]string, len(vals))
+		for i, v := range vals {
+			strs[i] = canonicalValue(v)
+		}
+		results = append(results, strings.Join(strs, ", "))
+	}
+	return results, errors.Trace(rows.Err())
+}
+
+func canonicalValue(v sql.RawBytes) string {
+	if v == nil {
+		return "NULL"
+	}
+	s := string(v)
+	if strings.ContainsAny(s, ".eE") {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return strconv.FormatFloat(f, 'g', 12, 64)
+		}
+	}
+	return s
+}
+
+// diffRows returns rows only in a and rows only in b, where rows are compared as multisets.
+func diffRows(a, b []string) (onlyInA, onlyInB []string) {
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case a[i] < b[j]:
+			onlyInA = append(onlyInA, a[i])
+			i++
+		default:
+			onlyInB = append(onlyInB, b[j])
+			j++
+		}
+	}
+	return append(onlyInA, a[i:]...), append(onlyInB, b[j:]...)
+}
+
+func headRows(rows []string) []string {
+	if len(rows) > maxDiffRows {
+		return rows[:maxDiffRows]
+	}
+	return rows
+}
+
+// RunDiffTest runs queries in the query path on all variants, and writes mismatches into the output directory.
+func RunDiffTest(opt Option) error {
+	if len(opt.DSNs) == 0 {
+		return errors.New("no DSN")
+	}
+	hintSets := opt.HintSets
+	if len(hintSets) == 0 {
+		hintSets = DefaultHintSets
+	}
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 1
+	}
+	queries, err := ReadQueries(opt.QueryPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[DiffTest] %v queries read from %v\n", len(queries), opt.QueryPath)
+
+	var instances []tidb.Instance
+	defer func() {
+		for _, ins := range instances {
+			ins.Close()
+		}
+	}()
+	for i, dsn := range opt.DSNs {
+		label := fmt.Sprintf("DSN#%d", i)
+		if i < len(opt.Labels) {
+			label = opt.Labels[i]
+		}
+		ins, err := tidb.ConnectToDSN(dsn, label)
+		if err != nil {
+			return err
+		}
+		instances = append(instances, ins)
+	}
+	t := NewTester(instances, hintSets, opt.Minimize)
+
+	results := make([][]Mismatch, len(queries))
+	var wg sync.WaitGroup
+	for w := 0; w < opt.Concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(queries); i += opt.Concurrency {
+				ms, err := t.Check(queries[i])
+				if err != nil {
+					fmt.Printf("[DiffTest] skip the query failing on the reference: %v\n", err)
+					continue
+				}
+				for _, m := range ms {
+					fmt.Printf("[DiffTest] mismatch of %v: %v\n", m.Variant, m.Query)
+				}
+				results[i] = ms
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	var mismatches []Mismatch
+	for _, ms := range results {
+		mismatches = append(mismatches, ms...)
+	}
+	return t.writeReport(opt.OutDir, len(queries), mismatches)
+}
+
+// writeReport writes a summary of variants and reproductions of mismatches into ReportFileName,
+// and all mismatches into MismatchesFileName.
+func (t *Tester) writeReport(outDir string, nQueries int, mismatches []Mismatch) error {
+	if err := os.MkdirAll(outDir, 0777); err != nil {
+		return errors.Trace(err)
+	}
+	data, err := json.MarshalIndent(mismatches, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := ioutil.WriteFile(path.Join(outDir, MismatchesFileName), data, 0666); err != nil {
+		return errors.Trace(err)
+	}
+
+	str := bytes.Buffer{}
+	str.WriteString("# Differential Test\n\n")
+	str.WriteString(fmt.Sprintf("%v queries are compared with the reference %v (%v).\n\n",
+		nQueries, t.ref.Label(), t.ref.Instance.Version().String()))
+	str.WriteString("| Variant | Version | Queries | Mismatches | Errors | First Error |\n")
+	str.WriteString("| ---- | ---- | ---- | ---- | ---- | ---- |\n")
+	for i, v := range t.variants {
+		s := t.stats[i]
+		str.WriteString(fmt.Sprintf("| %v | %v | %v | %v | %v | %v |\n", v.Label(), v.Instance.Version().String(),
+			s.queries, s.mismatches, s.errors, strings.ReplaceAll(s.firstErr, "|", "\\|")))
+	}
+	for i, m := range mismatches {
+		str.WriteString(fmt.Sprintf("\n## Mismatch %v: %v\n\n", i+1, m.Variant))
+		str.WriteString(fmt.Sprintf("Original query: `%v`\n\n", m.Query))
+		str.WriteString(fmt.Sprintf("The reference returns %v rows and the variant returns %v rows.\n\n", m.RefRows, m.Rows))
+		str.WriteString("```sql\n")
+		str.WriteString(fmt.Sprintf("-- on %v (%v)\n%v;\n", t.ref.Instance.Opt().Label, t.ref.Instance.Version().String(), m.RefSQL))
+		str.WriteString(fmt.Sprintf("-- on %v (%v)\n%v;\n", m.Instance, m.Version, m.VariantSQL))
+		str.WriteString("```\n")
+		for _, diff := range []struct {
+			name string
+			rows []string
+		}{{"reference", m.OnlyInRef}, {"variant", m.OnlyInVariant}} {
+			if len(diff.rows) > 0 {
+				str.WriteString(fmt.Sprintf("\nRows only in the %v (at most %v):\n\n", diff.name, maxDiffRows))
+				for _, r := range diff.rows {
+					str.WriteString(fmt.Sprintf("    %v\n", r))
+				}
+			}
+		}
+	}
+	return errors.Trace(ioutil.WriteFile(path.Join(outDir, ReportFileName), str.Bytes(), 0666))
+}
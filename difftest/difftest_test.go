@@ -0,0 +1,118 @@
+package difftest
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func TestInjectHints(t *testing.T) {
+	cases := []struct {
+		query, hints, expected string
+	}{
+		{"SELECT * FROM db.t WHERE a>1", "use_index({table})", "SELECT /*+ use_index(t) */ * FROM db.t WHERE a>1"},
+		{"select * from db.`t` x where a>1", "use_index({table})", "SELECT /*+ use_index(x) */ * from db.`t` x where a>1"},
+		{"SELECT /*+ no_reorder() */ a FROM t", "stream_agg()", "SELECT /*+ no_reorder(), stream_agg() */ a FROM t"},
+		{"SELECT a FROM t", "", "SELECT a FROM t"},
+	}
+	for _, c := range cases {
+		if q := injectHints(c.query, c.hints); q != c.expected {
+			t.Fatalf("%v: expected %v, got %v", c.query, c.expected, q)
+		}
+	}
+	if hints := splitHints("use_index(t, a), read_from_storage(tiflash[t])"); !reflect.DeepEqual(hints,
+		[]string{"use_index(t, a)", "read_from_storage(tiflash[t])"}) {
+		t.Fatalf("unexpected hints %v", hints)
+	}
+}
+
+func TestParseSelectQuery(t *testing.T) {
+	sq := parseSelectQuery("SELECT a, (SELECT 1 FROM s WHERE x AND y) FROM t WHERE a BETWEEN 1 AND 3 AND (b=1 AND c=2) AND d='x AND y' ORDER BY a")
+	if sq.head != "SELECT a, (SELECT 1 FROM s WHERE x AND y) FROM t" || sq.suffix != "ORDER BY a" ||
+		!reflect.DeepEqual(sq.conds, []string{"a BETWEEN 1 AND 3", "(b=1 AND c=2)", "d='x AND y'"}) {
+		t.Fatalf("unexpected query %+v", sq)
+	}
+	if q := parseSelectQuery("SELECT COUNT(*) FROM t").String(); q != "SELECT COUNT(*) FROM t" {
+		t.Fatalf("unexpected query %v", q)
+	}
+	if !hasLimit("SELECT * FROM t ORDER BY a LIMIT 10") || hasLimit("SELECT * FROM t WHERE a IN (SELECT b FROM s LIMIT 1)") {
+		t.Fatalf("unexpected LIMIT detection")
+	}
+}
+
+func TestDiffRows(t *testing.T) {
+	onlyInA, onlyInB := diffRows([]string{"1", "2", "2", "3"}, []string{"3", "2", "4"})
+	if !reflect.DeepEqual(onlyInA, []string{"1", "2"}) || !reflect.DeepEqual(onlyInB, []string{"4"}) {
+		t.Fatalf("unexpected diff %v %v", onlyInA, onlyInB)
+	}
+	if v := canonicalValue([]byte("0.30000000000000004")); v != "0.3" {
+		t.Fatalf("unexpected value %v", v)
+	}
+	if v := canonicalValue(nil); v != "NULL" {
+		t.Fatalf("unexpected value %v", v)
+	}
+}
+
+func TestReadQueries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "difftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	content := "-- seed: 1\nselect * from db.t where (a = 1);\nSET @@x = 1;\nselect * from db.t\nwhere (b > 2);\n"
+	if err := ioutil.WriteFile(path.Join(dir, "query.sql"), []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	queries, err := ReadQueries(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(queries, []string{"select * from db.t where (a = 1)", "select * from db.t\nwhere (b > 2)"}) {
+		t.Fatalf("unexpected queries %q", queries)
+	}
+}
+
+func TestTester(t *testing.T) {
+	ref := tidb.NewFakeInstance(tidb.Option{Label: "v1"})
+	ref.OnQuery(".*").Return([]string{"a"}, []interface{}{2}, []interface{}{3})
+	buggy := tidb.NewFakeInstance(tidb.Option{Label: "v2"})
+	buggy.OnQuery(`stream_agg\(\).*b < 5`).Return([]string{"a"}, []interface{}{2})
+	buggy.OnQuery(".*").Return([]string{"a"}, []interface{}{3}, []interface{}{2})
+
+	tester := NewTester([]tidb.Instance{ref, buggy}, []string{"use_index({table}), stream_agg()"}, true)
+	ms, err := tester.Check("SELECT a FROM db.t WHERE a > 1 AND b < 5 ORDER BY a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 {
+		t.Fatalf("expected 1 mismatch, got %+v", ms)
+	}
+	m := ms[0]
+	if m.Instance != "v2" || m.RefRows != 2 || m.Rows != 1 || !reflect.DeepEqual(m.OnlyInRef, []string{"3"}) {
+		t.Fatalf("unexpected mismatch %+v", m)
+	}
+	if m.RefSQL != "SELECT a FROM db.t WHERE b < 5" || m.VariantSQL != "SELECT /*+ stream_agg() */ a FROM db.t WHERE b < 5" {
+		t.Fatalf("unexpected reproduction %v, %v", m.RefSQL, m.VariantSQL)
+	}
+
+	dir, err := ioutil.TempDir("", "difftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := tester.writeReport(dir, 1, ms); err != nil {
+		t.Fatal(err)
+	}
+	report, err := ioutil.ReadFile(path.Join(dir, ReportFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "| v2 /*+ use_index({table}), stream_agg() */ |  | 1 | 1 | 0 |  |") {
+		t.Fatalf("unexpected report:\n%s", report)
+	}
+}
@@ -0,0 +1,246 @@
+package difftest
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// ReadQueries reads SELECT statements separated by semicolons from the SQL file or all files in the directory,
+// like outputs of querygen, where lines starting with "--" are ignored.
+func ReadQueries(location string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(location, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var queries []string
+	for _, f := range files {
+		file, err := os.Open(f)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(nil, 1<<24)
+		scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			for i := 0; i < len(data); i++ {
+				if data[i] == ';' {
+					return i + 1, data[:i], nil
+				}
+			}
+			if !atEOF {
+				return 0, nil, nil
+			}
+			return 0, data, bufio.ErrFinalToken
+		})
+		for scanner.Scan() {
+			var lines []string
+			for _, line := range strings.Split(scanner.Text(), "\n") {
+				if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+					lines = append(lines, line)
+				}
+			}
+			if q := strings.TrimSpace(strings.Join(lines, "\n")); isSelect(q) {
+				queries = append(queries, q)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, errors.Annotatef(err, "read %v", f)
+		}
+	}
+	return queries, nil
+}
+
+func isSelect(query string) bool {
+	return keywordAt(query, 0, "SELECT")
+}
+
+// scanTopLevel calls fn with positions of the query out of quotes, comments and parentheses until fn returns true.
+func scanTopLevel(query string, fn func(i int) bool) {
+	depth := 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; c {
+		case '\'', '"', '`':
+			for i++; i < len(query) && query[i] != c; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+			continue
+		case '/':
+			if strings.HasPrefix(query[i:], "/*") {
+				if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+					i += end + 3
+					continue
+				}
+			}
+		case '(':
+			depth++
+			continue
+		case ')':
+			depth--
+			continue
+		}
+		if depth == 0 && fn(i) {
+			return
+		}
+	}
+}
+
+// keywordAt tells whether the keyword is at the position of the query as a whole word, ignoring cases.
+func keywordAt(query string, i int, kw string) bool {
+	if i+len(kw) > len(query) || !strings.EqualFold(query[i:i+len(kw)], kw) {
+		return false
+	}
+	isWordChar := func(c byte) bool {
+		return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	}
+	return (i == 0 || !isWordChar(query[i-1])) && (i+len(kw) == len(query) || !isWordChar(query[i+len(kw)]))
+}
+
+// topLevelKeyword returns the position of the first keyword of the query out of parentheses after the position,
+// or -1 if there is no such keyword.
+func topLevelKeyword(query string, from int, kws ...string) int {
+	pos := -1
+	scanTopLevel(query, func(i int) bool {
+		if i < from {
+			return false
+		}
+		for _, kw := range kws {
+			if keywordAt(query, i, kw) {
+				pos = i
+				return true
+			}
+		}
+		return false
+	})
+	return pos
+}
+
+// hasLimit tells whether the query has a top-level LIMIT, whose results depend on plans.
+func hasLimit(query string) bool {
+	return topLevelKeyword(query, 0, "LIMIT") >= 0
+}
+
+// selectQuery is a query split into its WHERE conditions and the parts around them.
+type selectQuery struct {
+	head   string   // "SELECT ... FROM ..."
+	conds  []string // conjunctive conditions in WHERE
+	suffix string   // "GROUP BY ...", "ORDER BY ..." and so on
+}
+
+func parseSelectQuery(query string) selectQuery {
+	where := topLevelKeyword(query, 0, "WHERE")
+	if where < 0 {
+		return selectQuery{head: query}
+	}
+	end := topLevelKeyword(query, where, "GROUP", "HAVING", "ORDER", "LIMIT", "UNION", "WINDOW")
+	if end < 0 {
+		end = len(query)
+	}
+	sq := selectQuery{head: strings.TrimSpace(query[:where]), suffix: strings.TrimSpace(query[end:])}
+	cond := query[where+len("WHERE") : end]
+	between, begin := false, 0
+	scanTopLevel(cond, func(i int) bool {
+		if keywordAt(cond, i, "BETWEEN") {
+			between = true
+		} else if keywordAt(cond, i, "AND") {
+			if between { // the AND of BETWEEN
+				between = false
+			} else {
+				sq.conds = append(sq.conds, strings.TrimSpace(cond[begin:i]))
+				begin = i + len("AND")
+			}
+		}
+		return false
+	})
+	sq.conds = append(sq.conds, strings.TrimSpace(cond[begin:]))
+	return sq
+}
+
+func (sq selectQuery) String() string {
+	s := sq.head
+	if len(sq.conds) > 0 {
+		s += " WHERE " + strings.Join(sq.conds, " AND ")
+	}
+	if sq.suffix != "" {
+		s += " " + sq.suffix
+	}
+	return s
+}
+
+// splitHints splits hints like "use_index(t, a), stream_agg()" into single hints.
+func splitHints(hints string) []string {
+	var list []string
+	begin := 0
+	scanTopLevel(hints, func(i int) bool {
+		if hints[i] == ',' {
+			list = append(list, strings.TrimSpace(hints[begin:i]))
+			begin = i + 1
+		}
+		return false
+	})
+	if last := strings.TrimSpace(hints[begin:]); last != "" {
+		list = append(list, last)
+	}
+	return list
+}
+
+var (
+	hintBlock = regexp.MustCompile(`^(?is)SELECT\s*/\*\+(.*?)\*/`)
+	fromTable = regexp.MustCompile("(?i)\\bFROM\\s+([\\w.`]+)(?:\\s+(?:AS\\s+)?(\\w+))?")
+)
+
+// injectHints adds hints into the first SELECT of the query, after existing hints of the query if there are,
+// where "{table}" in hints is replaced by the alias or the name of the first table in FROM.
+func injectHints(query, hints string) string {
+	if hints == "" {
+		return query
+	}
+	if strings.Contains(hints, "{table}") {
+		hints = strings.ReplaceAll(hints, "{table}", firstTable(query))
+	}
+	if m := hintBlock.FindStringSubmatchIndex(query); m != nil {
+		existing := strings.TrimSpace(query[m[2]:m[3]])
+		if existing != "" {
+			hints = existing + ", " + hints
+		}
+		return "SELECT /*+ " + hints + " */" + query[m[1]:]
+	}
+	return "SELECT /*+ " + hints + " */" + query[len("SELECT"):]
+}
+
+// firstTable returns the alias or the name without the database of the first table in FROM.
+func firstTable(query string) string {
+	m := fromTable.FindStringSubmatch(query)
+	if m == nil {
+		return ""
+	}
+	switch alias := strings.ToUpper(m[2]); alias {
+	case "", "WHERE", "GROUP", "HAVING", "ORDER", "LIMIT", "JOIN", "INNER", "LEFT", "RIGHT", "CROSS", "NATURAL",
+		"STRAIGHT_JOIN", "USE", "FORCE", "IGNORE", "PARTITION", "UNION", "WINDOW", "ON":
+	default:
+		return m[2]
+	}
+	name := m[1]
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.Trim(name, "`")
+}
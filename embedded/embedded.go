@@ -0,0 +1,167 @@
+package embedded
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/config"
+	"github.com/pingcap/tidb/domain"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/server"
+	"github.com/pingcap/tidb/session"
+	"github.com/pingcap/tidb/store/mockstore"
+	"github.com/qw4990/OptimizerTester/datagen"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// Server is an in-process TiDB server on the unistore mock storage, which is used to run testers without a cluster.
+// Estimations from it are real, but execution time is only indicative since all data is in memory.
+type Server struct {
+	store kv.Storage
+	dom   *domain.Domain
+	svr   *server.Server
+	port  int
+}
+
+// Start starts an embedded TiDB server listening on a random port of 127.0.0.1.
+func Start() (*Server, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := mockstore.NewMockStore(mockstore.WithStoreType(mockstore.EmbedUnistore))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	session.SetSchemaLease(0)
+	dom, err := session.BootstrapSession(store)
+	if err != nil {
+		store.Close()
+		return nil, errors.Trace(err)
+	}
+
+	cfg := config.NewConfig()
+	cfg.Host = "127.0.0.1"
+	cfg.Port = uint(port)
+	cfg.Socket = ""
+	cfg.Status.ReportStatus = false
+	cfg.Security.AutoTLS = false
+	config.StoreGlobalConfig(cfg)
+	svr, err := server.NewServer(cfg, server.NewTiDBDriver(store))
+	if err != nil {
+		dom.Close()
+		store.Close()
+		return nil, errors.Trace(err)
+	}
+	svr.SetDomain(dom)
+	svr.InitGlobalConnID(dom.ServerID)
+	go func() {
+		if err := svr.Run(); err != nil {
+			fmt.Printf("[embedded] server exits with error: %v\n", err)
+		}
+	}()
+
+	s := &Server{store: store, dom: dom, svr: svr, port: port}
+	if err := s.waitForReady(time.Second * 10); err != nil {
+		s.Close()
+		return nil, err
+	}
+	fmt.Printf("[embedded] TiDB server is running on 127.0.0.1:%v\n", port)
+	return s, nil
+}
+
+// Option returns the option to connect to this server.
+func (s *Server) Option(label string) tidb.Option {
+	return tidb.Option{
+		Addr:  "127.0.0.1",
+		Port:  s.port,
+		User:  "root",
+		Label: label,
+	}
+}
+
+// DSN returns the DSN to connect to the database in this server.
+func (s *Server) DSN(db string) string {
+	return fmt.Sprintf("root@tcp(127.0.0.1:%v)/%v", s.port, db)
+}
+
+// Connect connects to this server as a tidb.Instance.
+func (s *Server) Connect(label string) (tidb.Instance, error) {
+	return tidb.ConnectTo(s.Option(label))
+}
+
+// Close stops the server and releases its storage.
+func (s *Server) Close() {
+	s.svr.Close()
+	s.dom.Close()
+	s.store.Close()
+}
+
+func (s *Server) waitForReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ins, err := s.Connect("embedded")
+		if err == nil {
+			return ins.Close()
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("embedded server is not ready after %v: %v", timeout, err)
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+}
+
+// PrepareDataset generates the datagen dataset with the arguments and seed and loads it into the database of the instance,
+// then analyzes all its tables.
+func PrepareDataset(ins tidb.Instance, dataset, args, db string, seed int64) error {
+	dir, err := ioutil.TempDir("", "optimizer-tester-"+dataset)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer os.RemoveAll(dir)
+
+	begin := time.Now()
+	if err := datagen.Generate(dataset, args, dir, seed); err != nil {
+		return err
+	}
+	if err := datagen.LoadData(ins, dataset, dir, db); err != nil {
+		return err
+	}
+	rows, err := ins.Query(fmt.Sprintf("SELECT table_name FROM information_schema.tables WHERE table_schema='%v'", db))
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for rows.Next() {
+		var tb string
+		if err := rows.Scan(&tb); err != nil {
+			rows.Close()
+			return errors.Trace(err)
+		}
+		tables = append(tables, tb)
+	}
+	if err := rows.Close(); err != nil {
+		return errors.Trace(err)
+	}
+	for _, tb := range tables {
+		if err := ins.Exec(fmt.Sprintf("ANALYZE TABLE %v.`%v`", db, tb)); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("[embedded] prepare dataset=%v, args=%v, db=%v, seed=%v, cost=%v\n", dataset, args, db, seed, time.Since(begin))
+	return nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,490 @@
+// Package predicate parses predicates on columns and literals in SQL and in optimizer traces, which are estimated
+// by the stats simulator and baseline estimators.
+package predicate
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pingcap/errors"
+)
+
+// Expr is a node of parsed predicates, which is one of And, Or and Cmp.
+type Expr interface{}
+
+// And is the conjunction of expressions.
+type And []Expr
+
+// Or is the disjunction of expressions.
+type Or []Expr
+
+// Operators of Cmp.
+const (
+	OpEQ      = "="
+	OpNE      = "!="
+	OpLT      = "<"
+	OpLE      = "<="
+	OpGT      = ">"
+	OpGE      = ">="
+	OpIn      = "in"
+	OpBetween = "between"
+	OpIsNull  = "is null"
+	OpNotNull = "is not null"
+)
+
+// Cmp compares a column with literals.
+type Cmp struct {
+	Col  string // the lower-case column name without qualifiers
+	Op   string
+	Vals []Literal
+}
+
+// Literal is a literal compared with columns.
+type Literal struct {
+	Text   string
+	Quoted bool // whether it's a string literal
+	Null   bool
+}
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokNumber
+	tokString
+	tokOp
+	tokEOF
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func (t token) is(kind tokenKind, text string) bool {
+	return t.kind == kind && strings.EqualFold(t.text, text)
+}
+
+func isIdentChar(r byte) bool {
+	return r == '_' || r == '$' || r == '.' || r == '`' || r >= 0x80 || unicode.IsLetter(rune(r)) || unicode.IsDigit(rune(r))
+}
+
+func isDigit(r byte) bool { return r >= '0' && r <= '9' }
+
+// keywords are identifiers followed by operands, so a '-' after them is the sign of a number.
+var keywords = map[string]bool{"and": true, "or": true, "between": true, "in": true, "is": true, "not": true}
+
+// tokenize splits the predicate into tokens, backquoted and qualified names are kept in one identifier.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		prevIsValue := len(tokens) > 0 && (tokens[len(tokens)-1].kind != tokOp || tokens[len(tokens)-1].text == ")") &&
+			!(tokens[len(tokens)-1].kind == tokIdent && keywords[strings.ToLower(tokens[len(tokens)-1].text)])
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(s); j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+					sb.WriteByte(s[j])
+				} else if s[j] == c && j+1 < len(s) && s[j+1] == c {
+					j++
+					sb.WriteByte(c)
+				} else if s[j] == c {
+					break
+				} else {
+					sb.WriteByte(s[j])
+				}
+			}
+			if j >= len(s) {
+				return nil, errors.Errorf("unterminated string in %v", s)
+			}
+			tokens = append(tokens, token{tokString, sb.String()})
+			i = j + 1
+		case isDigit(c) || (c == '.' && i+1 < len(s) && isDigit(s[i+1])) ||
+			(c == '-' && !prevIsValue && i+1 < len(s) && (isDigit(s[i+1]) || s[i+1] == '.')):
+			j := i + 1
+			for j < len(s) && (isDigit(s[j]) || s[j] == '.' || s[j] == 'e' || s[j] == 'E' ||
+				((s[j] == '-' || s[j] == '+') && (s[j-1] == 'e' || s[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, s[i:j]})
+			i = j
+		case isIdentChar(c):
+			j := i
+			for j < len(s) && isIdentChar(s[j]) {
+				if s[j] == '`' {
+					end := strings.IndexByte(s[j+1:], '`')
+					if end < 0 {
+						return nil, errors.Errorf("unterminated identifier in %v", s)
+					}
+					j += end + 2
+				} else {
+					j++
+				}
+			}
+			ident := s[i:j]
+			// skip charset introducers like _utf8mb4'abc'
+			if ident[0] == '_' && j < len(s) && (s[j] == '\'' || s[j] == '"') {
+				i = j
+				continue
+			}
+			tokens = append(tokens, token{tokIdent, ident})
+			i = j
+		default:
+			op := string(c)
+			if i+1 < len(s) {
+				switch two := s[i : i+2]; two {
+				case "<=", ">=", "!=", "<>", "&&", "||":
+					op = two
+				}
+			}
+			if !strings.Contains("=<>!(),&|", op[:1]) || op == "!" {
+				return nil, errors.Errorf("unexpected character %q in %v", c, s)
+			}
+			tokens = append(tokens, token{tokOp, op})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses predicates like "a=1 AND (b>'x' OR c IS NULL)", and function-style expressions in
+// optimizer traces like "and(eq(test.t.a, 1), lt(test.t.b, 2))".
+func Parse(s string) (Expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, errors.Annotatef(err, "parse %v", s)
+	}
+	if p.peek().kind != tokEOF {
+		return nil, errors.Errorf("unexpected %v in %v", p.peek().text, s)
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(kind tokenKind, text string) bool {
+	if p.peek().is(kind, text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if !p.accept(kind, text) {
+		return errors.Errorf("expect %v but got %v", text, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	var items Or
+	for {
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+		if !p.accept(tokIdent, "or") && !p.accept(tokOp, "||") {
+			break
+		}
+	}
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	return items, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	var items And
+	for {
+		e, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+		if !p.accept(tokIdent, "and") && !p.accept(tokOp, "&&") {
+			break
+		}
+	}
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	return items, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	if p.accept(tokOp, "(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(tokOp, ")")
+	}
+	if t := p.peek(); t.kind == tokIdent && p.tokens[p.pos+1].is(tokOp, "(") {
+		return p.parseFunc()
+	}
+	if p.peek().is(tokIdent, "not") {
+		return nil, errors.New("NOT is unsupported")
+	}
+
+	left, leftIsCol, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.accept(tokIdent, "is") {
+		if !leftIsCol {
+			return nil, errors.New("IS NULL on literals is unsupported")
+		}
+		op := OpIsNull
+		if p.accept(tokIdent, "not") {
+			op = OpNotNull
+		}
+		return Cmp{Col: left.Text, Op: op}, p.expect(tokIdent, "null")
+	}
+	if p.accept(tokIdent, "in") {
+		if !leftIsCol {
+			return nil, errors.New("IN on literals is unsupported")
+		}
+		vals, err := p.parseLiteralList()
+		return Cmp{Col: left.Text, Op: OpIn, Vals: vals}, err
+	}
+	if p.accept(tokIdent, "between") {
+		if !leftIsCol {
+			return nil, errors.New("BETWEEN on literals is unsupported")
+		}
+		low, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokIdent, "and"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseLiteral()
+		return Cmp{Col: left.Text, Op: OpBetween, Vals: []Literal{low, high}}, err
+	}
+
+	opTok := p.next()
+	op := opTok.text
+	if op == "<>" {
+		op = OpNE
+	}
+	switch op {
+	case OpEQ, OpNE, OpLT, OpLE, OpGT, OpGE:
+	default:
+		return nil, errors.Errorf("unsupported operator %v", opTok.text)
+	}
+	right, rightIsCol, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return newCmpExpr(op, left, leftIsCol, right, rightIsCol)
+}
+
+// flippedOps are operators after swapping their operands.
+var flippedOps = map[string]string{OpEQ: OpEQ, OpNE: OpNE, OpLT: OpGT, OpLE: OpGE, OpGT: OpLT, OpGE: OpLE}
+
+func newCmpExpr(op string, left Literal, leftIsCol bool, right Literal, rightIsCol bool) (Expr, error) {
+	switch {
+	case leftIsCol && !rightIsCol:
+		return Cmp{Col: left.Text, Op: op, Vals: []Literal{right}}, nil
+	case !leftIsCol && rightIsCol:
+		return Cmp{Col: right.Text, Op: flippedOps[op], Vals: []Literal{left}}, nil
+	}
+	return nil, errors.Errorf("comparisons between %v and %v are unsupported", left.Text, right.Text)
+}
+
+// parseOperand parses a column or a literal.
+func (p *parser) parseOperand() (Literal, bool, error) {
+	t := p.peek()
+	if t.kind == tokIdent && !t.is(tokIdent, "null") && !t.is(tokIdent, "true") && !t.is(tokIdent, "false") {
+		p.next()
+		return Literal{Text: ColumnName(t.text)}, true, nil
+	}
+	l, err := p.parseLiteral()
+	return l, false, err
+}
+
+func (p *parser) parseLiteral() (Literal, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokNumber:
+		return Literal{Text: t.text}, nil
+	case t.kind == tokString:
+		return Literal{Text: t.text, Quoted: true}, nil
+	case t.is(tokIdent, "null"):
+		return Literal{Null: true}, nil
+	case t.is(tokIdent, "true"):
+		return Literal{Text: "1"}, nil
+	case t.is(tokIdent, "false"):
+		return Literal{Text: "0"}, nil
+	}
+	return Literal{}, errors.Errorf("expect a literal but got %v", t.text)
+}
+
+func (p *parser) parseLiteralList() ([]Literal, error) {
+	if err := p.expect(tokOp, "("); err != nil {
+		return nil, err
+	}
+	var vals []Literal
+	for {
+		l, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, l)
+		if !p.accept(tokOp, ",") {
+			break
+		}
+	}
+	return vals, p.expect(tokOp, ")")
+}
+
+// funcOps are operators of comparison functions in optimizer traces.
+var funcOps = map[string]string{"eq": OpEQ, "ne": OpNE, "lt": OpLT, "le": OpLE, "gt": OpGT, "ge": OpGE}
+
+// parseFunc parses function-style expressions like `eq`(test.t.a, 1) in optimizer traces.
+func (p *parser) parseFunc() (Expr, error) {
+	name := strings.ToLower(strings.Trim(p.next().text, "`"))
+	if err := p.expect(tokOp, "("); err != nil {
+		return nil, err
+	}
+	switch name {
+	case "and", "or":
+		var items []Expr
+		for {
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, e)
+			if !p.accept(tokOp, ",") {
+				break
+			}
+		}
+		if name == "and" {
+			return And(items), p.expect(tokOp, ")")
+		}
+		return Or(items), p.expect(tokOp, ")")
+	case "isnull":
+		col, isCol, err := p.parseOperand()
+		if err != nil || !isCol {
+			return nil, errors.Errorf("unsupported arguments of isnull")
+		}
+		return Cmp{Col: col.Text, Op: OpIsNull}, p.expect(tokOp, ")")
+	case "not":
+		e, err := p.parseFunc()
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := e.(Cmp); ok && c.Op == OpIsNull {
+			return Cmp{Col: c.Col, Op: OpNotNull}, p.expect(tokOp, ")")
+		}
+		return nil, errors.New("NOT is unsupported")
+	case "in":
+		col, isCol, err := p.parseOperand()
+		if err != nil || !isCol {
+			return nil, errors.Errorf("unsupported arguments of in")
+		}
+		var vals []Literal
+		for p.accept(tokOp, ",") {
+			l, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, l)
+		}
+		return Cmp{Col: col.Text, Op: OpIn, Vals: vals}, p.expect(tokOp, ")")
+	}
+	op, ok := funcOps[name]
+	if !ok {
+		return nil, errors.Errorf("unsupported function %v", name)
+	}
+	left, leftIsCol, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokOp, ","); err != nil {
+		return nil, err
+	}
+	right, rightIsCol, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokOp, ")"); err != nil {
+		return nil, err
+	}
+	return newCmpExpr(op, left, leftIsCol, right, rightIsCol)
+}
+
+// ColumnName returns the lower-case column name without qualifiers and backquotes.
+func ColumnName(ident string) string {
+	name := ident
+	if strings.HasSuffix(name, "`") {
+		if start := strings.LastIndex(name[:len(name)-1], "`"); start >= 0 {
+			return strings.ToLower(name[start+1 : len(name)-1])
+		}
+	}
+	if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+		name = name[dot+1:]
+	}
+	return strings.ToLower(strings.Trim(name, "`"))
+}
+
+// Columns returns columns in the expression.
+func Columns(e Expr) map[string]bool {
+	cols := make(map[string]bool)
+	var walk func(e Expr)
+	walk = func(e Expr) {
+		switch x := e.(type) {
+		case And:
+			for _, item := range x {
+				walk(item)
+			}
+		case Or:
+			for _, item := range x {
+				walk(item)
+			}
+		case Cmp:
+			cols[x.Col] = true
+		}
+	}
+	walk(e)
+	return cols
+}
+
+// Conjuncts splits the expression by AND.
+func Conjuncts(e Expr) []Expr {
+	if and, ok := e.(And); ok {
+		var items []Expr
+		for _, item := range and {
+			items = append(items, Conjuncts(item)...)
+		}
+		return items
+	}
+	return []Expr{e}
+}
@@ -0,0 +1,35 @@
+package predicate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		cond string
+		expr Expr
+	}{
+		{"`名称`='x' AND db.t.b IN (1, 2)", And{Cmp{Col: "名称", Op: OpEQ, Vals: []Literal{{Text: "x", Quoted: true}}},
+			Cmp{Col: "b", Op: OpIn, Vals: []Literal{{Text: "1"}, {Text: "2"}}}}},
+		{"or(lt(10, test.t.a), isnull(test.t.a))", Or{Cmp{Col: "a", Op: OpGT, Vals: []Literal{{Text: "10"}}}, Cmp{Col: "a", Op: OpIsNull}}},
+		{"a BETWEEN -1 AND 1", Cmp{Col: "a", Op: OpBetween, Vals: []Literal{{Text: "-1"}, {Text: "1"}}}},
+	}
+	for _, c := range cases {
+		e, err := Parse(c.cond)
+		if err != nil {
+			t.Fatalf("%v: %v", c.cond, err)
+		}
+		if !reflect.DeepEqual(e, c.expr) {
+			t.Fatalf("%v: expected %+v, got %+v", c.cond, c.expr, e)
+		}
+	}
+}
+
+func TestParseUnsupported(t *testing.T) {
+	for _, cond := range []string{"a LIKE 'x%'", "NOT a = 1", "a = 1 AND", "a = b + 1"} {
+		if _, err := Parse(cond); err == nil {
+			t.Fatalf("%v should be unsupported", cond)
+		}
+	}
+}
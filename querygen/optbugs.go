@@ -0,0 +1,276 @@
+package querygen
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// Oracles deciding whether the planner returns wrong results of generated predicates.
+const (
+	// OracleTLP is Ternary Logic Partitioning: rows of a query are partitioned by a predicate p into rows satisfying
+	// p, NOT p and p IS NULL, so the query must return the same rows as the union of the three partitions.
+	OracleTLP = "tlp"
+	// OracleNoREC is Non-optimizing Reference Engine Construction: the count of rows filtered by p must equal the
+	// count of rows where p is true computed by a projection, which the optimizer cannot optimize by p.
+	OracleNoREC = "norec"
+)
+
+// bugPattern generates predicates on 1 to 3 random columns of the table, regardless of its indexes.
+type bugPattern struct {
+	cols []*column
+}
+
+func newBugPattern(tbl *table) *bugPattern {
+	bp := &bugPattern{}
+	for _, col := range tbl.Cols {
+		if len(col.RandVals) > 0 && len(col.RandDistinctVals) > 0 {
+			bp.cols = append(bp.cols, col)
+		}
+	}
+	return bp
+}
+
+func (bp *bugPattern) generate(rng *rand.Rand) string {
+	pt := &pattern{}
+	for _, i := range rng.Perm(len(bp.cols))[:1+rng.Intn(minInt(3, len(bp.cols)))] {
+		tp := equal
+		if rng.Intn(2) == 0 {
+			tp = interval
+		}
+		pt.cols = append(pt.cols, &colPattern{col: bp.cols[i], tp: tp})
+	}
+	return pt.generate(rng)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// discrepancy is a predicate whose queries violate an oracle.
+type discrepancy struct {
+	instance string
+	oracle   string
+	expected string
+	actual   string
+	sqls     []string
+}
+
+// maxDupPredicates is the number of duplicated predicates generated in a row after which RunOptBugs stops, since
+// few distinct values or columns may not produce enough distinct predicates.
+const maxDupPredicates = 1000
+
+// RunOptBugs generates n predicates on the table by patterns of querygen, checks queries of these predicates by
+// oracles on each DSN, and writes discrepancies with their SQLs and the seed into the output file.
+// Queries of a check always run on the same DSN, and errors of them are written as discrepancies.
+func RunOptBugs(dsns []string, outputFile, dbName, tableName string, n uint, oracles []string, seed int64) error {
+	for _, oracle := range oracles {
+		if oracle != OracleTLP && oracle != OracleNoREC {
+			return errors.Errorf("unknown oracle=%v", oracle)
+		}
+	}
+	queryTaskChan, err := startQueryRunners(dsns)
+	if err != nil {
+		return err
+	}
+	fullTableName := dbName + "." + tableName
+	tbl := loadTable(queryTaskChan, dbName, tableName, n, seed)
+	defer func() {
+		queryTaskChan <- &tidb.QueryTask{Exited: true}
+	}()
+	bp := newBugPattern(tbl)
+	if len(bp.cols) == 0 {
+		fmt.Println("Table " + fullTableName + " doesn't contain any column with values")
+		return nil
+	}
+	instances := make([]tidb.Instance, 0, len(dsns))
+	for i, dsn := range dsns {
+		ins, err := tidb.ConnectToDSN(dsn, fmt.Sprintf("DSN#%d", i))
+		if err != nil {
+			return err
+		}
+		defer ins.Close()
+		instances = append(instances, ins)
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer file.Close()
+	if _, err = file.WriteString(fmt.Sprintf("-- seed: %v\n", seed)); err != nil {
+		return errors.Trace(err)
+	}
+
+	colNames := make([]string, len(tbl.Cols))
+	for i, col := range tbl.Cols {
+		colNames[i] = col.Name
+	}
+	checksum := "COUNT(*), SUM(CRC32(CONCAT_WS('#', " + strings.Join(colNames, ", ") + ")))"
+	rng := rand.New(rand.NewSource(seed))
+	dedupMap := make(map[string]struct{})
+	nDiscrepancies, nChecked := 0, 0
+	for dups := 0; nChecked < int(n); {
+		pred := bp.generate(rng)
+		var base string // TLP partitions rows of queries with or without a base predicate
+		if rng.Intn(2) == 0 {
+			base = bp.generate(rng)
+		}
+		if _, ok := dedupMap[base+"#"+pred]; ok {
+			if dups++; dups >= maxDupPredicates {
+				fmt.Printf("[%s] stop after %d duplicated predicates in a row, the table can't produce %d distinct predicates.\n",
+					logTime(), dups, n)
+				break
+			}
+			continue
+		}
+		dedupMap[base+"#"+pred] = struct{}{}
+		dups = 0
+		nChecked++
+
+		var ds []discrepancy
+		for _, ins := range instances {
+			for _, oracle := range oracles {
+				var d *discrepancy
+				switch oracle {
+				case OracleTLP:
+					d = checkTLP(ins, fullTableName, checksum, base, pred)
+				case OracleNoREC:
+					d = checkNoREC(ins, fullTableName, pred)
+				}
+				if d != nil {
+					ds = append(ds, *d)
+				}
+			}
+		}
+		for _, d := range ds {
+			nDiscrepancies++
+			fmt.Printf("[%s] %v discrepancy found on %v: %v\n", logTime(), d.oracle, d.instance, pred)
+			str := fmt.Sprintf("\n-- %v on %v: expected %v, got %v\n%v;\n", d.oracle, d.instance, d.expected, d.actual, strings.Join(d.sqls, ";\n"))
+			if _, err = file.WriteString(str); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	fmt.Printf("[%s] %d predicates checked, %d discrepancies found.\n", logTime(), nChecked, nDiscrepancies)
+	return nil
+}
+
+// checkTLP checks whether the count and the checksum of rows of the table satisfying the base predicate equal
+// the sums of the ones of the three partitions by the predicate.
+func checkTLP(ins tidb.Instance, fullTableName, checksum, base, pred string) *discrepancy {
+	where := func(cond string) string {
+		if base == "" {
+			return " where " + cond
+		}
+		return " where (" + base + ") and " + cond
+	}
+	q := "select " + checksum + " from " + fullTableName
+	if base != "" {
+		q += " where " + base
+	}
+	sqls := []string{q}
+	for _, cond := range []string{"(" + pred + ")", "not (" + pred + ")", "(" + pred + ") is null"} {
+		sqls = append(sqls, "select "+checksum+" from "+fullTableName+where(cond))
+	}
+	var cnts, sums [4]uint64
+	for i, sql := range sqls {
+		row, err := queryUints(ins, sql)
+		if err != nil {
+			return errorDiscrepancy(ins, OracleTLP, sql, err)
+		}
+		cnts[i], sums[i] = row[0], row[1]
+	}
+	if cnts[0] == cnts[1]+cnts[2]+cnts[3] && sums[0] == sums[1]+sums[2]+sums[3] {
+		return nil
+	}
+	return &discrepancy{
+		instance: ins.Opt().Label,
+		oracle:   OracleTLP,
+		expected: fmt.Sprintf("count=%v checksum=%v", cnts[0], sums[0]),
+		actual:   fmt.Sprintf("count=%v checksum=%v of partitions", cnts[1]+cnts[2]+cnts[3], sums[1]+sums[2]+sums[3]),
+		sqls:     sqls,
+	}
+}
+
+// checkNoREC checks whether the count of rows filtered by the predicate equals the count of rows where the
+// predicate is true, which is computed by a projection instead of a filter.
+func checkNoREC(ins tidb.Instance, fullTableName, pred string) *discrepancy {
+	optimized := "select count(*) from " + fullTableName + " where " + pred
+	unoptimized := "select ifnull(sum(p), 0) from (select (" + pred + ") is true as p from " + fullTableName + ") t"
+	var cnts [2]uint64
+	for i, sql := range []string{optimized, unoptimized} {
+		row, err := queryUints(ins, sql)
+		if err != nil {
+			return errorDiscrepancy(ins, OracleNoREC, sql, err)
+		}
+		cnts[i] = row[0]
+	}
+	if cnts[0] == cnts[1] {
+		return nil
+	}
+	return &discrepancy{
+		instance: ins.Opt().Label,
+		oracle:   OracleNoREC,
+		expected: fmt.Sprintf("count=%v", cnts[1]),
+		actual:   fmt.Sprintf("count=%v", cnts[0]),
+		sqls:     []string{unoptimized, optimized},
+	}
+}
+
+// errorDiscrepancy reports the query of the check which fails on the instance.
+func errorDiscrepancy(ins tidb.Instance, oracle, sql string, err error) *discrepancy {
+	return &discrepancy{
+		instance: ins.Opt().Label,
+		oracle:   oracle,
+		expected: "no error",
+		actual:   "error: " + strings.Replace(err.Error(), "\n", " ", -1),
+		sqls:     []string{sql},
+	}
+}
+
+// queryUints runs the query and converts COUNT or SUM results of its first row to uint64, where NULL is 0.
+func queryUints(ins tidb.Instance, query string) ([]uint64, error) {
+	rows, err := ins.Query(query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, errors.Trace(err)
+		}
+		return nil, errors.Errorf("no result of %v", query)
+	}
+	vals := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, errors.Trace(err)
+	}
+	results := make([]uint64, len(cols))
+	for i, v := range vals {
+		if !v.Valid {
+			continue
+		}
+		if results[i], err = strconv.ParseUint(v.String, 10, 64); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return results, nil
+}
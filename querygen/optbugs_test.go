@@ -0,0 +1,64 @@
+package querygen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+const testChecksum = "COUNT(*), SUM(CRC32(CONCAT_WS('#', a, b)))"
+
+func newTLPInstance(isNullCnt, isNullSum interface{}) *tidb.FakeInstance {
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "DSN#0"})
+	cols := []string{"cnt", "sum"}
+	ins.OnExactQuery("select "+testChecksum+" from db.t where b > 1").Return(cols, []interface{}{10, 100})
+	ins.OnExactQuery("select "+testChecksum+" from db.t where (b > 1) and (a = 1)").Return(cols, []interface{}{4, 40})
+	ins.OnExactQuery("select "+testChecksum+" from db.t where (b > 1) and not (a = 1)").Return(cols, []interface{}{6, 60})
+	ins.OnExactQuery("select "+testChecksum+" from db.t where (b > 1) and (a = 1) is null").Return(cols, []interface{}{isNullCnt, isNullSum})
+	return ins
+}
+
+func TestCheckTLP(t *testing.T) {
+	if d := checkTLP(newTLPInstance(0, nil), "db.t", testChecksum, "b > 1", "a = 1"); d != nil {
+		t.Fatalf("unexpected discrepancy %+v", d)
+	}
+	d := checkTLP(newTLPInstance(1, 7), "db.t", testChecksum, "b > 1", "a = 1")
+	if d == nil || d.instance != "DSN#0" || d.oracle != OracleTLP || len(d.sqls) != 4 ||
+		d.expected != "count=10 checksum=100" || d.actual != "count=11 checksum=107 of partitions" {
+		t.Fatalf("unexpected discrepancy %+v", d)
+	}
+
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "DSN#0"})
+	ins.OnQuery("not").ReturnError(errors.New("out of memory"))
+	ins.OnQuery(".*").Return([]string{"cnt", "sum"}, []interface{}{1, 1})
+	d = checkTLP(ins, "db.t", testChecksum, "b > 1", "a = 1")
+	if d == nil || d.expected != "no error" || !strings.Contains(d.actual, "out of memory") ||
+		len(d.sqls) != 1 || !strings.Contains(d.sqls[0], "not (a = 1)") {
+		t.Fatalf("errors should be reported as discrepancies, got %+v", d)
+	}
+}
+
+func TestCheckNoREC(t *testing.T) {
+	optimized := "select count(*) from db.t where a = 1"
+	unoptimized := "select ifnull(sum(p), 0) from (select (a = 1) is true as p from db.t) t"
+	ins := tidb.NewFakeInstance(tidb.Option{Label: "DSN#1"})
+	ins.OnExactQuery(optimized).Return([]string{"count(*)"}, []interface{}{3}).Times(1)
+	ins.OnExactQuery(optimized).Return([]string{"count(*)"}, []interface{}{2})
+	ins.OnExactQuery(unoptimized).Return([]string{"p"}, []interface{}{3})
+	if d := checkNoREC(ins, "db.t", "a = 1"); d != nil {
+		t.Fatalf("unexpected discrepancy %+v", d)
+	}
+	d := checkNoREC(ins, "db.t", "a = 1")
+	if d == nil || d.instance != "DSN#1" || d.expected != "count=3" || d.actual != "count=2" || len(d.sqls) != 2 {
+		t.Fatalf("unexpected discrepancy %+v", d)
+	}
+
+	ins = tidb.NewFakeInstance(tidb.Option{Label: "DSN#1"})
+	ins.OnExactQuery(optimized).Return([]string{"count(*)"}, []interface{}{3})
+	d = checkNoREC(ins, "db.t", "a = 1") // the unoptimized query isn't matched and fails
+	if d == nil || d.oracle != OracleNoREC || d.expected != "no error" || len(d.sqls) != 1 || d.sqls[0] != unoptimized {
+		t.Fatalf("errors should be reported as discrepancies, got %+v", d)
+	}
+}
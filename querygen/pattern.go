@@ -9,10 +9,10 @@ type pattern struct {
 	cols []*colPattern
 }
 
-func (p *pattern) generate() string {
+func (p *pattern) generate(rng *rand.Rand) string {
 	exprs := make([]string, 0, len(p.cols))
 	for _, col := range p.cols {
-		exprs = append(exprs, "("+col.generate()+")")
+		exprs = append(exprs, "("+col.generate(rng)+")")
 	}
 	return strings.Join(exprs, " and ")
 }
@@ -22,26 +22,26 @@ type colPattern struct {
 	tp  exprType
 }
 
-func (cp *colPattern) generate() string {
+func (cp *colPattern) generate(rng *rand.Rand) string {
 	if cp.tp == equal {
-		return cp.generateEqual()
+		return cp.generateEqual(rng)
 	} else if cp.tp == interval {
-		return cp.generateInterval()
+		return cp.generateInterval(rng)
 	}
 	return "true"
 }
 
-func (cp *colPattern) generateEqual() string {
+func (cp *colPattern) generateEqual(rng *rand.Rand) string {
 	vals := cp.col.RandVals
-	val1 := vals[rand.Intn(len(vals))]
-	r := rand.Float64()
+	val1 := vals[rng.Intn(len(vals))]
+	r := rng.Float64()
 	n := cp.col.Name
 	if r < 0.5 {
 		return n + " = " + val1
 	}
 	dVals := cp.col.RandDistinctVals
-	val2 := dVals[rand.Intn(len(dVals))]
-	val3 := dVals[rand.Intn(len(dVals))]
+	val2 := dVals[rng.Intn(len(dVals))]
+	val3 := dVals[rng.Intn(len(dVals))]
 	if r < 0.75 {
 		return n + " = " + val1 + " or " + n + " = " + val2 + " or " + n + " = " + val3
 	}
@@ -51,10 +51,10 @@ func (cp *colPattern) generateEqual() string {
 	return n + " is null"
 }
 
-func (cp *colPattern) generateInterval() string {
+func (cp *colPattern) generateInterval(rng *rand.Rand) string {
 	dVals := cp.col.RandDistinctVals
-	val1 := dVals[rand.Intn(len(dVals))]
-	r := rand.Float64()
+	val1 := dVals[rng.Intn(len(dVals))]
+	r := rng.Float64()
 	n := cp.col.Name
 	if r < 0.05 {
 		return n + " > " + cp.col.Max
@@ -74,7 +74,7 @@ func (cp *colPattern) generateInterval() string {
 	if r < 0.6 {
 		return n + " > " + val1
 	}
-	rIdx1, rIdx2 := rand.Intn(len(dVals)), rand.Intn(len(dVals))
+	rIdx1, rIdx2 := rng.Intn(len(dVals)), rng.Intn(len(dVals))
 	if rIdx1 > rIdx2 {
 		rIdx1, rIdx2 = rIdx2, rIdx1
 	}
@@ -8,6 +8,7 @@ import (
 	"github.com/pingcap/tidb/parser/types"
 	_ "github.com/pingcap/tidb/types/parser_driver"
 	"github.com/qw4990/OptimizerTester/tidb"
+	"math"
 	"math/rand"
 	"os"
 	"strconv"
@@ -25,17 +26,22 @@ func logTime() string {
 
 const concurrencyForEachDSN = uint(1)
 
-func RunQueryGen(dsns []string, outputFile, dbName, tableName string, n uint) error {
-	var err error
+// startQueryRunners starts query runners on all DSNs, which run queries sent to the returned channel.
+func startQueryRunners(dsns []string) (chan *tidb.QueryTask, error) {
 	queryTaskChan := make(chan *tidb.QueryTask, 100)
 	for i, dsn := range dsns {
 		setMemQuotaSQL := "set @@tidb_mem_quota_query = 3221225472;"
-		err = tidb.StartQueryRunner(dsn, queryTaskChan, concurrencyForEachDSN, 1, uint(i), setMemQuotaSQL)
+		err := tidb.StartQueryRunner(dsn, queryTaskChan, concurrencyForEachDSN, 1, uint(i), setMemQuotaSQL)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		fmt.Printf("[%s] %d query runners started for DSN#%d: %s.\n", logTime(), concurrencyForEachDSN, i, dsn)
 	}
+	return queryTaskChan, nil
+}
+
+// loadTable collects the schema of the table, and the data distribution and sampled values of its columns.
+func loadTable(queryTaskChan chan *tidb.QueryTask, dbName, tableName string, n uint, seed int64) *table {
 	p := parser.New()
 	fullTableName := dbName + "." + tableName
 
@@ -117,14 +123,15 @@ func RunQueryGen(dsns []string, outputFile, dbName, tableName string, n uint) er
 	}
 	tbl.RowCount = uint(ndv)
 
-	sampleSQLTemplate := "select %s from " + fullTableName + " where rand() < %f order by %s"
-	sampleDistinctSQLTemplate := "select * from (select distinct %s from " + fullTableName + ") n where rand() < %f order by %s"
+	// sample values by hashing them with the seed instead of rand() to make samples reproducible
+	sampleSQLTemplate := "select %s from " + fullTableName + " where crc32(concat_ws('#', %s, %d)) < %f order by %s"
+	sampleDistinctSQLTemplate := "select * from (select distinct %s from " + fullTableName + ") n where crc32(concat_ws('#', %s, %d)) < %f order by %s"
 	for _, col := range cols {
 		sampleRate := float64(n) / float64(tbl.RowCount)
 		if sampleRate > 1 {
 			sampleRate = 1
 		}
-		sampleSQL := fmt.Sprintf(sampleSQLTemplate, col.Name, sampleRate, col.Name)
+		sampleSQL := fmt.Sprintf(sampleSQLTemplate, col.Name, col.Name, seed, sampleRate*math.MaxUint32, col.Name)
 		result := runQuery(sampleSQL, queryTaskChan)
 		for _, val := range result {
 			col.RandVals = append(col.RandVals, queryResultToStr(val[0], col.TP))
@@ -134,12 +141,24 @@ func RunQueryGen(dsns []string, outputFile, dbName, tableName string, n uint) er
 		if distinctSampleRate > 1 {
 			distinctSampleRate = 1
 		}
-		sampleDistinctSQL := fmt.Sprintf(sampleDistinctSQLTemplate, col.Name, distinctSampleRate, col.Name)
+		sampleDistinctSQL := fmt.Sprintf(sampleDistinctSQLTemplate, col.Name, col.Name, seed, distinctSampleRate*math.MaxUint32, col.Name)
 		result = runQuery(sampleDistinctSQL, queryTaskChan)
 		for _, val := range result {
 			col.RandDistinctVals = append(col.RandDistinctVals, queryResultToStr(val[0], col.TP))
 		}
 	}
+	return &tbl
+}
+
+// RunQueryGen generates n queries on the table into the output file.
+// The same seed always generates the same queries on the same data.
+func RunQueryGen(dsns []string, outputFile, dbName, tableName string, n uint, seed int64) error {
+	queryTaskChan, err := startQueryRunners(dsns)
+	if err != nil {
+		return err
+	}
+	fullTableName := dbName + "." + tableName
+	tbl := loadTable(queryTaskChan, dbName, tableName, n, seed)
 	queryTaskChan <- &tidb.QueryTask{Exited: true}
 
 	// 3. generate query patterns
@@ -147,7 +166,7 @@ func RunQueryGen(dsns []string, outputFile, dbName, tableName string, n uint) er
 		fmt.Println("Table " + fullTableName + " doesn't contain multi-col index")
 		return nil
 	}
-	patterns := make([]*pattern, 0, len(multiColIdxs)*2)
+	patterns := make([]*pattern, 0, len(tbl.multiColIdxes)*2)
 	for _, idx := range tbl.multiColIdxes {
 		for i := range idx.Cols {
 			colPatterns := make([]*colPattern, 0, len(idx.Cols))
@@ -176,11 +195,15 @@ func RunQueryGen(dsns []string, outputFile, dbName, tableName string, n uint) er
 			panic(err)
 		}
 	}()
+	if _, err = file.WriteString(fmt.Sprintf("-- seed: %v\n", seed)); err != nil {
+		panic(err)
+	}
+	rng := rand.New(rand.NewSource(seed))
 	dedupMap := make(map[string]struct{})
 	for i := 0; i < int(n); {
 		sql := "select * from " + fullTableName + " where "
-		pt := patterns[rand.Intn(len(patterns))]
-		expr := pt.generate()
+		pt := patterns[rng.Intn(len(patterns))]
+		expr := pt.generate(rng)
 		if _, ok := dedupMap[expr]; ok {
 			continue
 		}
@@ -0,0 +1,157 @@
+package statssim
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/spaolacci/murmur3"
+)
+
+// cmSketch is the Count-Min Sketch of stats version 1.
+type cmSketch struct {
+	depth        int
+	width        int
+	count        uint64 // the sum of counters of a row
+	defaultValue uint64
+	table        [][]uint32
+}
+
+func newCMSketch(depth, width int) *cmSketch {
+	c := &cmSketch{depth: depth, width: width, table: make([][]uint32, depth)}
+	for i := range c.table {
+		c.table[i] = make([]uint32, width)
+	}
+	return c
+}
+
+// insertBytes inserts the encoded value into the sketch, which is only used to build sketches in tests.
+func (c *cmSketch) insertBytes(d []byte, count uint64) {
+	h1, h2 := murmur3.Sum128(d)
+	c.count += count
+	for i := range c.table {
+		j := (h1 + h2*uint64(i)) % uint64(c.width)
+		c.table[i][j] += uint32(count)
+	}
+}
+
+func (c *cmSketch) considerDefVal(cnt uint64) bool {
+	return (cnt == 0 || (cnt > c.defaultValue && cnt < 2*(c.count/uint64(c.width)))) && c.defaultValue > 0
+}
+
+// queryBytes estimates the count of the encoded value like CMSketch.QueryBytes.
+func (c *cmSketch) queryBytes(d []byte) uint64 {
+	h1, h2 := murmur3.Sum128(d)
+	return c.queryHashValue(h1, h2)
+}
+
+func (c *cmSketch) queryHashValue(h1, h2 uint64) uint64 {
+	vals := make([]uint32, c.depth)
+	min := ^uint32(0)
+	// temp distinguishes counters which are 0 before and after eliminating the noise
+	temp := uint32(1)
+	for i := range c.table {
+		j := (h1 + h2*uint64(i)) % uint64(c.width)
+		if min > c.table[i][j] {
+			min = c.table[i][j]
+		}
+		noise := (c.count - uint64(c.table[i][j])) / (uint64(c.width) - 1)
+		if uint64(c.table[i][j]) == 0 {
+			vals[i] = 0
+		} else if uint64(c.table[i][j]) < noise {
+			vals[i] = temp
+		} else {
+			vals[i] = c.table[i][j] - uint32(noise) + temp
+		}
+	}
+	sort.Slice(vals, func(i, j int) bool { return vals[i] < vals[j] })
+	res := vals[(c.depth-1)/2] + (vals[c.depth/2]-vals[(c.depth-1)/2])/2
+	if res > min+temp {
+		res = min + temp
+	}
+	if res == 0 {
+		return 0
+	}
+	res = res - temp
+	if c.considerDefVal(uint64(res)) {
+		return c.defaultValue
+	}
+	return uint64(res)
+}
+
+type topNItem struct {
+	encoded []byte
+	count   uint64
+}
+
+// topN is the TopN of stats, whose items are sorted by their encoded values.
+type topN struct {
+	items []topNItem
+}
+
+func newTopN(items []topNItem) *topN {
+	sort.Slice(items, func(i, j int) bool { return bytes.Compare(items[i].encoded, items[j].encoded) < 0 })
+	return &topN{items: items}
+}
+
+func (t *topN) num() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.items)
+}
+
+// lowerBound returns the smallest index whose value is not less than d.
+func (t *topN) lowerBound(d []byte) (idx int, match bool) {
+	if t == nil {
+		return 0, false
+	}
+	idx = sort.Search(len(t.items), func(i int) bool {
+		cmp := bytes.Compare(t.items[i].encoded, d)
+		if cmp == 0 {
+			match = true
+		}
+		return cmp >= 0
+	})
+	return idx, match
+}
+
+func (t *topN) query(d []byte) (uint64, bool) {
+	idx, match := t.lowerBound(d)
+	if !match {
+		return 0, false
+	}
+	return t.items[idx].count, true
+}
+
+// betweenCount returns the count of values in [l, r).
+func (t *topN) betweenCount(l, r []byte) uint64 {
+	if t == nil {
+		return 0
+	}
+	lIdx, _ := t.lowerBound(l)
+	rIdx, _ := t.lowerBound(r)
+	var ret uint64
+	for i := lIdx; i < rIdx; i++ {
+		ret += t.items[i].count
+	}
+	return ret
+}
+
+func (t *topN) totalCount() uint64 {
+	if t == nil {
+		return 0
+	}
+	var total uint64
+	for _, item := range t.items {
+		total += item.count
+	}
+	return total
+}
+
+// queryValue estimates the count of the encoded value by TopN and then CMSketch like the stats version 1.
+func queryValue(c *cmSketch, t *topN, d []byte) uint64 {
+	if cnt, ok := t.query(d); ok {
+		return cnt
+	}
+	return c.queryBytes(d)
+}
@@ -0,0 +1,245 @@
+package statssim
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pingcap/errors"
+)
+
+// Flags of encoded values in TiDB's codec package.
+const (
+	nilFlag          byte = 0
+	bytesFlag        byte = 1
+	compactBytesFlag byte = 2
+	intFlag          byte = 3
+	uintFlag         byte = 4
+	floatFlag        byte = 5
+	varintFlag       byte = 8
+	uvarintFlag      byte = 9
+	maxFlag          byte = 250
+)
+
+const (
+	signMask        uint64 = 0x8000000000000000
+	encGroupSize           = 8
+	encMarker       byte   = 0xFF
+	encPad          byte   = 0x0
+	maxVarintLength        = 10
+)
+
+// encodeKey encodes values in the memory-comparable format like codec.EncodeKey,
+// which is used by index keys, index histograms and TopN of stats version 2.
+func encodeKey(b []byte, vals ...Value) []byte {
+	for _, v := range vals {
+		switch v.Kind {
+		case KindNull:
+			b = append(b, nilFlag)
+		case KindMinNotNull:
+			b = append(b, bytesFlag)
+		case KindMaxValue:
+			b = append(b, maxFlag)
+		case KindInt:
+			b = append(b, intFlag)
+			b = appendUint64(b, uint64(v.I)^signMask)
+		case KindUint:
+			b = append(b, uintFlag)
+			b = appendUint64(b, v.U)
+		case KindFloat:
+			b = append(b, floatFlag)
+			b = appendUint64(b, encodeFloatToCmpUint64(v.F))
+		case KindString:
+			b = append(b, bytesFlag)
+			b = encodeBytes(b, []byte(v.S))
+		}
+	}
+	return b
+}
+
+// encodeValue encodes values in the non-comparable format like codec.EncodeValue,
+// which is used by column CMSketch and TopN of stats version 1.
+func encodeValue(b []byte, vals ...Value) []byte {
+	for _, v := range vals {
+		switch v.Kind {
+		case KindNull:
+			b = append(b, nilFlag)
+		case KindMinNotNull:
+			b = append(b, bytesFlag)
+		case KindMaxValue:
+			b = append(b, maxFlag)
+		case KindInt:
+			b = append(b, varintFlag)
+			b = appendVarint(b, v.I)
+		case KindUint:
+			b = append(b, uvarintFlag)
+			b = appendUvarint(b, v.U)
+		case KindFloat:
+			b = append(b, floatFlag)
+			b = appendUint64(b, encodeFloatToCmpUint64(v.F))
+		case KindString:
+			b = append(b, compactBytesFlag)
+			b = appendVarint(b, int64(len(v.S)))
+			b = append(b, v.S...)
+		}
+	}
+	return b
+}
+
+func appendUint64(b []byte, u uint64) []byte {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], u)
+	return append(b, data[:]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var data [maxVarintLength]byte
+	n := binary.PutVarint(data[:], v)
+	return append(b, data[:n]...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var data [maxVarintLength]byte
+	n := binary.PutUvarint(data[:], v)
+	return append(b, data[:n]...)
+}
+
+func encodeFloatToCmpUint64(f float64) uint64 {
+	u := math.Float64bits(f)
+	if f >= 0 {
+		u |= signMask
+	} else {
+		u = ^u
+	}
+	return u
+}
+
+func decodeCmpUintToFloat(u uint64) float64 {
+	if u&signMask > 0 {
+		u &= ^signMask
+	} else {
+		u = ^u
+	}
+	return math.Float64frombits(u)
+}
+
+// encodeBytes encodes bytes in groups of 8 bytes, each of which is padded and followed by a marker.
+func encodeBytes(b []byte, data []byte) []byte {
+	dLen := len(data)
+	for idx := 0; idx <= dLen; idx += encGroupSize {
+		remain := dLen - idx
+		padCount := 0
+		if remain >= encGroupSize {
+			b = append(b, data[idx:idx+encGroupSize]...)
+		} else {
+			padCount = encGroupSize - remain
+			b = append(b, data[idx:]...)
+			for i := 0; i < padCount; i++ {
+				b = append(b, encPad)
+			}
+		}
+		b = append(b, encMarker-byte(padCount))
+	}
+	return b
+}
+
+func decodeBytes(b []byte) ([]byte, []byte, error) {
+	var data []byte
+	for {
+		if len(b) < encGroupSize+1 {
+			return nil, nil, errors.New("insufficient bytes to decode value")
+		}
+		group, marker := b[:encGroupSize], b[encGroupSize]
+		padCount := encMarker - marker
+		if padCount > encGroupSize {
+			return nil, nil, errors.Errorf("invalid marker byte, group bytes %q", b[:encGroupSize+1])
+		}
+		data = append(data, group[:encGroupSize-int(padCount)]...)
+		b = b[encGroupSize+1:]
+		if padCount != 0 {
+			return b, data, nil
+		}
+	}
+}
+
+// decodeOne decodes a value encoded by encodeKey or encodeValue.
+func decodeOne(b []byte) ([]byte, Value, error) {
+	if len(b) < 1 {
+		return nil, Value{}, errors.New("invalid encoded key")
+	}
+	flag, b := b[0], b[1:]
+	switch flag {
+	case nilFlag:
+		return b, nullValue, nil
+	case intFlag, uintFlag, floatFlag:
+		if len(b) < 8 {
+			return nil, Value{}, errors.New("insufficient bytes to decode value")
+		}
+		u := binary.BigEndian.Uint64(b[:8])
+		switch flag {
+		case intFlag:
+			return b[8:], IntValue(int64(u ^ signMask)), nil
+		case uintFlag:
+			return b[8:], UintValue(u), nil
+		}
+		return b[8:], FloatValue(decodeCmpUintToFloat(u)), nil
+	case varintFlag:
+		v, n := binary.Varint(b)
+		if n <= 0 {
+			return nil, Value{}, errors.New("invalid varint")
+		}
+		return b[n:], IntValue(v), nil
+	case uvarintFlag:
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, Value{}, errors.New("invalid uvarint")
+		}
+		return b[n:], UintValue(v), nil
+	case bytesFlag:
+		if len(b) == 0 {
+			return b, minNotNullValue, nil
+		}
+		b, data, err := decodeBytes(b)
+		return b, StringValue(string(data)), err
+	case compactBytesFlag:
+		l, n := binary.Varint(b)
+		if n <= 0 || int64(len(b)-n) < l {
+			return nil, Value{}, errors.New("invalid compact bytes")
+		}
+		return b[n+int(l):], StringValue(string(b[n : n+int(l)])), nil
+	case maxFlag:
+		return b, maxValue, nil
+	}
+	return nil, Value{}, errors.Errorf("unsupported encoded flag %v", flag)
+}
+
+// decodeAll decodes all values in the bytes.
+func decodeAll(b []byte) ([]Value, error) {
+	var vals []Value
+	for len(b) > 0 {
+		var v Value
+		var err error
+		if b, v, err = decodeOne(b); err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// prefixNext returns the next key in the byte order like kv.Key.PrefixNext.
+func prefixNext(k []byte) []byte {
+	buf := make([]byte, len(k))
+	copy(buf, k)
+	var i int
+	for i = len(k) - 1; i >= 0; i-- {
+		buf[i]++
+		if buf[i] != 0 {
+			break
+		}
+	}
+	if i == -1 {
+		copy(buf, k)
+		buf = append(buf, 0)
+	}
+	return buf
+}
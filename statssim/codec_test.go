@@ -0,0 +1,56 @@
+package statssim
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeBytes(t *testing.T) {
+	got := encodeBytes(nil, []byte("abc"))
+	expected := []byte{0x61, 0x62, 0x63, 0, 0, 0, 0, 0, 0xFA}
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("expected %x, got %x", expected, got)
+	}
+	got = encodeBytes(nil, []byte("12345678"))
+	expected = []byte{0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0, 0xF7}
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("expected %x, got %x", expected, got)
+	}
+}
+
+func TestEncodeAndDecode(t *testing.T) {
+	vals := []Value{IntValue(-3), UintValue(7), FloatValue(-1.5), StringValue("hello, world"), nullValue, StringValue("")}
+	for _, encode := range []func([]byte, ...Value) []byte{encodeKey, encodeValue} {
+		decoded, err := decodeAll(encode(nil, vals...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded) != len(vals) {
+			t.Fatalf("expected %v values, got %v", len(vals), decoded)
+		}
+		for i := range vals {
+			if compare(vals[i], decoded[i]) != 0 {
+				t.Fatalf("expected %v, got %v", vals[i], decoded[i])
+			}
+		}
+	}
+}
+
+func TestEncodeKeyIsComparable(t *testing.T) {
+	ordered := [][]Value{
+		{nullValue},
+		{IntValue(-10)},
+		{IntValue(-1)},
+		{IntValue(0)},
+		{IntValue(5)},
+	}
+	for i := 1; i < len(ordered); i++ {
+		if bytes.Compare(encodeKey(nil, ordered[i-1]...), encodeKey(nil, ordered[i]...)) >= 0 {
+			t.Fatalf("%v should be less than %v", ordered[i-1], ordered[i])
+		}
+	}
+	a, b := encodeKey(nil, StringValue("ab"), IntValue(1)), encodeKey(nil, StringValue("abc"), IntValue(0))
+	if bytes.Compare(a, b) >= 0 {
+		t.Fatalf("(ab, 1) should be less than (abc, 0)")
+	}
+}
@@ -0,0 +1,223 @@
+package statssim
+
+import (
+	"math"
+)
+
+// Column is the stats of a column.
+type Column struct {
+	Name     string
+	Kind     Kind
+	StatsVer int64
+
+	hist histogram
+	cms  *cmSketch
+	topN *topN
+}
+
+// TotalRowCount returns the number of rows in the stats of the column.
+func (c *Column) TotalRowCount() float64 {
+	if c.StatsVer >= 2 {
+		return c.hist.totalRowCount() + float64(c.topN.totalCount())
+	}
+	return c.hist.totalRowCount()
+}
+
+func (c *Column) increaseFactor(realtimeRowCount int64) float64 {
+	total := c.TotalRowCount()
+	if total == 0 {
+		return 1
+	}
+	return float64(realtimeRowCount) / total
+}
+
+func (c *Column) equalRowCount(v Value, realtimeRowCount int64) float64 {
+	if v.isNull() {
+		return float64(c.hist.nullCount)
+	}
+	if c.StatsVer < 2 {
+		if c.hist.len() == 0 {
+			return 0
+		}
+		if c.hist.ndv > 0 && c.hist.outOfRange(v) {
+			return outOfRangeEQSelectivity(c.hist.ndv, realtimeRowCount, int64(c.TotalRowCount())) * c.TotalRowCount()
+		}
+		if c.cms != nil {
+			return float64(queryValue(c.cms, c.topN, encodeValue(nil, v)))
+		}
+		cnt, _ := c.hist.equalRowCount(v, false)
+		return cnt
+	}
+
+	if c.hist.len() == 0 && c.topN.num() == 0 {
+		return 0
+	}
+	if cnt, ok := c.topN.query(encodeKey(nil, v)); ok {
+		return float64(cnt)
+	}
+	if cnt, matched := c.hist.equalRowCount(v, true); matched {
+		return cnt
+	}
+	histNDV := float64(c.hist.ndv - int64(c.topN.num()))
+	if histNDV <= 0 {
+		return 0
+	}
+	return c.hist.notNullCount() / histNDV
+}
+
+// betweenRowCount estimates the count of values in [l, r).
+func (c *Column) betweenRowCount(l, r Value) float64 {
+	cnt := c.hist.betweenRowCount(l, r)
+	if c.StatsVer <= 1 {
+		return cnt
+	}
+	return cnt + float64(c.topN.betweenCount(encodeKey(nil, l), encodeKey(nil, r)))
+}
+
+// maxNumStep is the max number of values in small ranges, which are estimated as points in stats version 1.
+const maxNumStep = 10
+
+// enumRangeValues enumerates values of a small integer range.
+func enumRangeValues(low, high Value, lowExcl, highExcl bool) []Value {
+	if low.Kind != high.Kind {
+		return nil
+	}
+	exclude := int64(0)
+	if lowExcl {
+		exclude++
+	}
+	if highExcl {
+		exclude++
+	}
+	switch low.Kind {
+	case KindInt:
+		lowVal, highVal := low.I, high.I
+		if lowVal <= 0 && highVal >= 0 && (lowVal < -maxNumStep || highVal > maxNumStep) {
+			return nil // avoid overflows
+		}
+		remaining := highVal - lowVal
+		if remaining >= maxNumStep+1 {
+			return nil
+		}
+		remaining = remaining + 1 - exclude
+		if remaining >= maxNumStep || remaining < 0 {
+			return nil
+		}
+		start := lowVal
+		if lowExcl {
+			start++
+		}
+		values := make([]Value, 0, remaining)
+		for i := int64(0); i < remaining; i++ {
+			values = append(values, IntValue(start+i))
+		}
+		return values
+	case KindUint:
+		if high.U-low.U >= maxNumStep+1 {
+			return nil
+		}
+		remaining := int64(high.U-low.U) + 1 - exclude
+		if remaining >= maxNumStep || remaining < 0 {
+			return nil
+		}
+		start := low.U
+		if lowExcl {
+			start++
+		}
+		values := make([]Value, 0, remaining)
+		for i := int64(0); i < remaining; i++ {
+			values = append(values, UintValue(start+uint64(i)))
+		}
+		return values
+	}
+	return nil
+}
+
+// RowCount estimates the number of rows in the ranges like Column.GetColumnRowCount.
+func (c *Column) RowCount(ranges []Range, realtimeRowCount int64) float64 {
+	var rowCount float64
+	factor := c.increaseFactor(realtimeRowCount)
+	for _, rg := range ranges {
+		low, high := rg.Low[0], rg.High[0]
+		if compare(low, high) == 0 {
+			if !rg.LowExclude && !rg.HighExclude {
+				rowCount += c.equalRowCount(low, realtimeRowCount) * factor
+			}
+			continue
+		}
+		// small ranges are estimated as points by CMSketch in stats version 1
+		if c.StatsVer < 2 {
+			if vals := enumRangeValues(low, high, rg.LowExclude, rg.HighExclude); vals != nil {
+				for _, v := range vals {
+					rowCount += c.equalRowCount(v, realtimeRowCount) * factor
+				}
+				continue
+			}
+		}
+
+		cnt := c.betweenRowCount(low, high)
+		if rg.LowExclude && !low.isNull() {
+			cnt -= c.equalRowCount(low, realtimeRowCount)
+		}
+		if !rg.LowExclude && low.isNull() {
+			cnt += float64(c.hist.nullCount)
+		}
+		if !rg.HighExclude {
+			cnt += c.equalRowCount(high, realtimeRowCount)
+		}
+		cnt = math.Max(math.Min(cnt, c.TotalRowCount()), 0)
+		cnt *= factor
+
+		if (c.hist.outOfRange(low) && !low.isNull()) || c.hist.outOfRange(high) {
+			increaseCount := realtimeRowCount - int64(c.TotalRowCount())
+			if increaseCount < 0 {
+				increaseCount = 0
+			}
+			cnt += c.hist.outOfRangeRowCount(low, high, increaseCount)
+		}
+		rowCount += cnt
+	}
+	return math.Max(math.Min(rowCount, float64(realtimeRowCount)), 0)
+}
+
+// invalid tells whether the column has no stats, in which case pseudo estimations are used.
+func (c *Column) invalid() bool {
+	notNull := c.hist.notNullCount()
+	if c.StatsVer >= 2 {
+		notNull += float64(c.topN.totalCount())
+	}
+	return c.TotalRowCount() == 0 || (c.hist.ndv > 0 && notNull == 0)
+}
+
+// Rates of pseudo estimations, which are used for columns without stats.
+const (
+	pseudoEqualRate   = 1000
+	pseudoLessRate    = 3
+	pseudoBetweenRate = 40
+)
+
+// pseudoRowCount estimates the number of rows in the ranges without stats like GetPseudoRowCountByColumnRanges.
+func pseudoRowCount(ranges []Range, tableRowCount float64) float64 {
+	var rowCount float64
+	for _, rg := range ranges {
+		low, high := rg.Low[0], rg.High[0]
+		switch {
+		case low.Kind == KindNull && high.Kind == KindMaxValue:
+			rowCount += tableRowCount
+		case low.Kind == KindMinNotNull:
+			nullCount := tableRowCount / pseudoEqualRate
+			if high.Kind == KindMaxValue {
+				rowCount += tableRowCount - nullCount
+			} else {
+				rowCount += tableRowCount/pseudoLessRate - nullCount
+			}
+		case high.Kind == KindMaxValue:
+			rowCount += tableRowCount / pseudoLessRate
+		case compare(low, high) == 0:
+			rowCount += tableRowCount / pseudoEqualRate
+		default:
+			rowCount += tableRowCount / pseudoBetweenRate
+		}
+	}
+	return math.Min(rowCount, tableRowCount)
+}
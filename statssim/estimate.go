@@ -0,0 +1,409 @@
+package statssim
+
+import (
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/predicate"
+)
+
+// selectionFactor is the selectivity of conditions which cannot be estimated by stats.
+const selectionFactor = 0.8
+
+// maxIndexRanges limits the number of ranges of the cartesian product of points on index columns.
+const maxIndexRanges = 4096
+
+// statsNode is a column or an index with the conditions it covers, like the StatsNode in TiDB.
+type statsNode struct {
+	isIndex     bool
+	name        string
+	mask        int64
+	numCols     int
+	ranges      []Range
+	selectivity float64
+}
+
+// Estimate estimates the number of rows of the table filtered by the condition.
+func (t *Table) Estimate(cond string) (float64, error) {
+	if strings.TrimSpace(cond) == "" {
+		return float64(t.Count), nil
+	}
+	e, err := predicate.Parse(cond)
+	if err != nil {
+		return 0, err
+	}
+	sel, err := t.selectivity(predicate.Conjuncts(e))
+	if err != nil {
+		return 0, err
+	}
+	return sel * float64(t.Count), nil
+}
+
+// EstimateColumn estimates the number of rows by stats of the only column in the condition,
+// like "Column Stats" in optimizer traces.
+func (t *Table) EstimateColumn(cond string) (float64, error) {
+	e, err := predicate.Parse(cond)
+	if err != nil {
+		return 0, err
+	}
+	cols := predicate.Columns(e)
+	if len(cols) != 1 {
+		return 0, errors.Errorf("%v is not a condition on a single column", cond)
+	}
+	for colName := range cols {
+		col, ok := t.Columns[colName]
+		if !ok {
+			return 0, errors.Errorf("no stats of column %v in %v.%v", colName, t.DB, t.Name)
+		}
+		ranges, err := buildRanges(e, col.Kind)
+		if err != nil {
+			return 0, err
+		}
+		return t.columnRowCount(colName, ranges), nil
+	}
+	return 0, nil
+}
+
+// EstimateIndex estimates the number of rows by stats of the index whose prefix are columns in the condition,
+// like "Index Stats" in optimizer traces.
+func (t *Table) EstimateIndex(cond string) (float64, error) {
+	e, err := predicate.Parse(cond)
+	if err != nil {
+		return 0, err
+	}
+	cols := predicate.Columns(e)
+	var idx *Index
+	for _, candidate := range t.usableIndices() {
+		if len(candidate.Columns) < len(cols) {
+			continue
+		}
+		covered := true
+		for _, c := range candidate.Columns[:len(cols)] {
+			covered = covered && cols[c]
+		}
+		if covered && (idx == nil || len(candidate.Columns) < len(idx.Columns)) {
+			idx = candidate
+		}
+	}
+	if idx == nil {
+		return 0, errors.Errorf("no index of %v.%v on columns of %v, set columns of indexes first", t.DB, t.Name, cond)
+	}
+	ranges, err := t.indexRanges(idx, e)
+	if err != nil {
+		return 0, err
+	}
+	return t.indexRowCount(idx, ranges), nil
+}
+
+// indexRanges builds index ranges from the expression, which is a DNF of CNFs on index columns like those in
+// optimizer traces, or a CNF whose conjuncts on prefix columns are points.
+func (t *Table) indexRanges(idx *Index, e predicate.Expr) ([]Range, error) {
+	var ranges []Range
+	dnf, ok := e.(predicate.Or)
+	if !ok {
+		dnf = predicate.Or{e}
+	}
+	for _, item := range dnf {
+		cnf := predicate.Conjuncts(item)
+		perCol := make([][]predicate.Expr, len(idx.Columns))
+		for _, c := range cnf {
+			cols := predicate.Columns(c)
+			if len(cols) != 1 {
+				return nil, errors.Errorf("unsupported condition on multiple columns in %v", e)
+			}
+			found := false
+			for i, colName := range idx.Columns {
+				if cols[colName] {
+					perCol[i], found = append(perCol[i], c), true
+				}
+			}
+			if !found {
+				return nil, errors.Errorf("condition %v is not on columns of index %v", c, idx.Name)
+			}
+		}
+		rs, _, err := t.indexPrefixRanges(idx, perCol, false)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, rs...)
+	}
+	return ranges, nil
+}
+
+// indexPrefixRanges builds ranges of conditions on each column of the index: columns with only point conditions
+// are appended into ranges one by one until a column with range conditions or without conditions.
+// It also returns the number of columns used. If eqOnly is set, columns are points only if their conditions are
+// equal or IN conditions like the ranger, otherwise columns are points if their conditions are points.
+func (t *Table) indexPrefixRanges(idx *Index, perCol [][]predicate.Expr, eqOnly bool) ([]Range, int, error) {
+	ranges := []Range{{}}
+	for i, conds := range perCol {
+		if len(conds) == 0 {
+			break
+		}
+		colRanges, err := buildRanges(predicate.And(conds), t.columnKind(idx.Columns[i]))
+		if err != nil {
+			return nil, 0, err
+		}
+		isPoint := len(ranges)*len(colRanges) <= maxIndexRanges
+		for _, r := range colRanges {
+			isPoint = isPoint && r.isPoint()
+		}
+		if eqOnly {
+			for _, c := range conds {
+				if cmp, ok := c.(predicate.Cmp); !ok || (cmp.Op != predicate.OpEQ && cmp.Op != predicate.OpIn && cmp.Op != predicate.OpIsNull) {
+					isPoint = false
+				}
+			}
+		}
+		var next []Range
+		for _, prefix := range ranges {
+			for _, r := range colRanges {
+				nr := Range{
+					Low:  append(append([]Value{}, prefix.Low...), r.Low...),
+					High: append(append([]Value{}, prefix.High...), r.High...),
+				}
+				if !isPoint {
+					nr.LowExclude, nr.HighExclude = r.LowExclude, r.HighExclude
+				}
+				next = append(next, nr)
+			}
+		}
+		ranges = next
+		if !isPoint {
+			return ranges, i + 1, nil
+		}
+	}
+	if len(ranges[0].Low) == 0 {
+		return nil, 0, nil
+	}
+	return ranges, len(ranges[0].Low), nil
+}
+
+func (t *Table) columnKind(colName string) Kind {
+	if col, ok := t.Columns[colName]; ok {
+		return col.Kind
+	}
+	return KindString
+}
+
+// singleColumn returns the column of the conjunct if it only references one column with stats.
+func (t *Table) singleColumn(e predicate.Expr) (string, bool) {
+	cols := predicate.Columns(e)
+	if len(cols) != 1 {
+		return "", false
+	}
+	for c := range cols {
+		if _, ok := t.Columns[c]; ok {
+			if _, err := buildRanges(e, t.Columns[c].Kind); err == nil {
+				return c, true
+			}
+		}
+	}
+	return "", false
+}
+
+// selectivity estimates the selectivity of the CNF like HistColl.Selectivity: columns and indexes covering most
+// conditions are chosen greedily and their selectivities are multiplied, uncovered DNF conditions are estimated
+// by the independence assumption, and other conditions are estimated by the selection factor.
+func (t *Table) selectivity(exprs []predicate.Expr) (float64, error) {
+	if t.Count == 0 || len(exprs) == 0 {
+		return 1, nil
+	}
+	if len(exprs) > 63 {
+		return 0, errors.Errorf("too many conditions %v", len(exprs))
+	}
+	ret := 1.0
+	colConds := make(map[string][]int) // column => positions of its conjuncts
+	for i, e := range exprs {
+		if c, ok := t.singleColumn(e); ok {
+			colConds[c] = append(colConds[c], i)
+		}
+	}
+
+	var nodes []*statsNode
+	colNames := make([]string, 0, len(colConds))
+	for c := range colConds {
+		colNames = append(colNames, c)
+	}
+	sort.Strings(colNames)
+	for _, c := range colNames {
+		node := &statsNode{name: c, numCols: 1}
+		conds := make(predicate.And, 0, len(colConds[c]))
+		for _, i := range colConds[c] {
+			node.mask |= 1 << uint(i)
+			conds = append(conds, exprs[i])
+		}
+		ranges, err := buildRanges(conds, t.Columns[c].Kind)
+		if err != nil {
+			return 0, err
+		}
+		node.ranges = ranges
+		node.selectivity = t.columnRowCount(c, ranges) / float64(t.Count)
+		nodes = append(nodes, node)
+	}
+	for _, idx := range t.usableIndices() {
+		perCol := make([][]predicate.Expr, len(idx.Columns))
+		masks := make([]int64, len(idx.Columns))
+		for i, c := range idx.Columns {
+			for _, pos := range colConds[c] {
+				perCol[i] = append(perCol[i], exprs[pos])
+				masks[i] |= 1 << uint(pos)
+			}
+		}
+		ranges, usedCols, err := t.indexPrefixRanges(idx, perCol, true)
+		if err != nil {
+			return 0, err
+		}
+		if usedCols == 0 {
+			continue
+		}
+		node := &statsNode{isIndex: true, name: idx.Name, numCols: len(idx.Columns), ranges: ranges}
+		for _, m := range masks[:usedCols] {
+			node.mask |= m
+		}
+		node.selectivity = t.indexRowCount(idx, ranges) / float64(t.Count)
+		nodes = append(nodes, node)
+	}
+
+	mask := int64(1)<<uint(len(exprs)) - 1
+	for _, node := range usableSetsByGreedy(nodes) {
+		mask &^= node.mask
+		ret *= node.selectivity
+	}
+
+	// estimate uncovered DNF conditions by sel(A or B) = sel(A) + sel(B) - sel(A)*sel(B)
+	for i, e := range exprs {
+		dnf, ok := e.(predicate.Or)
+		if mask&(1<<uint(i)) == 0 || !ok {
+			continue
+		}
+		allWithStats := true
+		for c := range predicate.Columns(dnf) {
+			_, ok := t.Columns[c]
+			allWithStats = allWithStats && ok
+		}
+		if !allWithStats {
+			continue
+		}
+		items := t.mergeDNFItems(dnf)
+		if len(items) <= 1 {
+			continue
+		}
+		selectivity := 0.0
+		for _, item := range items {
+			cur, err := t.selectivity(predicate.Conjuncts(item))
+			if err != nil {
+				cur = selectionFactor
+			}
+			selectivity = selectivity + cur - selectivity*cur
+		}
+		if selectivity != 0 {
+			ret *= selectivity
+			mask &^= 1 << uint(i)
+		}
+	}
+
+	if mask > 0 {
+		ret *= selectionFactor
+	}
+	return ret, nil
+}
+
+// mergeDNFItems merges items of the DNF on the same single column into one like ranger.MergeDNFItems4Col.
+func (t *Table) mergeDNFItems(dnf predicate.Or) []predicate.Expr {
+	var items []predicate.Expr
+	flattened := predicate.Or{}
+	var flatten func(e predicate.Expr)
+	flatten = func(e predicate.Expr) {
+		if or, ok := e.(predicate.Or); ok {
+			for _, item := range or {
+				flatten(item)
+			}
+			return
+		}
+		flattened = append(flattened, e)
+	}
+	flatten(dnf)
+
+	colItems := make(map[string]int) // column => position in items
+	for _, item := range flattened {
+		c, ok := t.singleColumn(item)
+		if !ok {
+			items = append(items, item)
+			continue
+		}
+		if pos, ok := colItems[c]; ok {
+			if or, ok := items[pos].(predicate.Or); ok {
+				items[pos] = append(or, item)
+			} else {
+				items[pos] = predicate.Or{items[pos], item}
+			}
+			continue
+		}
+		colItems[c] = len(items)
+		items = append(items, item)
+	}
+	return items
+}
+
+// usableSetsByGreedy chooses nodes like GetUsableSetsByGreedy: indexes are preferred to columns, and then nodes
+// covering more conditions, with fewer columns and with lower selectivities are preferred in order.
+func usableSetsByGreedy(nodes []*statsNode) (chosen []*statsNode) {
+	marked := make([]bool, len(nodes))
+	mask := int64(^uint64(0) >> 1)
+	for {
+		bestID, bestCount, bestIsIndex, bestNumCols, bestMask, bestSel := -1, 0, false, 0, int64(0), float64(0)
+		for i, node := range nodes {
+			if marked[i] {
+				continue
+			}
+			curMask := node.mask & mask
+			if curMask != node.mask {
+				marked[i] = true
+				continue
+			}
+			cnt := bits.OnesCount64(uint64(curMask))
+			if cnt == 0 {
+				marked[i] = true
+				continue
+			}
+			if (!bestIsIndex && node.isIndex) || bestCount < cnt ||
+				(bestCount == cnt && bestNumCols > node.numCols) ||
+				(bestCount == cnt && bestNumCols == node.numCols && bestSel > node.selectivity) {
+				bestID, bestCount, bestIsIndex, bestNumCols, bestMask, bestSel = i, cnt, node.isIndex, node.numCols, curMask, node.selectivity
+			}
+		}
+		if bestCount == 0 {
+			break
+		}
+		mask &^= bestMask
+		chosen = append(chosen, nodes[bestID])
+		marked[bestID] = true
+	}
+	return
+}
+
+// splitSQL splits a query like "SELECT * FROM db.t WHERE cond" into the table and the condition.
+func splitSQL(sql string) (db, table, cond string, err error) {
+	lower := strings.ToLower(sql)
+	from := strings.Index(lower, " from ")
+	if from < 0 {
+		return "", "", "", errors.Errorf("no FROM clause in %v", sql)
+	}
+	rest := strings.TrimSpace(sql[from+len(" from "):])
+	where := strings.Index(strings.ToLower(rest), " where ")
+	name := rest
+	if where >= 0 {
+		name, cond = strings.TrimSpace(rest[:where]), strings.TrimSpace(rest[where+len(" where "):])
+	}
+	if strings.ContainsAny(name, " ,") {
+		return "", "", "", errors.Errorf("only queries on a single table are supported, got %v", sql)
+	}
+	name = strings.ReplaceAll(strings.ReplaceAll(name, "`", ""), `"`, "")
+	if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+		db, name = name[:dot], name[dot+1:]
+	}
+	return strings.ToLower(db), strings.ToLower(name), strings.TrimSuffix(cond, ";"), nil
+}
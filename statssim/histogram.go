@@ -0,0 +1,197 @@
+package statssim
+
+import (
+	"math"
+	"sort"
+)
+
+type bucket struct {
+	lower, upper Value
+	count        int64 // the cumulative count
+	repeat       int64
+	ndv          int64
+}
+
+// histogram is an equal-depth histogram, whose bounds of indexes are encoded keys.
+type histogram struct {
+	ndv       int64
+	nullCount int64
+	buckets   []bucket
+}
+
+func (h *histogram) len() int { return len(h.buckets) }
+
+// bound returns the i-th bound like Histogram.Bounds, which stores lower and upper bounds of buckets in turn.
+func (h *histogram) bound(i int) Value {
+	if i%2 == 0 {
+		return h.buckets[i/2].lower
+	}
+	return h.buckets[i/2].upper
+}
+
+func (h *histogram) lowerBound(v Value) (index int, match bool) {
+	index = sort.Search(2*h.len(), func(i int) bool {
+		cmp := compare(h.bound(i), v)
+		if cmp == 0 {
+			match = true
+		}
+		return cmp >= 0
+	})
+	return
+}
+
+func (h *histogram) bucketCount(idx int) int64 {
+	if idx == 0 {
+		return h.buckets[0].count
+	}
+	return h.buckets[idx].count - h.buckets[idx-1].count
+}
+
+func (h *histogram) notNullCount() float64 {
+	if h.len() == 0 {
+		return 0
+	}
+	return float64(h.buckets[h.len()-1].count)
+}
+
+func (h *histogram) totalRowCount() float64 {
+	return h.notNullCount() + float64(h.nullCount)
+}
+
+// equalRowCount estimates the count of the value, matched tells whether it's estimated by repeats or bucket NDV.
+func (h *histogram) equalRowCount(v Value, hasBucketNDV bool) (count float64, matched bool) {
+	index, match := h.lowerBound(v)
+	if index%2 == 1 {
+		if match {
+			return float64(h.buckets[index/2].repeat), true
+		}
+		if hasBucketNDV && h.buckets[index/2].ndv > 1 {
+			return float64(h.bucketCount(index/2)-h.buckets[index/2].repeat) / float64(h.buckets[index/2].ndv-1), true
+		}
+		return h.notNullCount() / float64(h.ndv), false
+	}
+	if match {
+		b := h.buckets[index/2]
+		if compare(b.lower, b.upper) == 0 {
+			return float64(b.repeat), true
+		}
+		if hasBucketNDV && b.ndv > 1 {
+			return float64(h.bucketCount(index/2)-b.repeat) / float64(b.ndv-1), true
+		}
+		return h.notNullCount() / float64(h.ndv), false
+	}
+	return 0, false
+}
+
+// lessRowCount estimates the count of values less than v.
+func (h *histogram) lessRowCount(v Value) float64 {
+	if h.len() == 0 {
+		return 0
+	}
+	index, match := h.lowerBound(v)
+	if index == 2*h.len() {
+		return h.notNullCount()
+	}
+	bucketIdx := index / 2
+	curCount, curRepeat := float64(h.buckets[bucketIdx].count), float64(h.buckets[bucketIdx].repeat)
+	preCount := float64(0)
+	if bucketIdx > 0 {
+		preCount = float64(h.buckets[bucketIdx-1].count)
+	}
+	if index%2 == 1 {
+		if match {
+			return curCount - curRepeat
+		}
+		return preCount + h.calcFraction(bucketIdx, v)*(curCount-curRepeat-preCount)
+	}
+	return preCount
+}
+
+// betweenRowCount estimates the count of values in [a, b).
+func (h *histogram) betweenRowCount(a, b Value) float64 {
+	lessCountA := h.lessRowCount(a)
+	lessCountB := h.lessRowCount(b)
+	// they may fall into the same bucket, then the count is estimated by NDV
+	if lessCountA >= lessCountB && h.ndv > 0 {
+		result := math.Min(lessCountB, h.notNullCount()-lessCountA)
+		return math.Min(result, h.notNullCount()/float64(h.ndv))
+	}
+	return lessCountB - lessCountA
+}
+
+func (h *histogram) calcFraction(bucketIdx int, v Value) float64 {
+	lower, upper := h.buckets[bucketIdx].lower, h.buckets[bucketIdx].upper
+	switch v.Kind {
+	case KindInt, KindUint, KindFloat:
+		return calcFraction(lower.float(), upper.float(), v.float())
+	case KindString:
+		common := commonPrefixLength(lower.S, upper.S)
+		return calcFraction(toScalar(lower, common), toScalar(upper, common), toScalar(v, common))
+	}
+	return 0.5
+}
+
+func (h *histogram) outOfRange(v Value) bool {
+	if h.len() == 0 {
+		return false
+	}
+	return compare(h.buckets[0].lower, v) > 0 || compare(h.buckets[h.len()-1].upper, v) < 0
+}
+
+// outOfRangeRowCount estimates the count of the part of [l, r] out of the histogram, assuming that the density
+// decreases linearly from the bounds of the histogram to the bounds as far as the width of the histogram.
+func (h *histogram) outOfRangeRowCount(lVal, rVal Value, increaseCount int64) float64 {
+	if h.len() == 0 {
+		return 0
+	}
+	histLower, histUpper := h.buckets[0].lower, h.buckets[h.len()-1].upper
+	commonPrefix := 0
+	if histLower.Kind == KindString {
+		commonPrefix = commonPrefixLength(histLower.S, histUpper.S, lVal.S, rVal.S)
+	}
+	l, r := toScalar(lVal, commonPrefix), toScalar(rVal, commonPrefix)
+	if histLower.Kind == KindUint {
+		l, r = math.Max(l, 0), math.Max(r, 0)
+	}
+	if l >= r {
+		return 0
+	}
+	histL, histR := toScalar(histLower, commonPrefix), toScalar(histUpper, commonPrefix)
+	histWidth := histR - histL
+	if histWidth <= 0 {
+		return 0
+	}
+	boundL, boundR := histL-histWidth, histR+histWidth
+
+	leftPercent, rightPercent := float64(0), float64(0)
+	if actualL, actualR := l, r; actualL < histL && actualR > boundL {
+		actualL, actualR = math.Max(actualL, boundL), math.Min(actualR, histL)
+		leftPercent = (math.Pow(actualR-boundL, 2) - math.Pow(actualL-boundL, 2)) / math.Pow(histWidth, 2)
+	}
+	if actualL, actualR := l, r; actualL < boundR && actualR > histR {
+		actualL, actualR = math.Max(actualL, histR), math.Min(actualR, boundR)
+		rightPercent = (math.Pow(boundR-actualL, 2) - math.Pow(boundR-actualR, 2)) / math.Pow(histWidth, 2)
+	}
+
+	totalPercent := math.Min(leftPercent*0.5+rightPercent*0.5, 1)
+	return math.Min(totalPercent*h.notNullCount(), float64(increaseCount))
+}
+
+// outOfRangeEQSelectivity estimates the selectivity of values out of the histogram by the newly inserted rows.
+func outOfRangeEQSelectivity(ndv, realtimeRowCount, columnRowCount int64) float64 {
+	increaseRowCount := realtimeRowCount - columnRowCount
+	if increaseRowCount <= 0 {
+		return 0 // the histogram contains all the data
+	}
+	if ndv < outOfRangeBetweenRate {
+		ndv = outOfRangeBetweenRate
+	}
+	selectivity := 1 / float64(ndv)
+	if selectivity*float64(columnRowCount) > float64(increaseRowCount) {
+		selectivity = float64(increaseRowCount) / float64(columnRowCount)
+	}
+	return selectivity
+}
+
+// outOfRangeBetweenRate is the minimal NDV used for values out of the histogram.
+const outOfRangeBetweenRate = 100
@@ -0,0 +1,281 @@
+package statssim
+
+import (
+	"bytes"
+	"math"
+	"sort"
+)
+
+// Index is the stats of an index, whose columns are not in the stats dump and must be set by the user.
+type Index struct {
+	Name     string
+	Columns  []string
+	Unique   bool
+	StatsVer int64
+
+	hist histogram
+	cms  *cmSketch
+	topN *topN
+}
+
+var nullKeyBytes = encodeKey(nil, nullValue)
+
+// TotalRowCount returns the number of rows in the stats of the index.
+func (idx *Index) TotalRowCount() float64 {
+	if idx.StatsVer >= 2 {
+		return idx.hist.totalRowCount() + float64(idx.topN.totalCount())
+	}
+	return idx.hist.totalRowCount()
+}
+
+func (idx *Index) increaseFactor(realtimeRowCount int64) float64 {
+	total := idx.TotalRowCount()
+	if total == 0 {
+		return 1
+	}
+	return float64(realtimeRowCount) / total
+}
+
+// outOfRange is like histogram.outOfRange, but keys prefixing the lower bound are in range.
+func (idx *Index) outOfRange(key []byte) bool {
+	if !idx.hist.outOfRange(StringValue(string(key))) {
+		return false
+	}
+	return !(idx.hist.len() > 0 && bytes.HasPrefix([]byte(idx.hist.buckets[0].lower.S), key))
+}
+
+func (idx *Index) equalRowCount(key []byte, realtimeRowCount int64) float64 {
+	if len(idx.Columns) == 1 && bytes.Equal(key, nullKeyBytes) {
+		return float64(idx.hist.nullCount)
+	}
+	val := StringValue(string(key))
+	if idx.StatsVer < 2 {
+		if idx.hist.ndv > 0 && idx.outOfRange(key) {
+			return outOfRangeEQSelectivity(idx.hist.ndv, realtimeRowCount, int64(idx.TotalRowCount())) * idx.TotalRowCount()
+		}
+		if idx.cms != nil {
+			return float64(queryValue(idx.cms, idx.topN, key))
+		}
+		cnt, _ := idx.hist.equalRowCount(val, false)
+		return cnt
+	}
+	if cnt, ok := idx.topN.query(key); ok {
+		return float64(cnt)
+	}
+	if cnt, matched := idx.hist.equalRowCount(val, true); matched {
+		return cnt
+	}
+	histNDV := float64(idx.hist.ndv - int64(idx.topN.num()))
+	if histNDV <= 0 {
+		return 0
+	}
+	return idx.hist.notNullCount() / histNDV
+}
+
+// betweenRowCount estimates the count of keys in [l, r).
+func (idx *Index) betweenRowCount(l, r []byte) float64 {
+	cnt := idx.hist.betweenRowCount(StringValue(string(l)), StringValue(string(r)))
+	if idx.StatsVer == 1 {
+		return cnt
+	}
+	return cnt + float64(idx.topN.betweenCount(l, r))
+}
+
+// ordinalOfRangeCond returns the position of the first column which is not a point in the range.
+func ordinalOfRangeCond(rg Range) int {
+	for i := range rg.Low {
+		if compare(rg.Low[i], rg.High[i]) != 0 {
+			return i
+		}
+	}
+	return len(rg.Low)
+}
+
+// indexRowCount estimates the number of rows in the index ranges like HistColl.GetRowCountByIndexRanges.
+func (t *Table) indexRowCount(idx *Index, ranges []Range) float64 {
+	if idx.cms != nil && idx.StatsVer == 1 {
+		return t.indexRowCountV1(idx, ranges)
+	}
+	return t.indexRowCountByHist(idx, ranges, true)
+}
+
+// indexRowCountByHist is Index.GetRowCount, which estimates by TopN and histograms of the index,
+// and by the exponential backoff of columns if the first column is a point and expBackoff is true.
+func (t *Table) indexRowCountByHist(idx *Index, ranges []Range, expBackoff bool) float64 {
+	totalCount := float64(0)
+	isSingleCol := len(idx.Columns) == 1
+	for _, rg := range ranges {
+		lb, rb := encodeKey(nil, rg.Low...), encodeKey(nil, rg.High...)
+		fullLen := len(rg.Low) == len(rg.High) && len(rg.Low) == len(idx.Columns)
+		if bytes.Equal(lb, rb) {
+			if rg.LowExclude || rg.HighExclude {
+				continue
+			}
+			if fullLen {
+				if idx.Unique {
+					totalCount++
+					continue
+				}
+				totalCount += idx.equalRowCount(lb, t.Count) * idx.increaseFactor(t.Count)
+				continue
+			}
+		}
+
+		// the interval is [lb, rb)
+		if rg.LowExclude {
+			lb = prefixNext(lb)
+		}
+		if !rg.HighExclude {
+			rb = prefixNext(rb)
+		}
+		lowIsNull := bytes.Equal(lb, nullKeyBytes)
+		if isSingleCol && lowIsNull {
+			totalCount += float64(idx.hist.nullCount)
+		}
+		expBackoffSuccess := false
+		if ordinalOfRangeCond(rg) > 0 && idx.StatsVer >= 2 && expBackoff {
+			var sel float64
+			if sel, expBackoffSuccess = t.expBackoffSelectivity(idx, rg); expBackoffSuccess {
+				totalCount += sel * idx.TotalRowCount()
+			}
+		}
+		if !expBackoffSuccess {
+			totalCount += idx.betweenRowCount(lb, rb)
+		}
+		// the increase factor is applied to the total count like TiDB
+		totalCount *= idx.increaseFactor(t.Count)
+
+		if (idx.outOfRange(lb) && !(isSingleCol && lowIsNull)) || idx.outOfRange(rb) {
+			increaseCount := t.Count - int64(idx.TotalRowCount())
+			if increaseCount < 0 {
+				increaseCount = 0
+			}
+			totalCount += idx.hist.outOfRangeRowCount(StringValue(string(lb)), StringValue(string(rb)), increaseCount)
+		}
+	}
+	return math.Min(totalCount, float64(t.Count))
+}
+
+// expBackoffSelectivity combines selectivities of columns of the range, the most selective one s1 and others
+// as s1 * s2^(1/2) * s3^(1/4) * s4^(1/8) to reduce the impact of the independence assumption.
+func (t *Table) expBackoffSelectivity(idx *Index, rg Range) (float64, bool) {
+	counts := make([]float64, 0, len(rg.Low))
+	for i := range rg.Low {
+		colRange := Range{Low: rg.Low[i : i+1], High: rg.High[i : i+1]}
+		if i == len(rg.Low)-1 {
+			colRange.LowExclude, colRange.HighExclude = rg.LowExclude, rg.HighExclude
+		}
+		colName := idx.Columns[i]
+		if another := t.firstColumnIndex(colName); another != nil && another != idx {
+			counts = append(counts, t.indexRowCount(another, []Range{colRange}))
+		} else if col := t.Columns[colName]; col != nil && !col.invalid() {
+			counts = append(counts, col.RowCount([]Range{colRange}, t.Count))
+		}
+	}
+	sort.Float64s(counts)
+	for i := 0; i < len(counts) && i < 4; i++ {
+		counts[i] /= float64(t.Count)
+	}
+	switch len(counts) {
+	case 0:
+		return 0, false
+	case 1:
+		return counts[0], true
+	case 2:
+		return counts[0] * math.Sqrt(counts[1]), true
+	case 3:
+		return counts[0] * math.Sqrt(counts[1]) * math.Sqrt(math.Sqrt(counts[2])), true
+	}
+	return counts[0] * math.Sqrt(counts[1]) * math.Sqrt(math.Sqrt(counts[2])) * math.Sqrt(math.Sqrt(math.Sqrt(counts[3]))), true
+}
+
+// indexRowCountV1 is HistColl.getIndexRowCount of stats version 1, which estimates points on prefix columns by
+// CMSketch validated by columns, and the range on the next column by its own stats.
+func (t *Table) indexRowCountV1(idx *Index, ranges []Range) float64 {
+	totalCount := float64(0)
+	for _, rg := range ranges {
+		rangePosition := ordinalOfRangeCond(rg)
+		var rangeVals []Value
+		if rangePosition != len(rg.Low) {
+			rangeVals = enumRangeValues(rg.Low[rangePosition], rg.High[rangePosition], rg.LowExclude, rg.HighExclude)
+			if rangeVals != nil {
+				rangePosition++
+			}
+		}
+		// CMSketch doesn't contain NULL of single column indexes
+		if rangePosition == 0 || (len(idx.Columns) == 1 && rg.Low[0].isNull() && rg.High[0].isNull()) {
+			totalCount += t.indexRowCountByHist(idx, []Range{rg}, false)
+			continue
+		}
+		var selectivity float64
+		if rangeVals == nil {
+			selectivity = t.equalCondSelectivity(idx, encodeKey(nil, rg.Low[:rangePosition]...), rangePosition, rg)
+		} else {
+			prefix := encodeKey(nil, rg.Low[:rangePosition-1]...)
+			for _, v := range rangeVals {
+				key := encodeKey(append([]byte{}, prefix...), v)
+				selectivity += t.equalCondSelectivity(idx, key, rangePosition, rg)
+			}
+		}
+		if rangePosition != len(rg.Low) {
+			colRange := Range{
+				Low: rg.Low[rangePosition : rangePosition+1], High: rg.High[rangePosition : rangePosition+1],
+				LowExclude: rg.LowExclude, HighExclude: rg.HighExclude,
+			}
+			var count float64
+			if rangePosition < len(idx.Columns) {
+				colName := idx.Columns[rangePosition]
+				if another := t.firstColumnIndex(colName); another != nil {
+					count = t.indexRowCount(another, []Range{colRange})
+				} else {
+					count = t.columnRowCount(colName, []Range{colRange})
+				}
+			}
+			selectivity = selectivity * count / idx.TotalRowCount()
+		}
+		totalCount += selectivity * idx.TotalRowCount()
+	}
+	return math.Min(totalCount, idx.TotalRowCount())
+}
+
+// equalCondSelectivity estimates the selectivity of points on the first usedCols columns of the index.
+func (t *Table) equalCondSelectivity(idx *Index, key []byte, usedCols int, pointRange Range) float64 {
+	coverAll := len(idx.Columns) == usedCols
+	if idx.Unique && coverAll {
+		return 1 / idx.TotalRowCount()
+	}
+	if idx.outOfRange(key) {
+		if idx.hist.ndv > 0 && coverAll {
+			return outOfRangeEQSelectivity(idx.hist.ndv, t.Count, int64(idx.TotalRowCount()))
+		}
+		var ndv int64
+		for _, colName := range idx.Columns[:usedCols] {
+			if col := t.Columns[colName]; col != nil && col.hist.ndv > ndv {
+				ndv = col.hist.ndv
+			}
+		}
+		return outOfRangeEQSelectivity(ndv, t.Count, int64(idx.TotalRowCount()))
+	}
+
+	// cross validate the count of the index by counts of its columns
+	minRowCount, crossValidationSel := math.MaxFloat64, 1.0
+	for i, colName := range idx.Columns[:usedCols] {
+		col := t.Columns[colName]
+		if col == nil || col.invalid() {
+			continue
+		}
+		lowExcl, highExcl := pointRange.LowExclude, pointRange.HighExclude
+		if lowExcl != highExcl {
+			lowExcl, highExcl = false, false
+		}
+		rowCount := col.RowCount([]Range{{Low: pointRange.Low[i : i+1], High: pointRange.High[i : i+1],
+			LowExclude: lowExcl, HighExclude: highExcl}}, t.Count)
+		crossValidationSel *= rowCount / idx.TotalRowCount()
+		minRowCount = math.Min(minRowCount, rowCount)
+	}
+	idxCount := float64(queryValue(idx.cms, idx.topN, key))
+	if minRowCount < idxCount {
+		return crossValidationSel
+	}
+	return idxCount / idx.TotalRowCount()
+}
@@ -0,0 +1,70 @@
+package statssim
+
+import (
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/predicate"
+)
+
+func TestSplitSQL(t *testing.T) {
+	cases := []struct {
+		sql, db, table, cond string
+	}{
+		{"SELECT * FROM imdb.`title` WHERE a=1 AND b='x'", "imdb", "title", "a=1 AND b='x'"},
+		{`SELECT * FROM imdb."title" WHERE a IS NULL`, "imdb", "title", "a IS NULL"},
+		{"select count(*) from t", "", "t", ""},
+	}
+	for _, c := range cases {
+		db, table, cond, err := splitSQL(c.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if db != c.db || table != c.table || cond != c.cond {
+			t.Fatalf("%v: expected (%v, %v, %v), got (%v, %v, %v)", c.sql, c.db, c.table, c.cond, db, table, cond)
+		}
+	}
+}
+
+func TestBuildRanges(t *testing.T) {
+	cases := []struct {
+		cond   string
+		kind   Kind
+		ranges string
+	}{
+		{"a>=1 AND a<=5", KindInt, "[[1,5]]"},
+		{"`a` = 3 OR a IN (1, 2, 3)", KindInt, "[[1,1] [2,2] [3,3]]"},
+		{"a < 1.5", KindInt, "[[-inf,1]]"},
+		{"a = 1.5", KindInt, "[]"},
+		{"a IS NULL OR a > 'b~'", KindString, "[[NULL,NULL] (\"b~\",+inf]]"},
+		{"a BETWEEN 1 AND 3 AND a != 2", KindFloat, "[[1,2) (2,3]]"},
+		{"a IS NOT NULL AND a = NULL", KindInt, "[]"},
+		{"ge(test.t.a, 10)", KindInt, "[[10,+inf]]"},
+		{"or(lt(test.t.a, -1), eq(test.t.a, 7))", KindInt, "[[-inf,-1) [7,7]]"},
+		{"not(isnull(test.t.a))", KindInt, "[[-inf,+inf]]"},
+		{"gt(10, test.t.a)", KindInt, "[[-inf,10)]"},
+	}
+	for _, c := range cases {
+		e, err := predicate.Parse(c.cond)
+		if err != nil {
+			t.Fatalf("%v: %v", c.cond, err)
+		}
+		ranges, err := buildRanges(e, c.kind)
+		if err != nil {
+			t.Fatalf("%v: %v", c.cond, err)
+		}
+		if got := rangesString(ranges); got != c.ranges {
+			t.Fatalf("%v: expected %v, got %v", c.cond, c.ranges, got)
+		}
+	}
+}
+
+func rangesString(ranges []Range) string {
+	s := "["
+	for i, r := range ranges {
+		if i > 0 {
+			s += " "
+		}
+		s += r.String()
+	}
+	return s + "]"
+}
@@ -0,0 +1,264 @@
+package statssim
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/predicate"
+)
+
+// Range is a range of values of columns like ranger.Range.
+type Range struct {
+	Low, High               []Value
+	LowExclude, HighExclude bool
+}
+
+func (r Range) String() string {
+	l, h := "[", "]"
+	if r.LowExclude {
+		l = "("
+	}
+	if r.HighExclude {
+		h = ")"
+	}
+	return l + valuesString(r.Low) + "," + valuesString(r.High) + h
+}
+
+func valuesString(vals []Value) string {
+	s := ""
+	for i, v := range vals {
+		if i > 0 {
+			s += " "
+		}
+		s += v.String()
+	}
+	return s
+}
+
+func pointRange(v Value) Range {
+	return Range{Low: []Value{v}, High: []Value{v}}
+}
+
+func (r Range) isPoint() bool {
+	return !r.LowExclude && !r.HighExclude && compare(r.Low[0], r.High[0]) == 0
+}
+
+func (r Range) isEmpty() bool {
+	cmp := compare(r.Low[0], r.High[0])
+	return cmp > 0 || (cmp == 0 && (r.LowExclude || r.HighExclude))
+}
+
+// lowLess tells whether the low bound of a is less than the one of b.
+func lowLess(a, b Range) bool {
+	cmp := compare(a.Low[0], b.Low[0])
+	return cmp < 0 || (cmp == 0 && !a.LowExclude && b.LowExclude)
+}
+
+// unionRanges merges overlapped single column ranges and sorts them.
+func unionRanges(ranges []Range) []Range {
+	var rs []Range
+	for _, r := range ranges {
+		if !r.isEmpty() {
+			rs = append(rs, r)
+		}
+	}
+	if len(rs) <= 1 {
+		return rs
+	}
+	sort.Slice(rs, func(i, j int) bool { return lowLess(rs[i], rs[j]) })
+	merged := []Range{rs[0]}
+	for _, r := range rs[1:] {
+		cur := &merged[len(merged)-1]
+		cmp := compare(r.Low[0], cur.High[0])
+		if cmp > 0 || (cmp == 0 && r.LowExclude && cur.HighExclude) {
+			merged = append(merged, r)
+			continue
+		}
+		if hc := compare(r.High[0], cur.High[0]); hc > 0 {
+			cur.High, cur.HighExclude = r.High, r.HighExclude
+		} else if hc == 0 {
+			cur.HighExclude = cur.HighExclude && r.HighExclude
+		}
+	}
+	return merged
+}
+
+// intersectRanges intersects two sets of single column ranges.
+func intersectRanges(as, bs []Range) []Range {
+	var rs []Range
+	for _, a := range as {
+		for _, b := range bs {
+			r := a
+			if lc := compare(b.Low[0], r.Low[0]); lc > 0 {
+				r.Low, r.LowExclude = b.Low, b.LowExclude
+			} else if lc == 0 {
+				r.LowExclude = r.LowExclude || b.LowExclude
+			}
+			if hc := compare(b.High[0], r.High[0]); hc < 0 {
+				r.High, r.HighExclude = b.High, b.HighExclude
+			} else if hc == 0 {
+				r.HighExclude = r.HighExclude || b.HighExclude
+			}
+			rs = append(rs, r)
+		}
+	}
+	return unionRanges(rs)
+}
+
+func fullNotNullRange() Range {
+	return Range{Low: []Value{minNotNullValue}, High: []Value{maxValue}}
+}
+
+// buildRanges builds ranges of the column from the expression, which must only reference the column.
+func buildRanges(e predicate.Expr, kind Kind) ([]Range, error) {
+	switch x := e.(type) {
+	case predicate.And:
+		ranges := []Range{{Low: []Value{nullValue}, High: []Value{maxValue}}}
+		for _, item := range x {
+			rs, err := buildRanges(item, kind)
+			if err != nil {
+				return nil, err
+			}
+			ranges = intersectRanges(ranges, rs)
+		}
+		return ranges, nil
+	case predicate.Or:
+		var ranges []Range
+		for _, item := range x {
+			rs, err := buildRanges(item, kind)
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, rs...)
+		}
+		return unionRanges(ranges), nil
+	case predicate.Cmp:
+		return cmpRanges(x, kind)
+	}
+	return nil, errors.Errorf("unsupported expression %v", e)
+}
+
+func cmpRanges(c predicate.Cmp, kind Kind) ([]Range, error) {
+	switch c.Op {
+	case predicate.OpIsNull:
+		return []Range{pointRange(nullValue)}, nil
+	case predicate.OpNotNull:
+		return []Range{fullNotNullRange()}, nil
+	case predicate.OpIn:
+		var ranges []Range
+		for _, l := range c.Vals {
+			rs, err := compareRanges(predicate.OpEQ, l, kind)
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, rs...)
+		}
+		return unionRanges(ranges), nil
+	case predicate.OpBetween:
+		low, err := compareRanges(predicate.OpGE, c.Vals[0], kind)
+		if err != nil {
+			return nil, err
+		}
+		high, err := compareRanges(predicate.OpLE, c.Vals[1], kind)
+		if err != nil {
+			return nil, err
+		}
+		return intersectRanges(low, high), nil
+	}
+	return compareRanges(c.Op, c.Vals[0], kind)
+}
+
+// compareRanges builds ranges of comparing the column with the literal, which is converted to the kind of the column.
+func compareRanges(op string, l predicate.Literal, kind Kind) ([]Range, error) {
+	if l.Null {
+		return nil, nil // comparisons with NULL are never true
+	}
+	var v Value
+	switch kind {
+	case KindInt, KindUint:
+		if i, err := strconv.ParseInt(l.Text, 10, 64); err == nil && (kind == KindInt || i >= 0) {
+			if kind == KindUint {
+				v = UintValue(uint64(i))
+			} else {
+				v = IntValue(i)
+			}
+			break
+		}
+		if u, err := strconv.ParseUint(l.Text, 10, 64); err == nil && kind == KindUint {
+			v = UintValue(u)
+			break
+		}
+		f, err := strconv.ParseFloat(l.Text, 64)
+		if err != nil {
+			return nil, errors.Errorf("cannot convert %v to %v", l.Text, kind)
+		}
+		return intRangesOfFloat(op, f, kind)
+	case KindFloat:
+		f, err := strconv.ParseFloat(l.Text, 64)
+		if err != nil {
+			return nil, errors.Errorf("cannot convert %v to %v", l.Text, kind)
+		}
+		v = FloatValue(f)
+	default:
+		v = StringValue(l.Text)
+	}
+	return opRanges(op, v), nil
+}
+
+func opRanges(op string, v Value) []Range {
+	switch op {
+	case predicate.OpEQ:
+		return []Range{pointRange(v)}
+	case predicate.OpNE:
+		return []Range{
+			{Low: []Value{minNotNullValue}, High: []Value{v}, HighExclude: true},
+			{Low: []Value{v}, High: []Value{maxValue}, LowExclude: true},
+		}
+	case predicate.OpLT:
+		return []Range{{Low: []Value{minNotNullValue}, High: []Value{v}, HighExclude: true}}
+	case predicate.OpLE:
+		return []Range{{Low: []Value{minNotNullValue}, High: []Value{v}}}
+	case predicate.OpGT:
+		return []Range{{Low: []Value{v}, High: []Value{maxValue}, LowExclude: true}}
+	}
+	return []Range{{Low: []Value{v}, High: []Value{maxValue}}} // predicate.OpGE
+}
+
+// intRangesOfFloat builds ranges of comparing an integer column with a float, which is rounded like the ranger:
+// a=1.5 is always false, a<1.5 is a<=1, and a>1.5 is a>=2.
+func intRangesOfFloat(op string, f float64, kind Kind) ([]Range, error) {
+	if f > math.MaxInt64 || f < math.MinInt64 {
+		return nil, errors.Errorf("%v is out of the range of %v", f, kind)
+	}
+	floor, ceil := math.Floor(f), math.Ceil(f)
+	if kind == KindUint && ceil < 0 {
+		switch op {
+		case predicate.OpEQ, predicate.OpLT, predicate.OpLE:
+			return nil, nil
+		}
+		return []Range{fullNotNullRange()}, nil
+	}
+	toValue := func(x float64) Value {
+		if kind == KindUint {
+			return UintValue(uint64(x))
+		}
+		return IntValue(int64(x))
+	}
+	if floor == ceil {
+		return opRanges(op, toValue(f)), nil
+	}
+	switch op {
+	case predicate.OpEQ:
+		return nil, nil
+	case predicate.OpNE:
+		return []Range{fullNotNullRange()}, nil
+	case predicate.OpLT, predicate.OpLE:
+		if kind == KindUint && floor < 0 {
+			return nil, nil
+		}
+		return opRanges(predicate.OpLE, toValue(floor)), nil
+	}
+	return opRanges(predicate.OpGE, toValue(ceil)), nil
+}
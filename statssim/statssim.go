@@ -0,0 +1,355 @@
+// Package statssim estimates queries offline by TiDB statistics loaded from stats dumps (/stats/dump/{db}/{table}),
+// which ports how TiDB estimates rows by histograms, CMSketch and TopN of columns and indexes, and how it combines
+// them by the greedy selectivity algorithm, so that estimations can be studied without a running cluster.
+//
+// It's an approximation of TiDB and has some limitations:
+//   - only int, uint, float and string columns with the binary collation are supported;
+//   - columns of indexes are not in stats dumps and must be specified unless the index has the name of its only column;
+//   - primary keys as handles are treated as normal columns;
+//   - predicates are limited to comparisons, IN, BETWEEN, IS [NOT] NULL, AND and OR on columns and literals.
+package statssim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/qw4990/OptimizerTester/cebench"
+	"github.com/qw4990/OptimizerTester/cetest"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+// ReportFileName is the name of the report comparing simulated estimations with live ones.
+const ReportFileName = "statssim.md"
+
+// unsupportedExamples is the number of unsupported queries listed in the report.
+const unsupportedExamples = 10
+
+// Option is the option of the simulator.
+type Option struct {
+	Dumps     []string // stats dump files returned by /stats/dump/{db}/{table}
+	Partition string   // the partition whose stats are used for partitioned tables, GlobalPartition by default
+	Kinds     []string // kinds of columns overriding the inferred ones, like "[db.]t.col=string"
+	Indexes   []string // columns of indexes, like "[db.]t.idx(a,b)" or "[db.]t.idx(a,b):unique"
+}
+
+// Simulator estimates queries offline by stats of tables loaded from stats dumps.
+type Simulator struct {
+	tables map[string]*Table // "db.table" and "table" => stats
+}
+
+// NewSimulator returns a simulator of the tables.
+func NewSimulator(tables ...*Table) *Simulator {
+	s := &Simulator{tables: make(map[string]*Table)}
+	for _, t := range tables {
+		s.tables[t.DB+"."+t.Name] = t
+		if _, ok := s.tables[t.Name]; !ok {
+			s.tables[t.Name] = t
+		}
+	}
+	return s
+}
+
+var (
+	kindSpec  = regexp.MustCompile(`^(?:([^.=]+)\.)?([^.=]+)\.([^.=]+)=(\w+)$`)
+	indexSpec = regexp.MustCompile(`^(?:([^.(]+)\.)?([^.(]+)\.([^.(]+)\(([^)]*)\)(:unique)?$`)
+)
+
+// NewSimulatorFromOption loads stats dumps and sets kinds of columns and columns of indexes by the option.
+func NewSimulatorFromOption(opt Option) (*Simulator, error) {
+	kinds := make(map[string]map[string]Kind) // table => column => kind
+	for _, spec := range opt.Kinds {
+		m := kindSpec.FindStringSubmatch(strings.ToLower(strings.TrimSpace(spec)))
+		if m == nil {
+			return nil, errors.Errorf("invalid column kind %v, expect [db.]table.column=kind", spec)
+		}
+		kind, err := ParseKind(m[4])
+		if err != nil {
+			return nil, err
+		}
+		tbl := m[2]
+		if m[1] != "" {
+			tbl = m[1] + "." + m[2]
+		}
+		if kinds[tbl] == nil {
+			kinds[tbl] = make(map[string]Kind)
+		}
+		kinds[tbl][m[3]] = kind
+	}
+
+	var tables []*Table
+	for _, dump := range opt.Dumps {
+		data, err := ioutil.ReadFile(dump)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		var meta struct {
+			DB    string `json:"database_name"`
+			Table string `json:"table_name"`
+		}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, errors.Annotatef(err, "load stats dump %v", dump)
+		}
+		db, tbl := strings.ToLower(meta.DB), strings.ToLower(meta.Table)
+		colKinds := make(map[string]Kind)
+		for _, key := range []string{tbl, db + "." + tbl} { // kinds of "db.table" override ones of "table"
+			for col, kind := range kinds[key] {
+				colKinds[col] = kind
+			}
+		}
+		t, err := ParseTable(data, LoadOption{Partition: opt.Partition, Kinds: colKinds})
+		if err != nil {
+			return nil, errors.Annotatef(err, "load stats dump %v", dump)
+		}
+		tables = append(tables, t)
+	}
+	s := NewSimulator(tables...)
+
+	for _, spec := range opt.Indexes {
+		m := indexSpec.FindStringSubmatch(strings.ToLower(strings.ReplaceAll(spec, " ", "")))
+		if m == nil {
+			return nil, errors.Errorf("invalid index %v, expect [db.]table.index(col1,col2,...)[:unique]", spec)
+		}
+		t, err := s.Table(m[1], m[2])
+		if err != nil {
+			return nil, err
+		}
+		if err := t.SetIndexColumns(m[3], strings.Split(m[4], ","), m[5] != ""); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Table returns stats of the table, the db can be empty if table names are unique.
+func (s *Simulator) Table(db, name string) (*Table, error) {
+	key := strings.ToLower(name)
+	if db != "" {
+		key = strings.ToLower(db) + "." + key
+	}
+	t, ok := s.tables[key]
+	if !ok {
+		return nil, errors.Errorf("no stats of table %v", key)
+	}
+	return t, nil
+}
+
+// EstimateSQL estimates the number of rows of a query like "SELECT * FROM db.t WHERE cond".
+func (s *Simulator) EstimateSQL(sql string) (float64, error) {
+	db, name, cond, err := splitSQL(sql)
+	if err != nil {
+		return 0, err
+	}
+	t, err := s.Table(db, name)
+	if err != nil {
+		return 0, err
+	}
+	if cond == "" {
+		return float64(t.Count), nil
+	}
+	return t.Estimate(cond)
+}
+
+// EstimateTrace estimates the expression of a record in optimizer traces by its type, like "Column Stats-Range".
+func (s *Simulator) EstimateTrace(tableName, tp, expr string) (float64, error) {
+	db, name := "", tableName
+	if dot := strings.LastIndexByte(tableName, '.'); dot >= 0 {
+		db, name = tableName[:dot], tableName[dot+1:]
+	}
+	t, err := s.Table(db, name)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case strings.HasPrefix(tp, "Column Stats"):
+		return t.EstimateColumn(expr)
+	case strings.HasPrefix(tp, "Index Stats"):
+		return t.EstimateIndex(expr)
+	}
+	return t.Estimate(expr)
+}
+
+// comparison collects simulated estimations with live ones and true cardinalities of groups of queries.
+type comparison struct {
+	groups      []string
+	simVsLive   map[string][]float64 // q-errors between simulated and live estimations
+	liveVsTrue  map[string][]float64
+	simVsTrue   map[string][]float64
+	same        map[string]int
+	unsupported map[string]int
+	examples    []string
+}
+
+func newComparison() *comparison {
+	return &comparison{simVsLive: make(map[string][]float64), liveVsTrue: make(map[string][]float64),
+		simVsTrue: make(map[string][]float64), same: make(map[string]int), unsupported: make(map[string]int)}
+}
+
+func (c *comparison) addGroup(group string) {
+	for _, g := range c.groups {
+		if g == group {
+			return
+		}
+	}
+	c.groups = append(c.groups, group)
+}
+
+func qError(est, act float64) float64 {
+	return cetest.QError(cetest.EstResult{EstCard: est, TrueCard: act})
+}
+
+func (c *comparison) add(group string, sim, live, act float64) {
+	c.addGroup(group)
+	c.simVsLive[group] = append(c.simVsLive[group], qError(sim, live))
+	c.liveVsTrue[group] = append(c.liveVsTrue[group], qError(live, act))
+	c.simVsTrue[group] = append(c.simVsTrue[group], qError(sim, act))
+	if math.Abs(sim-live) <= 0.01*math.Max(live, 1) {
+		c.same[group]++
+	}
+}
+
+func (c *comparison) addUnsupported(group, query string, err error) {
+	c.addGroup(group)
+	c.unsupported[group]++
+	if len(c.examples) < unsupportedExamples {
+		c.examples = append(c.examples, fmt.Sprintf("%v: %v", query, errors.Cause(err)))
+	}
+}
+
+func percentile(vals []float64, p float64) float64 {
+	if len(vals) == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64{}, vals...)
+	sort.Float64s(sorted)
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+// write writes the comparison into statssim.md in the dir.
+func (c *comparison) write(dir, title string) error {
+	md := bytes.Buffer{}
+	md.WriteString(fmt.Sprintf("# %v\n\n", title))
+	md.WriteString("Same: simulated estimations within 1% of live ones. Q-errors are between simulated and live estimations, ")
+	md.WriteString("and of live and simulated estimations against true cardinalities.\n\n")
+	md.WriteString("| Group | Total | Unsupported | Same | Sim-Live P50 | Sim-Live P90 | Sim-Live Max | Live-True P90 | Sim-True P90 |\n")
+	md.WriteString("| ---- | ---- | ---- | ---- | ---- | ---- | ---- | ---- | ---- |\n")
+	for _, g := range c.groups {
+		n := len(c.simVsLive[g])
+		same := "-"
+		if n > 0 {
+			same = fmt.Sprintf("%.1f%%", float64(c.same[g])*100/float64(n))
+		}
+		md.WriteString(fmt.Sprintf("| %v | %v | %v | %v | %.3f | %.3f | %.3f | %.3f | %.3f |\n", g, n+c.unsupported[g], c.unsupported[g], same,
+			percentile(c.simVsLive[g], 0.5), percentile(c.simVsLive[g], 0.9), percentile(c.simVsLive[g], 1),
+			percentile(c.liveVsTrue[g], 0.9), percentile(c.simVsTrue[g], 0.9)))
+	}
+	if len(c.examples) > 0 {
+		md.WriteString("\n## Unsupported Queries\n\n")
+		for _, e := range c.examples {
+			md.WriteString(fmt.Sprintf("- `%v`\n", e))
+		}
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(ioutil.WriteFile(path.Join(dir, ReportFileName), md.Bytes(), 0666))
+}
+
+// CompareCETest estimates queries of the instance in cetest results offline, and saves them as a new instance
+// labeled "<instance>-statssim" with the original ones into results.json and reports in the output dir,
+// and a comparison with the live estimations into statssim.md.
+func CompareCETest(s *Simulator, resultsPath, instance, outDir string) error {
+	opt, collector, err := cetest.LoadResults(resultsPath)
+	if err != nil {
+		return err
+	}
+	insIdx := 0
+	if instance != "" {
+		insIdx = -1
+		for i, ins := range opt.Instances {
+			if ins.Label == instance {
+				insIdx = i
+			}
+		}
+		if insIdx < 0 {
+			return errors.Errorf("no instance %v in %v", instance, resultsPath)
+		}
+	}
+	if len(opt.Instances) == 0 {
+		return errors.Errorf("no instance in %v", resultsPath)
+	}
+
+	simOpt := opt
+	simOpt.ReportDir = outDir
+	simOpt.Instances = append(append([]tidb.Option{}, opt.Instances...), tidb.Option{Label: opt.Instances[insIdx].Label + "-statssim"})
+	simIdx := len(simOpt.Instances) - 1
+	simCollector := cetest.NewEstResultCollector(len(simOpt.Instances), len(opt.Datasets), len(opt.QueryTypes))
+	cmp := newComparison()
+	for dsIdx, ds := range opt.Datasets {
+		for qtIdx, qt := range opt.QueryTypes {
+			for i := range opt.Instances {
+				simCollector.AppendEstResults(i, dsIdx, qtIdx, collector.EstResults(i, dsIdx, qtIdx))
+			}
+			group := fmt.Sprintf("%v/%v", ds.Label, qt)
+			for _, r := range collector.EstResults(insIdx, dsIdx, qtIdx) {
+				est, err := s.EstimateSQL(r.SQL)
+				if err != nil {
+					cmp.addUnsupported(group, r.SQL, err)
+					continue
+				}
+				cmp.add(group, est, r.EstCard, r.TrueCard)
+				simCollector.AddEstResult(simIdx, dsIdx, qtIdx, cetest.EstResult{SQL: r.SQL, EstCard: est,
+					TrueCard: r.TrueCard, FreqClass: r.FreqClass, Stats: r.Stats})
+			}
+		}
+	}
+
+	if err := cetest.SaveResults(simOpt, simCollector); err != nil {
+		return err
+	}
+	if err := cetest.RenderReports(simOpt, simCollector); err != nil {
+		return err
+	}
+	return cmp.write(outDir, fmt.Sprintf("Simulated estimations of %v", opt.Instances[insIdx].Label))
+}
+
+// CompareCEBench estimates records of optimizer traces in cebench results (full_est_info.json) offline,
+// and compares them with the live estimations in statssim.md in the output dir.
+func CompareCEBench(s *Simulator, jsonPaths []string, outDir string) error {
+	cmp := newComparison()
+	for _, p := range jsonPaths {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		infoMap := make(map[string]cebench.EstInfos)
+		if err := json.Unmarshal(data, &infoMap); err != nil {
+			return errors.Annotatef(err, "load cebench results %v", p)
+		}
+		keys := make([]string, 0, len(infoMap))
+		for k := range infoMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, info := range infoMap[k] {
+				est, err := s.EstimateTrace(info.TableName, info.Type, info.Expr)
+				if err != nil {
+					cmp.addUnsupported(info.Type, info.Expr, err)
+					continue
+				}
+				cmp.add(info.Type, est, float64(info.Est), float64(info.Actual))
+			}
+		}
+	}
+	sort.Strings(cmp.groups)
+	return cmp.write(outDir, "Simulated estimations of optimizer traces")
+}
@@ -0,0 +1,50 @@
+package statssim
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/qw4990/OptimizerTester/cetest"
+	"github.com/qw4990/OptimizerTester/tidb"
+)
+
+func TestCompareCETest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statssim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opt := cetest.Option{
+		QueryTypes: []cetest.QueryType{cetest.QTSingleColPointQueryOnCol},
+		Datasets:   []cetest.DatasetOpt{{Label: "test"}},
+		Instances:  []tidb.Option{{Label: "v5.4"}},
+		ReportDir:  path.Join(dir, "live"),
+	}
+	collector := cetest.NewEstResultCollector(1, 1, 1)
+	collector.AddEstResult(0, 0, 0, cetest.EstResult{SQL: "SELECT * FROM test.`t` WHERE a=1", EstCard: 100, TrueCard: 90})
+	collector.AddEstResult(0, 0, 0, cetest.EstResult{SQL: "SELECT * FROM test.`t` WHERE a LIKE 'x%'", EstCard: 10, TrueCard: 10})
+	if err := cetest.SaveResults(opt, collector); err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := path.Join(dir, "sim")
+	if err := CompareCETest(NewSimulator(testTable(t)), path.Join(opt.ReportDir, cetest.ResultsFileName), "", outDir); err != nil {
+		t.Fatal(err)
+	}
+	simOpt, simCollector, err := cetest.LoadResults(path.Join(outDir, cetest.ResultsFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(simOpt.Instances) != 2 || simOpt.Instances[1].Label != "v5.4-statssim" {
+		t.Fatalf("unexpected instances %v", simOpt.Instances)
+	}
+	if rs := simCollector.EstResults(1, 0, 0); len(rs) != 1 || rs[0].EstCard != 100 || rs[0].TrueCard != 90 {
+		t.Fatalf("unexpected simulated results %v", rs)
+	}
+	if _, err := os.Stat(path.Join(outDir, ReportFileName)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,329 @@
+package statssim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// jsonTable is the stats dump of a table returned by the TiDB status API /stats/dump/{db}/{table}.
+type jsonTable struct {
+	DatabaseName string                 `json:"database_name"`
+	TableName    string                 `json:"table_name"`
+	Columns      map[string]*jsonColumn `json:"columns"`
+	Indices      map[string]*jsonColumn `json:"indices"`
+	Count        int64                  `json:"count"`
+	ModifyCount  int64                  `json:"modify_count"`
+	Partitions   map[string]*jsonTable  `json:"partitions"`
+}
+
+type jsonColumn struct {
+	Histogram *jsonHistogram `json:"histogram"`
+	CMSketch  *jsonCMSketch  `json:"cm_sketch"`
+	NullCount int64          `json:"null_count"`
+	StatsVer  *int64         `json:"stats_ver"`
+}
+
+type jsonHistogram struct {
+	Ndv     int64         `json:"ndv"`
+	Buckets []*jsonBucket `json:"buckets"`
+}
+
+type jsonBucket struct {
+	Count      int64  `json:"count"`
+	LowerBound []byte `json:"lower_bound"`
+	UpperBound []byte `json:"upper_bound"`
+	Repeats    int64  `json:"repeats"`
+	Ndv        *int64 `json:"ndv"`
+}
+
+type jsonCMSketch struct {
+	Rows []*struct {
+		Counters []uint32 `json:"counters"`
+	} `json:"rows"`
+	TopN []*struct {
+		Data  []byte `json:"data"`
+		Count uint64 `json:"count"`
+	} `json:"top_n"`
+	DefaultValue uint64 `json:"default_value"`
+}
+
+// GlobalPartition is the name of global stats of partitioned tables in stats dumps.
+const GlobalPartition = "global"
+
+// LoadOption tells how to load stats dumps.
+type LoadOption struct {
+	Partition string          // the partition whose stats are used for partitioned tables, GlobalPartition by default
+	Kinds     map[string]Kind // kinds of columns overriding the inferred ones
+}
+
+// Table is the stats of a table loaded from a stats dump.
+type Table struct {
+	DB          string
+	Name        string
+	Count       int64 // the realtime row count
+	ModifyCount int64
+	Columns     map[string]*Column
+	Indices     map[string]*Index
+}
+
+// FetchDump fetches the stats dump of the table from the status address of a TiDB server.
+func FetchDump(statusAddr, db, table string) ([]byte, error) {
+	resp, err := http.Get(fmt.Sprintf("http://%v/stats/dump/%v/%v", statusAddr, db, table))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetch stats of %v.%v: %v %s", db, table, resp.Status, data)
+	}
+	return data, nil
+}
+
+// LoadTable loads the stats dump file.
+func LoadTable(dumpPath string, opt LoadOption) (*Table, error) {
+	data, err := ioutil.ReadFile(dumpPath)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return ParseTable(data, opt)
+}
+
+// ParseTable parses the stats dump.
+func ParseTable(data []byte, opt LoadOption) (*Table, error) {
+	var jt jsonTable
+	if err := json.Unmarshal(data, &jt); err != nil {
+		return nil, errors.Trace(err)
+	}
+	db, name := jt.DatabaseName, jt.TableName
+	if len(jt.Partitions) > 0 {
+		partition := opt.Partition
+		if partition == "" {
+			partition = GlobalPartition
+		}
+		p, ok := jt.Partitions[partition]
+		if !ok {
+			names := make([]string, 0, len(jt.Partitions))
+			for n := range jt.Partitions {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, errors.Errorf("no stats of partition %v in %v.%v, available partitions are %v", partition, db, name, names)
+		}
+		jt = *p
+	}
+
+	t := &Table{DB: strings.ToLower(db), Name: strings.ToLower(name), Count: jt.Count, ModifyCount: jt.ModifyCount,
+		Columns: make(map[string]*Column), Indices: make(map[string]*Index)}
+	for colName, jc := range jt.Columns {
+		colName = strings.ToLower(colName)
+		kind, ok := opt.Kinds[colName]
+		if !ok {
+			kind = inferKind(jc)
+		}
+		col, err := newColumn(colName, kind, jc)
+		if err != nil {
+			return nil, errors.Annotatef(err, "load stats of column %v.%v.%v", db, name, colName)
+		}
+		t.Columns[colName] = col
+	}
+	for idxName, jc := range jt.Indices {
+		idxName = strings.ToLower(idxName)
+		idx := newIndex(idxName, jc)
+		// an index is named after its first column by default, so its columns are known if it only has one column
+		if _, ok := t.Columns[idxName]; ok && idxColumnCount(jc) == 1 {
+			idx.Columns = []string{idxName}
+		}
+		t.Indices[idxName] = idx
+	}
+	return t, nil
+}
+
+// SetIndexColumns sets columns of the index, which are not in stats dumps.
+func (t *Table) SetIndexColumns(idxName string, cols []string, unique bool) error {
+	idx, ok := t.Indices[strings.ToLower(idxName)]
+	if !ok {
+		return errors.Errorf("no stats of index %v in %v.%v", idxName, t.DB, t.Name)
+	}
+	idx.Columns = idx.Columns[:0]
+	for _, c := range cols {
+		idx.Columns = append(idx.Columns, strings.ToLower(c))
+	}
+	idx.Unique = unique
+	return nil
+}
+
+// usableIndices returns indexes with known columns in name order.
+func (t *Table) usableIndices() []*Index {
+	var indices []*Index
+	for _, idx := range t.Indices {
+		if len(idx.Columns) > 0 {
+			indices = append(indices, idx)
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i].Name < indices[j].Name })
+	return indices
+}
+
+// firstColumnIndex returns an index whose first column is the column, which is preferred to column stats.
+func (t *Table) firstColumnIndex(colName string) *Index {
+	for _, idx := range t.usableIndices() {
+		if idx.Columns[0] == colName && idx.TotalRowCount() > 0 {
+			return idx
+		}
+	}
+	return nil
+}
+
+// columnRowCount estimates the number of rows in ranges of the column, which is pseudo if it has no stats.
+func (t *Table) columnRowCount(colName string, ranges []Range) float64 {
+	col, ok := t.Columns[colName]
+	if !ok || col.invalid() {
+		return pseudoRowCount(ranges, float64(t.Count))
+	}
+	return col.RowCount(ranges, t.Count)
+}
+
+func statsVer(jc *jsonColumn) int64 {
+	if jc.StatsVer == nil {
+		return 0
+	}
+	return *jc.StatsVer
+}
+
+func loadSketches(jc *jsonColumn) (*cmSketch, *topN) {
+	if jc.CMSketch == nil {
+		return nil, nil
+	}
+	var t *topN
+	if len(jc.CMSketch.TopN) > 0 {
+		items := make([]topNItem, 0, len(jc.CMSketch.TopN))
+		for _, item := range jc.CMSketch.TopN {
+			items = append(items, topNItem{encoded: item.Data, count: item.Count})
+		}
+		t = newTopN(items)
+	}
+	rows := jc.CMSketch.Rows
+	if len(rows) == 0 || len(rows[0].Counters) == 0 {
+		return nil, t
+	}
+	c := newCMSketch(len(rows), len(rows[0].Counters))
+	for i, row := range rows {
+		c.count = 0
+		for j, counter := range row.Counters {
+			c.table[i][j] = counter
+			c.count += uint64(counter)
+		}
+	}
+	c.defaultValue = jc.CMSketch.DefaultValue
+	return c, t
+}
+
+func loadHistogram(jc *jsonColumn, parse func([]byte) (Value, error)) (histogram, error) {
+	h := histogram{nullCount: jc.NullCount}
+	if jc.Histogram == nil {
+		return h, nil
+	}
+	h.ndv = jc.Histogram.Ndv
+	for _, b := range jc.Histogram.Buckets {
+		lower, err := parse(b.LowerBound)
+		if err != nil {
+			return h, err
+		}
+		upper, err := parse(b.UpperBound)
+		if err != nil {
+			return h, err
+		}
+		bkt := bucket{lower: lower, upper: upper, count: b.Count, repeat: b.Repeats}
+		if b.Ndv != nil {
+			bkt.ndv = *b.Ndv
+		}
+		h.buckets = append(h.buckets, bkt)
+	}
+	return h, nil
+}
+
+func newColumn(name string, kind Kind, jc *jsonColumn) (*Column, error) {
+	col := &Column{Name: name, Kind: kind, StatsVer: statsVer(jc)}
+	var err error
+	col.hist, err = loadHistogram(jc, func(b []byte) (Value, error) { return parseBound(string(b), kind) })
+	if err != nil {
+		return nil, err
+	}
+	col.cms, col.topN = loadSketches(jc)
+	return col, nil
+}
+
+func newIndex(name string, jc *jsonColumn) *Index {
+	idx := &Index{Name: name, StatsVer: statsVer(jc)}
+	idx.hist, _ = loadHistogram(jc, func(b []byte) (Value, error) { return StringValue(string(b)), nil })
+	idx.cms, idx.topN = loadSketches(jc)
+	return idx
+}
+
+// inferKind infers the kind of the column by flags of its encoded TopN values, or by its histogram bounds.
+func inferKind(jc *jsonColumn) Kind {
+	if jc.CMSketch != nil {
+		for _, item := range jc.CMSketch.TopN {
+			if len(item.Data) == 0 {
+				continue
+			}
+			switch item.Data[0] {
+			case intFlag, varintFlag:
+				return KindInt
+			case uintFlag, uvarintFlag:
+				return KindUint
+			case floatFlag:
+				return KindFloat
+			case bytesFlag, compactBytesFlag:
+				return KindString
+			}
+		}
+	}
+	if jc.Histogram == nil || len(jc.Histogram.Buckets) == 0 {
+		return KindString
+	}
+	isInt, isFloat := true, true
+	for _, b := range jc.Histogram.Buckets {
+		for _, bound := range [][]byte{b.LowerBound, b.UpperBound} {
+			if _, err := strconv.ParseInt(string(bound), 10, 64); err != nil {
+				isInt = false
+			}
+			if _, err := strconv.ParseFloat(string(bound), 64); err != nil {
+				isFloat = false
+			}
+		}
+	}
+	switch {
+	case isInt:
+		return KindInt
+	case isFloat:
+		return KindFloat
+	}
+	return KindString
+}
+
+// idxColumnCount returns the number of columns of the index by decoding its TopN or bounds, -1 if it's unknown.
+func idxColumnCount(jc *jsonColumn) int {
+	var key []byte
+	if jc.CMSketch != nil && len(jc.CMSketch.TopN) > 0 {
+		key = jc.CMSketch.TopN[0].Data
+	} else if jc.Histogram != nil && len(jc.Histogram.Buckets) > 0 {
+		key = jc.Histogram.Buckets[0].UpperBound
+	}
+	vals, err := decodeAll(key)
+	if err != nil || len(vals) == 0 {
+		return -1
+	}
+	return len(vals)
+}
@@ -0,0 +1,161 @@
+package statssim
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+type testBucket struct {
+	lower, upper        string
+	count, repeats, ndv int64
+}
+
+func testColumn(statsVer int64, ndv, nullCount int64, buckets []testBucket, topN map[string]uint64) *jsonColumn {
+	jc := &jsonColumn{Histogram: &jsonHistogram{Ndv: ndv}, NullCount: nullCount, StatsVer: &statsVer,
+		CMSketch: &jsonCMSketch{}}
+	for _, b := range buckets {
+		ndv := b.ndv
+		jc.Histogram.Buckets = append(jc.Histogram.Buckets, &jsonBucket{Count: b.count,
+			LowerBound: []byte(b.lower), UpperBound: []byte(b.upper), Repeats: b.repeats, Ndv: &ndv})
+	}
+	for data, cnt := range topN {
+		jc.CMSketch.TopN = append(jc.CMSketch.TopN, &struct {
+			Data  []byte `json:"data"`
+			Count uint64 `json:"count"`
+		}{Data: []byte(data), Count: cnt})
+	}
+	return jc
+}
+
+// testTable returns a table with 550 rows in stats version 2:
+// a: TopN 1 (100 rows) and 2 (50 rows), buckets [3,5] and [6,10] of 200 rows each;
+// b: TopN "x" (300 rows), a bucket ["a","w"] of 200 rows and 50 NULLs;
+// index a_b on (a, b).
+func testTable(t *testing.T) *Table {
+	jt := jsonTable{DatabaseName: "test", TableName: "t", Count: 550, Columns: map[string]*jsonColumn{
+		"a": testColumn(2, 10, 0, []testBucket{{"3", "5", 200, 40, 3}, {"6", "10", 400, 40, 5}},
+			map[string]uint64{string(encodeKey(nil, IntValue(1))): 100, string(encodeKey(nil, IntValue(2))): 50}),
+		"b": testColumn(2, 21, 50, []testBucket{{"a", "w", 200, 10, 20}},
+			map[string]uint64{string(encodeKey(nil, StringValue("x"))): 300}),
+	}, Indices: map[string]*jsonColumn{
+		"a_b": testColumn(2, 30, 0, []testBucket{{string(encodeKey(nil, IntValue(3), StringValue("a"))),
+			string(encodeKey(nil, IntValue(10), StringValue("w"))), 450, 10, 28}},
+			map[string]uint64{string(encodeKey(nil, IntValue(1), StringValue("x"))): 100}),
+	}}
+	data, err := json.Marshal(jt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl, err := ParseTable(data, LoadOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tbl.SetIndexColumns("a_b", []string{"a", "b"}, false); err != nil {
+		t.Fatal(err)
+	}
+	return tbl
+}
+
+func TestParseTable(t *testing.T) {
+	tbl := testTable(t)
+	if tbl.Columns["a"].Kind != KindInt || tbl.Columns["b"].Kind != KindString {
+		t.Fatalf("unexpected kinds %v %v", tbl.Columns["a"].Kind, tbl.Columns["b"].Kind)
+	}
+	if cnt := idxColumnCount(testColumn(2, 1, 0, nil, map[string]uint64{string(encodeKey(nil, IntValue(1), StringValue("x"))): 1})); cnt != 2 {
+		t.Fatalf("expected 2 index columns, got %v", cnt)
+	}
+	if _, err := ParseTable([]byte(`{"database_name":"test","table_name":"p","partitions":{"p0":{}}}`), LoadOption{}); err == nil {
+		t.Fatalf("partitioned tables without global stats should fail")
+	}
+}
+
+func TestEstimateColumn(t *testing.T) {
+	tbl := testTable(t)
+	cases := []struct {
+		cond string
+		est  float64
+	}{
+		{"a = 1", 100},          // TopN
+		{"a = 5", 40},           // the upper bound of a bucket
+		{"a = 4", 80},           // (count - repeats) / (ndv - 1) of the bucket
+		{"a IN (1, 2, 5)", 190}, // TopN and the bucket
+		{"b IS NULL", 50},       // null count
+		{"b = 'x'", 300},        // TopN
+		{"a < 3", 150},          // only TopN
+		{"a IS NOT NULL", 550},  // capped by the total row count
+		{"b IS NOT NULL", 510},  // TiDB also adds the average count in buckets for the MaxValue bound
+		{"a = 4 OR a = 1", 180}, // disjoint points
+		{"a >= 1 AND a <= 2", 150},
+	}
+	for _, c := range cases {
+		est, err := tbl.EstimateColumn(c.cond)
+		if err != nil {
+			t.Fatalf("%v: %v", c.cond, err)
+		}
+		if math.Abs(est-c.est) > 1e-6 {
+			t.Fatalf("%v: expected %v, got %v", c.cond, c.est, est)
+		}
+	}
+	if _, err := tbl.EstimateColumn("a = 1 AND b = 'x'"); err == nil {
+		t.Fatalf("conditions on multiple columns should fail")
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	tbl := testTable(t)
+	if est, err := tbl.Estimate(""); err != nil || est != 550 {
+		t.Fatalf("expected 550, got %v, %v", est, err)
+	}
+	// the index a_b covers both conditions, and its TopN knows (1, "x")
+	est, err := tbl.Estimate("a = 1 AND b = 'x'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(est-100) > 1e-6 {
+		t.Fatalf("expected 100, got %v", est)
+	}
+	// conditions that cannot be estimated by stats are estimated by the selection factor
+	est, err = tbl.Estimate("a = 1 AND c = 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(est-100*selectionFactor) > 1e-6 {
+		t.Fatalf("expected %v, got %v", 100*selectionFactor, est)
+	}
+}
+
+func TestSimulator(t *testing.T) {
+	s := NewSimulator(testTable(t))
+	est, err := s.EstimateSQL("SELECT * FROM test.`t` WHERE a=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(est-100) > 1e-6 {
+		t.Fatalf("expected 100, got %v", est)
+	}
+	est, err = s.EstimateTrace("test.t", "Column Stats-Point", "eq(test.t.b, 'x')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(est-300) > 1e-6 {
+		t.Fatalf("expected 300, got %v", est)
+	}
+	if _, err := s.EstimateSQL("SELECT * FROM test.t2 WHERE a=1"); err == nil {
+		t.Fatalf("tables without stats should fail")
+	}
+}
+
+func TestCMSketch(t *testing.T) {
+	c := newCMSketch(5, 2048)
+	key := encodeValue(nil, IntValue(7))
+	c.insertBytes(key, 30)
+	c.insertBytes(encodeValue(nil, IntValue(8)), 5)
+	if cnt := queryValue(c, nil, key); cnt != 30 {
+		t.Fatalf("expected 30, got %v", cnt)
+	}
+	tn := newTopN([]topNItem{{encoded: key, count: 100}})
+	if cnt := queryValue(c, tn, key); cnt != 100 {
+		t.Fatalf("expected 100, got %v", cnt)
+	}
+}
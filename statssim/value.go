@@ -0,0 +1,255 @@
+package statssim
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// Kind is the kind of values and columns.
+type Kind int
+
+// Kinds of values, in the order of TiDB: NULL < MinNotNull < all other values < MaxValue.
+const (
+	KindNull Kind = iota
+	KindMinNotNull
+	KindInt
+	KindUint
+	KindFloat
+	KindString // strings of columns and encoded keys of indexes
+	KindMaxValue
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNull:
+		return "null"
+	case KindMinNotNull:
+		return "min-not-null"
+	case KindInt:
+		return "int"
+	case KindUint:
+		return "uint"
+	case KindFloat:
+		return "float"
+	case KindString:
+		return "string"
+	case KindMaxValue:
+		return "max-value"
+	}
+	return fmt.Sprintf("kind(%d)", int(k))
+}
+
+// ParseKind parses the kind of columns.
+func ParseKind(s string) (Kind, error) {
+	switch strings.ToLower(s) {
+	case "int":
+		return KindInt, nil
+	case "uint":
+		return KindUint, nil
+	case "float", "double":
+		return KindFloat, nil
+	case "string":
+		return KindString, nil
+	}
+	return 0, errors.Errorf("unknown column kind %v, supported kinds are int, uint, float and string", s)
+}
+
+// Value is a value in stats or predicates, like the Datum in TiDB.
+type Value struct {
+	Kind Kind
+	I    int64
+	U    uint64
+	F    float64
+	S    string
+}
+
+var (
+	nullValue       = Value{Kind: KindNull}
+	minNotNullValue = Value{Kind: KindMinNotNull}
+	maxValue        = Value{Kind: KindMaxValue}
+)
+
+// IntValue returns an int value.
+func IntValue(i int64) Value { return Value{Kind: KindInt, I: i} }
+
+// UintValue returns an uint value.
+func UintValue(u uint64) Value { return Value{Kind: KindUint, U: u} }
+
+// FloatValue returns a float value.
+func FloatValue(f float64) Value { return Value{Kind: KindFloat, F: f} }
+
+// StringValue returns a string value.
+func StringValue(s string) Value { return Value{Kind: KindString, S: s} }
+
+func (v Value) isNull() bool { return v.Kind == KindNull }
+
+func (v Value) isSpecial() bool {
+	return v.Kind == KindNull || v.Kind == KindMinNotNull || v.Kind == KindMaxValue
+}
+
+func (v Value) String() string {
+	switch v.Kind {
+	case KindNull:
+		return "NULL"
+	case KindMinNotNull:
+		return "-inf"
+	case KindMaxValue:
+		return "+inf"
+	case KindInt:
+		return strconv.FormatInt(v.I, 10)
+	case KindUint:
+		return strconv.FormatUint(v.U, 10)
+	case KindFloat:
+		return strconv.FormatFloat(v.F, 'g', -1, 64)
+	}
+	return strconv.Quote(v.S)
+}
+
+func (v Value) float() float64 {
+	switch v.Kind {
+	case KindInt:
+		return float64(v.I)
+	case KindUint:
+		return float64(v.U)
+	case KindFloat:
+		return v.F
+	}
+	return 0
+}
+
+func compareKinds(a, b Kind) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compare compares two values like chunk.Compare, strings are compared in the binary collation.
+func compare(a, b Value) int {
+	if a.isSpecial() || b.isSpecial() {
+		ka, kb := a.Kind, b.Kind
+		if !a.isSpecial() {
+			ka = KindInt
+		}
+		if !b.isSpecial() {
+			kb = KindInt
+		}
+		return compareKinds(ka, kb)
+	}
+	switch {
+	case a.Kind == KindString && b.Kind == KindString:
+		return strings.Compare(a.S, b.S)
+	case a.Kind == KindString || b.Kind == KindString:
+		return compareKinds(a.Kind, b.Kind)
+	case a.Kind == KindInt && b.Kind == KindInt:
+		return compareInt64(a.I, b.I)
+	case a.Kind == KindUint && b.Kind == KindUint:
+		switch {
+		case a.U < b.U:
+			return -1
+		case a.U > b.U:
+			return 1
+		}
+		return 0
+	}
+	fa, fb := a.float(), b.float()
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// toScalar converts the value to a float like convertDatumToScalar, strings are cut by the common prefix length.
+func toScalar(v Value, commonPfxLen int) float64 {
+	switch v.Kind {
+	case KindInt, KindUint, KindFloat:
+		return v.float()
+	case KindString:
+		if len(v.S) <= commonPfxLen {
+			return 0
+		}
+		var buf [8]byte
+		copy(buf[:], v.S[commonPfxLen:])
+		return float64(binary.BigEndian.Uint64(buf[:]))
+	case KindMinNotNull:
+		return -math.MaxFloat64
+	case KindMaxValue:
+		return math.MaxFloat64
+	}
+	return 0
+}
+
+func commonPrefixLength(strs ...string) int {
+	if len(strs) == 0 {
+		return 0
+	}
+	minLen := len(strs[0])
+	for _, s := range strs {
+		if len(s) < minLen {
+			minLen = len(s)
+		}
+	}
+	for i := 0; i < minLen; i++ {
+		for _, s := range strs {
+			if s[i] != strs[0][i] {
+				return i
+			}
+		}
+	}
+	return minLen
+}
+
+// calcFraction calculates the fraction of [lower, value] in [lower, upper] on the continuous-value assumption.
+func calcFraction(lower, upper, value float64) float64 {
+	if upper <= lower {
+		return 0.5
+	}
+	if value <= lower {
+		return 0
+	}
+	if value >= upper {
+		return 1
+	}
+	frac := (value - lower) / (upper - lower)
+	if math.IsNaN(frac) || math.IsInf(frac, 0) || frac < 0 || frac > 1 {
+		return 0.5
+	}
+	return frac
+}
+
+// parseBound parses a bound of column histograms, which is dumped as a string, in the kind of the column.
+func parseBound(s string, kind Kind) (Value, error) {
+	switch kind {
+	case KindInt:
+		i, err := strconv.ParseInt(s, 10, 64)
+		return IntValue(i), errors.Trace(err)
+	case KindUint:
+		u, err := strconv.ParseUint(s, 10, 64)
+		return UintValue(u), errors.Trace(err)
+	case KindFloat:
+		f, err := strconv.ParseFloat(s, 64)
+		return FloatValue(f), errors.Trace(err)
+	}
+	return StringValue(s), nil
+}
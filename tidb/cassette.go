@@ -0,0 +1,306 @@
+package tidb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sync"
+
+	"github.com/pingcap/errors"
+)
+
+// Cassette records every statement sent to databases and its full result set, and can replay them without databases.
+// Once a cassette is started by RecordTo or ReplayFrom, all connections opened by this package go through it,
+// including Instances and connections of StartQueryRunner, so a whole session can be captured and replayed offline.
+// The seed of random generators of the session is also saved, since statements generated by other seeds have no
+// recorded result.
+type Cassette struct {
+	Seed         int64         `json:"seed,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+
+	mu        sync.Mutex
+	path      string
+	replaying bool
+	queues    map[string][]Interaction // (source, SQL) => interactions not replayed yet
+}
+
+// Interaction is a statement sent to a database and its result.
+type Interaction struct {
+	Source  string      `json:"source"` // the database receiving the statement
+	SQL     string      `json:"sql"`
+	Columns []string    `json:"columns,omitempty"`
+	Rows    [][]*string `json:"rows,omitempty"` // values in text, nil means NULL
+	Error   string      `json:"error,omitempty"`
+}
+
+var (
+	cassetteLock  sync.Mutex
+	cassetteInUse *Cassette
+)
+
+// RecordTo starts recording all statements into a new cassette, which is saved to the path by SaveCassette.
+func RecordTo(path string) {
+	cassetteLock.Lock()
+	defer cassetteLock.Unlock()
+	cassetteInUse = &Cassette{path: path}
+}
+
+// ReplayFrom loads the cassette from the path and serves all statements with results in it from now on.
+func ReplayFrom(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	c := &Cassette{path: path, replaying: true, queues: make(map[string][]Interaction)}
+	if err := json.Unmarshal(data, c); err != nil {
+		return errors.Trace(err)
+	}
+	for _, i := range c.Interactions {
+		k := cassetteKey(i.Source, i.SQL)
+		c.queues[k] = append(c.queues[k], i)
+	}
+	cassetteLock.Lock()
+	defer cassetteLock.Unlock()
+	cassetteInUse = c
+	return nil
+}
+
+// SaveCassette saves the cassette being recorded to its path, and does nothing if it's not recording.
+func SaveCassette() error {
+	cassetteLock.Lock()
+	c := cassetteInUse
+	cassetteLock.Unlock()
+	if c == nil || c.replaying {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(c); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(ioutil.WriteFile(c.path, buf.Bytes(), 0666))
+}
+
+// UseSeed saves the seed of the session into the cassette in use, or returns an error if the cassette has been recorded
+// with another seed. It does nothing if there is no cassette in use.
+func UseSeed(seed int64) error {
+	cassetteLock.Lock()
+	c := cassetteInUse
+	cassetteLock.Unlock()
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Seed != 0 && c.Seed != seed {
+		if c.replaying {
+			return errors.Errorf("cassette %v was recorded with seed=%v, but seed=%v is used to replay it", c.path, c.Seed, seed)
+		}
+		return errors.Errorf("cassette %v can only record a session with one seed, but both seed=%v and seed=%v are used", c.path, c.Seed, seed)
+	}
+	c.Seed = seed
+	return nil
+}
+
+// CassetteSeed returns the seed of the session saved in the cassette in use if there is one.
+func CassetteSeed() (int64, bool) {
+	cassetteLock.Lock()
+	c := cassetteInUse
+	cassetteLock.Unlock()
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Seed, c.Seed != 0
+}
+
+func cassetteKey(source, sql string) string {
+	return source + "\x00" + sql
+}
+
+func (c *Cassette) record(i Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Interactions = append(c.Interactions, i)
+}
+
+// next returns the next recorded interaction of the statement; the last one is reused if all have been replayed.
+func (c *Cassette) next(source, sql string) (Interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := cassetteKey(source, sql)
+	q := c.queues[k]
+	if len(q) == 0 {
+		return Interaction{}, false
+	}
+	if len(q) > 1 {
+		c.queues[k] = q[1:]
+	}
+	return q[0], true
+}
+
+// openDB opens a database through the cassette in use if there is one.
+func openDB(driverName, dsn, source string) (*sql.DB, error) {
+	cassetteLock.Lock()
+	c := cassetteInUse
+	cassetteLock.Unlock()
+	if c == nil {
+		return sql.Open(driverName, dsn)
+	}
+	if c.replaying {
+		return sql.OpenDB(fakeConnector{&replayBackend{c, source}}), nil
+	}
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(&recordingConnector{drv, dsn, source, c}), nil
+}
+
+var dsnPasswordPattern = regexp.MustCompile(`:[^:@/]*@`)
+
+// dsnSource identifies the database of the DSN in cassettes without its password.
+func dsnSource(dsn string) string {
+	return dsnPasswordPattern.ReplaceAllString(dsn, "@")
+}
+
+// instanceSource identifies the database of the instance in cassettes.
+func instanceSource(opt Option) string {
+	return fmt.Sprintf("%v@%v:%v", opt.Label, opt.Addr, opt.Port)
+}
+
+// replayBackend serves statements of a source with interactions in the cassette.
+type replayBackend struct {
+	c      *Cassette
+	source string
+}
+
+func (b *replayBackend) serve(sql string, exec bool) ([]string, [][]interface{}, error) {
+	i, ok := b.c.next(b.source, sql)
+	if !ok {
+		return nil, nil, errors.Errorf("no recorded result for %v in cassette %v", sql, b.c.path)
+	}
+	if i.Error != "" {
+		return nil, nil, errors.New(i.Error)
+	}
+	rows := make([][]interface{}, len(i.Rows))
+	for r := range i.Rows {
+		rows[r] = make([]interface{}, len(i.Rows[r]))
+		for k, v := range i.Rows[r] {
+			if v != nil {
+				rows[r][k] = *v
+			}
+		}
+	}
+	return i.Columns, rows, nil
+}
+
+// recordingConnector opens connections with the real driver and records all statements through them.
+type recordingConnector struct {
+	drv    driver.Driver
+	dsn    string
+	source string
+	c      *Cassette
+}
+
+func (rc *recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := rc.drv.Open(rc.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingConn{conn, rc}, nil
+}
+
+func (rc *recordingConnector) Driver() driver.Driver {
+	return rc.drv
+}
+
+type recordingConn struct {
+	driver.Conn
+	rc *recordingConnector
+}
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok || len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	result, err := execer.ExecContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	i := Interaction{Source: c.rc.source, SQL: query}
+	if err != nil {
+		i.Error = err.Error()
+	}
+	c.rc.c.record(i)
+	return result, err
+}
+
+func (c *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok || len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	i := Interaction{Source: c.rc.source, SQL: query}
+	if err != nil {
+		i.Error = err.Error()
+		c.rc.c.record(i)
+		return nil, err
+	}
+
+	// read all rows to record them, and then return them in text like the fake instance does
+	i.Columns = rows.Columns()
+	values := make([][]interface{}, 0, 8)
+	dest := make([]driver.Value, len(i.Columns))
+	for {
+		if err = rows.Next(dest); err != nil {
+			break
+		}
+		row := make([]*string, len(dest))
+		value := make([]interface{}, len(dest))
+		for k, v := range dest {
+			if v == nil {
+				continue
+			}
+			var s string
+			if b, ok := v.([]byte); ok {
+				s = string(b)
+			} else {
+				s = fmt.Sprint(v)
+			}
+			row[k], value[k] = &s, s
+		}
+		i.Rows = append(i.Rows, row)
+		values = append(values, value)
+	}
+	if closeErr := rows.Close(); err == io.EOF {
+		err = closeErr
+	}
+	if err != nil && err != io.EOF {
+		i.Columns, i.Rows, i.Error = nil, nil, err.Error()
+		c.rc.c.record(i)
+		return nil, err
+	}
+	c.rc.c.record(i)
+	return &fakeRows{columns: i.Columns, rows: values}, nil
+}
@@ -0,0 +1,145 @@
+package tidb
+
+import (
+	"bytes"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassette(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+
+	server := &fakeServer{}
+	server.addRule((&FakeRule{exact: "SELECT a, b FROM t"}).Return([]string{"a", "b"}, []interface{}{1, nil}, []interface{}{2, "x"}))
+	server.addRule((&FakeRule{exact: "SELECT COUNT(*) FROM t"}).Return([]string{"cnt"}, []interface{}{2}).Times(1))
+	server.addRule((&FakeRule{exact: "SELECT COUNT(*) FROM t"}).Return([]string{"cnt"}, []interface{}{3}))
+	sql.Register("cassette-test", fakeConnector{server})
+
+	readAll := func(db *sql.DB, query string) (results [][]sql.NullString, err error) {
+		rows, err := db.Query(query)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		cols, _ := rows.Columns()
+		for rows.Next() {
+			row := make([]sql.NullString, len(cols))
+			ptrs := make([]interface{}, len(cols))
+			for i := range row {
+				ptrs[i] = &row[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				return nil, err
+			}
+			results = append(results, row)
+		}
+		return results, rows.Err()
+	}
+	session := func() (results [][][]sql.NullString, errs []error) {
+		db, err := openDB("cassette-test", "root:secret@tcp(127.0.0.1:4000)/test", dsnSource("root:secret@tcp(127.0.0.1:4000)/test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		for _, q := range []string{"SELECT a, b FROM t", "SELECT COUNT(*) FROM t", "SELECT COUNT(*) FROM t", "SELECT * FROM unknown"} {
+			r, err := readAll(db, q)
+			results, errs = append(results, r), append(errs, err)
+		}
+		_, err = db.Exec("SET @@tidb_enable_x = 1")
+		errs = append(errs, err)
+		return
+	}
+
+	RecordTo(path)
+	recorded, recordedErrs := session()
+	if err := SaveCassette(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReplayFrom(path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { cassetteInUse = nil }()
+	replayed, replayedErrs := session()
+
+	if recorded[1][0][0].String != "2" || recorded[2][0][0].String != "3" || recorded[0][0][1].Valid {
+		t.Fatalf("unexpected recorded results %v", recorded)
+	}
+	for i := range recorded {
+		if (recordedErrs[i] == nil) != (replayedErrs[i] == nil) {
+			t.Fatalf("statement %v: recorded error %v, replayed error %v", i, recordedErrs[i], replayedErrs[i])
+		}
+		if len(recorded[i]) != len(replayed[i]) {
+			t.Fatalf("statement %v: recorded %v, replayed %v", i, recorded[i], replayed[i])
+		}
+		for r := range recorded[i] {
+			for c := range recorded[i][r] {
+				if recorded[i][r][c] != replayed[i][r][c] {
+					t.Fatalf("statement %v: recorded %v, replayed %v", i, recorded[i], replayed[i])
+				}
+			}
+		}
+	}
+	if recordedErrs[3] == nil || recordedErrs[4] != nil {
+		t.Fatalf("unexpected recorded errors %v", recordedErrs)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret")) {
+		t.Fatal("the password is recorded in the cassette")
+	}
+
+	db, err := openDB("cassette-test", "root@tcp(127.0.0.1:4000)/test", "another")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := readAll(db, "SELECT a, b FROM t"); err == nil {
+		t.Fatal("expect an error for statements not recorded")
+	}
+}
+
+func TestCassetteSeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+	defer func() { cassetteInUse = nil }()
+
+	RecordTo(path)
+	if err := UseSeed(42); err != nil {
+		t.Fatal(err)
+	}
+	if err := UseSeed(43); err == nil {
+		t.Fatal("expect an error for another seed in the same session")
+	}
+	if err := SaveCassette(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReplayFrom(path); err != nil {
+		t.Fatal(err)
+	}
+	if seed, ok := CassetteSeed(); !ok || seed != 42 {
+		t.Fatalf("expect the recorded seed 42, got %v", seed)
+	}
+	if err := UseSeed(42); err != nil {
+		t.Fatal(err)
+	}
+	if err := UseSeed(7); err == nil || !strings.Contains(err.Error(), "seed=42") {
+		t.Fatalf("expect an error naming the recorded seed, got %v", err)
+	}
+}
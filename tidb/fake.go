@@ -0,0 +1,283 @@
+package tidb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+)
+
+// FakeInstance is an in-memory Instance used to test testers without a live cluster.
+// Statements are matched against rules registered by OnQuery and OnExactQuery in order,
+// and the first matched rule decides the result:
+//
+//	ins := tidb.NewFakeInstance(tidb.Option{Label: "fake"})
+//	ins.OnQuery(`^SELECT COUNT\(\*\) FROM t`).Return([]string{"cnt"}, []interface{}{100})
+//	ins.OnExactQuery("EXPLAIN ANALYZE SELECT * FROM t").ReturnTable(explainAnalyzeTable)
+//	ins.OnQuery(`^SET GLOBAL`).ReturnError(errors.New("access denied"))
+//
+// Exec statements without any matched rule succeed, and queries without any matched rule fail.
+type FakeInstance struct {
+	*instance
+	server *fakeServer
+}
+
+// NewFakeInstance creates a FakeInstance without any rule, whose version is an unknown TiDB version.
+func NewFakeInstance(opt Option) *FakeInstance {
+	server := &fakeServer{}
+	return &FakeInstance{
+		instance: &instance{db: sql.OpenDB(fakeConnector{server}), opt: opt},
+		server:   server,
+	}
+}
+
+// SetVersion sets the version returned by Version(), which is parsed from the result of SELECT VERSION().
+func (f *FakeInstance) SetVersion(raw string) {
+	f.ver = ParseVersion(raw)
+}
+
+// OnQuery registers a rule for statements matching the regular expression.
+func (f *FakeInstance) OnQuery(pattern string) *FakeRule {
+	r := &FakeRule{pattern: regexp.MustCompile(pattern)}
+	f.server.addRule(r)
+	return r
+}
+
+// OnExactQuery registers a rule for statements equal to the SQL, ignoring leading and trailing spaces.
+func (f *FakeInstance) OnExactQuery(sql string) *FakeRule {
+	r := &FakeRule{exact: strings.TrimSpace(sql)}
+	f.server.addRule(r)
+	return r
+}
+
+// Queries returns all statements received by the instance in order.
+func (f *FakeInstance) Queries() []string {
+	return f.server.history()
+}
+
+// FakeRule decides the result of statements matched by it.
+type FakeRule struct {
+	pattern *regexp.Regexp
+	exact   string
+
+	columns []string
+	rows    [][]interface{}
+	err     error
+	limited bool
+	times   int // the number of remaining times this rule can be used if it's limited
+}
+
+// Return makes matched statements return these rows.
+// Values are returned as text like the MySQL protocol does, so they can be scanned into strings or numbers.
+func (r *FakeRule) Return(columns []string, rows ...[]interface{}) *FakeRule {
+	r.columns, r.rows, r.err = columns, rows, nil
+	return r
+}
+
+// ReturnTable makes matched statements return rows in the ASCII table printed by the MySQL client:
+//
+//	+-------------+----------+
+//	| id          | estRows  |
+//	+-------------+----------+
+//	| TableReader | 10000.00 |
+//	+-------------+----------+
+func (r *FakeRule) ReturnTable(table string) *FakeRule {
+	columns, rows, err := ParseASCIITable(table)
+	if err != nil {
+		panic(err)
+	}
+	return r.Return(columns, rows...)
+}
+
+// ReturnError makes matched statements fail with the error.
+func (r *FakeRule) ReturnError(err error) *FakeRule {
+	r.columns, r.rows, r.err = nil, nil, err
+	return r
+}
+
+// Times limits the number of times the rule can be used, after which it's skipped when matching.
+func (r *FakeRule) Times(n int) *FakeRule {
+	r.limited, r.times = true, n
+	return r
+}
+
+func (r *FakeRule) match(sql string) bool {
+	if r.pattern != nil {
+		return r.pattern.MatchString(sql)
+	}
+	return r.exact == strings.TrimSpace(sql)
+}
+
+// ParseASCIITable parses the ASCII table printed by the MySQL client into column names and rows.
+// Border lines are ignored, the first row is the header and NULL cells are returned as nil.
+func ParseASCIITable(table string) (columns []string, rows [][]interface{}, err error) {
+	for _, line := range strings.Split(table, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") {
+			continue
+		}
+		cells := strings.Split(strings.Trim(line, "|"), "|")
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+		if columns == nil {
+			columns = cells
+			continue
+		}
+		if len(cells) != len(columns) {
+			return nil, nil, errors.Errorf("expect %v cells but got %v in line %v", len(columns), len(cells), line)
+		}
+		row := make([]interface{}, len(cells))
+		for i, cell := range cells {
+			if cell != "NULL" {
+				row[i] = cell
+			}
+		}
+		rows = append(rows, row)
+	}
+	if columns == nil {
+		return nil, nil, errors.Errorf("no header in table %v", table)
+	}
+	return columns, rows, nil
+}
+
+// fakeBackend serves statements received by fake connections.
+type fakeBackend interface {
+	serve(sql string, exec bool) (columns []string, rows [][]interface{}, err error)
+}
+
+// fakeServer serves statements of FakeInstance by its rules.
+type fakeServer struct {
+	mu      sync.Mutex
+	rules   []*FakeRule
+	queries []string
+}
+
+func (s *fakeServer) addRule(r *FakeRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, r)
+}
+
+func (s *fakeServer) history() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+func (s *fakeServer) serve(sql string, exec bool) ([]string, [][]interface{}, error) {
+	r := s.match(sql)
+	if r == nil {
+		if exec {
+			return nil, nil, nil
+		}
+		return nil, nil, errors.Errorf("no fake rule matches query %v", sql)
+	}
+	return r.columns, r.rows, r.err
+}
+
+// match returns the rule matching the statement, or nil if there is no such rule.
+func (s *fakeServer) match(sql string) *FakeRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, sql)
+	for _, r := range s.rules {
+		if (r.limited && r.times <= 0) || !r.match(sql) {
+			continue
+		}
+		if r.limited {
+			r.times--
+		}
+		return r
+	}
+	return nil
+}
+
+// fakeConnector implements driver.Connector on a fakeBackend.
+type fakeConnector struct {
+	backend fakeBackend
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{backend: c.backend}, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return c
+}
+
+func (c fakeConnector) Open(string) (driver.Conn, error) {
+	return &fakeConn{backend: c.backend}, nil
+}
+
+type fakeConn struct {
+	backend fakeBackend
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	if _, _, err := c.backend.serve(query, true); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	columns, rows, err := c.backend.serve(query, false)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are unsupported by the fake instance")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are unsupported by the fake instance")
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]interface{}
+	pos     int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	row := r.rows[r.pos]
+	r.pos++
+	for i := range dest {
+		dest[i] = nil
+		if i < len(row) && row[i] != nil {
+			dest[i] = []byte(fmt.Sprint(row[i]))
+		}
+	}
+	return nil
+}
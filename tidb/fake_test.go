@@ -0,0 +1,92 @@
+package tidb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFakeInstance(t *testing.T) {
+	ins := NewFakeInstance(Option{Label: "fake"})
+	ins.SetVersion("5.7.25-TiDB-v6.1.0")
+	ins.OnQuery(`^SELECT COUNT\(\*\) FROM t`).Return([]string{"cnt"}, []interface{}{100}).Times(1)
+	ins.OnQuery(`^SELECT COUNT\(\*\) FROM t`).Return([]string{"cnt"}, []interface{}{200})
+	ins.OnExactQuery("SELECT a, b FROM t").ReturnTable(`
++------+------+
+| a    | b    |
++------+------+
+| 1    | x    |
+| NULL | y    |
++------+------+`)
+	ins.OnQuery(`^SET GLOBAL`).ReturnError(errors.New("access denied"))
+
+	if !ins.Version().AtLeast(6, 1, 0) {
+		t.Fatalf("unexpected version %v", ins.Version())
+	}
+
+	for _, expected := range []int{100, 200, 200} {
+		var cnt int
+		rows := ins.MustQuery("SELECT COUNT(*) FROM t WHERE a > 1")
+		if !rows.Next() {
+			t.Fatal("no rows")
+		}
+		if err := rows.Scan(&cnt); err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+		if cnt != expected {
+			t.Fatalf("expect %v, got %v", expected, cnt)
+		}
+	}
+
+	rows, err := ins.Query("  SELECT a, b FROM t ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var as []*int
+	var bs []string
+	for rows.Next() {
+		var a *int
+		var b string
+		if err := rows.Scan(&a, &b); err != nil {
+			t.Fatal(err)
+		}
+		as, bs = append(as, a), append(bs, b)
+	}
+	rows.Close()
+	if len(as) != 2 || as[0] == nil || *as[0] != 1 || as[1] != nil || bs[0] != "x" || bs[1] != "y" {
+		t.Fatalf("unexpected rows %v %v", as, bs)
+	}
+
+	if err := ins.Exec("SET GLOBAL tidb_enable_x = 1"); err == nil {
+		t.Fatal("expect an error")
+	}
+	if err := ins.ExecInNewSession("use test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ins.Query("SELECT * FROM unknown"); err == nil {
+		t.Fatal("expect an error for queries without rules")
+	}
+	if n := len(ins.Queries()); n != 7 {
+		t.Fatalf("expect 7 queries, got %v: %v", n, ins.Queries())
+	}
+}
+
+func TestParseASCIITable(t *testing.T) {
+	columns, rows, err := ParseASCIITable(`
++-------------------------+----------+
+| id                      | estRows  |
++-------------------------+----------+
+| IndexReader_6           | 10.00    |
+| └─IndexRangeScan_5      | 10.00    |
++-------------------------+----------+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(columns) != 2 || columns[1] != "estRows" || len(rows) != 2 || rows[1][0] != "└─IndexRangeScan_5" {
+		t.Fatalf("unexpected results %v %v", columns, rows)
+	}
+
+	if _, _, err := ParseASCIITable("| a | b |\n| 1 |"); err == nil {
+		t.Fatal("expect an error for mismatched cells")
+	}
+}
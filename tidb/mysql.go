@@ -0,0 +1,40 @@
+package tidb
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// connectToMySQL connects to a MySQL 8 server, which can be used as a comparison estimator.
+// It shares the implementation with TiDB instances since both speak the MySQL protocol,
+// and only the way to extract estimations, which depends on Option.EngineName(), is different.
+func connectToMySQL(opt Option) (Instance, error) {
+	ins, err := openInstance(opt)
+	if err != nil {
+		return nil, err
+	}
+	if err := ins.initVersion(); err != nil {
+		ins.Close()
+		return nil, err
+	}
+	if !ins.ver.NotTiDB {
+		ins.Close()
+		return nil, errors.Errorf("instance %v is TiDB %v, please use engine=%v", opt.Label, ins.ver, EngineTiDB)
+	}
+	if major := mysqlMajorVersion(ins.ver.Raw); major < 8 || strings.Contains(ins.ver.Raw, "MariaDB") {
+		ins.Close()
+		return nil, errors.Errorf("instance %v runs %v, but MySQL 8.0 or later is required", opt.Label, ins.ver.Raw)
+	}
+	return ins, nil
+}
+
+// mysqlMajorVersion returns the major version of a MySQL version like 8.0.32-0ubuntu0.22.04.2.
+func mysqlMajorVersion(raw string) int {
+	major, err := strconv.Atoi(strings.SplitN(raw, ".", 2)[0])
+	if err != nil {
+		return 0
+	}
+	return major
+}
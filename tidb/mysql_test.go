@@ -0,0 +1,26 @@
+package tidb
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+// TestConnectToMySQL runs against a local MySQL server specified by MYSQL_TEST_PORT, e.g.
+//
+//	docker run -e MYSQL_ALLOW_EMPTY_PASSWORD=yes -p 3306:3306 mysql:8
+//	MYSQL_TEST_PORT=3306 go test ./tidb
+func TestConnectToMySQL(t *testing.T) {
+	port, err := strconv.Atoi(os.Getenv("MYSQL_TEST_PORT"))
+	if err != nil {
+		t.Skip("MYSQL_TEST_PORT is not set")
+	}
+	ins, err := ConnectTo(Option{Addr: "127.0.0.1", Port: port, User: "root", Label: "mysql", Engine: EngineMySQL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ins.Close()
+	if !ins.Version().NotTiDB || mysqlMajorVersion(ins.Version().Raw) < 8 {
+		t.Fatalf("unexpected version %v", ins.Version().Raw)
+	}
+}
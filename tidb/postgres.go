@@ -0,0 +1,36 @@
+package tidb
+
+import (
+	"fmt"
+
+	_ "github.com/lib/pq"
+	"github.com/pingcap/errors"
+)
+
+// connectToPostgres connects to a PostgreSQL server, which can be used as a comparison estimator.
+// Option.Database specifies the database to connect, and datasets' DBs are used as schemas in it.
+func connectToPostgres(opt Option) (Instance, error) {
+	database := opt.Database
+	if database == "" {
+		database = "postgres"
+	}
+	dsn := fmt.Sprintf("host=%v port=%v user=%v dbname=%v sslmode=disable", opt.Addr, opt.Port, opt.User, database)
+	if opt.Password != "" {
+		dsn += fmt.Sprintf(" password=%v", opt.Password)
+	}
+	db, err := openDB("postgres", dsn, instanceSource(opt))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, errors.Trace(err)
+	}
+	db.SetMaxOpenConns(64) // max_connections is 100 by default
+	ins := &instance{db: db, opt: opt}
+	if err := ins.initVersion(); err != nil {
+		ins.Close()
+		return nil, err
+	}
+	return ins, nil
+}
@@ -40,7 +40,7 @@ type QueryResult struct {
 }
 
 func StartQueryRunner(dsn string, inChan chan *QueryTask, concurrency, nTaskSender, dsnID uint, initSQLs ...string) error {
-	db, err := sql.Open("mysql", dsn)
+	db, err := openDB("mysql", dsn, dsnSource(dsn))
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -17,6 +17,22 @@ type Option struct {
 	User     string `toml:"user"`
 	Password string `toml:"password"`
 	Label    string `toml:"label"`
+	Engine   string `toml:"engine"`   // tidb (default), mysql or postgres
+	Database string `toml:"database"` // the database to connect, only used by postgres
+}
+
+const (
+	EngineTiDB     = "tidb"
+	EngineMySQL    = "mysql"
+	EnginePostgres = "postgres"
+)
+
+// EngineName returns the lower-case engine name of the instance, which is EngineTiDB by default.
+func (opt Option) EngineName() string {
+	if opt.Engine == "" {
+		return EngineTiDB
+	}
+	return strings.ToLower(opt.Engine)
 }
 
 type Instance interface {
@@ -25,7 +41,7 @@ type Instance interface {
 	ExecInNewSession(sql string) error
 	MustQuery(query string) *sql.Rows
 	Query(query string) (*sql.Rows, error)
-	Version() string
+	Version() Version
 	Opt() Option
 	Close() error
 }
@@ -33,7 +49,7 @@ type Instance interface {
 type instance struct {
 	db  *sql.DB
 	opt Option
-	ver string
+	ver Version
 }
 
 func (ins *instance) ExecInNewSession(sql string) error {
@@ -90,7 +106,7 @@ func (ins *instance) Query(query string) (*sql.Rows, error) {
 	return rows, errors.Trace(err)
 }
 
-func (ins *instance) Version() string {
+func (ins *instance) Version() Version {
 	return ins.ver
 }
 
@@ -103,18 +119,11 @@ func (ins *instance) Close() error {
 }
 
 func (ins *instance) initVersion() error {
-	rows, err := ins.Query(`SELECT VERSION()`)
+	ver, err := DetectVersion(ins.db)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
-	var version string
-	rows.Next()
-	if err := rows.Scan(&version); err != nil {
-		return err
-	}
-	tmp := strings.Split(version, "-")
-	ins.ver = tmp[2]
+	ins.ver = ver
 	return nil
 }
 
@@ -139,18 +148,52 @@ func ConnectToInstances(opts []Option) (xs []Instance, err error) {
 }
 
 func ConnectTo(opt Option) (Instance, error) {
+	switch opt.EngineName() {
+	case EngineTiDB:
+		return connectToTiDB(opt)
+	case EngineMySQL:
+		return connectToMySQL(opt)
+	case EnginePostgres:
+		return connectToPostgres(opt)
+	}
+	return nil, errors.Errorf("unknown engine=%v", opt.Engine)
+}
+
+func connectToTiDB(opt Option) (Instance, error) {
+	ins, err := openInstance(opt)
+	if err != nil {
+		return nil, err
+	}
+	return ins, ins.initVersion()
+}
+
+// ConnectToDSN connects to the TiDB server specified by the DSN, like "root@tcp(127.0.0.1:4000)/imdb".
+func ConnectToDSN(dsn, label string) (Instance, error) {
+	db, err := openDB("mysql", dsn, dsnSource(dsn))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	db.SetMaxOpenConns(256)
+	ins := &instance{db: db, opt: Option{Label: label}}
+	return ins, ins.initVersion()
+}
+
+// openInstance opens a connection pool to the MySQL-protocol server specified by the option.
+func openInstance(opt Option) (*instance, error) {
 	dns := fmt.Sprintf("%s:%s@tcp(%s:%v)/%v", opt.User, opt.Password, opt.Addr, opt.Port, "mysql")
 	if opt.Password == "" {
 		dns = fmt.Sprintf("%s@tcp(%s:%v)/%v", opt.User, opt.Addr, opt.Port, "mysql")
 	}
-	db, err := sql.Open("mysql", dns)
+	db, err := openDB("mysql", dns, instanceSource(opt))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 	if err := db.Ping(); err != nil {
 		return nil, errors.Trace(err)
 	}
-	ins := &instance{db: db, opt: opt}
 	db.SetMaxOpenConns(256)
-	return ins, ins.initVersion()
+	return &instance{db: db, opt: opt}, nil
 }
@@ -0,0 +1,245 @@
+package tidb
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// Version is a parsed version of the server.
+//
+//	5.7.25-TiDB-v5.4.0 => {Major: 5, Minor: 4, Patch: 0}
+//	5.7.25-TiDB-v6.2.0-alpha-93-g0f8e5c4bd => {Major: 6, Minor: 2, Patch: 0, Prerelease: "alpha", GitHash: "0f8e5c4bd"}
+//	8.0.32 (MySQL) => {NotTiDB: true}
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string // alpha, rc.1, ...
+	GitHash    string
+	NotTiDB    bool   // the server is not TiDB, e.g. MySQL
+	Raw        string // the raw result of SELECT VERSION()
+}
+
+var (
+	tidbVerPattern    = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-(.+))?$`)
+	gitSuffixPattern  = regexp.MustCompile(`(?:^|-)(?:\d+-)?g([0-9a-f]{7,40})$`)
+	releaseVerPattern = regexp.MustCompile(`(?m)^Release Version:\s*(\S+)\s*$`)
+	gitHashPattern    = regexp.MustCompile(`(?m)^Git Commit Hash:\s*(\S+)\s*$`)
+)
+
+// ParseVersion parses the result of SELECT VERSION().
+// Unknown TiDB versions (e.g. 5.7.25-TiDB-None from custom builds) are returned with all numbers set to 0.
+func ParseVersion(raw string) Version {
+	v := Version{Raw: raw}
+	idx := strings.Index(raw, "-TiDB-")
+	if idx < 0 {
+		if !strings.HasPrefix(raw, "v") { // "v5.4.0" is also accepted as a TiDB version
+			v.NotTiDB = true
+			return v
+		}
+		idx = -len("-TiDB-")
+	}
+	v.parseTiDBVersion(raw[idx+len("-TiDB-"):])
+	return v
+}
+
+// parseTiDBVersion parses versions like v6.2.0-alpha-93-g0f8e5c4bd.
+func (v *Version) parseTiDBVersion(ver string) bool {
+	m := tidbVerPattern.FindStringSubmatch(strings.TrimSpace(ver))
+	if m == nil {
+		return false
+	}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	suffix := m[4]
+	if g := gitSuffixPattern.FindStringSubmatchIndex(suffix); g != nil {
+		v.GitHash = suffix[g[2]:g[3]]
+		suffix = suffix[:g[0]]
+	}
+	v.Prerelease = suffix
+	return true
+}
+
+// parseTiDBVersionInfo completes the version with the result of tidb_version(), which looks like:
+//
+//	Release Version: v6.1.0
+//	Edition: Community
+//	Git Commit Hash: 1a89decdb192cbdce6a7b0020d71128bc964d30f
+//	...
+func (v *Version) parseTiDBVersionInfo(info string) {
+	if m := releaseVerPattern.FindStringSubmatch(info); m != nil && !v.Known() {
+		v.parseTiDBVersion(m[1])
+	}
+	if m := gitHashPattern.FindStringSubmatch(info); m != nil && m[1] != "None" {
+		v.GitHash = m[1]
+	}
+}
+
+// Known returns whether the version numbers are known.
+func (v Version) Known() bool {
+	return !v.NotTiDB && (v.Major != 0 || v.Minor != 0 || v.Patch != 0)
+}
+
+// Comparable converts this version to a comparable number.
+//
+//	vX.Y.Z => x*10000 + Y*100 + Z
+//	v3.0.15 => 300015 < 400002 <= v4.0.2
+func (v Version) Comparable() int {
+	return v.Major*10000 + v.Minor*100 + v.Patch
+}
+
+// AtLeast returns whether this version is equal to or newer than vX.Y.Z.
+// Prereleases (e.g. nightly builds of v6.2.0-alpha) are treated as the release they precede.
+func (v Version) AtLeast(major, minor, patch int) bool {
+	return v.Comparable() >= major*10000+minor*100+patch
+}
+
+func (v Version) String() string {
+	if v.NotTiDB || !v.Known() {
+		return v.Raw
+	}
+	s := fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.GitHash != "" {
+		s += "-g" + v.GitHash
+	}
+	return s
+}
+
+// Feature is a feature used by the tester which is only available in some versions of TiDB.
+type Feature int
+
+const (
+	// FeatureTraceEstimation is TRACE PLAN TARGET='estimation'.
+	FeatureTraceEstimation Feature = iota
+	// FeatureCostModelV2 is tidb_cost_model_version=2.
+	FeatureCostModelV2
+	// FeatureTrueCardCost is EXPLAIN ANALYZE FORMAT='true_card_cost'.
+	FeatureTrueCardCost
+	// FeatureCostTrace is EXPLAIN FORMAT='cost_trace' exposing cost formulas of cost model v2.
+	FeatureCostTrace
+	// FeatureVerboseExplain is EXPLAIN ANALYZE FORMAT='verbose' showing costs of operators.
+	FeatureVerboseExplain
+)
+
+var featureMinVersions = map[Feature]struct {
+	name                string
+	major, minor, patch int
+	probe               string // the statement which succeeds with at least one row if the server supports the feature
+}{
+	FeatureTraceEstimation: {"TRACE PLAN TARGET='estimation'", 5, 4, 0, "trace plan target='estimation' select 1"},
+	FeatureCostModelV2:     {"cost model v2", 6, 2, 0, "show variables like 'tidb_cost_model_version'"},
+	FeatureTrueCardCost:    {"EXPLAIN ANALYZE FORMAT='true_card_cost'", 6, 2, 0, "explain analyze format='true_card_cost' select 1"},
+	FeatureCostTrace:       {"EXPLAIN FORMAT='cost_trace'", 6, 2, 0, "explain format='cost_trace' select 1"},
+	FeatureVerboseExplain:  {"EXPLAIN ANALYZE FORMAT='verbose'", 5, 1, 0, "explain analyze format='verbose' select 1"},
+}
+
+func (f Feature) String() string {
+	return featureMinVersions[f].name
+}
+
+// Supports returns whether this version supports the feature.
+// Unknown TiDB versions are not assumed to support any feature, and the feature should be probed on the server.
+func (v Version) Supports(f Feature) bool {
+	if !v.Known() {
+		return false
+	}
+	m := featureMinVersions[f]
+	return v.AtLeast(m.major, m.minor, m.patch)
+}
+
+// CheckFeature returns an error if this version doesn't support the feature.
+func (v Version) CheckFeature(f Feature) error {
+	if v.Supports(f) {
+		return nil
+	}
+	m := featureMinVersions[f]
+	if v.NotTiDB {
+		return errors.Errorf("%v is only supported by TiDB, but the server version is %v", m.name, v.Raw)
+	}
+	if !v.Known() {
+		return errors.Errorf("%v requires TiDB v%d.%d.%d or later, but the server version %v is unknown", m.name, m.major, m.minor, m.patch, v)
+	}
+	return errors.Errorf("%v requires TiDB v%d.%d.%d or later, but the server version is %v", m.name, m.major, m.minor, m.patch, v)
+}
+
+// CheckFeature returns an error if the instance doesn't support the feature.
+// If the version of the instance is unknown, the feature is probed on the instance instead.
+func CheckFeature(ins Instance, f Feature) error {
+	err := ins.Version().CheckFeature(f)
+	if err != nil && !ins.Version().NotTiDB && !ins.Version().Known() {
+		err = probeFeature(ins.Query, f)
+	}
+	if err != nil {
+		return errors.Errorf("instance %v: %v", ins.Opt().Label, err)
+	}
+	return nil
+}
+
+// CheckFeatureByDSN returns an error if the server specified by the DSN doesn't support the feature.
+// If the version of the server is unknown, the feature is probed on the server instead.
+func CheckFeatureByDSN(dsn string, f Feature) error {
+	db, err := openDB("mysql", dsn, dsnSource(dsn))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer db.Close()
+	ver, err := DetectVersion(db)
+	if err != nil {
+		return err
+	}
+	if err := ver.CheckFeature(f); err == nil || ver.NotTiDB || ver.Known() {
+		return err
+	}
+	return probeFeature(func(q string) (*sql.Rows, error) { return db.Query(q) }, f)
+}
+
+// probeFeature runs the probe statement of the feature, and returns an error if it fails or returns no row.
+func probeFeature(query func(string) (*sql.Rows, error), f Feature) error {
+	m := featureMinVersions[f]
+	rows, err := query(m.probe)
+	if err != nil {
+		return errors.Annotatef(err, "the server of the unknown version doesn't support %v", m.name)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return errors.Errorf("the server of the unknown version doesn't support %v: no result of %v", m.name, m.probe)
+	}
+	return nil
+}
+
+// DetectVersion detects the version of the server by SELECT VERSION() and tidb_version().
+func DetectVersion(db *sql.DB) (Version, error) {
+	var raw string
+	if err := db.QueryRow(`SELECT VERSION()`).Scan(&raw); err != nil {
+		return Version{}, errors.Trace(err)
+	}
+	v := ParseVersion(raw)
+	if v.NotTiDB {
+		return v, nil
+	}
+	var info string
+	if err := db.QueryRow(`SELECT tidb_version()`).Scan(&info); err != nil {
+		return Version{}, errors.Trace(err)
+	}
+	v.parseTiDBVersionInfo(info)
+	return v, nil
+}
+
+// DetectVersionByDSN detects the version of the server specified by the DSN.
+func DetectVersionByDSN(dsn string) (Version, error) {
+	db, err := openDB("mysql", dsn, dsnSource(dsn))
+	if err != nil {
+		return Version{}, errors.Trace(err)
+	}
+	defer db.Close()
+	return DetectVersion(db)
+}
@@ -0,0 +1,77 @@
+package tidb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	cases := []struct {
+		raw    string
+		expect Version
+	}{
+		{"5.7.25-TiDB-v5.4.0", Version{Major: 5, Minor: 4, Patch: 0}},
+		{"8.0.11-TiDB-v7.5.1", Version{Major: 7, Minor: 5, Patch: 1}},
+		{"5.7.25-TiDB-v6.2.0-alpha", Version{Major: 6, Minor: 2, Patch: 0, Prerelease: "alpha"}},
+		{"5.7.25-TiDB-v6.2.0-alpha-93-g0f8e5c4bd", Version{Major: 6, Minor: 2, Patch: 0, Prerelease: "alpha", GitHash: "0f8e5c4bd"}},
+		{"5.7.10-TiDB-v2.0.0-rc.4-31-g0c2f1b7", Version{Major: 2, Minor: 0, Patch: 0, Prerelease: "rc.4", GitHash: "0c2f1b7"}},
+		{"5.7.25-TiDB-v4.0.2-21-gabcdef1", Version{Major: 4, Minor: 0, Patch: 2, GitHash: "abcdef1"}},
+		{"v3.0.15", Version{Major: 3, Minor: 0, Patch: 15}},
+		{"5.7.25-TiDB-None", Version{}},
+		{"8.0.32", Version{NotTiDB: true}},
+		{"10.6.12-MariaDB", Version{NotTiDB: true}},
+	}
+	for _, c := range cases {
+		v := ParseVersion(c.raw)
+		c.expect.Raw = c.raw
+		if v != c.expect {
+			t.Fatalf("parse %v: expected %+v, got %+v", c.raw, c.expect, v)
+		}
+	}
+}
+
+func TestVersionFeatures(t *testing.T) {
+	v := ParseVersion("5.7.25-TiDB-v5.3.0")
+	if v.Supports(FeatureTraceEstimation) || v.CheckFeature(FeatureCostModelV2) == nil {
+		t.Fatal("v5.3.0 doesn't support trace estimation or cost model v2")
+	}
+	v = ParseVersion("5.7.25-TiDB-v6.2.0-alpha-93-g0f8e5c4bd")
+	if !v.Supports(FeatureTraceEstimation) || !v.Supports(FeatureCostModelV2) {
+		t.Fatal("nightly builds of v6.2.0 should support trace estimation and cost model v2")
+	}
+	v = ParseVersion("5.7.25-TiDB-None")
+	v.parseTiDBVersionInfo("Release Version: v6.1.0\nEdition: Community\nGit Commit Hash: 1a89decdb192cbdce6a7b0020d71128bc964d30f\n")
+	if v.String() != "v6.1.0-g1a89decdb192cbdce6a7b0020d71128bc964d30f" || v.Supports(FeatureCostModelV2) {
+		t.Fatalf("unexpected version %v", v)
+	}
+	if ParseVersion("8.0.32").Supports(FeatureTraceEstimation) {
+		t.Fatal("MySQL doesn't support trace estimation")
+	}
+	v = ParseVersion("5.7.25-TiDB-None")
+	if err := v.CheckFeature(FeatureTraceEstimation); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("unknown versions shouldn't support any feature, got %v", err)
+	}
+	if ToComparableVersion("v3.0.15") >= ToComparableVersion("5.7.25-TiDB-v4.0.2") {
+		t.Fatal("v3.0.15 should be older than v4.0.2")
+	}
+}
+
+func TestProbeFeature(t *testing.T) {
+	ins := NewFakeInstance(Option{Label: "fake"}) // the version is unknown
+	ins.OnExactQuery("show variables like 'tidb_cost_model_version'").Return([]string{"Variable_name", "Value"}, []interface{}{"tidb_cost_model_version", "1"})
+	if err := CheckFeature(ins, FeatureCostModelV2); err != nil {
+		t.Fatalf("cost model v2 should be probed, got %v", err)
+	}
+	if err := CheckFeature(ins, FeatureCostTrace); err == nil {
+		t.Fatal("cost trace should fail the probe")
+	}
+	ins = NewFakeInstance(Option{Label: "fake"})
+	ins.OnQuery("^show variables").Return([]string{"Variable_name", "Value"})
+	if err := CheckFeature(ins, FeatureCostModelV2); err == nil {
+		t.Fatal("cost model v2 should fail the probe without the variable")
+	}
+	ins.SetVersion("5.7.25-TiDB-v6.2.0")
+	if err := CheckFeature(ins, FeatureCostModelV2); err != nil || len(ins.Queries()) != 1 {
+		t.Fatalf("known versions shouldn't be probed, got %v, %v", err, ins.Queries())
+	}
+}